@@ -16,6 +16,7 @@ import (
 	"github.com/google/jsonschema-go/jsonschema"
 
 	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
 )
 
 const (
@@ -24,13 +25,14 @@ const (
 )
 
 type messageState struct {
+	ctx      context.Context
 	messages []anthropicSDK.MessageParam
 	system   string
 	output   chan<- llm.TextStreamEvent
 	depth    int
 	config   llm.LanguageModelConfig
 	tools    []llm.Tool
-	resolver func(name string, argsGetter llm.ToolArgumentGetter, context *llm.Context) (string, error)
+	resolver func(ctx context.Context, name string, argsGetter llm.ToolArgumentGetter, llmContext *llm.Context) (string, error)
 	context  *llm.Context
 }
 
@@ -93,6 +95,9 @@ func conversationToMessages(posts []llm.Post) (string, []anthropicSDK.MessagePar
 	for _, post := range posts {
 		switch post.Role {
 		case llm.PostRoleSystem:
+			if systemMessage != "" {
+				systemMessage += "\n\n"
+			}
 			systemMessage += post.Message
 			continue
 		case llm.PostRoleBot:
@@ -236,7 +241,10 @@ func (a *Anthropic) streamChatWithTools(state messageState) {
 		}
 	}
 
-	stream := a.client.Messages.NewStreaming(context.Background(), params)
+	addCacheBreakpoints(&params)
+
+	// Retries are handled by llm.RetryWrapper so they can honour the overall streaming budget
+	stream := a.client.Messages.NewStreaming(state.ctx, params, option.WithMaxRetries(0))
 
 	message := anthropicSDK.Message{}
 	var thinkingBuffer strings.Builder
@@ -303,6 +311,9 @@ func (a *Anthropic) streamChatWithTools(state messageState) {
 	}
 
 	if err := stream.Err(); err != nil {
+		if ctxErr := state.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
 		state.output <- llm.TextStreamEvent{
 			Type:  llm.EventTypeError,
 			Value: fmt.Errorf("error from anthropic stream: %w", err),
@@ -351,10 +362,12 @@ func (a *Anthropic) streamChatWithTools(state messageState) {
 		}
 	}
 
-	// Extract and send token usage data
+	// Extract and send token usage data. Anthropic reports cached tokens separately from input tokens.
 	usage := llm.TokenUsage{
-		InputTokens:  message.Usage.InputTokens,
-		OutputTokens: message.Usage.OutputTokens,
+		InputTokens:              message.Usage.InputTokens + message.Usage.CacheReadInputTokens + message.Usage.CacheCreationInputTokens,
+		OutputTokens:             message.Usage.OutputTokens,
+		CacheReadInputTokens:     message.Usage.CacheReadInputTokens,
+		CacheCreationInputTokens: message.Usage.CacheCreationInputTokens,
 	}
 	state.output <- llm.TextStreamEvent{
 		Type:  llm.EventTypeUsage,
@@ -429,7 +442,7 @@ func (a *Anthropic) extractAnnotations(message anthropicSDK.Message) []llm.Annot
 	return annotations
 }
 
-func (a *Anthropic) ChatCompletion(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+func (a *Anthropic) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
 	eventStream := make(chan llm.TextStreamEvent)
 
 	cfg := a.createConfig(opts)
@@ -437,6 +450,7 @@ func (a *Anthropic) ChatCompletion(request llm.CompletionRequest, opts ...llm.La
 	system, messages := conversationToMessages(request.Posts)
 
 	initialState := messageState{
+		ctx:      ctx,
 		messages: messages,
 		system:   system,
 		output:   eventStream,
@@ -458,9 +472,9 @@ func (a *Anthropic) ChatCompletion(request llm.CompletionRequest, opts ...llm.La
 	return &llm.TextStreamResult{Stream: eventStream}, nil
 }
 
-func (a *Anthropic) ChatCompletionNoStream(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+func (a *Anthropic) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
 	// This could perform better if we didn't use the streaming API here, but the complexity is not worth it.
-	result, err := a.ChatCompletion(request, opts...)
+	result, err := a.ChatCompletion(ctx, request, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -468,7 +482,36 @@ func (a *Anthropic) ChatCompletionNoStream(request llm.CompletionRequest, opts .
 }
 
 func (a *Anthropic) CountTokens(text string) int {
-	return 0
+	// Anthropic doesn't publish its tokenizer, cl100k_base is a close approximation
+	return tokenizer.ForModel(a.defaultModel).CountTokens(text)
+}
+
+// addCacheBreakpoints marks the tool list, the system prompt and the conversation up to the newest message
+// as cacheable. The prompt is cached in that order, so a follow up request in the same thread only pays
+// full price for the messages added since the previous one. Prefixes that are too short to be cached are
+// ignored by the API.
+func addCacheBreakpoints(params *anthropicSDK.MessageNewParams) {
+	if len(params.Tools) > 0 {
+		if cacheControl := params.Tools[len(params.Tools)-1].GetCacheControl(); cacheControl != nil {
+			*cacheControl = anthropicSDK.NewCacheControlEphemeralParam()
+		}
+	}
+
+	if len(params.System) > 0 {
+		params.System[len(params.System)-1].CacheControl = anthropicSDK.NewCacheControlEphemeralParam()
+	}
+
+	if len(params.Messages) < 2 {
+		return
+	}
+	// Thinking blocks can't be marked, so use the last block of the previous message that can
+	blocks := params.Messages[len(params.Messages)-2].Content
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if cacheControl := blocks[i].GetCacheControl(); cacheControl != nil {
+			*cacheControl = anthropicSDK.NewCacheControlEphemeralParam()
+			return
+		}
+	}
 }
 
 // convertTools converts from llm.Tool to anthropicSDK.ToolUnionParam format
@@ -682,3 +682,66 @@ func TestThinkingBudgetConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestAddCacheBreakpoints(t *testing.T) {
+	t.Run("marks tools, system prompt and the conversation before the newest message", func(t *testing.T) {
+		system, messages := conversationToMessages([]llm.Post{
+			{Role: llm.PostRoleSystem, Message: "You are a helpful assistant"},
+			{Role: llm.PostRoleUser, Message: "First question"},
+			{Role: llm.PostRoleBot, Message: "First answer", Reasoning: "Thinking", ReasoningSignature: "sig", ToolUse: []llm.ToolCall{{
+				ID:     "tool1",
+				Name:   "search",
+				Result: "results",
+				Status: llm.ToolCallStatusSuccess,
+			}}},
+			{Role: llm.PostRoleUser, Message: "Second question"},
+		})
+		params := anthropicSDK.MessageNewParams{
+			System:   []anthropicSDK.TextBlockParam{{Text: system}},
+			Messages: messages,
+			Tools: convertTools([]llm.Tool{
+				{Name: "search", Description: "Search"},
+				{Name: "lookup", Description: "Lookup"},
+			}),
+		}
+
+		addCacheBreakpoints(&params)
+
+		assert.Empty(t, params.Tools[0].GetCacheControl().Type)
+		assert.Equal(t, "ephemeral", string(params.Tools[1].GetCacheControl().Type))
+		assert.Equal(t, "ephemeral", string(params.System[0].CacheControl.Type))
+
+		// The tool result message comes right before the newest user message
+		require.Len(t, params.Messages, 4)
+		prefix := params.Messages[2]
+		require.NotNil(t, prefix.Content[0].OfToolResult)
+		assert.Equal(t, "ephemeral", string(prefix.Content[0].OfToolResult.CacheControl.Type))
+		for _, message := range []anthropicSDK.MessageParam{params.Messages[0], params.Messages[1], params.Messages[3]} {
+			for _, block := range message.Content {
+				if cacheControl := block.GetCacheControl(); cacheControl != nil {
+					assert.Empty(t, cacheControl.Type)
+				}
+			}
+		}
+	})
+
+	t.Run("single message without tools or system prompt", func(t *testing.T) {
+		_, messages := conversationToMessages([]llm.Post{{Role: llm.PostRoleUser, Message: "Hello"}})
+		params := anthropicSDK.MessageNewParams{Messages: messages}
+
+		addCacheBreakpoints(&params)
+
+		assert.Empty(t, params.Messages[0].Content[0].OfText.CacheControl.Type)
+	})
+
+	t.Run("skips thinking blocks", func(t *testing.T) {
+		params := anthropicSDK.MessageNewParams{Messages: []anthropicSDK.MessageParam{
+			anthropicSDK.NewAssistantMessage(anthropicSDK.NewTextBlock("Answer"), anthropicSDK.NewThinkingBlock("sig", "Thinking")),
+			anthropicSDK.NewUserMessage(anthropicSDK.NewTextBlock("Question")),
+		}}
+
+		addCacheBreakpoints(&params)
+
+		assert.Equal(t, "ephemeral", string(params.Messages[0].Content[0].OfText.CacheControl.Type))
+	})
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package anthropic
+
+import (
+	"errors"
+	"time"
+
+	anthropicSDK "github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// ClassifyError categorises errors returned by the Anthropic SDK.
+func ClassifyError(err error) llm.ErrorClass {
+	var apiErr *anthropicSDK.Error
+	if errors.As(err, &apiErr) {
+		return llm.ClassifyHTTPStatus(apiErr.StatusCode)
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by the Anthropic API in a rate limit or overloaded response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *anthropicSDK.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return llm.ParseRetryAfter(apiErr.Response.Header)
+	}
+	return 0, false
+}
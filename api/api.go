@@ -5,6 +5,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,7 +16,9 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/anthropic"
 	"github.com/mattermost/mattermost-plugin-ai/bots"
 	"github.com/mattermost/mattermost-plugin-ai/conversations"
+	"github.com/mattermost/mattermost-plugin-ai/costs"
 	"github.com/mattermost/mattermost-plugin-ai/enterprise"
+	"github.com/mattermost/mattermost-plugin-ai/gemini"
 	"github.com/mattermost/mattermost-plugin-ai/i18n"
 	"github.com/mattermost/mattermost-plugin-ai/indexer"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
@@ -23,9 +26,12 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/mcp"
 	"github.com/mattermost/mattermost-plugin-ai/mcpserver"
 	"github.com/mattermost/mattermost-plugin-ai/meetings"
+	"github.com/mattermost/mattermost-plugin-ai/memory"
 	"github.com/mattermost/mattermost-plugin-ai/metrics"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/ollama"
 	"github.com/mattermost/mattermost-plugin-ai/openai"
+	"github.com/mattermost/mattermost-plugin-ai/quota"
 	"github.com/mattermost/mattermost-plugin-ai/search"
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
 	"github.com/mattermost/mattermost/server/public/model"
@@ -75,6 +81,9 @@ type API struct {
 	mcpClientManager      MCPClientManager
 	mcpHandlers           *mcpserver.PluginMCPHandlers
 	llmUpstreamHTTPClient *http.Client
+	quotaService          *quota.Service
+	costService           *costs.Service
+	memoryService         *memory.Service
 }
 
 // New creates a new API instance
@@ -97,6 +106,9 @@ func New(
 	mcpClientManager MCPClientManager,
 	mcpHandlers *mcpserver.PluginMCPHandlers,
 	llmUpstreamHTTPClient *http.Client,
+	quotaService *quota.Service,
+	costService *costs.Service,
+	memoryService *memory.Service,
 ) *API {
 	return &API{
 		bots:                  bots,
@@ -118,6 +130,9 @@ func New(
 		mcpClientManager:      mcpClientManager,
 		mcpHandlers:           mcpHandlers,
 		llmUpstreamHTTPClient: llmUpstreamHTTPClient,
+		quotaService:          quotaService,
+		costService:           costService,
+		memoryService:         memoryService,
 	}
 }
 
@@ -168,6 +183,10 @@ func (a *API) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Reques
 	router.GET("/oauth/callback", a.handleOAuthCallback)
 	router.GET("/ai_threads", a.handleGetAIThreads)
 	router.GET("/ai_bots", a.handleGetAIBots)
+	router.GET("/usage/me", a.handleGetMyUsage)
+	router.GET("/memories", a.handleGetMemories)
+	router.DELETE("/memories", a.handleDeleteMemories)
+	router.DELETE("/memories/:memoryid", a.handleDeleteMemory)
 
 	botRequiredRouter := router.Group("")
 	botRequiredRouter.Use(a.aiBotRequired)
@@ -180,7 +199,11 @@ func (a *API) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Reques
 	postRouter.POST("/summarize_transcription", a.handleSummarizeTranscription)
 	postRouter.POST("/stop", a.handleStop)
 	postRouter.POST("/regenerate", a.handleRegenerate)
+	postRouter.POST("/fork", a.handleFork)
+	postRouter.GET("/versions", a.handleGetPostVersions)
+	postRouter.POST("/versions/:version/activate", a.handleActivatePostVersion)
 	postRouter.POST("/tool_call", a.handleToolCall)
+	postRouter.POST("/feedback", a.handleAutoResponseFeedback)
 	postRouter.POST("/postback_summary", a.handlePostbackSummary)
 
 	channelRouter := botRequiredRouter.Group("/channel/:channelid")
@@ -195,6 +218,8 @@ func (a *API) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Reques
 	adminRouter.GET("/mcp/tools", a.handleGetMCPTools)
 	adminRouter.POST("/mcp/tools/cache/clear", a.handleClearMCPToolsCache)
 	adminRouter.POST("/models/fetch", a.handleFetchModels)
+	adminRouter.GET("/usage", a.handleGetUsage)
+	adminRouter.GET("/usage/costs", a.handleGetCostReport)
 
 	searchRouter := botRequiredRouter.Group("/search")
 	// Only returns search results
@@ -247,6 +272,28 @@ func (a *API) ginlogger(c *gin.Context) {
 	}
 }
 
+// abortWithLLMError aborts the request with err from an LLM request. Users over a token quota get
+// the explanation of streamed responses in their language rather than the error.
+func (a *API) abortWithLLMError(c *gin.Context, locale string, err error) {
+	var quotaErr *llm.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		T := i18n.LocalizerFunc(a.i18nBundle, locale)
+		_ = c.Error(err)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": streaming.QuotaExceededMessage(T, quotaErr)})
+		return
+	}
+	c.AbortWithError(http.StatusInternalServerError, err)
+}
+
+// userLocale returns the locale of userID, or the default locale if the user can't be found.
+func (a *API) userLocale(userID string) string {
+	user, err := a.pluginAPI.User.Get(userID)
+	if err != nil {
+		return ""
+	}
+	return user.Locale
+}
+
 func (a *API) MattermostAuthorizationRequired(c *gin.Context) {
 	userID := c.GetHeader("Mattermost-User-Id")
 	if userID == "" {
@@ -381,8 +428,8 @@ func (a *API) handleFetchModels(c *gin.Context) {
 		return
 	}
 
-	// API key is required for most services, but optional for openaicompatible (some don't require auth)
-	if req.APIKey == "" && req.ServiceType != "openaicompatible" {
+	// API key is required for most services, but optional for openaicompatible and ollama (some don't require auth)
+	if req.APIKey == "" && req.ServiceType != "openaicompatible" && req.ServiceType != "ollama" {
 		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("apiKey is required"))
 		return
 	}
@@ -393,6 +440,11 @@ func (a *API) handleFetchModels(c *gin.Context) {
 		return
 	}
 
+	if req.ServiceType == "ollama" && req.APIURL == "" {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("apiURL is required for ollama"))
+		return
+	}
+
 	var models []llm.ModelInfo
 	var err error
 
@@ -401,6 +453,10 @@ func (a *API) handleFetchModels(c *gin.Context) {
 		models, err = anthropic.FetchModels(req.APIKey, a.llmUpstreamHTTPClient)
 	case "openai", "azure", "openaicompatible":
 		models, err = openai.FetchModels(req.APIKey, req.APIURL, req.OrgID, a.llmUpstreamHTTPClient)
+	case "gemini":
+		models, err = gemini.FetchModels(req.APIKey, req.APIURL, a.llmUpstreamHTTPClient)
+	case "ollama":
+		models, err = ollama.FetchModels(req.APIURL, req.APIKey, a.llmUpstreamHTTPClient)
 	default:
 		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("model fetching not supported for service type: %s", req.ServiceType))
 		return
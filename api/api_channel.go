@@ -4,7 +4,6 @@
 package api
 
 import (
-	stdcontext "context"
 	"encoding/json"
 	"net/http"
 
@@ -125,8 +124,10 @@ func (a *API) handleInterval(c *gin.Context) {
 	}
 
 	// Call channels interval processing
-	resultStream, err := channels.New(bot.LLM(), a.prompts, a.mmClient, a.dbClient).Interval(context, channel.Id, data.StartTime, data.EndTime, promptPreset)
+	ctx, cancel := a.streamingService.NewRequestContext()
+	resultStream, err := channels.New(bot.LLM(), a.prompts, a.mmClient, a.dbClient).Interval(ctx, context, channel.Id, data.StartTime, data.EndTime, promptPreset)
 	if err != nil {
+		cancel()
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
@@ -136,7 +137,8 @@ func (a *API) handleInterval(c *gin.Context) {
 	post.AddProp(streaming.NoRegen, "true")
 
 	// Stream result to new DM
-	if err := a.streamingService.StreamToNewDM(stdcontext.Background(), bot.GetMMBot().UserId, resultStream, user.Id, post, ""); err != nil {
+	if err := a.streamingService.StreamToNewDM(ctx, bot.GetMMBot().UserId, resultStream, user.Id, post, ""); err != nil {
+		cancel()
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattermost/mattermost-plugin-ai/costs"
+)
+
+type CostReportResponse struct {
+	GroupBy []costs.Dimension `json:"group_by"`
+	Since   string            `json:"since"`
+	Until   string            `json:"until"`
+	Costs   []costs.ReportRow `json:"costs"`
+}
+
+// handleGetCostReport returns the cost of bot requests aggregated by the requested dimensions.
+// Query parameters: group_by (comma separated list of day, team, bot, user, channel_type and model; default day),
+// since and until (YYYY-MM-DD, until is exclusive; default the current month) and format (json or csv; default json).
+func (a *API) handleGetCostReport(c *gin.Context) {
+	if a.costService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("cost tracking is not available"))
+		return
+	}
+
+	groupBy, err := costs.ParseDimensions(strings.Split(c.DefaultQuery("group_by", string(costs.DimensionDay)), ","))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid group_by: %w", err))
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid format %q", format))
+		return
+	}
+
+	since, until, err := parseUsageDateRange(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	rows, err := a.costService.GetReport(groupBy, since, until)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="agents-costs-%s-%s.csv"`, since.Format(usageDateFormat), until.Format(usageDateFormat)))
+		c.Status(http.StatusOK)
+		if err := costs.WriteCSV(c.Writer, groupBy, rows); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, CostReportResponse{
+		GroupBy: groupBy,
+		Since:   since.Format(usageDateFormat),
+		Until:   until.Format(usageDateFormat),
+		Costs:   rows,
+	})
+}
@@ -5,6 +5,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,8 +13,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/i18n"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/public/bridgeclient"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
 )
 
 // convertLLMBridgeRequestToInternal converts the API request format to internal llm.CompletionRequest
@@ -156,20 +159,21 @@ func (a *API) checkBridgePermissions(userID, channelID string, bot *bots.Bot) er
 }
 
 // streamLLMResponse handles streaming LLM responses as Server-Sent Events
-func (a *API) streamLLMResponse(c *gin.Context, bot *bots.Bot, llmRequest llm.CompletionRequest, opts ...llm.LanguageModelOption) {
+func (a *API) streamLLMResponse(c *gin.Context, bot *bots.Bot, userID string, llmRequest llm.CompletionRequest, opts ...llm.LanguageModelOption) {
 	// Start streaming response
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Status(http.StatusOK)
 
-	// Make the streaming LLM call
-	streamResult, err := bot.LLM().ChatCompletion(llmRequest, opts...)
+	// Make the streaming LLM call. The request context is cancelled when the client disconnects,
+	// which aborts the upstream request.
+	streamResult, err := bot.LLM().ChatCompletion(c.Request.Context(), llmRequest, opts...)
 	if err != nil {
 		// If streaming hasn't started, we can still send a JSON error
 		errorEvent := llm.TextStreamEvent{
 			Type:  llm.EventTypeError,
-			Value: err.Error(),
+			Value: a.bridgeErrorResponse(userID, err),
 		}
 		eventJSON, _ := json.Marshal(errorEvent)
 		fmt.Fprintf(c.Writer, "data: %s\n\n", string(eventJSON))
@@ -178,6 +182,11 @@ func (a *API) streamLLMResponse(c *gin.Context, bot *bots.Bot, llmRequest llm.Co
 
 	// Stream the response as JSON-encoded events
 	for event := range streamResult.Stream {
+		// Errors don't encode to JSON, so they are sent as an ErrorResponse
+		if err, ok := event.Value.(error); ok && event.Type == llm.EventTypeError {
+			event.Value = a.bridgeErrorResponse(userID, err)
+		}
+
 		// Convert the event to JSON
 		eventJSON, err := json.Marshal(event)
 		if err != nil {
@@ -198,10 +207,41 @@ func (a *API) streamLLMResponse(c *gin.Context, bot *bots.Bot, llmRequest llm.Co
 	}
 }
 
+// bridgeErrorResponse describes an error from the LLM to bridge clients. Token quota errors are
+// explained in the language of userID, like in streamed responses.
+func (a *API) bridgeErrorResponse(userID string, err error) bridgeclient.ErrorResponse {
+	var validationErr *llm.JSONValidationError
+	if errors.As(err, &validationErr) {
+		return bridgeclient.ErrorResponse{
+			Error: err.Error(),
+			Code:  bridgeclient.ErrorCodeJSONValidation,
+		}
+	}
+	var quotaErr *llm.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		locale := ""
+		if userID != "" {
+			locale = a.userLocale(userID)
+		}
+		return bridgeclient.ErrorResponse{Error: streaming.QuotaExceededMessage(i18n.LocalizerFunc(a.i18nBundle, locale), quotaErr)}
+	}
+	return bridgeclient.ErrorResponse{Error: err.Error()}
+}
+
 // handleNonStreamingLLMResponse handles non-streaming LLM responses
-func (a *API) handleNonStreamingLLMResponse(c *gin.Context, bot *bots.Bot, llmRequest llm.CompletionRequest, opts ...llm.LanguageModelOption) {
+func (a *API) handleNonStreamingLLMResponse(c *gin.Context, bot *bots.Bot, userID string, llmRequest llm.CompletionRequest, opts ...llm.LanguageModelOption) {
 	// Make the non-streaming LLM call
-	response, err := bot.LLM().ChatCompletionNoStream(llmRequest, opts...)
+	response, err := bot.LLM().ChatCompletionNoStream(c.Request.Context(), llmRequest, opts...)
+	var validationErr *llm.JSONValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusUnprocessableEntity, a.bridgeErrorResponse(userID, err))
+		return
+	}
+	var quotaErr *llm.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		c.JSON(http.StatusTooManyRequests, a.bridgeErrorResponse(userID, err))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, bridgeclient.ErrorResponse{
 			Error: fmt.Sprintf("failed to complete LLM request: %v", err),
@@ -343,7 +383,7 @@ func (a *API) handleAgentCompletionStreaming(c *gin.Context) {
 	}
 
 	// Stream the response
-	a.streamLLMResponse(c, bot, llmRequest, opts...)
+	a.streamLLMResponse(c, bot, req.UserID, llmRequest, opts...)
 }
 
 // handleAgentCompletionNoStream handles non-streaming completion requests for a specific agent
@@ -404,8 +444,12 @@ func (a *API) handleAgentCompletionNoStream(c *gin.Context) {
 		return
 	}
 
+	if !req.DisableCache {
+		opts = append(opts, llm.WithResponseCache())
+	}
+
 	// Handle non-streaming response
-	a.handleNonStreamingLLMResponse(c, bot, llmRequest, opts...)
+	a.handleNonStreamingLLMResponse(c, bot, req.UserID, llmRequest, opts...)
 }
 
 // handleServiceCompletionStreaming handles streaming completion requests for a specific service
@@ -470,7 +514,7 @@ func (a *API) handleServiceCompletionStreaming(c *gin.Context) {
 	}
 
 	// Stream the response
-	a.streamLLMResponse(c, bot, llmRequest, opts...)
+	a.streamLLMResponse(c, bot, req.UserID, llmRequest, opts...)
 }
 
 // handleServiceCompletionNoStream handles non-streaming completion requests for a specific service
@@ -534,6 +578,10 @@ func (a *API) handleServiceCompletionNoStream(c *gin.Context) {
 		return
 	}
 
+	if !req.DisableCache {
+		opts = append(opts, llm.WithResponseCache())
+	}
+
 	// Handle non-streaming response
-	a.handleNonStreamingLLMResponse(c, bot, llmRequest, opts...)
+	a.handleNonStreamingLLMResponse(c, bot, req.UserID, llmRequest, opts...)
 }
@@ -8,6 +8,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mattermost/mattermost-plugin-ai/bots"
@@ -74,6 +75,30 @@ func TestBridgeClientAgentCompletion(t *testing.T) {
 			expectError: true,
 			errorMsg:    "failed to complete LLM request",
 		},
+		{
+			name:  "response does not match JSON output format",
+			agent: testBotUserID,
+			request: bridgeclient.CompletionRequest{
+				Posts: []bridgeclient.Post{
+					{Role: "user", Message: "Hello"},
+				},
+			},
+			fakeLLM:     NewFakeLLMWithError(&llm.JSONValidationError{Response: "{", Attempts: 3, Err: fmt.Errorf("invalid JSON")}),
+			expectError: true,
+			errorMsg:    bridgeclient.ErrJSONValidation.Error(),
+		},
+		{
+			name:  "token quota exceeded",
+			agent: testBotUserID,
+			request: bridgeclient.CompletionRequest{
+				Posts: []bridgeclient.Post{
+					{Role: "user", Message: "Hello"},
+				},
+			},
+			fakeLLM:     NewFakeLLMWithError(&llm.QuotaExceededError{Scope: "bot", ScopeID: "testbot", Period: "daily", ResetAt: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}),
+			expectError: true,
+			errorMsg:    "Sorry! This agent has used up its token quota. It resets at 2025-01-02 00:00 UTC.",
+		},
 		{
 			name:  "empty posts array",
 			agent: testBotUserID,
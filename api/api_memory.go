@@ -0,0 +1,76 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattermost/mattermost-plugin-ai/memory"
+)
+
+type MemoriesResponse struct {
+	Memories []memory.Memory `json:"memories"`
+}
+
+// handleGetMemories returns every fact the bots saved about the requesting user.
+func (a *API) handleGetMemories(c *gin.Context) {
+	if a.memoryService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("memory is not available"))
+		return
+	}
+
+	userID := c.GetHeader("Mattermost-User-Id")
+	memories, err := a.memoryService.GetMemories(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if memories == nil {
+		memories = []memory.Memory{}
+	}
+
+	c.JSON(http.StatusOK, MemoriesResponse{
+		Memories: memories,
+	})
+}
+
+// handleDeleteMemories deletes the facts saved about the requesting user.
+// Query parameters: bot_id (optional, only delete the facts saved by this bot).
+func (a *API) handleDeleteMemories(c *gin.Context) {
+	if a.memoryService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("memory is not available"))
+		return
+	}
+
+	userID := c.GetHeader("Mattermost-User-Id")
+	if err := a.memoryService.DeleteMemories(userID, c.Query("bot_id")); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// handleDeleteMemory deletes one of the facts saved about the requesting user.
+func (a *API) handleDeleteMemory(c *gin.Context) {
+	if a.memoryService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("memory is not available"))
+		return
+	}
+
+	userID := c.GetHeader("Mattermost-User-Id")
+	err := a.memoryService.DeleteMemory(userID, c.Param("memoryid"))
+	if errors.Is(err, memory.ErrMemoryNotFound) {
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -4,9 +4,9 @@
 package api
 
 import (
-	stdcontext "context"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"errors"
 
@@ -77,9 +77,9 @@ func (a *API) handleReact(c *gin.Context) {
 	emojiName, err := react.New(
 		bot.LLM(),
 		a.prompts,
-	).Resolve(post.Message, context)
+	).Resolve(c.Request.Context(), post.Message, context)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		a.abortWithLLMError(c, requestingUser.Locale, err)
 		return
 	}
 
@@ -143,20 +143,22 @@ func (a *API) handleThreadAnalysis(c *gin.Context) {
 
 	// Create thread analyzer
 	analyzer := threads.New(bot.LLM(), a.prompts, a.mmClient)
+	ctx, cancel := a.streamingService.NewRequestContext()
 	var analysisStream *llm.TextStreamResult
 	var title string
 	switch data.AnalysisType {
 	case "summarize_thread":
 		title = TitleThreadSummary
-		analysisStream, err = analyzer.Summarize(post.Id, llmContext)
+		analysisStream, err = analyzer.Summarize(ctx, post.Id, llmContext)
 	case "action_items":
 		title = TitleFindActionItems
-		analysisStream, err = analyzer.FindActionItems(post.Id, llmContext)
+		analysisStream, err = analyzer.FindActionItems(ctx, post.Id, llmContext)
 	case "open_questions":
 		title = TitleFindOpenQuestions
-		analysisStream, err = analyzer.FindOpenQuestions(post.Id, llmContext)
+		analysisStream, err = analyzer.FindOpenQuestions(ctx, post.Id, llmContext)
 	}
 	if err != nil {
+		cancel()
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to analyze thread: %w", err))
 		return
 	}
@@ -164,7 +166,8 @@ func (a *API) handleThreadAnalysis(c *gin.Context) {
 	// Create analysis post
 	siteURL := a.pluginAPI.Configuration.GetConfig().ServiceSettings.SiteURL
 	analysisPost := a.makeAnalysisPost(user.Locale, post.Id, data.AnalysisType, *siteURL)
-	if err := a.streamingService.StreamToNewDM(stdcontext.Background(), bot.GetMMBot().UserId, analysisStream, user.Id, analysisPost, post.Id); err != nil {
+	if err := a.streamingService.StreamToNewDM(ctx, bot.GetMMBot().UserId, analysisStream, user.Id, analysisPost, post.Id); err != nil {
+		cancel()
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
@@ -265,6 +268,126 @@ func (a *API) handleRegenerate(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (a *API) handleFork(c *gin.Context) {
+	userID := c.GetHeader("Mattermost-User-Id")
+	post := c.MustGet(ContextPostKey).(*model.Post)
+	channel := c.MustGet(ContextChannelKey).(*model.Channel)
+
+	if err := a.enforceEmptyBody(c); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if post.UserId != userID {
+		c.AbortWithError(http.StatusForbidden, errors.New("only the poster can fork the conversation"))
+		return
+	}
+
+	err := a.conversationsService.ForkFromPost(userID, post, channel)
+	switch {
+	case errors.Is(err, conversations.ErrNoResponse):
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	case errors.Is(err, streaming.ErrAlreadyStreamingToPost):
+		c.AbortWithError(http.StatusConflict, err)
+		return
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("unable to fork conversation: %w", err))
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (a *API) handleGetPostVersions(c *gin.Context) {
+	post := c.MustGet(ContextPostKey).(*model.Post)
+
+	if !a.bots.IsAnyBot(post.UserId) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("not a bot post"))
+		return
+	}
+
+	versions, err := a.conversationsService.GetPostVersions(post)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("unable to get post versions: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func (a *API) handleActivatePostVersion(c *gin.Context) {
+	userID := c.GetHeader("Mattermost-User-Id")
+	post := c.MustGet(ContextPostKey).(*model.Post)
+
+	if err := a.enforceEmptyBody(c); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid version: %w", err))
+		return
+	}
+
+	if !a.bots.IsAnyBot(post.UserId) {
+		c.AbortWithError(http.StatusBadRequest, errors.New("not a bot post"))
+		return
+	}
+
+	if post.GetProp(streaming.LLMRequesterUserID) != userID {
+		c.AbortWithError(http.StatusForbidden, errors.New("only the original poster can switch versions"))
+		return
+	}
+
+	err = a.conversationsService.SwitchPostVersion(post, version)
+	switch {
+	case errors.Is(err, conversations.ErrPostVersionNotFound):
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	case errors.Is(err, streaming.ErrAlreadyStreamingToPost):
+		c.AbortWithError(http.StatusConflict, err)
+		return
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("unable to switch post version: %w", err))
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (a *API) handleAutoResponseFeedback(c *gin.Context) {
+	userID := c.GetHeader("Mattermost-User-Id")
+	post := c.MustGet(ContextPostKey).(*model.Post)
+
+	if post.GetProp(conversations.AutoResponseProp) == nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("not an auto response"))
+		return
+	}
+
+	if post.GetProp(streaming.LLMRequesterUserID) != userID {
+		c.AbortWithError(http.StatusForbidden, errors.New("only the user who asked can give feedback"))
+		return
+	}
+
+	var data struct {
+		Helpful *bool `json:"helpful" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.conversationsService.SetAutoResponseFeedback(post, *data.Helpful); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("unable to save feedback: %w", err))
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
 func (a *API) handleToolCall(c *gin.Context) {
 	userID := c.GetHeader("Mattermost-User-Id")
 	post := c.MustGet(ContextPostKey).(*model.Post)
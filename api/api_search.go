@@ -66,7 +66,7 @@ func (a *API) handleSearchQuery(c *gin.Context) {
 
 	response, err := a.searchService.SearchQuery(c.Request.Context(), userID, bot, req.Query, req.TeamID, req.ChannelID, req.MaxResults)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		a.abortWithLLMError(c, a.userLocale(userID), err)
 		return
 	}
 
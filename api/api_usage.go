@@ -0,0 +1,101 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattermost/mattermost-plugin-ai/quota"
+)
+
+const usageDateFormat = "2006-01-02"
+
+type UsageReportResponse struct {
+	GroupBy quota.Scope        `json:"group_by"`
+	Since   string             `json:"since"`
+	Until   string             `json:"until"`
+	Usage   []quota.ScopeUsage `json:"usage"`
+}
+
+type MyUsageResponse struct {
+	Periods []quota.PeriodUsage `json:"periods"`
+}
+
+// parseUsageDateRange returns the since and until query parameters (YYYY-MM-DD, until is exclusive),
+// defaulting to the current month.
+func parseUsageDateRange(c *gin.Context) (time.Time, time.Time, error) {
+	now := time.Now()
+	since := quota.PeriodMonthly.Start(now)
+	until := quota.PeriodDaily.End(now)
+	var err error
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		if since, err = time.Parse(usageDateFormat, sinceParam); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if untilParam := c.Query("until"); untilParam != "" {
+		if until, err = time.Parse(usageDateFormat, untilParam); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	return since, until, nil
+}
+
+// handleGetUsage returns token usage grouped by bot, team or user.
+// Query parameters: group_by (bot, team or user; default user), since and until (YYYY-MM-DD, until is exclusive;
+// default the current month).
+func (a *API) handleGetUsage(c *gin.Context) {
+	if a.quotaService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("usage tracking is not available"))
+		return
+	}
+
+	groupBy := quota.Scope(c.DefaultQuery("group_by", string(quota.ScopeUser)))
+	if groupBy != quota.ScopeBot && groupBy != quota.ScopeTeam && groupBy != quota.ScopeUser {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid group_by %q", groupBy))
+		return
+	}
+
+	since, until, err := parseUsageDateRange(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	usage, err := a.quotaService.GetUsageReport(groupBy, since, until)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UsageReportResponse{
+		GroupBy: groupBy,
+		Since:   since.Format(usageDateFormat),
+		Until:   until.Format(usageDateFormat),
+		Usage:   usage,
+	})
+}
+
+// handleGetMyUsage returns the requesting user's usage for the current day and month along with their limits.
+func (a *API) handleGetMyUsage(c *gin.Context) {
+	if a.quotaService == nil {
+		c.AbortWithError(http.StatusNotFound, errors.New("usage tracking is not available"))
+		return
+	}
+
+	userID := c.GetHeader("Mattermost-User-Id")
+	periods, err := a.quotaService.GetUserUsage(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MyUsageResponse{
+		Periods: periods,
+	})
+}
@@ -6,10 +6,11 @@
 package asage
 
 import (
+	"context"
 	"net/http"
-	"strings"
 
 	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
 )
 
 type Provider struct {
@@ -70,35 +71,31 @@ func (s *Provider) queryParamsFromConfig(cfg llm.LanguageModelConfig) QueryParam
 	}
 }
 
-func (s *Provider) ChatCompletion(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+func (s *Provider) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
 	// ASage does not support streaming.
-	result, err := s.ChatCompletionNoStream(request, opts...)
+	result, err := s.ChatCompletionNoStream(ctx, request, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return llm.NewStreamFromString(result), nil
 }
 
-func (s *Provider) ChatCompletionNoStream(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+func (s *Provider) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
 	params := s.queryParamsFromConfig(s.createConfig(opts))
 	params.Message = conversationToMessagesList(request.Posts)
 	params.SystemPrompt = request.ExtractSystemMessage()
 	params.Persona = "default"
 
-	response, err := s.client.Query(params)
+	response, err := s.client.Query(ctx, params)
 	if err != nil {
 		return "", err
 	}
 	return response.Message, nil
 }
 
-// TODO: Implement actual token counting. For now just estimated based off OpenAI estimations
+// Asage doesn't expose its tokenizer so counts are estimated with cl100k_base
 func (s *Provider) CountTokens(text string) int {
-	charCount := float64(len(text)) / 4.0
-	wordCount := float64(len(strings.Fields(text))) / 0.75
-
-	// Average the two and add a buffer
-	return int((charCount+wordCount)/2.0) + 100
+	return tokenizer.Get(tokenizer.EncodingCL100kBase).CountTokens(text)
 }
 
 // TODO: Figure out what the actual token limit is. For now just be conservative.
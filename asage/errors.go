@@ -0,0 +1,30 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package asage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// ClassifyError categorises errors returned by the Ask Sage client.
+func ClassifyError(err error) llm.ErrorClass {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return llm.ClassifyHTTPStatus(apiErr.StatusCode)
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by the Ask Sage server, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return llm.ParseRetryAfter(apiErr.Header)
+	}
+	return 0, false
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/aws/smithy-go/auth/bearer"
 
 	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
 )
 
 const (
@@ -28,13 +29,14 @@ const (
 )
 
 type messageState struct {
+	ctx      context.Context
 	messages []types.Message
 	system   []types.SystemContentBlock
 	output   chan<- llm.TextStreamEvent
 	depth    int
 	config   llm.LanguageModelConfig
 	tools    []llm.Tool
-	resolver func(name string, argsGetter llm.ToolArgumentGetter, context *llm.Context) (string, error)
+	resolver func(ctx context.Context, name string, argsGetter llm.ToolArgumentGetter, llmContext *llm.Context) (string, error)
 	context  *llm.Context
 }
 
@@ -328,7 +330,14 @@ func (b *Bedrock) streamChatWithTools(state messageState) {
 		}
 	}
 
-	stream, err := b.client.ConverseStream(context.Background(), params)
+	if supportsPromptCaching(state.config.Model) {
+		addCachePoints(params)
+	}
+
+	// Retries are handled by llm.RetryWrapper so they can honour the overall streaming budget
+	stream, err := b.client.ConverseStream(state.ctx, params, func(o *bedrockruntime.Options) {
+		o.RetryMaxAttempts = 1
+	})
 	if err != nil {
 		state.output <- llm.TextStreamEvent{
 			Type:  llm.EventTypeError,
@@ -403,9 +412,14 @@ func (b *Bedrock) streamChatWithTools(state messageState) {
 		case *types.ConverseStreamOutputMemberMetadata:
 			// Extract token usage
 			if e.Value.Usage != nil {
+				// Bedrock reports cached tokens separately from input tokens
+				cacheRead := int64(aws.ToInt32(e.Value.Usage.CacheReadInputTokens))
+				cacheWrite := int64(aws.ToInt32(e.Value.Usage.CacheWriteInputTokens))
 				usage := llm.TokenUsage{
-					InputTokens:  int64(aws.ToInt32(e.Value.Usage.InputTokens)),
-					OutputTokens: int64(aws.ToInt32(e.Value.Usage.OutputTokens)),
+					InputTokens:              int64(aws.ToInt32(e.Value.Usage.InputTokens)) + cacheRead + cacheWrite,
+					OutputTokens:             int64(aws.ToInt32(e.Value.Usage.OutputTokens)),
+					CacheReadInputTokens:     cacheRead,
+					CacheCreationInputTokens: cacheWrite,
 				}
 				state.output <- llm.TextStreamEvent{
 					Type:  llm.EventTypeUsage,
@@ -416,6 +430,9 @@ func (b *Bedrock) streamChatWithTools(state messageState) {
 	}
 
 	if err := eventStream.Err(); err != nil {
+		if ctxErr := state.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
 		state.output <- llm.TextStreamEvent{
 			Type:  llm.EventTypeError,
 			Value: fmt.Errorf("error from bedrock stream: %w", err),
@@ -452,7 +469,7 @@ func (b *Bedrock) streamChatWithTools(state messageState) {
 	}
 }
 
-func (b *Bedrock) ChatCompletion(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+func (b *Bedrock) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
 	eventStream := make(chan llm.TextStreamEvent)
 
 	cfg := b.createConfig(opts)
@@ -460,6 +477,7 @@ func (b *Bedrock) ChatCompletion(request llm.CompletionRequest, opts ...llm.Lang
 	system, messages := conversationToMessages(request.Posts)
 
 	initialState := messageState{
+		ctx:      ctx,
 		messages: messages,
 		system:   system,
 		output:   eventStream,
@@ -481,9 +499,9 @@ func (b *Bedrock) ChatCompletion(request llm.CompletionRequest, opts ...llm.Lang
 	return &llm.TextStreamResult{Stream: eventStream}, nil
 }
 
-func (b *Bedrock) ChatCompletionNoStream(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+func (b *Bedrock) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
 	// This could perform better if we didn't use the streaming API here, but the complexity is not worth it.
-	result, err := b.ChatCompletion(request, opts...)
+	result, err := b.ChatCompletion(ctx, request, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -491,13 +509,46 @@ func (b *Bedrock) ChatCompletionNoStream(request llm.CompletionRequest, opts ...
 }
 
 func (b *Bedrock) CountTokens(text string) int {
-	// Bedrock doesn't provide a token counting API
-	// Approximate using character and word counts
-	charCount := float64(len(text)) / 4.0
-	wordCount := float64(len(strings.Fields(text))) / 0.75
+	// Bedrock doesn't provide a token counting API, cl100k_base is a close approximation
+	return tokenizer.ForModel(b.defaultModel).CountTokens(text)
+}
+
+// supportsPromptCaching reports whether the model accepts cache points. Only Claude models from
+// Claude 3.5 Haiku and 3.7 Sonnet onwards do, other models reject requests that contain them.
+func supportsPromptCaching(modelID string) bool {
+	if !strings.Contains(modelID, "anthropic.claude") {
+		return false
+	}
+	for _, legacy := range []string{"claude-v2", "claude-instant", "claude-3-haiku", "claude-3-sonnet", "claude-3-opus", "claude-3-5-sonnet"} {
+		if strings.Contains(modelID, legacy) {
+			return false
+		}
+	}
+	return true
+}
+
+// addCachePoints adds cache points after the tool list, the system prompt and the conversation up to
+// the newest message, so a follow up request in the same thread only pays full price for the messages
+// added since the previous one. Prefixes that are too short to be cached are ignored by Bedrock.
+func addCachePoints(params *bedrockruntime.ConverseStreamInput) {
+	cachePoint := types.CachePointBlock{Type: types.CachePointTypeDefault}
+
+	if params.ToolConfig != nil && len(params.ToolConfig.Tools) > 0 {
+		params.ToolConfig.Tools = append(params.ToolConfig.Tools, &types.ToolMemberCachePoint{Value: cachePoint})
+	}
 
-	// Average the two
-	return int((charCount + wordCount) / 2.0)
+	if len(params.System) > 0 {
+		params.System = append(params.System[:len(params.System):len(params.System)], &types.SystemContentBlockMemberCachePoint{Value: cachePoint})
+	}
+
+	if len(params.Messages) < 2 {
+		return
+	}
+	// Copy the messages so the cache point isn't added to the caller's conversation
+	messages := append([]types.Message(nil), params.Messages...)
+	prefix := &messages[len(messages)-2]
+	prefix.Content = append(prefix.Content[:len(prefix.Content):len(prefix.Content)], &types.ContentBlockMemberCachePoint{Value: cachePoint})
+	params.Messages = messages
 }
 
 // convertTools converts from llm.Tool to Bedrock types.Tool format
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/stretchr/testify/assert"
@@ -290,8 +291,48 @@ func TestInputTokenLimit(t *testing.T) {
 func TestCountTokens(t *testing.T) {
 	b := &Bedrock{}
 
-	// CountTokens uses: (len(text)/4.0 + len(Fields)/0.75) / 2.0
+	// CountTokens uses the cl100k_base tokenizer
 	assert.Equal(t, 0, b.CountTokens(""))
 	assert.Equal(t, 2, b.CountTokens("Hello world"))
-	assert.Equal(t, 12, b.CountTokens("This is a longer piece of text with more words"))
+	assert.Equal(t, 10, b.CountTokens("This is a longer piece of text with more words"))
+}
+
+func TestSupportsPromptCaching(t *testing.T) {
+	assert.True(t, supportsPromptCaching("anthropic.claude-sonnet-4-20250514-v1:0"))
+	assert.True(t, supportsPromptCaching("us.anthropic.claude-3-7-sonnet-20250219-v1:0"))
+	assert.True(t, supportsPromptCaching("anthropic.claude-3-5-haiku-20241022-v1:0"))
+	assert.False(t, supportsPromptCaching("anthropic.claude-3-sonnet-20240229-v1:0"))
+	assert.False(t, supportsPromptCaching("anthropic.claude-3-5-sonnet-20240620-v1:0"))
+	assert.False(t, supportsPromptCaching("meta.llama3-70b-instruct-v1:0"))
+}
+
+func TestAddCachePoints(t *testing.T) {
+	system, messages := conversationToMessages([]llm.Post{
+		{Role: llm.PostRoleSystem, Message: "You are a helpful assistant"},
+		{Role: llm.PostRoleUser, Message: "First question"},
+		{Role: llm.PostRoleBot, Message: "First answer"},
+		{Role: llm.PostRoleUser, Message: "Second question"},
+	})
+	params := &bedrockruntime.ConverseStreamInput{
+		System:   system,
+		Messages: messages,
+		ToolConfig: &types.ToolConfiguration{
+			Tools: convertTools([]llm.Tool{{Name: "search", Description: "Search"}}),
+		},
+	}
+
+	addCachePoints(params)
+
+	require.Len(t, params.ToolConfig.Tools, 2)
+	assert.IsType(t, &types.ToolMemberCachePoint{}, params.ToolConfig.Tools[1])
+	require.Len(t, params.System, 2)
+	assert.IsType(t, &types.SystemContentBlockMemberCachePoint{}, params.System[1])
+
+	require.Len(t, params.Messages, 3)
+	require.Len(t, params.Messages[1].Content, 2)
+	assert.IsType(t, &types.ContentBlockMemberCachePoint{}, params.Messages[1].Content[1])
+	assert.Len(t, params.Messages[2].Content, 1)
+
+	// The caller's conversation is left untouched
+	assert.Len(t, messages[1].Content, 1)
 }
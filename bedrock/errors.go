@@ -0,0 +1,50 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package bedrock
+
+import (
+	"errors"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// ClassifyError categorises errors returned by the Bedrock runtime.
+// Errors raised mid-stream carry no HTTP status, so the modeled exception code is checked first.
+func ClassifyError(err error) llm.ErrorClass {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "ServiceQuotaExceededException":
+			return llm.ErrorClassRateLimit
+		case "InternalServerException", "ServiceUnavailableException", "ModelNotReadyException", "ModelStreamErrorException":
+			return llm.ErrorClassServer
+		case "ModelTimeoutException":
+			return llm.ErrorClassTimeout
+		case "AccessDeniedException", "UnrecognizedClientException":
+			return llm.ErrorClassAuth
+		case "ValidationException", "ResourceNotFoundException":
+			return llm.ErrorClassInvalidRequest
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return llm.ClassifyHTTPStatus(respErr.HTTPStatusCode())
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by Bedrock in a throttling response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		return llm.ParseRetryAfter(respErr.Response.Header)
+	}
+	return 0, false
+}
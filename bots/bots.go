@@ -7,15 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-ai/anthropic"
 	"github.com/mattermost/mattermost-plugin-ai/asage"
 	"github.com/mattermost/mattermost-plugin-ai/bedrock"
 	"github.com/mattermost/mattermost-plugin-ai/config"
 	"github.com/mattermost/mattermost-plugin-ai/enterprise"
+	"github.com/mattermost/mattermost-plugin-ai/gemini"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/ollama"
 	"github.com/mattermost/mattermost-plugin-ai/openai"
 	"github.com/mattermost/mattermost-plugin-ai/subtitles"
 	"github.com/mattermost/mattermost/server/public/model"
@@ -46,6 +51,11 @@ type MMBots struct {
 	llmUpstreamHTTPClient  *http.Client
 	tokenLogger            *mlog.Logger
 	metrics                llm.MetricsObserver
+	summaryStore           llm.ConversationSummaryStore
+	quotaEnforcer          llm.QuotaEnforcer
+	costTracker            llm.CostTracker
+	responseCache          llm.ResponseCache
+	redactor               llm.Redactor
 
 	botsLock sync.RWMutex
 	bots     []*Bot
@@ -67,6 +77,36 @@ func New(mutexPluginAPI cluster.MutexPluginAPI, pluginAPI *pluginapi.Client, lic
 	}
 }
 
+// SetConversationSummaryStore sets the store used to cache conversation summaries for bots
+// using the summarize truncation strategy. It must be called before EnsureBots.
+func (b *MMBots) SetConversationSummaryStore(store llm.ConversationSummaryStore) {
+	b.summaryStore = store
+}
+
+// SetQuotaEnforcer sets the enforcer used to apply token quotas to bot requests.
+// It must be called before EnsureBots.
+func (b *MMBots) SetQuotaEnforcer(enforcer llm.QuotaEnforcer) {
+	b.quotaEnforcer = enforcer
+}
+
+// SetCostTracker sets the tracker used to price and record the cost of bot requests.
+// It must be called before EnsureBots.
+func (b *MMBots) SetCostTracker(costTracker llm.CostTracker) {
+	b.costTracker = costTracker
+}
+
+// SetResponseCache sets the cache used to answer repeated requests made with llm.WithResponseCache.
+// It must be called before EnsureBots.
+func (b *MMBots) SetResponseCache(cache llm.ResponseCache) {
+	b.responseCache = cache
+}
+
+// SetRedactor sets the redactor used for bots that have redaction enabled.
+// It must be called before EnsureBots.
+func (b *MMBots) SetRedactor(redactor llm.Redactor) {
+	b.redactor = redactor
+}
+
 // botConfigsEqual compares two bot config slices for equality
 // This is used for optimistic checking to avoid unnecessary cluster mutex acquisition
 func botConfigsEqual(a, b []llm.BotConfig) bool {
@@ -89,7 +129,26 @@ func botConfigsEqual(a, b []llm.BotConfig) bool {
 		if aCfg.Name != cfg.Name ||
 			aCfg.DisplayName != cfg.DisplayName ||
 			aCfg.ServiceID != cfg.ServiceID ||
-			aCfg.Model != cfg.Model {
+			aCfg.Model != cfg.Model ||
+			aCfg.TruncationStrategy != cfg.TruncationStrategy ||
+			aCfg.EnableRedaction != cfg.EnableRedaction ||
+			aCfg.JSONValidationRetries != cfg.JSONValidationRetries ||
+			aCfg.DefaultToolPolicy != cfg.DefaultToolPolicy ||
+			aCfg.MaxParallelToolCalls != cfg.MaxParallelToolCalls ||
+			!slices.Equal(aCfg.ToolPolicies, cfg.ToolPolicies) ||
+			aCfg.ToolResultTokenLimit != cfg.ToolResultTokenLimit ||
+			aCfg.ToolResultStrategy != cfg.ToolResultStrategy ||
+			aCfg.MaxAgentIterations != cfg.MaxAgentIterations ||
+			aCfg.MaxAgentDurationSeconds != cfg.MaxAgentDurationSeconds ||
+			aCfg.AgentTokenBudget != cfg.AgentTokenBudget ||
+			aCfg.EnableMemory != cfg.EnableMemory ||
+			aCfg.MemoryTokenBudget != cfg.MemoryTokenBudget ||
+			!slices.Equal(aCfg.FallbackServiceIDs, cfg.FallbackServiceIDs) ||
+			!slices.Equal(aCfg.ModelRoutes, cfg.ModelRoutes) ||
+			!reflect.DeepEqual(aCfg.AutoResponseRules, cfg.AutoResponseRules) ||
+			aCfg.Description != cfg.Description ||
+			aCfg.RouterMode != cfg.RouterMode ||
+			!slices.Equal(aCfg.DelegateBots, cfg.DelegateBots) {
 			return false
 		}
 	}
@@ -151,6 +210,11 @@ func (b *MMBots) EnsureBots() error {
 			continue
 		}
 
+		// Rules with invalid patterns are skipped so messages are never matched against them
+		if err := botCfg.CompileAutoResponseRules(); err != nil {
+			b.pluginAPI.Log.Error("Skipping invalid auto response rules", "bot_name", botCfg.Name, "error", err.Error())
+		}
+
 		// Get service by ID
 		service, ok := b.config.GetServiceByID(botCfg.ServiceID)
 		if !ok {
@@ -242,6 +306,126 @@ func (b *MMBots) EnsureBots() error {
 }
 
 func (b *MMBots) getLLM(serviceConfig llm.ServiceConfig, botConfig llm.BotConfig) (llm.LanguageModel, error) {
+	result, err := b.getServiceLLM(serviceConfig, botConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failover Support
+	if len(botConfig.FallbackServiceIDs) > 0 {
+		targets := []llm.FailoverTarget{{
+			Service:  serviceInfo(serviceConfig),
+			Model:    result,
+			Classify: errorClassifierForService(serviceConfig.Type),
+		}}
+		for _, fallbackID := range botConfig.FallbackServiceIDs {
+			fallbackConfig, ok := b.config.GetServiceByID(fallbackID)
+			if !ok || !llm.IsValidService(fallbackConfig) {
+				b.pluginAPI.Log.Error("Bot references invalid fallback service", "bot_name", botConfig.Name, "service_id", fallbackID)
+				continue
+			}
+			fallback, err := b.getServiceLLM(fallbackConfig, botConfig)
+			if err != nil {
+				b.pluginAPI.Log.Error("Failed to create fallback service", "bot_name", botConfig.Name, "service_id", fallbackID, "error", err.Error())
+				continue
+			}
+			targets = append(targets, llm.FailoverTarget{
+				Service:  serviceInfo(fallbackConfig),
+				Model:    fallback,
+				Classify: errorClassifierForService(fallbackConfig.Type),
+			})
+		}
+		if len(targets) > 1 {
+			result = llm.NewFailoverWrapper(targets, &b.pluginAPI.Log)
+		}
+	}
+
+	// Model Routing, inside usage logging so the routed model is logged
+	if len(botConfig.ModelRoutes) > 0 {
+		result = llm.NewModelRoutingWrapper(result, b.getModelRoutes(serviceConfig, botConfig, result))
+	}
+
+	// Token Usage Logging and Cost Tracking
+	tokenLoggingEnabled := b.tokenLogger != nil && b.config.EnableTokenUsageLogging()
+	if tokenLoggingEnabled || b.costTracker != nil {
+		var tokenLogger *mlog.Logger
+		if tokenLoggingEnabled {
+			tokenLogger = b.tokenLogger
+		}
+		wrapper := llm.NewTokenUsageLoggingWrapper(
+			result,
+			botConfig.Name,
+			tokenLogger,
+			b.metrics,
+		)
+		if b.costTracker != nil {
+			wrapper = wrapper.WithCostTracking(serviceInfo(serviceConfig), b.costTracker)
+		}
+		result = wrapper
+	}
+
+	// Token Quotas
+	if b.quotaEnforcer != nil {
+		result = llm.NewQuotaWrapper(result, botConfig.Name, b.quotaEnforcer)
+	}
+
+	// JSON Validation, outside usage logging and quotas so each retry is accounted for
+	result = llm.NewJSONValidationWrapper(result, botConfig.JSONValidationRetries)
+
+	// Logging
+	if b.config.EnableLLMLogging() {
+		result = llm.NewLanguageModelLogWrapper(b.pluginAPI.Log, result)
+	}
+
+	return result, nil
+}
+
+// getModelRoutes creates the models the bot routes tasks to. Routes to the bot's own service
+// share defaultLLM, and routes to the same service share its model.
+func (b *MMBots) getModelRoutes(serviceConfig llm.ServiceConfig, botConfig llm.BotConfig, defaultLLM llm.LanguageModel) map[llm.Task]llm.RouteTarget {
+	models := map[string]llm.LanguageModel{serviceConfig.ID: defaultLLM}
+	routes := make(map[llm.Task]llm.RouteTarget, len(botConfig.ModelRoutes))
+	for _, route := range botConfig.ModelRoutes {
+		if route.Task == "" {
+			b.pluginAPI.Log.Error("Bot model route has no task", "bot_name", botConfig.Name)
+			continue
+		}
+
+		routeConfig := serviceConfig
+		if route.ServiceID != "" && route.ServiceID != serviceConfig.ID {
+			var ok bool
+			routeConfig, ok = b.config.GetServiceByID(route.ServiceID)
+			if !ok || !llm.IsValidService(routeConfig) {
+				b.pluginAPI.Log.Error("Bot model route references invalid service", "bot_name", botConfig.Name, "task", route.Task, "service_id", route.ServiceID)
+				continue
+			}
+		}
+
+		model, ok := models[routeConfig.ID]
+		if !ok {
+			var err error
+			model, err = b.getServiceLLM(routeConfig, botConfig)
+			if err != nil {
+				b.pluginAPI.Log.Error("Failed to create model route service", "bot_name", botConfig.Name, "task", route.Task, "service_id", route.ServiceID, "error", err.Error())
+				continue
+			}
+			models[routeConfig.ID] = model
+		}
+
+		service := serviceInfo(routeConfig)
+		if route.Model != "" {
+			service.Model = route.Model
+		}
+		routes[route.Task] = llm.RouteTarget{
+			Service: service,
+			Model:   model,
+		}
+	}
+	return routes
+}
+
+// getServiceLLM creates the provider for a single service with truncation applied.
+func (b *MMBots) getServiceLLM(serviceConfig llm.ServiceConfig, botConfig llm.BotConfig) (llm.LanguageModel, error) {
 	// Create the correct model
 	var result llm.LanguageModel
 	switch serviceConfig.Type {
@@ -271,32 +455,104 @@ func (b *MMBots) getLLM(serviceConfig llm.ServiceConfig, botConfig llm.BotConfig
 		mistralCfg := serviceConfig
 		mistralCfg.APIURL = "https://api.mistral.ai/v1"
 		result = openai.NewCompatible(config.OpenAIConfigFromServiceConfigWithOptions(mistralCfg, botConfig, true, true), b.llmUpstreamHTTPClient)
+	case llm.ServiceTypeGemini:
+		result = gemini.New(serviceConfig, botConfig, b.llmUpstreamHTTPClient)
+	case llm.ServiceTypeOllama:
+		result = ollama.New(serviceConfig, botConfig, b.llmUpstreamHTTPClient)
 	default:
 		b.pluginAPI.Log.Error("Unsupported service type for bot", "bot_name", botConfig.Name, "service_type", serviceConfig.Type)
 		return nil, fmt.Errorf("unsupported service type: %s", serviceConfig.Type)
 	}
 
-	// Truncation Support
-	result = llm.NewLLMTruncationWrapper(result)
+	// Tracing, innermost so each retry attempt is a separate span
+	result = llm.NewTracingWrapper(result, serviceInfo(serviceConfig), botConfig.Name)
 
-	// Token Usage Logging
-	if b.tokenLogger != nil && b.config.EnableTokenUsageLogging() {
-		result = llm.NewTokenUsageLoggingWrapper(
-			result,
-			botConfig.Name,
-			b.tokenLogger,
-			b.metrics,
-		)
+	// Latency and error metrics, inside retry so failed attempts are counted
+	if b.metrics != nil {
+		result = llm.NewStreamMetricsWrapper(result, botConfig.Name, serviceConfig.Name, errorClassifierForService(serviceConfig.Type), b.metrics)
 	}
 
-	// Logging
-	if b.config.EnableLLMLogging() {
-		result = llm.NewLanguageModelLogWrapper(b.pluginAPI.Log, result)
+	// Retry Support
+	maxTotalWait := config.DefaultStreamingTimeout
+	if serviceConfig.StreamingTimeoutSeconds > 0 {
+		maxTotalWait = time.Duration(serviceConfig.StreamingTimeoutSeconds) * time.Second
+	}
+	result = llm.NewRetryWrapper(result, llm.RetryConfig{
+		MaxTotalWait: maxTotalWait,
+		Classify:     errorClassifierForService(serviceConfig.Type),
+		RetryAfter:   retryAfterForService(serviceConfig.Type),
+	}, &b.pluginAPI.Log)
+
+	// Response Cache, inside redaction so only redacted requests and responses are stored, and
+	// inside usage logging so cache hits are logged with no tokens
+	if b.responseCache != nil {
+		result = llm.NewResponseCacheWrapper(result, serviceInfo(serviceConfig), b.responseCache)
+	}
+
+	// Redaction, below truncation so conversation summaries are also made from redacted posts
+	if botConfig.EnableRedaction && b.redactor != nil {
+		result = llm.NewRedactionWrapper(result, botConfig.Name, b.redactor, b.metrics)
+	}
+
+	// Truncation Support
+	if botConfig.TruncationStrategy == llm.TruncationStrategySummarize {
+		result = llm.NewSummarizingTruncationWrapper(result, b.summaryStore, &b.pluginAPI.Log)
+	} else {
+		result = llm.NewLLMTruncationWrapper(result)
 	}
 
 	return result, nil
 }
 
+func serviceInfo(serviceConfig llm.ServiceConfig) llm.ServiceInfo {
+	return llm.ServiceInfo{
+		ID:    serviceConfig.ID,
+		Name:  serviceConfig.Name,
+		Type:  serviceConfig.Type,
+		Model: serviceConfig.DefaultModel,
+	}
+}
+
+// errorClassifierForService returns the error classifier for the provider backing a service type.
+func errorClassifierForService(serviceType string) llm.ErrorClassifier {
+	switch serviceType {
+	case llm.ServiceTypeOpenAI, llm.ServiceTypeOpenAICompatible, llm.ServiceTypeAzure, llm.ServiceTypeCohere, llm.ServiceTypeMistral:
+		return openai.ClassifyError
+	case llm.ServiceTypeAnthropic:
+		return anthropic.ClassifyError
+	case llm.ServiceTypeBedrock:
+		return bedrock.ClassifyError
+	case llm.ServiceTypeASage:
+		return asage.ClassifyError
+	case llm.ServiceTypeGemini:
+		return gemini.ClassifyError
+	case llm.ServiceTypeOllama:
+		return ollama.ClassifyError
+	default:
+		return llm.ClassifyError
+	}
+}
+
+// retryAfterForService returns the Retry-After extractor for the provider backing a service type.
+func retryAfterForService(serviceType string) llm.RetryAfterFunc {
+	switch serviceType {
+	case llm.ServiceTypeOpenAI, llm.ServiceTypeOpenAICompatible, llm.ServiceTypeAzure, llm.ServiceTypeCohere, llm.ServiceTypeMistral:
+		return openai.RetryAfter
+	case llm.ServiceTypeAnthropic:
+		return anthropic.RetryAfter
+	case llm.ServiceTypeBedrock:
+		return bedrock.RetryAfter
+	case llm.ServiceTypeASage:
+		return asage.RetryAfter
+	case llm.ServiceTypeGemini:
+		return gemini.RetryAfter
+	case llm.ServiceTypeOllama:
+		return ollama.RetryAfter
+	default:
+		return nil
+	}
+}
+
 // TODO: This really doesn't belong here. Figure out where to put this.
 func (b *MMBots) GetTranscribe() Transcriber {
 	// Get the configured transcript generator bot
@@ -217,3 +217,88 @@ func TestEnsureBots(t *testing.T) {
 		})
 	}
 }
+
+func TestBotConfigsEqual(t *testing.T) {
+	base := llm.BotConfig{ID: "bot", Name: "bot", DisplayName: "Bot", ServiceID: "service"}
+	require.True(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{base}))
+
+	t.Run("toggling redaction recreates the bot", func(t *testing.T) {
+		redacted := base
+		redacted.EnableRedaction = true
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{redacted}))
+	})
+
+	t.Run("changing JSON validation retries recreates the bot", func(t *testing.T) {
+		retries := base
+		retries.JSONValidationRetries = -1
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{retries}))
+	})
+
+	t.Run("changing tool policies recreates the bot", func(t *testing.T) {
+		defaultPolicy := base
+		defaultPolicy.DefaultToolPolicy = llm.ToolPolicyAutoApprove
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{defaultPolicy}))
+
+		rules := base
+		rules.ToolPolicies = []llm.ToolPolicyRule{{MCPServer: "github", Policy: llm.ToolPolicyDeny}}
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{rules}))
+
+		parallel := base
+		parallel.MaxParallelToolCalls = 1
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{parallel}))
+	})
+
+	t.Run("changing tool result limits recreates the bot", func(t *testing.T) {
+		limit := base
+		limit.ToolResultTokenLimit = 500
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{limit}))
+
+		strategy := base
+		strategy.ToolResultStrategy = llm.ToolResultStrategySummarize
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{strategy}))
+	})
+
+	t.Run("changing agent loop limits recreates the bot", func(t *testing.T) {
+		iterations := base
+		iterations.MaxAgentIterations = 3
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{iterations}))
+
+		duration := base
+		duration.MaxAgentDurationSeconds = 30
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{duration}))
+
+		budget := base
+		budget.AgentTokenBudget = 10000
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{budget}))
+	})
+
+	t.Run("changing memory settings recreates the bot", func(t *testing.T) {
+		memory := base
+		memory.EnableMemory = true
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{memory}))
+
+		budget := base
+		budget.MemoryTokenBudget = 200
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{budget}))
+	})
+
+	t.Run("changing auto response rules recreates the bot", func(t *testing.T) {
+		rules := base
+		rules.AutoResponseRules = []llm.AutoResponseRule{{Pattern: "(?i)vpn"}}
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{rules}))
+	})
+
+	t.Run("changing delegation recreates the bot", func(t *testing.T) {
+		description := base
+		description.Description = "Answers questions about HR policies"
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{description}))
+
+		delegates := base
+		delegates.DelegateBots = []string{"hr"}
+		require.False(t, botConfigsEqual([]llm.BotConfig{base}, []llm.BotConfig{delegates}))
+
+		router := delegates
+		router.RouterMode = true
+		require.False(t, botConfigsEqual([]llm.BotConfig{delegates}, []llm.BotConfig{router}))
+	})
+}
@@ -4,6 +4,7 @@
 package channels
 
 import (
+	"context"
 	"slices"
 
 	"github.com/mattermost/mattermost-plugin-ai/format"
@@ -35,6 +36,7 @@ func New(
 }
 
 func (c *Channels) Interval(
+	ctx context.Context,
 	context *llm.Context,
 	channelID string,
 	startTime int64,
@@ -91,7 +93,7 @@ func (c *Channels) Interval(
 		Context: context,
 	}
 
-	resultStream, err := c.llm.ChatCompletion(completionRequest, llm.WithToolsDisabled())
+	resultStream, err := c.llm.ChatCompletion(ctx, completionRequest, llm.WithToolsDisabled(), llm.WithTask(llm.TaskSummary))
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +74,7 @@ func TestChannelSummarization(t *testing.T) {
 			ctx.Team = threadData.Team
 
 			// Perform summarization based on type
-			textStream, err := channelService.Interval(ctx, threadData.Channel.Id, fixedStart, 0, prompts.PromptSummarizeChannelRangeSystem)
+			textStream, err := channelService.Interval(t.Context(), ctx, threadData.Channel.Id, fixedStart, 0, prompts.PromptSummarizeChannelRangeSystem)
 			require.NoError(t, err, "Failed to summarize channel")
 			require.NotNil(t, textStream, "Expected a non-nil text stream")
 
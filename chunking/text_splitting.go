@@ -5,6 +5,9 @@ package chunking
 
 import (
 	"strings"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
 )
 
 // SplitPlaintextOnSentences splits a string into chunks of the given size.
@@ -33,3 +36,32 @@ func SplitPlaintextOnSentences(text string, chunksize int) []string {
 
 	return chunks
 }
+
+// SplitPlaintextOnSentencesByTokens splits a string into chunks of at most maxTokens tokens as measured by countTokens.
+// Like SplitPlaintextOnSentences it prefers to split on sentence endings as long as that keeps the chunk
+// at least 3/4 of the maximum size. Text is only ever split on rune boundaries.
+func SplitPlaintextOnSentencesByTokens(text string, maxTokens int, countTokens func(string) int) []string {
+	chunks := []string{}
+	remainingText := text
+
+	for countTokens(remainingText) > maxTokens {
+		chunk := llm.KeepFirstTokens(remainingText, maxTokens, countTokens)
+		if chunk == "" {
+			// A single rune exceeds the limit, take it anyway to make progress
+			_, size := utf8.DecodeRuneInString(remainingText)
+			chunk = remainingText[:size]
+		}
+
+		sentenceEnd := strings.LastIndexAny(chunk, ".!?")
+		if sentenceEnd != -1 && sentenceEnd >= len(chunk)*3/4 {
+			chunk = chunk[:sentenceEnd+1]
+		}
+
+		chunks = append(chunks, strings.TrimSpace(chunk))
+		remainingText = strings.TrimSpace(remainingText[len(chunk):])
+	}
+
+	chunks = append(chunks, remainingText)
+
+	return chunks
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -113,3 +114,41 @@ func TestSplitPlaintextOnSentences(t *testing.T) {
 		}
 	})
 }
+
+func TestSplitPlaintextOnSentencesByTokens(t *testing.T) {
+	countTokens := func(text string) int {
+		return len(strings.Fields(text))
+	}
+
+	t.Run("Fits in a single chunk", func(t *testing.T) {
+		chunks := SplitPlaintextOnSentencesByTokens("Hello there. How are you?", 10, countTokens)
+		assert.Equal(t, []string{"Hello there. How are you?"}, chunks)
+	})
+
+	t.Run("Splits on sentence boundaries", func(t *testing.T) {
+		input := "One two three four. Five six seven eight. Nine ten eleven twelve."
+		chunks := SplitPlaintextOnSentencesByTokens(input, 5, countTokens)
+		assert.Equal(t, []string{"One two three four.", "Five six seven eight.", "Nine ten eleven twelve."}, chunks)
+	})
+
+	t.Run("Chunks stay within the token limit", func(t *testing.T) {
+		input := strings.Repeat("word ", 103)
+		chunks := SplitPlaintextOnSentencesByTokens(input, 10, countTokens)
+		for i, chunk := range chunks {
+			assert.LessOrEqual(t, countTokens(chunk), 10, "Chunk %d exceeds the token limit", i)
+		}
+		assert.Equal(t, 103, countTokens(strings.Join(chunks, " ")))
+	})
+
+	t.Run("Multi-byte text is split on rune boundaries", func(t *testing.T) {
+		input := strings.Repeat("日本語のテキスト", 50)
+		chunks := SplitPlaintextOnSentencesByTokens(input, 10, func(text string) int {
+			return len(text) / 4
+		})
+		require.Greater(t, len(chunks), 1)
+		for _, chunk := range chunks {
+			assert.True(t, utf8.ValidString(chunk))
+		}
+		assert.Equal(t, input, strings.Join(chunks, ""))
+	})
+}
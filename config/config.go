@@ -13,6 +13,10 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/mcp"
 	"github.com/mattermost/mattermost-plugin-ai/openai"
+	"github.com/mattermost/mattermost-plugin-ai/quota"
+	"github.com/mattermost/mattermost-plugin-ai/redaction"
+	"github.com/mattermost/mattermost-plugin-ai/responsecache"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 )
 
 type Config struct {
@@ -26,6 +30,11 @@ type Config struct {
 	AllowUnsafeLinks         bool                             `json:"allowUnsafeLinks"`
 	EmbeddingSearchConfig    embeddings.EmbeddingSearchConfig `json:"embeddingSearchConfig"`
 	MCP                      mcp.Config                       `json:"mcp"`
+	TokenQuotas              []quota.Limit                    `json:"tokenQuotas"`
+	ModelPrices              []llm.ModelPrice                 `json:"modelPrices"`
+	ResponseCache            responsecache.Config             `json:"responseCache"`
+	Redaction                redaction.Config                 `json:"redaction"`
+	Tracing                  tracing.Config                   `json:"tracing"`
 }
 
 func (c *Config) Clone() *Config {
@@ -72,6 +81,26 @@ func (c *Container) GetBots() []llm.BotConfig {
 	return c.cfg.Load().Bots
 }
 
+func (c *Container) GetTokenQuotas() []quota.Limit {
+	return c.cfg.Load().TokenQuotas
+}
+
+func (c *Container) GetModelPrices() []llm.ModelPrice {
+	return c.cfg.Load().ModelPrices
+}
+
+func (c *Container) GetResponseCacheConfig() responsecache.Config {
+	return c.cfg.Load().ResponseCache
+}
+
+func (c *Container) GetRedactionConfig() redaction.Config {
+	return c.cfg.Load().Redaction
+}
+
+func (c *Container) GetTracingConfig() tracing.Config {
+	return c.cfg.Load().Tracing
+}
+
 func (c *Container) GetDefaultBotName() string {
 	return c.cfg.Load().DefaultBotName
 }
@@ -148,8 +177,11 @@ func DeepCopyJSON[T any](src T) (T, error) {
 	return dst, err
 }
 
+// DefaultStreamingTimeout is used when a service does not configure StreamingTimeoutSeconds.
+const DefaultStreamingTimeout = 30 * time.Second
+
 func OpenAIConfigFromServiceConfig(serviceConfig llm.ServiceConfig, botConfig llm.BotConfig) openai.Config {
-	streamingTimeout := time.Second * 30
+	streamingTimeout := DefaultStreamingTimeout
 	if serviceConfig.StreamingTimeoutSeconds > 0 {
 		streamingTimeout = time.Duration(serviceConfig.StreamingTimeoutSeconds) * time.Second
 	}
@@ -0,0 +1,183 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+)
+
+const (
+	defaultMaxAgentIterations = 10
+	defaultMaxAgentDuration   = 5 * time.Minute
+)
+
+// agentLimits bound how long a response resolves tools on its own. Once a limit is reached,
+// the remaining tool calls are left to the user to approve.
+type agentLimits struct {
+	maxIterations int
+	deadline      time.Time
+	tokenBudget   int64
+}
+
+func newAgentLimits(botConfig llm.BotConfig) agentLimits {
+	limits := agentLimits{
+		maxIterations: defaultMaxAgentIterations,
+		deadline:      time.Now().Add(defaultMaxAgentDuration),
+		tokenBudget:   botConfig.AgentTokenBudget,
+	}
+	if botConfig.MaxAgentIterations > 0 {
+		limits.maxIterations = botConfig.MaxAgentIterations
+	}
+	if botConfig.MaxAgentDurationSeconds > 0 {
+		limits.deadline = time.Now().Add(time.Duration(botConfig.MaxAgentDurationSeconds) * time.Second)
+	}
+	return limits
+}
+
+// allow reports whether the response can make another request to the model after making
+// iterations requests that used tokensUsed tokens.
+func (l agentLimits) allow(iterations int, tokensUsed int64) bool {
+	if iterations >= l.maxIterations || time.Now().After(l.deadline) {
+		return false
+	}
+	return l.tokenBudget <= 0 || tokensUsed < l.tokenBudget
+}
+
+// runAgentLoop passes the events of result through. When the model calls tools that don't need
+// the user's approval, the calls are resolved and the response continues with their results in the
+// same stream, preceded by an agent step event with the resolved calls. This repeats until the model
+// answers without calling tools, calls tools that need approval, or a limit of the bot is reached.
+// Tool calls left to the user are sent last, once the model's response is complete.
+func (c *Conversations) runAgentLoop(ctx context.Context, bot *bots.Bot, request llm.CompletionRequest, result *llm.TextStreamResult, opts ...llm.LanguageModelOption) *llm.TextStreamResult {
+	if request.Context == nil || request.Context.Tools == nil {
+		return result
+	}
+
+	// Later requests continue the response with tool results, which can be routed to another model
+	followupOpts := append(slices.Clone(opts), llm.WithTask(llm.TaskToolFollowup))
+
+	output := make(chan llm.TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		botConfig := bot.GetConfig()
+		limits := newAgentLimits(botConfig)
+		posts := request.Posts
+		stream := result.Stream
+		var tokensUsed int64
+		for iterations := 1; ; iterations++ {
+			var message strings.Builder
+			var reasoning llm.ReasoningData
+			var toolCalls []llm.ToolCall
+			for event := range stream {
+				switch event.Type {
+				case llm.EventTypeText:
+					if text, ok := event.Value.(string); ok {
+						message.WriteString(text)
+					}
+				case llm.EventTypeReasoningEnd:
+					if data, ok := event.Value.(llm.ReasoningData); ok {
+						reasoning = data
+					}
+				case llm.EventTypeUsage:
+					if usage, ok := event.Value.(llm.TokenUsage); ok {
+						tokensUsed += usage.InputTokens + usage.OutputTokens
+					}
+				case llm.EventTypeToolCalls:
+					// Held back until the response is complete, the stream ends with them
+					if calls, ok := event.Value.([]llm.ToolCall); ok {
+						toolCalls = calls
+						continue
+					}
+				case llm.EventTypeEnd:
+					if toolCalls != nil {
+						continue
+					}
+				}
+				output <- event
+			}
+			if toolCalls == nil || ctx.Err() != nil {
+				return
+			}
+
+			needsApproval := applyToolPolicies(botConfig, request.Context.Tools, toolCalls)
+			if needsApproval || !limits.allow(iterations, tokensUsed) {
+				for i := range toolCalls {
+					if toolCalls[i].Status == llm.ToolCallStatusAccepted {
+						toolCalls[i].Status = llm.ToolCallStatusPending
+					}
+				}
+				output <- llm.TextStreamEvent{Type: llm.EventTypeToolCalls, Value: toolCalls}
+				return
+			}
+
+			resolveToolCalls(ctx, bot, request.Context, toolCalls)
+			if botIDs := c.delegatedBotIDs(toolCalls); len(botIDs) > 0 {
+				output <- llm.TextStreamEvent{Type: llm.EventTypeDelegation, Value: botIDs}
+			}
+			step := llm.AgentStep{
+				Message:            message.String(),
+				Reasoning:          reasoning.Text,
+				ReasoningSignature: reasoning.Signature,
+				ToolCalls:          toolCalls,
+			}
+			posts = append(posts, agentStepPost(step))
+			output <- llm.TextStreamEvent{Type: llm.EventTypeAgentStep, Value: step}
+
+			next, err := bot.LLM().ChatCompletion(ctx, llm.CompletionRequest{
+				Posts:   posts,
+				Context: request.Context,
+			}, followupOpts...)
+			if err != nil {
+				output <- llm.TextStreamEvent{Type: llm.EventTypeError, Value: err}
+				return
+			}
+			stream = next.Stream
+		}
+	}()
+
+	return &llm.TextStreamResult{Stream: output}
+}
+
+// agentStepPost returns the bot post of the conversation with the model for a completed step.
+func agentStepPost(step llm.AgentStep) llm.Post {
+	return llm.Post{
+		Role:               llm.PostRoleBot,
+		Message:            step.Message,
+		ToolUse:            step.ToolCalls,
+		Reasoning:          step.Reasoning,
+		ReasoningSignature: step.ReasoningSignature,
+	}
+}
+
+// currentAgentStep returns the part of a response post that follows its completed steps: the text
+// written after them, the tool calls they didn't resolve and reasoning that isn't theirs.
+func currentAgentStep(post llm.Post, steps []llm.AgentStep) llm.Post {
+	if len(steps) == 0 {
+		return post
+	}
+
+	resolved := make(map[string]bool)
+	for _, step := range steps {
+		for _, toolCall := range step.ToolCalls {
+			resolved[toolCall.ID] = true
+		}
+		if step.Reasoning == post.Reasoning && step.ReasoningSignature == post.ReasoningSignature {
+			post.Reasoning = ""
+			post.ReasoningSignature = ""
+		}
+	}
+	post.Message = streaming.TrimAgentSteps(post.Message, steps)
+	post.ToolUse = slices.DeleteFunc(slices.Clone(post.ToolUse), func(toolCall llm.ToolCall) bool {
+		return resolved[toolCall.ID]
+	})
+	return post
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func eventStream(events ...llm.TextStreamEvent) *llm.TextStreamResult {
+	stream := make(chan llm.TextStreamEvent, len(events))
+	for _, event := range events {
+		stream <- event
+	}
+	close(stream)
+	return &llm.TextStreamResult{Stream: stream}
+}
+
+func readEvents(result *llm.TextStreamResult) []llm.TextStreamEvent {
+	var events []llm.TextStreamEvent
+	for event := range result.Stream {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestRunAgentLoop(t *testing.T) {
+	botConfig := llm.BotConfig{
+		Name: "bot",
+		ToolPolicies: []llm.ToolPolicyRule{
+			{Tool: "search", Policy: llm.ToolPolicyAutoApprove},
+		},
+	}
+
+	newRequest := func() llm.CompletionRequest {
+		llmContext := llm.NewContext()
+		llmContext.Tools = llm.NewNoTools()
+		llmContext.Tools.AddTools([]llm.Tool{
+			{
+				Name: "search",
+				Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+					return "3 results", nil
+				},
+			},
+			{
+				Name: "post",
+				Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+					return "posted", nil
+				},
+			},
+		})
+		return llm.CompletionRequest{
+			Posts:   []llm.Post{{Role: llm.PostRoleUser, Message: "Find the release notes"}},
+			Context: llmContext,
+		}
+	}
+
+	searchCall := func(id string) llm.TextStreamEvent {
+		return llm.TextStreamEvent{Type: llm.EventTypeToolCalls, Value: []llm.ToolCall{{ID: id, Name: "search", Arguments: []byte(`{}`)}}}
+	}
+	usage := llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 100, OutputTokens: 10}}
+	end := llm.TextStreamEvent{Type: llm.EventTypeEnd}
+
+	t.Run("auto-approved tools continue the response", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, request llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			require.Len(t, request.Posts, 2)
+			toolPost := request.Posts[1]
+			assert.Equal(t, llm.PostRoleBot, toolPost.Role)
+			assert.Equal(t, "Searching.", toolPost.Message)
+			require.Len(t, toolPost.ToolUse, 1)
+			assert.Equal(t, llm.ToolCallStatusSuccess, toolPost.ToolUse[0].Status)
+			assert.Equal(t, "3 results", toolPost.ToolUse[0].Result)
+			return eventStream(llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Found them."}, end), nil
+		}).Once()
+		bot := bots.NewBot(botConfig, llm.ServiceConfig{}, &model.Bot{}, mockLLM)
+
+		c := &Conversations{}
+		events := readEvents(c.runAgentLoop(t.Context(), bot, newRequest(), eventStream(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Searching."},
+			searchCall("1"),
+			usage,
+			end,
+		)))
+
+		require.Len(t, events, 5)
+		assert.Equal(t, llm.EventTypeText, events[0].Type)
+		assert.Equal(t, usage, events[1])
+		require.Equal(t, llm.EventTypeAgentStep, events[2].Type)
+		step := events[2].Value.(llm.AgentStep)
+		assert.Equal(t, "Searching.", step.Message)
+		require.Len(t, step.ToolCalls, 1)
+		assert.Equal(t, "3 results", step.ToolCalls[0].Result)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Found them."}, events[3])
+		assert.Equal(t, end, events[4])
+	})
+
+	t.Run("tool calls that need approval end the response", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		bot := bots.NewBot(botConfig, llm.ServiceConfig{}, &model.Bot{}, mockLLM)
+
+		c := &Conversations{}
+		events := readEvents(c.runAgentLoop(t.Context(), bot, newRequest(), eventStream(
+			llm.TextStreamEvent{Type: llm.EventTypeToolCalls, Value: []llm.ToolCall{
+				{ID: "1", Name: "search"},
+				{ID: "2", Name: "post"},
+			}},
+			usage,
+			end,
+		)))
+
+		// The tool calls are sent after the rest of the response, in place of its end
+		require.Len(t, events, 2)
+		assert.Equal(t, usage, events[0])
+		require.Equal(t, llm.EventTypeToolCalls, events[1].Type)
+		toolCalls := events[1].Value.([]llm.ToolCall)
+		assert.Equal(t, llm.ToolCallStatusPending, toolCalls[0].Status)
+		assert.Equal(t, llm.ToolCallStatusPending, toolCalls[1].Status)
+		// Nothing is resolved until the user decides
+		assert.Empty(t, toolCalls[0].Result)
+	})
+
+	t.Run("limits leave tool calls to the user", func(t *testing.T) {
+		for name, config := range map[string]llm.BotConfig{
+			"iterations":   {ToolPolicies: botConfig.ToolPolicies, MaxAgentIterations: 2},
+			"token budget": {ToolPolicies: botConfig.ToolPolicies, AgentTokenBudget: 200},
+		} {
+			t.Run(name, func(t *testing.T) {
+				mockLLM := mocks.NewMockLanguageModel(t)
+				mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(eventStream(searchCall("2"), usage, end), nil).Once()
+				bot := bots.NewBot(config, llm.ServiceConfig{}, &model.Bot{}, mockLLM)
+
+				c := &Conversations{}
+				events := readEvents(c.runAgentLoop(t.Context(), bot, newRequest(), eventStream(searchCall("1"), usage, end)))
+
+				require.Len(t, events, 4)
+				assert.Equal(t, llm.EventTypeAgentStep, events[1].Type)
+				require.Equal(t, llm.EventTypeToolCalls, events[3].Type)
+				toolCalls := events[3].Value.([]llm.ToolCall)
+				assert.Equal(t, "2", toolCalls[0].ID)
+				assert.Equal(t, llm.ToolCallStatusPending, toolCalls[0].Status)
+			})
+		}
+	})
+}
+
+func TestCurrentAgentStep(t *testing.T) {
+	post := &model.Post{Message: "Let me search."}
+	post.AddProp(streaming.ReasoningSummaryProp, "The user wants results")
+	require.NoError(t, streaming.AddAgentStep(post, llm.AgentStep{
+		Message:   post.Message,
+		Reasoning: "The user wants results",
+		ToolCalls: []llm.ToolCall{{ID: "search", Name: "search", Status: llm.ToolCallStatusSuccess, Result: "3 results"}},
+	}))
+	post.Message += "\n\nFound 3 results, posting them."
+	require.NoError(t, streaming.SetToolCalls(post, []llm.ToolCall{{ID: "post", Name: "post", Status: llm.ToolCallStatusPending}}))
+
+	steps, err := streaming.GetAgentSteps(post)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+
+	var toolCalls []llm.ToolCall
+	require.NoError(t, json.Unmarshal([]byte(post.GetProp(streaming.ToolCallProp).(string)), &toolCalls))
+	require.Len(t, toolCalls, 2, "the tool calls of completed steps stay on the post")
+
+	current := currentAgentStep(llm.Post{
+		Role:      llm.PostRoleBot,
+		Message:   post.Message,
+		ToolUse:   toolCalls,
+		Reasoning: post.GetProp(streaming.ReasoningSummaryProp).(string),
+	}, steps)
+	assert.Equal(t, "Found 3 results, posting them.", current.Message)
+	require.Len(t, current.ToolUse, 1)
+	assert.Equal(t, "post", current.ToolUse[0].ID)
+	assert.Empty(t, current.Reasoning, "the reasoning belongs to the completed step")
+}
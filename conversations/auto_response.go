@@ -0,0 +1,207 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/prompts"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// AutoResponseProp marks responses to messages the bot answered without being mentioned
+	AutoResponseProp = "auto_response"
+	// AutoResponseFeedbackProp is whether the user who asked found an auto response helpful
+	AutoResponseFeedbackProp = "auto_response_feedback"
+
+	AutoResponseFeedbackHelpful    = "helpful"
+	AutoResponseFeedbackNotHelpful = "not_helpful"
+)
+
+// intentClassification is how the LLM classifies a message against the intent of an auto response rule.
+type intentClassification struct {
+	Matches    bool    `json:"matches" jsonschema_description:"Whether the message is the kind of question described"`
+	Confidence float64 `json:"confidence" jsonschema_description:"How confident the classification is, between 0 and 1"`
+}
+
+// autoResponseLimiter counts the auto responses of each bot in each channel over the last hour. The
+// counts are kept in memory, so each server of a cluster limits only the responses it posts.
+type autoResponseLimiter struct {
+	mu        sync.Mutex
+	responses map[string][]time.Time
+}
+
+// recent returns the responses of key in the hour before now. The caller must hold the lock.
+func (l *autoResponseLimiter) recent(key string, now time.Time) []time.Time {
+	responses := l.responses[key]
+	for len(responses) > 0 && now.Sub(responses[0]) >= time.Hour {
+		responses = responses[1:]
+	}
+	return responses
+}
+
+// full reports whether key reached limit responses in the hour before now.
+func (l *autoResponseLimiter) full(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.recent(key, now)) >= limit
+}
+
+// take records a response of key at now, unless key already reached limit responses in the hour before.
+func (l *autoResponseLimiter) take(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	responses := l.recent(key, now)
+	if len(responses) >= limit {
+		return false
+	}
+	if l.responses == nil {
+		l.responses = make(map[string][]time.Time)
+	}
+	l.responses[key] = append(responses, now)
+	return true
+}
+
+// getAutoResponseRule returns the first bot with a rule answering post on its own, and the rule.
+func (c *Conversations) getAutoResponseRule(post *model.Post, channel *model.Channel, now time.Time) (*bots.Bot, llm.AutoResponseRule) {
+	// Only questions starting a thread are answered, replies have to mention the bot
+	if post.RootId != "" {
+		return nil, llm.AutoResponseRule{}
+	}
+
+	for _, bot := range c.bots.GetAllBots() {
+		for _, rule := range bot.GetConfig().AutoResponseRules {
+			if !rule.Watches(channel.Id) {
+				continue
+			}
+			matches, err := rule.MatchesMessage(post.Message)
+			if err != nil {
+				c.mmClient.LogError("Skipping auto response rule", "bot_name", bot.GetConfig().Name, "error", err)
+				continue
+			}
+			active, err := rule.IsActive(now)
+			if err != nil {
+				c.mmClient.LogError("Skipping auto response rule", "bot_name", bot.GetConfig().Name, "error", err)
+				continue
+			}
+			if matches && active {
+				return bot, rule
+			}
+		}
+	}
+	return nil, llm.AutoResponseRule{}
+}
+
+func (c *Conversations) handleAutoResponse(bot *bots.Bot, rule llm.AutoResponseRule, post *model.Post, postingUser *model.User, channel *model.Channel) error {
+	// Users and channels the bot can't respond to don't get an answer either
+	if err := c.bots.CheckUsageRestrictions(postingUser.Id, bot, channel); err != nil {
+		return fmt.Errorf("not auto responding: %w: %w", err, ErrNoResponse)
+	}
+
+	limiterKey := bot.GetMMBot().UserId + ":" + channel.Id
+	if c.autoResponses.full(limiterKey, rule.ResponsesPerHour(), time.Now()) {
+		return fmt.Errorf("auto response limit of the channel reached: %w", ErrNoResponse)
+	}
+
+	// Classifying the message waits on the LLM, which shouldn't hold up the hook
+	go func() {
+		if err := c.autoRespond(bot, rule, limiterKey, post, postingUser, channel); err != nil {
+			if errors.Is(err, ErrNoResponse) {
+				c.mmClient.LogDebug(err.Error(), "post_id", post.Id)
+				return
+			}
+			c.mmClient.LogError("Failed to auto respond", "post_id", post.Id, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// autoRespond answers post if it matches the intent of rule and the channel is below the limit of the rule.
+func (c *Conversations) autoRespond(bot *bots.Bot, rule llm.AutoResponseRule, limiterKey string, post *model.Post, postingUser *model.User, channel *model.Channel) error {
+	ctx, cancel := c.streamingService.NewRequestContext()
+	ctx, span := tracing.Start(ctx, "conversations.handleAutoResponse")
+	defer span.End()
+
+	if rule.Intent != "" {
+		classification, err := c.classifyIntent(ctx, bot, rule, post, postingUser, channel)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("unable to classify message for auto response: %w", err)
+		}
+		if !classification.Matches || classification.Confidence < rule.Confidence() {
+			cancel()
+			return fmt.Errorf("message doesn't match the auto response intent with confidence %.2f: %w", classification.Confidence, ErrNoResponse)
+		}
+	}
+
+	if !c.autoResponses.take(limiterKey, rule.ResponsesPerHour(), time.Now()) {
+		cancel()
+		return fmt.Errorf("auto response limit of the channel reached: %w", ErrNoResponse)
+	}
+
+	stream, err := c.ProcessUserRequest(ctx, bot, postingUser, channel, post)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("unable to process auto response: %w", err)
+	}
+
+	responsePost := &model.Post{
+		ChannelId: channel.Id,
+		RootId:    post.Id,
+	}
+	responsePost.AddProp(AutoResponseProp, true)
+	if err := c.streamingService.StreamToNewPost(ctx, bot.GetMMBot().UserId, postingUser.Id, stream, responsePost, post.Id); err != nil {
+		cancel()
+		return fmt.Errorf("unable to stream response: %w", err)
+	}
+
+	return nil
+}
+
+// classifyIntent asks the LLM whether post is the kind of question rule answers.
+func (c *Conversations) classifyIntent(ctx context.Context, bot *bots.Bot, rule llm.AutoResponseRule, post *model.Post, postingUser *model.User, channel *model.Channel) (intentClassification, error) {
+	llmContext := c.contextBuilder.BuildLLMContextUserRequest(bot, postingUser, channel)
+	llmContext.Parameters = map[string]any{"Intent": rule.Intent}
+
+	prompt, err := c.prompts.Format(prompts.PromptAutoResponseClassifySystem, llmContext)
+	if err != nil {
+		return intentClassification{}, fmt.Errorf("failed to format prompt: %w", err)
+	}
+
+	return llm.CompleteJSON[intentClassification](ctx, bot.LLM(), llm.CompletionRequest{
+		Posts: []llm.Post{
+			{
+				Role:    llm.PostRoleSystem,
+				Message: prompt,
+			},
+			{
+				Role:    llm.PostRoleUser,
+				Message: post.Message,
+			},
+		},
+		Context: llmContext,
+	}, llm.WithMaxGeneratedTokens(500), llm.WithReasoningDisabled(), llm.WithToolsDisabled(), llm.WithTask(llm.TaskClassify))
+}
+
+// SetAutoResponseFeedback records whether the user who asked found the auto response post helpful.
+func (c *Conversations) SetAutoResponseFeedback(post *model.Post, helpful bool) error {
+	feedback := AutoResponseFeedbackNotHelpful
+	if helpful {
+		feedback = AutoResponseFeedbackHelpful
+	}
+	post.AddProp(AutoResponseFeedbackProp, feedback)
+	if err := c.mmClient.UpdatePost(post); err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoResponseLimiter(t *testing.T) {
+	var limiter autoResponseLimiter
+	now := time.Now()
+
+	assert.True(t, limiter.take("bot:support", 2, now))
+	assert.True(t, limiter.take("bot:support", 2, now.Add(10*time.Minute)))
+	assert.True(t, limiter.full("bot:support", 2, now.Add(20*time.Minute)))
+	assert.False(t, limiter.take("bot:support", 2, now.Add(20*time.Minute)))
+	assert.True(t, limiter.take("bot:other", 2, now.Add(20*time.Minute)), "channels are limited separately")
+
+	assert.False(t, limiter.full("bot:support", 2, now.Add(time.Hour)), "responses older than an hour don't count")
+	assert.True(t, limiter.take("bot:support", 2, now.Add(time.Hour)))
+}
+
+func TestGetAutoResponseRule(t *testing.T) {
+	e := SetupTestEnvironment(t)
+	defer e.Cleanup(t)
+
+	vpnRule := llm.AutoResponseRule{ChannelIDs: []string{"support"}, Pattern: `(?i)vpn`}
+	hrRule := llm.AutoResponseRule{
+		ChannelIDs:    []string{"support"},
+		BusinessHours: &llm.BusinessHours{Start: "09:00", End: "17:00"},
+	}
+	e.bots.SetBotsForTesting([]*bots.Bot{
+		bots.NewBot(llm.BotConfig{Name: "it", AutoResponseRules: []llm.AutoResponseRule{vpnRule}}, llm.ServiceConfig{}, &model.Bot{UserId: "itbot"}, nil),
+		bots.NewBot(llm.BotConfig{Name: "hr", AutoResponseRules: []llm.AutoResponseRule{hrRule}}, llm.ServiceConfig{}, &model.Bot{UserId: "hrbot"}, nil),
+	})
+	support := &model.Channel{Id: "support"}
+	afternoon := time.Date(2024, time.March, 6, 14, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, time.March, 6, 20, 0, 0, 0, time.UTC)
+
+	t.Run("the first matching rule answers", func(t *testing.T) {
+		bot, rule := e.conversations.getAutoResponseRule(&model.Post{Message: "Is the VPN down?"}, support, afternoon)
+		require.NotNil(t, bot)
+		assert.Equal(t, "it", bot.GetConfig().Name)
+		assert.Equal(t, vpnRule, rule)
+
+		bot, _ = e.conversations.getAutoResponseRule(&model.Post{Message: "How many vacation days do I have?"}, support, afternoon)
+		require.NotNil(t, bot)
+		assert.Equal(t, "hr", bot.GetConfig().Name)
+	})
+
+	t.Run("no rule answers outside its business hours", func(t *testing.T) {
+		bot, _ := e.conversations.getAutoResponseRule(&model.Post{Message: "How many vacation days do I have?"}, support, evening)
+		assert.Nil(t, bot)
+	})
+
+	t.Run("no rule answers replies or other channels", func(t *testing.T) {
+		bot, _ := e.conversations.getAutoResponseRule(&model.Post{Message: "Is the VPN down?", RootId: "root"}, support, afternoon)
+		assert.Nil(t, bot)
+		bot, _ = e.conversations.getAutoResponseRule(&model.Post{Message: "Is the VPN down?"}, &model.Channel{Id: "town-square"}, afternoon)
+		assert.Nil(t, bot)
+	})
+
+	t.Run("channels the bot can't respond in stay silent", func(t *testing.T) {
+		bot := bots.NewBot(llm.BotConfig{Name: "it", ChannelAccessLevel: llm.ChannelAccessLevelNone}, llm.ServiceConfig{}, &model.Bot{UserId: "itbot"}, nil)
+		err := e.conversations.handleAutoResponse(bot, vpnRule, &model.Post{Message: "Is the VPN down?"}, &model.User{Id: "user"}, support)
+		require.ErrorIs(t, err, ErrNoResponse)
+	})
+}
@@ -4,11 +4,13 @@
 package conversations
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-ai/bots"
 	"github.com/mattermost/mattermost-plugin-ai/enterprise"
@@ -21,13 +23,18 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
 	"github.com/mattermost/mattermost-plugin-ai/subtitles"
 	"github.com/mattermost/mattermost-plugin-ai/threads"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const ThreadIDProp = "referenced_thread"
 const AnalysisTypeProp = "prompt_type"
 
+// titleTimeout limits generating the title of a conversation, which continues after the response
+const titleTimeout = time.Minute
+
 // AIThread represents a user's conversation with an AI
 type AIThread struct {
 	ID         string `json:"id"`
@@ -48,12 +55,13 @@ type Conversations struct {
 	licenseChecker   *enterprise.LicenseChecker
 	i18n             *i18n.Bundle
 	meetingsService  MeetingsService
+	autoResponses    autoResponseLimiter
 }
 
 // MeetingsService defines the interface for meetings functionality needed by conversations
 type MeetingsService interface {
 	GetCaptionsFileIDFromProps(post *model.Post) (fileID string, err error)
-	SummarizeTranscription(bot *bots.Bot, transcription *subtitles.Subtitles, context *llm.Context) (*llm.TextStreamResult, error)
+	SummarizeTranscription(ctx context.Context, bot *bots.Bot, transcription *subtitles.Subtitles, context *llm.Context) (*llm.TextStreamResult, error)
 }
 
 func New(
@@ -86,14 +94,23 @@ func (c *Conversations) SetMeetingsService(meetingsService MeetingsService) {
 }
 
 // ProcessUserRequestWithContext is an internal helper that uses an existing context to process a message
-func (c *Conversations) ProcessUserRequestWithContext(bot *bots.Bot, postingUser *model.User, channel *model.Channel, post *model.Post, context *llm.Context) (*llm.TextStreamResult, error) {
-	isDM := mmapi.IsDMWith(bot.GetMMBot().UserId, channel)
+func (c *Conversations) ProcessUserRequestWithContext(ctx context.Context, bot *bots.Bot, postingUser *model.User, channel *model.Channel, post *model.Post, context *llm.Context) (*llm.TextStreamResult, error) {
+	isDM := c.isBotDM(bot, channel)
 	var disabledToolsInfo []llm.ToolInfo
 	if !isDM && context != nil && context.Tools != nil {
 		disabledToolsInfo = context.Tools.GetToolsInfo()
 	}
 	if context != nil {
 		context.DisabledToolsInfo = disabledToolsInfo
+		context.RootPostID = post.RootId
+		if context.RootPostID == "" {
+			context.RootPostID = post.Id
+		}
+		context.Question = post.Message
+		// Memories are private to the user, so they are only shared in DMs with the bot
+		if isDM && c.contextBuilder != nil {
+			c.contextBuilder.WithLLMContextMemories(bot)(context)
+		}
 	}
 
 	var posts []llm.Post
@@ -130,19 +147,25 @@ func (c *Conversations) ProcessUserRequestWithContext(bot *bots.Bot, postingUser
 		Posts:   posts,
 		Context: context,
 	}
-	var opts []llm.LanguageModelOption
+	opts := []llm.LanguageModelOption{llm.WithTask(llm.TaskChat)}
 	if !isDM {
 		// In non-DM channels, disable tools for security but provide info about DM-only tools
 		opts = append(opts, llm.WithToolsDisabled())
 	}
-	result, err := bot.LLM().ChatCompletion(completionRequest, opts...)
+	result, err := bot.LLM().ChatCompletion(ctx, completionRequest, opts...)
 	if err != nil {
 		return nil, err
 	}
+	if isDM {
+		result = c.runAgentLoop(ctx, bot, completionRequest, result, opts...)
+	}
 
+	// The request context is canceled once the response finishes streaming
+	titleCtx, cancelTitle := titleContext(ctx)
 	go func() {
+		defer cancelTitle()
 		request := "Write a short title for the following request. Include only the title and nothing else, no quotations. Request:\n" + post.Message
-		if err := c.GenerateTitle(bot, request, post.Id, context); err != nil {
+		if err := c.GenerateTitle(titleCtx, bot, request, post.Id, context); err != nil {
 			c.mmClient.LogError("Failed to generate title", "error", err.Error())
 			return
 		}
@@ -151,16 +174,34 @@ func (c *Conversations) ProcessUserRequestWithContext(bot *bots.Bot, postingUser
 	return result, nil
 }
 
+// titleContext returns the context to generate a title in, which keeps the values of ctx but
+// isn't canceled with it.
+func titleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), titleTimeout)
+}
+
 // ProcessUserRequest processes a user request to a bot
-func (c *Conversations) ProcessUserRequest(bot *bots.Bot, postingUser *model.User, channel *model.Channel, post *model.Post) (*llm.TextStreamResult, error) {
-	// Create a context with tools for LLM awareness
-	// Security restriction is enforced later via WithToolsDisabled based on channel type
+func (c *Conversations) ProcessUserRequest(ctx context.Context, bot *bots.Bot, postingUser *model.User, channel *model.Channel, post *model.Post) (*llm.TextStreamResult, error) {
+	ctx, span := tracing.Start(ctx, "conversations.ProcessUserRequest",
+		attribute.String("bot", bot.GetConfig().Name),
+		attribute.String("channel_id", channel.Id),
+		attribute.String("post_id", post.Id),
+	)
+	start := time.Now()
+
+	_, buildSpan := tracing.Start(ctx, "llmcontext.BuildLLMContextUserRequest")
 	context := c.contextBuilder.BuildLLMContextUserRequest(
 		bot,
 		postingUser,
 		channel,
-		c.contextBuilder.WithLLMContextTools(bot),
 	)
+	buildSpan.End()
+
+	// Add tools for LLM awareness, traced separately as connecting to MCP servers can be slow
+	// Security restriction is enforced later via WithToolsDisabled based on channel type
+	_, toolsSpan := tracing.Start(ctx, "llmcontext.WithLLMContextTools")
+	c.contextBuilder.WithLLMContextTools(bot)(context)
+	toolsSpan.End()
 
 	// Check for auth errors in the tool store
 	if context.Tools != nil {
@@ -170,16 +211,23 @@ func (c *Conversations) ProcessUserRequest(bot *bots.Bot, postingUser *model.Use
 		}
 	}
 
-	return c.ProcessUserRequestWithContext(bot, postingUser, channel, post, context)
+	result, err := c.ProcessUserRequestWithContext(ctx, bot, postingUser, channel, post, context)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	// The span covers the whole response, ending with the stream
+	return llm.TraceStream(result, span, start), nil
 }
 
-func (c *Conversations) GenerateTitle(bot *bots.Bot, request string, postID string, context *llm.Context) error {
+func (c *Conversations) GenerateTitle(ctx context.Context, bot *bots.Bot, request string, postID string, context *llm.Context) error {
 	titleRequest := llm.CompletionRequest{
 		Posts:   []llm.Post{{Role: llm.PostRoleUser, Message: request}},
 		Context: context,
 	}
 
-	conversationTitle, err := bot.LLM().ChatCompletionNoStream(titleRequest, llm.WithMaxGeneratedTokens(25), llm.WithReasoningDisabled())
+	conversationTitle, err := bot.LLM().ChatCompletionNoStream(ctx, titleRequest, llm.WithMaxGeneratedTokens(25), llm.WithReasoningDisabled(), llm.WithResponseCache(), llm.WithTask(llm.TaskTitle))
 	if err != nil {
 		return fmt.Errorf("failed to get title: %w", err)
 	}
@@ -195,6 +243,8 @@ func (c *Conversations) GenerateTitle(bot *bots.Bot, request string, postID stri
 
 // existingConversationToLLMPosts converts existing conversation to LLM posts format
 func (c *Conversations) existingConversationToLLMPosts(bot *bots.Bot, conversation *mmapi.ThreadData, context *llm.Context) ([]llm.Post, error) {
+	conversation = c.activeBranch(conversation)
+
 	// Handle thread summarization requests
 	originalThreadID, ok := conversation.Posts[0].GetProp(ThreadIDProp).(string)
 	if ok && originalThreadID != "" && conversation.Posts[0].UserId == bot.GetMMBot().UserId {
@@ -408,6 +458,22 @@ func (c *Conversations) ThreadToLLMPosts(bot *bots.Bot, threadData *mmapi.Thread
 	for _, post := range threadData.Posts {
 		aiPost := c.PostToAIPost(bot, post)
 
+		// Responses that resolved tools start with their completed steps
+		if aiPost.Role == llm.PostRoleBot {
+			steps, err := streaming.GetAgentSteps(post)
+			if err != nil {
+				c.mmClient.LogError("Error unmarshalling agent steps", "error", err)
+			}
+			for _, step := range steps {
+				result = append(result, agentStepPost(step))
+			}
+			aiPost = currentAgentStep(aiPost, steps)
+			// The next step hasn't started if the response was stopped right after resolving tools
+			if len(steps) > 0 && aiPost.Message == "" && len(aiPost.ToolUse) == 0 {
+				continue
+			}
+		}
+
 		// Add username prefix for user messages in multi-user threads
 		if aiPost.Role == llm.PostRoleUser {
 			if user, exists := threadData.UsersByID[post.UserId]; exists {
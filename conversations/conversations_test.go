@@ -5,6 +5,8 @@ package conversations_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -16,6 +18,7 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/evals"
 	"github.com/mattermost/mattermost-plugin-ai/i18n"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
+	llmmocks "github.com/mattermost/mattermost-plugin-ai/llm/mocks"
 	"github.com/mattermost/mattermost-plugin-ai/llmcontext"
 	"github.com/mattermost/mattermost-plugin-ai/mcp"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi/mocks"
@@ -25,6 +28,7 @@ import (
 	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -38,7 +42,7 @@ func (m *mockToolProvider) GetTools(bot *bots.Bot) []llm.Tool {
 		Name:        "GetGithubIssue",
 		Description: "Retrieve a single GitHub issue by owner, repo, and issue number.",
 		Schema:      llm.NewJSONSchemaFromStruct[mmtools.GetGithubIssueArgs](),
-		Resolver: func(context *llm.Context, args llm.ToolArgumentGetter) (string, error) {
+		Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
 			return "Unable to retrieve GitHub issue", nil
 		},
 	})
@@ -118,6 +122,7 @@ func TestConversationMentionHandling(t *testing.T) {
 				client,
 				toolProvider,
 				mcpClientManager,
+				nil,
 				configProvider,
 			)
 
@@ -155,7 +160,7 @@ func TestConversationMentionHandling(t *testing.T) {
 
 			bot := bots.NewBot(botConfig, serviceConfig, mmBot, llmInstance)
 
-			textStream, err := conv.ProcessUserRequest(bot, threadData.RequestingUser(), threadData.Channel, threadData.LatestPost())
+			textStream, err := conv.ProcessUserRequest(t.Context(), bot, threadData.RequestingUser(), threadData.Channel, threadData.LatestPost())
 			require.NoError(t, err, "Failed to process user request")
 			require.NotNil(t, textStream, "Expected a non-nil text stream")
 
@@ -171,3 +176,154 @@ func TestConversationMentionHandling(t *testing.T) {
 		})
 	}
 }
+
+type mockMemoryProvider struct{}
+
+func (m *mockMemoryProvider) GetTools() []llm.Tool {
+	return nil
+}
+
+func (m *mockMemoryProvider) GetPromptMemories(userID, botID string, budget int, countTokens func(string) int) ([]string, error) {
+	return []string{"Prefers answers in Go"}, nil
+}
+
+func TestProcessUserRequestMemories(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	client := pluginapi.NewClient(mockAPI, nil)
+	licenseChecker := enterprise.NewLicenseChecker(client)
+	botService := bots.New(mockAPI, client, licenseChecker, nil, &http.Client{}, nil, nil)
+	prompts, err := llm.NewPrompts(prompts.PromptsFolder)
+	require.NoError(t, err)
+	contextBuilder := llmcontext.NewLLMContextBuilder(client, &mockToolProvider{}, &mockMCPClientManager{}, &mockMemoryProvider{}, &mockConfigProvider{})
+	user := &model.User{Id: "userid", Username: "user"}
+
+	// processRequest answers post in channel and returns the context of the request once the title
+	// generated alongside the response was requested
+	processRequest := func(t *testing.T, channel *model.Channel, chatOpts ...any) *llm.Context {
+		mmClient := mocks.NewMockClient(t)
+		mmClient.EXPECT().LogError(mock.Anything, mock.Anything, mock.Anything).Maybe()
+		conv := conversations.New(prompts, mmClient, nil, contextBuilder, botService, nil, licenseChecker, i18n.Init(), nil)
+
+		mockLLM := llmmocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, chatOpts...).Return(&llm.TextStreamResult{}, nil)
+		titleRequested := make(chan struct{})
+		mockLLM.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(context.Context, llm.CompletionRequest, ...llm.LanguageModelOption) { close(titleRequested) }).
+			Return("", errors.New("no title"))
+		bot := bots.NewBot(llm.BotConfig{Name: "ai", EnableMemory: true}, llm.ServiceConfig{}, &model.Bot{UserId: "botid", Username: "ai"}, mockLLM)
+
+		llmContext := llm.NewContext()
+		llmContext.RequestingUser = user
+		_, err := conv.ProcessUserRequestWithContext(t.Context(), bot, user, channel, &model.Post{Id: "postid", Message: "@ai What should I use?"}, llmContext)
+		require.NoError(t, err)
+		<-titleRequested
+		return llmContext
+	}
+
+	t.Run("memories are used in DMs with the bot", func(t *testing.T) {
+		channel := &model.Channel{Id: "dm", Type: model.ChannelTypeDirect, Name: model.GetDMNameFromIds("userid", "botid")}
+		llmContext := processRequest(t, channel, mock.Anything)
+		assert.Equal(t, []string{"Prefers answers in Go"}, llmContext.Memories)
+	})
+
+	t.Run("memories are not shared in channels the bot is mentioned in", func(t *testing.T) {
+		channel := &model.Channel{Id: "town-square", Type: model.ChannelTypeOpen}
+		llmContext := processRequest(t, channel, mock.Anything, mock.Anything)
+		assert.Empty(t, llmContext.Memories)
+	})
+}
+
+func TestAskAgentTools(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockAPI.On("GetConfig").Return(&model.Config{})
+	mockAPI.On("GetLicense").Return(nil)
+	client := pluginapi.NewClient(mockAPI, nil)
+	licenseChecker := enterprise.NewLicenseChecker(client)
+	botService := bots.New(mockAPI, client, licenseChecker, nil, &http.Client{}, nil, nil)
+	prompts, err := llm.NewPrompts(prompts.PromptsFolder)
+	require.NoError(t, err)
+	contextBuilder := llmcontext.NewLLMContextBuilder(client, &mockToolProvider{}, &mockMCPClientManager{}, nil, &mockConfigProvider{})
+	conv := conversations.New(prompts, mocks.NewMockClient(t), nil, contextBuilder, botService, nil, licenseChecker, i18n.Init(), nil)
+	contextBuilder.SetDelegationProvider(conv)
+
+	// The asked bot delegates to other bots too, but can't ask them when answering for another bot
+	var delegateTools *llm.ToolStore
+	codeLLM := llmmocks.NewMockLanguageModel(t)
+	codeLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+		Run(func(_ context.Context, request llm.CompletionRequest, _ ...llm.LanguageModelOption) {
+			delegateTools = request.Context.Tools
+		}).
+		RunAndReturn(func(context.Context, llm.CompletionRequest, ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			stream := make(chan llm.TextStreamEvent, 2)
+			stream <- llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Use slices.Sort."}
+			stream <- llm.TextStreamEvent{Type: llm.EventTypeEnd}
+			close(stream)
+			return &llm.TextStreamResult{Stream: stream}, nil
+		})
+	general := bots.NewBot(llm.BotConfig{Name: "general", DelegateBots: []string{"code"}}, llm.ServiceConfig{}, &model.Bot{UserId: "generalbot", Username: "general"}, nil)
+	code := bots.NewBot(llm.BotConfig{Name: "code", DelegateBots: []string{"general"}}, llm.ServiceConfig{}, &model.Bot{UserId: "codebot", Username: "code"}, codeLLM)
+	botService.SetBotsForTesting([]*bots.Bot{general, code})
+
+	user := &model.User{Id: "userid", Username: "user"}
+	channel := &model.Channel{Id: "dm", Type: model.ChannelTypeDirect, Name: model.GetDMNameFromIds("userid", "generalbot")}
+	llmContext := contextBuilder.BuildLLMContextUserRequest(general, user, channel, contextBuilder.WithLLMContextDefaultTools(general))
+	_, ok := llmContext.Tools.GetTool(conversations.AskAgentToolName)
+	require.True(t, ok)
+
+	result, err := llmContext.Tools.ResolveTool(t.Context(), conversations.AskAgentToolName, func(args any) error {
+		args.(*conversations.AskAgentArgs).Agent = "code"
+		args.(*conversations.AskAgentArgs).Question = "How do I sort a slice?"
+		return nil
+	}, llmContext)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Use slices.Sort.")
+
+	require.NotNil(t, delegateTools)
+	_, ok = delegateTools.GetTool("GetGithubIssue")
+	assert.True(t, ok, "the asked bot keeps its own tools")
+	_, ok = delegateTools.GetTool(conversations.AskAgentToolName)
+	assert.False(t, ok, "the asked bot can't ask other bots")
+}
+
+func TestRoutedDMTools(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	mockAPI.On("GetConfig").Return(&model.Config{}).Maybe()
+	mockAPI.On("GetLicense").Return(nil).Maybe()
+	client := pluginapi.NewClient(mockAPI, nil)
+	licenseChecker := enterprise.NewLicenseChecker(client)
+	botService := bots.New(mockAPI, client, licenseChecker, nil, &http.Client{}, nil, nil)
+	prompts, err := llm.NewPrompts(prompts.PromptsFolder)
+	require.NoError(t, err)
+	contextBuilder := llmcontext.NewLLMContextBuilder(client, &mockToolProvider{}, &mockMCPClientManager{}, nil, &mockConfigProvider{})
+	mmClient := mocks.NewMockClient(t)
+	mmClient.EXPECT().LogError(mock.Anything, mock.Anything, mock.Anything).Maybe()
+	conv := conversations.New(prompts, mmClient, nil, contextBuilder, botService, nil, licenseChecker, i18n.Init(), nil)
+
+	// Only the task option is given when tools are enabled
+	var request llm.CompletionRequest
+	codeLLM := llmmocks.NewMockLanguageModel(t)
+	codeLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+		Run(func(_ context.Context, r llm.CompletionRequest, _ ...llm.LanguageModelOption) { request = r }).
+		Return(&llm.TextStreamResult{}, nil)
+	titleRequested := make(chan struct{})
+	codeLLM.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(context.Context, llm.CompletionRequest, ...llm.LanguageModelOption) { close(titleRequested) }).
+		Return("", errors.New("no title"))
+	router := bots.NewBot(llm.BotConfig{Name: "router", RouterMode: true, DelegateBots: []string{"code"}}, llm.ServiceConfig{}, &model.Bot{UserId: "routerbot", Username: "router"}, nil)
+	code := bots.NewBot(llm.BotConfig{Name: "code"}, llm.ServiceConfig{}, &model.Bot{UserId: "codebot", Username: "code"}, codeLLM)
+	botService.SetBotsForTesting([]*bots.Bot{router, code})
+
+	// The router's DM is answered by the bot it routed the message to
+	user := &model.User{Id: "userid", Username: "user"}
+	channel := &model.Channel{Id: "dm", Type: model.ChannelTypeDirect, Name: model.GetDMNameFromIds("userid", "routerbot")}
+	llmContext := contextBuilder.BuildLLMContextUserRequest(code, user, channel, contextBuilder.WithLLMContextDefaultTools(code))
+	_, err = conv.ProcessUserRequestWithContext(t.Context(), code, user, channel, &model.Post{Id: "postid", Message: "How do I sort a slice?"}, llmContext)
+	require.NoError(t, err)
+	<-titleRequested
+
+	require.NotNil(t, request.Context)
+	_, ok := request.Context.Tools.GetTool("GetGithubIssue")
+	assert.True(t, ok)
+	assert.Empty(t, request.Context.DisabledToolsInfo)
+}
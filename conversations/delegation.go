@@ -0,0 +1,343 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/prompts"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// AskAgentToolName is the tool bots use to ask the bots they delegate to
+	AskAgentToolName = "ask_agent"
+	// RoutedByProp is the router bot that forwarded the question to the bot of the response
+	RoutedByProp = "routed_by"
+
+	// minRouteConfidence is the confidence below which a router answers a message itself
+	minRouteConfidence = 0.5
+)
+
+// delegatedKey marks the context of a bot answering the question of another bot
+type delegatedKey struct{}
+
+type AskAgentArgs struct {
+	Agent    string `jsonschema_description:"The username of the agent to ask, one of the agents listed in the tool description. Example: 'codebot'"`
+	Question string `jsonschema_description:"The question for the agent, with all the details it needs to answer. The agent doesn't see the rest of the conversation."`
+}
+
+// agentRoute is the agent the LLM picks to answer the first message sent to a router.
+type agentRoute struct {
+	Agent      string  `json:"agent" jsonschema_description:"The username of the agent to forward the message to, empty when no agent is suited"`
+	Confidence float64 `json:"confidence" jsonschema_description:"How confident the choice is, between 0 and 1"`
+}
+
+// routeAgent describes a bot to the router prompt.
+type routeAgent struct {
+	Username    string
+	DisplayName string
+	Description string
+}
+
+// delegates returns the configured bots bot can ask questions or forward messages to.
+func (c *Conversations) delegates(bot *bots.Bot) []*bots.Bot {
+	var result []*bots.Bot
+	for _, name := range bot.GetConfig().DelegateBots {
+		delegate := c.bots.GetBotByUsername(strings.TrimPrefix(name, "@"))
+		if delegate == nil || delegate.GetConfig().Name == bot.GetConfig().Name {
+			continue
+		}
+		result = append(result, delegate)
+	}
+	return result
+}
+
+// checkDelegateAccess checks the requesting user can use delegate where bot was asked.
+func (c *Conversations) checkDelegateAccess(userID string, bot, delegate *bots.Bot, channel *model.Channel) error {
+	// Like direct messages with the delegate, direct messages with the bot only follow the user rules
+	if bot.GetMMBot() != nil && mmapi.IsDMWith(bot.GetMMBot().UserId, channel) {
+		return c.bots.CheckUsageRestrictionsForUser(delegate, userID)
+	}
+	return c.bots.CheckUsageRestrictions(userID, delegate, channel)
+}
+
+// isBotDM returns whether channel is a DM with bot, including DMs with routers that forward
+// messages to bot, where bot answers in the router's place.
+func (c *Conversations) isBotDM(bot *bots.Bot, channel *model.Channel) bool {
+	if mmapi.IsDMWith(bot.GetMMBot().UserId, channel) {
+		return true
+	}
+
+	router := c.bots.GetBotForDMChannel(channel)
+	if router == nil || !router.GetConfig().RouterMode {
+		return false
+	}
+	for _, delegate := range c.delegates(router) {
+		if delegate.GetConfig().Name == bot.GetConfig().Name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDelegationTools returns the ask_agent tool when bot delegates to other bots.
+func (c *Conversations) GetDelegationTools(bot *bots.Bot) []llm.Tool {
+	delegates := c.delegates(bot)
+	if len(delegates) == 0 {
+		return nil
+	}
+
+	var description strings.Builder
+	description.WriteString("Ask another agent a question when it is better suited to answer it, and get its answer. Include the answer in your response, attributed to the agent. The available agents are:\n")
+	for _, delegate := range delegates {
+		fmt.Fprintf(&description, "- %s (%s)", delegate.GetConfig().Name, delegate.GetConfig().DisplayName)
+		if delegate.GetConfig().Description != "" {
+			description.WriteString(": " + delegate.GetConfig().Description)
+		}
+		description.WriteString("\n")
+	}
+
+	return []llm.Tool{
+		{
+			Name:        AskAgentToolName,
+			Description: description.String(),
+			Schema:      llm.NewJSONSchemaFromStruct[AskAgentArgs](),
+			Resolver:    c.toolAskAgent,
+		},
+	}
+}
+
+func (c *Conversations) toolAskAgent(ctx context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+	var args AskAgentArgs
+	if err := argsGetter(&args); err != nil {
+		return "invalid parameters to function", fmt.Errorf("failed to get arguments for tool ask_agent: %w", err)
+	}
+
+	// Agents answering for another agent can't pass the question on, which could go on forever
+	if ctx.Value(delegatedKey{}) != nil {
+		return "agents answering another agent can't ask other agents", errors.New("delegated questions can't be delegated again")
+	}
+	if llmContext.RequestingUser == nil || llmContext.Channel == nil {
+		return "unable to ask agent", errors.New("ask_agent needs a requesting user and channel")
+	}
+	bot := c.bots.GetBotByID(llmContext.BotUserID)
+	if bot == nil {
+		return "unable to ask agent", errors.New("unable to get bot asking the agent")
+	}
+
+	var delegate *bots.Bot
+	for _, candidate := range c.delegates(bot) {
+		if candidate.GetConfig().Name == strings.TrimPrefix(args.Agent, "@") {
+			delegate = candidate
+			break
+		}
+	}
+	if delegate == nil {
+		return fmt.Sprintf("%s is not an agent you can ask", args.Agent), fmt.Errorf("unknown agent %s", args.Agent)
+	}
+	if err := c.checkDelegateAccess(llmContext.RequestingUser.Id, bot, delegate, llmContext.Channel); err != nil {
+		return fmt.Sprintf("the user is not allowed to use %s here", args.Agent), fmt.Errorf("unable to ask agent %s: %w", args.Agent, err)
+	}
+
+	answer, err := c.askAgent(ctx, delegate, llmContext.RequestingUser, llmContext.Channel, args.Question)
+	if err != nil {
+		return "unable to get an answer from the agent", fmt.Errorf("failed to ask agent %s: %w", args.Agent, err)
+	}
+
+	return fmt.Sprintf("@%s (%s) answered:\n\n%s", delegate.GetConfig().Name, delegate.GetConfig().DisplayName, answer), nil
+}
+
+// askAgent has delegate answer question in a conversation of its own, with its prompt, tools and
+// tool policies. Tool calls that need the user's approval end the answer.
+func (c *Conversations) askAgent(ctx context.Context, delegate *bots.Bot, user *model.User, channel *model.Channel, question string) (string, error) {
+	ctx = context.WithValue(ctx, delegatedKey{}, true)
+
+	llmContext := c.contextBuilder.BuildLLMContextUserRequest(
+		delegate,
+		user,
+		channel,
+		c.contextBuilder.WithLLMContextDefaultTools(delegate),
+		c.contextBuilder.WithLLMContextMemories(delegate),
+	)
+	llmContext.Question = question
+	// The delegate answers on its own, only the bot that asked it can ask other agents
+	llmContext.Tools.RemoveTool(AskAgentToolName)
+
+	prompt, err := c.prompts.Format(prompts.PromptDirectMessageQuestionSystem, llmContext)
+	if err != nil {
+		return "", fmt.Errorf("failed to format prompt: %w", err)
+	}
+	request := llm.CompletionRequest{
+		Posts: []llm.Post{
+			{
+				Role:    llm.PostRoleSystem,
+				Message: prompt,
+			},
+			{
+				Role:    llm.PostRoleUser,
+				Message: question,
+			},
+		},
+		Context: llmContext,
+	}
+
+	opts := []llm.LanguageModelOption{llm.WithTask(llm.TaskChat)}
+	result, err := delegate.LLM().ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	result = c.runAgentLoop(ctx, delegate, request, result, opts...)
+
+	return readAgentAnswer(delegate, result)
+}
+
+// delegatedBotIDs returns the IDs of the bots that answered the ask_agent calls of toolCalls.
+func (c *Conversations) delegatedBotIDs(toolCalls []llm.ToolCall) []string {
+	var botIDs []string
+	for _, call := range toolCalls {
+		if call.Name != AskAgentToolName || call.Status != llm.ToolCallStatusSuccess {
+			continue
+		}
+		var args AskAgentArgs
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			continue
+		}
+		if delegate := c.bots.GetBotByUsername(strings.TrimPrefix(args.Agent, "@")); delegate != nil && delegate.GetMMBot() != nil {
+			botIDs = append(botIDs, delegate.GetMMBot().UserId)
+		}
+	}
+	return botIDs
+}
+
+// readAgentAnswer reads the text of the answer of delegate to a delegated question.
+func readAgentAnswer(delegate *bots.Bot, result *llm.TextStreamResult) (string, error) {
+	var answer strings.Builder
+	var streamErr error
+	// The whole stream is read for the agent loop to finish
+	for event := range result.Stream {
+		switch event.Type {
+		case llm.EventTypeText:
+			if text, ok := event.Value.(string); ok {
+				answer.WriteString(text)
+			}
+		case llm.EventTypeToolCalls:
+			calls, _ := event.Value.([]llm.ToolCall)
+			names := make([]string, 0, len(calls))
+			for _, call := range calls {
+				names = append(names, call.Name)
+			}
+			fmt.Fprintf(&answer, "\n\n(@%s stopped to use tools that need the user's approval: %s. The user can ask @%s directly.)", delegate.GetConfig().Name, strings.Join(names, ", "), delegate.GetConfig().Name)
+		case llm.EventTypeError:
+			if err, ok := event.Value.(error); ok {
+				streamErr = err
+			}
+		}
+	}
+	if streamErr != nil {
+		return "", streamErr
+	}
+	return strings.TrimSpace(answer.String()), nil
+}
+
+// routeMessage returns the bot that answers post sent to bot. Routers forward the first message of
+// a conversation to the delegate best suited to answer it, and the rest of the conversation follows.
+// Other bots, and routers without a suited delegate, answer themselves.
+func (c *Conversations) routeMessage(ctx context.Context, bot *bots.Bot, post *model.Post, postingUser *model.User, channel *model.Channel) *bots.Bot {
+	if !bot.GetConfig().RouterMode {
+		return bot
+	}
+
+	var candidates []*bots.Bot
+	for _, delegate := range c.delegates(bot) {
+		if err := c.checkDelegateAccess(postingUser.Id, bot, delegate, channel); err == nil {
+			candidates = append(candidates, delegate)
+		}
+	}
+	if len(candidates) == 0 {
+		return bot
+	}
+
+	if post.RootId != "" {
+		return c.routedBot(bot, post.RootId, candidates)
+	}
+
+	route, err := c.classifyRoute(ctx, bot, candidates, post, postingUser, channel)
+	if err != nil {
+		c.mmClient.LogError("Unable to route message, answering with the router", "bot_name", bot.GetConfig().Name, "error", err)
+		return bot
+	}
+	if route.Confidence < minRouteConfidence {
+		return bot
+	}
+	for _, candidate := range candidates {
+		if candidate.GetConfig().Name == strings.TrimPrefix(route.Agent, "@") {
+			return candidate
+		}
+	}
+	return bot
+}
+
+// routedBot returns the candidate bot router forwarded the thread of rootID to.
+func (c *Conversations) routedBot(router *bots.Bot, rootID string, candidates []*bots.Bot) *bots.Bot {
+	thread, err := mmapi.GetThreadData(c.mmClient, rootID)
+	if err != nil {
+		c.mmClient.LogError("Unable to get routed thread, answering with the router", "bot_name", router.GetConfig().Name, "error", err)
+		return router
+	}
+
+	for _, post := range thread.Posts {
+		if routedBy, _ := post.GetProp(RoutedByProp).(string); routedBy != router.GetMMBot().UserId {
+			continue
+		}
+		for _, candidate := range candidates {
+			if candidate.GetMMBot().UserId == post.UserId {
+				return candidate
+			}
+		}
+		break
+	}
+	return router
+}
+
+// classifyRoute asks the LLM of router which of candidates answers post.
+func (c *Conversations) classifyRoute(ctx context.Context, router *bots.Bot, candidates []*bots.Bot, post *model.Post, postingUser *model.User, channel *model.Channel) (agentRoute, error) {
+	agents := make([]routeAgent, 0, len(candidates))
+	for _, candidate := range candidates {
+		agents = append(agents, routeAgent{
+			Username:    candidate.GetConfig().Name,
+			DisplayName: candidate.GetConfig().DisplayName,
+			Description: candidate.GetConfig().Description,
+		})
+	}
+
+	llmContext := c.contextBuilder.BuildLLMContextUserRequest(router, postingUser, channel)
+	llmContext.Parameters = map[string]any{"Agents": agents}
+
+	prompt, err := c.prompts.Format(prompts.PromptAgentRouterSystem, llmContext)
+	if err != nil {
+		return agentRoute{}, fmt.Errorf("failed to format prompt: %w", err)
+	}
+
+	return llm.CompleteJSON[agentRoute](ctx, router.LLM(), llm.CompletionRequest{
+		Posts: []llm.Post{
+			{
+				Role:    llm.PostRoleSystem,
+				Message: prompt,
+			},
+			{
+				Role:    llm.PostRoleUser,
+				Message: post.Message,
+			},
+		},
+		Context: llmContext,
+	}, llm.WithMaxGeneratedTokens(500), llm.WithReasoningDisabled(), llm.WithToolsDisabled(), llm.WithTask(llm.TaskClassify))
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi/mocks"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegation(t *testing.T) {
+	e := SetupTestEnvironment(t)
+	defer e.Cleanup(t)
+
+	general := bots.NewBot(llm.BotConfig{Name: "general", DelegateBots: []string{"hr", "@code", "missing", "general"}}, llm.ServiceConfig{}, &model.Bot{UserId: "generalbot"}, nil)
+	hr := bots.NewBot(llm.BotConfig{Name: "hr", DisplayName: "HR Policy", Description: "Answers questions about HR policies", UserAccessLevel: llm.UserAccessLevelNone}, llm.ServiceConfig{}, &model.Bot{UserId: "hrbot"}, nil)
+	code := bots.NewBot(llm.BotConfig{Name: "code", DisplayName: "Code", Description: "Reviews and writes code"}, llm.ServiceConfig{}, &model.Bot{UserId: "codebot"}, nil)
+	e.bots.SetBotsForTesting([]*bots.Bot{general, hr, code})
+
+	llmContext := &llm.Context{
+		RequestingUser: &model.User{Id: "user"},
+		Channel:        &model.Channel{Id: "town-square", Type: model.ChannelTypeOpen},
+		BotUserID:      "generalbot",
+	}
+	askAgent := func(ctx context.Context, agent string) (string, error) {
+		tools := llm.NewNoTools()
+		tools.AddTools(e.conversations.GetDelegationTools(general))
+		return tools.ResolveTool(ctx, AskAgentToolName, func(args any) error {
+			args.(*AskAgentArgs).Agent = agent
+			args.(*AskAgentArgs).Question = "How many vacation days do I have?"
+			return nil
+		}, llmContext)
+	}
+
+	t.Run("bots can ask the configured bots", func(t *testing.T) {
+		tools := e.conversations.GetDelegationTools(general)
+		require.Len(t, tools, 1)
+		assert.Equal(t, AskAgentToolName, tools[0].Name)
+		assert.Contains(t, tools[0].Description, "- hr (HR Policy): Answers questions about HR policies")
+		assert.Contains(t, tools[0].Description, "- code (Code): Reviews and writes code")
+		assert.NotContains(t, tools[0].Description, "- general", "bots don't ask themselves")
+
+		assert.Empty(t, e.conversations.GetDelegationTools(code))
+	})
+
+	t.Run("only configured bots can be asked", func(t *testing.T) {
+		result, err := askAgent(t.Context(), "missing")
+		require.Error(t, err)
+		assert.Equal(t, "missing is not an agent you can ask", result)
+	})
+
+	t.Run("the access rules of the asked bot apply", func(t *testing.T) {
+		result, err := askAgent(t.Context(), "hr")
+		require.ErrorIs(t, err, bots.ErrUsageRestriction)
+		assert.Equal(t, "the user is not allowed to use hr here", result)
+	})
+
+	t.Run("delegated questions can't be delegated again", func(t *testing.T) {
+		_, err := askAgent(context.WithValue(t.Context(), delegatedKey{}, true), "code")
+		require.Error(t, err)
+	})
+
+	t.Run("responses record the bots that answered", func(t *testing.T) {
+		toolCalls := []llm.ToolCall{
+			{Name: AskAgentToolName, Arguments: []byte(`{"Agent":"@code","Question":"How do I sort a slice?"}`), Status: llm.ToolCallStatusSuccess},
+			{Name: AskAgentToolName, Arguments: []byte(`{"Agent":"hr","Question":"How many vacation days do I have?"}`), Status: llm.ToolCallStatusError},
+			{Name: "search", Arguments: []byte(`{"Agent":"hr"}`), Status: llm.ToolCallStatusSuccess},
+		}
+		assert.Equal(t, []string{"codebot"}, e.conversations.delegatedBotIDs(toolCalls))
+	})
+
+	t.Run("bots that aren't routers answer themselves", func(t *testing.T) {
+		assert.Equal(t, general, e.conversations.routeMessage(t.Context(), general, &model.Post{Message: "Hi"}, &model.User{Id: "user"}, llmContext.Channel))
+	})
+
+	t.Run("routed conversations stay with the bot that answered the first message", func(t *testing.T) {
+		router := bots.NewBot(llm.BotConfig{Name: "router", RouterMode: true, DelegateBots: []string{"hr", "code"}}, llm.ServiceConfig{}, &model.Bot{UserId: "routerbot"}, nil)
+		e.bots.SetBotsForTesting([]*bots.Bot{router, hr, code})
+
+		mmClient := mocks.NewMockClient(t)
+		e.conversations.mmClient = mmClient
+		routed := &model.Post{Id: "response", UserId: "codebot", RootId: "question", CreateAt: 20}
+		routed.AddProp(RoutedByProp, "routerbot")
+		postList := model.NewPostList()
+		for _, post := range []*model.Post{{Id: "question", UserId: "user", CreateAt: 10}, routed} {
+			postList.AddPost(post)
+			postList.AddOrder(post.Id)
+		}
+		mmClient.EXPECT().GetPostThread("question").Return(postList, nil)
+		mmClient.EXPECT().GetUser(mock.Anything).Return(&model.User{}, nil)
+
+		reply := &model.Post{Id: "reply", RootId: "question", Message: "And in Go?"}
+		assert.Equal(t, code, e.conversations.routeMessage(t.Context(), router, reply, &model.User{Id: "user"}, llmContext.Channel))
+	})
+}
@@ -116,6 +116,7 @@ func TestDirectMessageConversations(t *testing.T) {
 				client,
 				toolProvider,
 				mcpClientManager,
+				nil,
 				configProvider,
 			)
 
@@ -154,7 +155,7 @@ func TestDirectMessageConversations(t *testing.T) {
 			bot := bots.NewBot(botConfig, serviceConfig, mmBot, llmInstance)
 
 			// Process the DM request
-			textStream, err := conv.ProcessUserRequest(bot, threadData.RequestingUser(), threadData.Channel, threadData.LatestPost())
+			textStream, err := conv.ProcessUserRequest(t.Context(), bot, threadData.RequestingUser(), threadData.Channel, threadData.LatestPost())
 			require.NoError(t, err, "Failed to process DM request")
 			require.NotNil(t, textStream, "Expected a non-nil text stream")
 
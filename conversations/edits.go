@@ -0,0 +1,156 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// MessageHasBeenUpdated regenerates the response of a bot when the user edits the question it
+// answers. Only the latest question of a conversation is answered again, edits to older posts
+// need ForkFromPost.
+func (c *Conversations) MessageHasBeenUpdated(ctx *plugin.Context, newPost, oldPost *model.Post) {
+	if err := c.handleEdit(newPost, oldPost); err != nil {
+		if errors.Is(err, ErrNoResponse) {
+			c.mmClient.LogDebug(err.Error())
+		} else {
+			c.mmClient.LogError(err.Error())
+		}
+	}
+}
+
+func (c *Conversations) handleEdit(post, oldPost *model.Post) error {
+	// Pinning, reactions and other prop updates don't change the question
+	if post.Message == oldPost.Message {
+		return fmt.Errorf("not responding to updates that keep the message: %w", ErrNoResponse)
+	}
+
+	if post.DeleteAt != 0 {
+		return fmt.Errorf("not responding to deleted posts: %w", ErrNoResponse)
+	}
+
+	if c.bots.IsAnyBot(post.UserId) {
+		return fmt.Errorf("not responding to edits of bot posts: %w", ErrNoResponse)
+	}
+
+	if post.RemoteId != nil && *post.RemoteId != "" {
+		return fmt.Errorf("not responding to edits of remote posts: %w", ErrNoResponse)
+	}
+
+	channel, err := c.mmClient.GetChannel(post.ChannelId)
+	if err != nil {
+		return fmt.Errorf("unable to get channel: %w", err)
+	}
+
+	// Most edits aren't addressed to a bot, which is known without loading the thread
+	mentioned := c.bots.GetBotMentioned(post.Message)
+	if mentioned == nil && c.bots.GetBotForDMChannel(channel) == nil {
+		return fmt.Errorf("edited post isn't addressed to a bot: %w", ErrNoResponse)
+	}
+
+	thread, err := mmapi.GetThreadData(c.mmClient, post.Id)
+	if err != nil {
+		return fmt.Errorf("unable to get thread of edited post: %w", err)
+	}
+	thread = c.activeBranch(thread)
+
+	response := c.findResponse(thread, post)
+	if response == nil {
+		return fmt.Errorf("edited post has no bot response: %w", ErrNoResponse)
+	}
+	if !c.isLatestQuestion(thread, post) {
+		return fmt.Errorf("not responding to edits of older posts: %w", ErrNoResponse)
+	}
+
+	// The edited question still has to be addressed to the bot that answered it
+	bot := c.bots.GetBotByID(response.UserId)
+	if bot == nil {
+		return fmt.Errorf("unable to get bot of response: %w", ErrNoResponse)
+	}
+	// Routed responses answer questions addressed to the router
+	if routedBy, ok := response.GetProp(RoutedByProp).(string); ok {
+		if router := c.bots.GetBotByID(routedBy); router != nil {
+			bot = router
+		}
+	}
+	isMentioned := mentioned != nil && mentioned.GetMMBot().UserId == bot.GetMMBot().UserId
+	if !isMentioned && !mmapi.IsDMWith(bot.GetMMBot().UserId, channel) {
+		return fmt.Errorf("edited post no longer mentions the bot: %w", ErrNoResponse)
+	}
+
+	// Regenerating streams the whole response, which shouldn't hold up the hook
+	go func() {
+		if err := c.regenerateResponse(post.UserId, response, channel); err != nil {
+			if errors.Is(err, streaming.ErrAlreadyStreamingToPost) {
+				c.mmClient.LogDebug("Not regenerating a response that is still being generated", "post_id", response.Id)
+				return
+			}
+			c.mmClient.LogError("Failed to regenerate response to edited post", "post_id", post.Id, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// ForkFromPost answers post again, continuing the conversation from there. The previous response
+// and the posts that followed it are kept as a version of the response the user can switch back to.
+func (c *Conversations) ForkFromPost(userID string, post *model.Post, channel *model.Channel) error {
+	if post.UserId != userID {
+		return errors.New("only the poster can fork the conversation")
+	}
+
+	thread, err := mmapi.GetThreadData(c.mmClient, post.Id)
+	if err != nil {
+		return fmt.Errorf("unable to get thread: %w", err)
+	}
+
+	response := c.findResponse(c.activeBranch(thread), post)
+	if response == nil {
+		return fmt.Errorf("post has no bot response: %w", ErrNoResponse)
+	}
+
+	return c.regenerateResponse(userID, response, channel)
+}
+
+// regenerateResponse answers the question of response again on behalf of userID.
+func (c *Conversations) regenerateResponse(userID string, response *model.Post, channel *model.Channel) error {
+	bot := c.bots.GetBotByID(response.UserId)
+	if bot == nil {
+		return errors.New("unable to get bot of response")
+	}
+	if err := c.bots.CheckUsageRestrictions(userID, bot, channel); err != nil {
+		return err
+	}
+
+	return c.HandleRegenerate(userID, response, channel)
+}
+
+// findResponse returns the bot post of thread responding to post, or nil when there is none.
+func (c *Conversations) findResponse(thread *mmapi.ThreadData, post *model.Post) *model.Post {
+	for _, threadPost := range thread.Posts {
+		if threadPost.CreateAt < post.CreateAt || !c.bots.IsAnyBot(threadPost.UserId) {
+			continue
+		}
+		if respondingTo, _ := threadPost.GetProp(streaming.RespondingToProp).(string); respondingTo == post.Id {
+			return threadPost
+		}
+	}
+	return nil
+}
+
+// isLatestQuestion returns whether no user posted in thread after post.
+func (c *Conversations) isLatestQuestion(thread *mmapi.ThreadData, post *model.Post) bool {
+	for _, threadPost := range thread.Posts {
+		if threadPost.CreateAt > post.CreateAt && !c.bots.IsAnyBot(threadPost.UserId) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi/mocks"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEdit(t *testing.T) {
+	e := SetupTestEnvironment(t)
+	defer e.Cleanup(t)
+	e.bots.SetBotsForTesting([]*bots.Bot{bots.NewBot(llm.BotConfig{Name: "ai"}, llm.ServiceConfig{}, &model.Bot{UserId: "botid", Username: "ai"}, nil)})
+
+	response := func(id string, createAt int64, respondingTo string) *model.Post {
+		post := &model.Post{Id: id, UserId: "botid", RootId: "question1", CreateAt: createAt}
+		post.AddProp(streaming.RespondingToProp, respondingTo)
+		return post
+	}
+	thread := &mmapi.ThreadData{Posts: []*model.Post{
+		{Id: "question1", UserId: "userid", CreateAt: 10},
+		response("response1", 20, "question1"),
+		{Id: "question2", UserId: "userid", RootId: "question1", CreateAt: 30},
+		response("response2", 40, "question2"),
+	}}
+
+	t.Run("the response to a question is found", func(t *testing.T) {
+		assert.Equal(t, "response1", e.conversations.findResponse(thread, thread.Posts[0]).Id)
+		assert.Equal(t, "response2", e.conversations.findResponse(thread, thread.Posts[2]).Id)
+		assert.Nil(t, e.conversations.findResponse(thread, &model.Post{Id: "other", CreateAt: 50}))
+	})
+
+	t.Run("only the last question of the user is the latest", func(t *testing.T) {
+		assert.False(t, e.conversations.isLatestQuestion(thread, thread.Posts[0]))
+		assert.True(t, e.conversations.isLatestQuestion(thread, thread.Posts[2]))
+	})
+
+	t.Run("updates that keep the message are ignored", func(t *testing.T) {
+		post := &model.Post{Id: "question2", UserId: "userid", Message: "What is a bot?"}
+		err := e.conversations.handleEdit(post, post.Clone())
+		require.ErrorIs(t, err, ErrNoResponse)
+	})
+
+	t.Run("edits of bot posts are ignored", func(t *testing.T) {
+		err := e.conversations.handleEdit(
+			&model.Post{Id: "response2", UserId: "botid", Message: "Fixed"},
+			&model.Post{Id: "response2", UserId: "botid", Message: "Fixd"},
+		)
+		require.ErrorIs(t, err, ErrNoResponse)
+	})
+
+	t.Run("edits not addressed to a bot are ignored without loading the thread", func(t *testing.T) {
+		mmClient := mocks.NewMockClient(t)
+		e.conversations.mmClient = mmClient
+		mmClient.EXPECT().GetChannel("channelid").Return(&model.Channel{Id: "channelid", Type: model.ChannelTypeOpen}, nil)
+
+		err := e.conversations.handleEdit(
+			&model.Post{Id: "question2", UserId: "userid", ChannelId: "channelid", Message: "Lunch is at noon"},
+			&model.Post{Id: "question2", UserId: "userid", ChannelId: "channelid", Message: "Lunch is at 12"},
+		)
+		require.ErrorIs(t, err, ErrNoResponse)
+	})
+
+	t.Run("edits of older questions are ignored", func(t *testing.T) {
+		mmClient := mocks.NewMockClient(t)
+		e.conversations.mmClient = mmClient
+		mmClient.EXPECT().GetChannel("channelid").Return(&model.Channel{Id: "channelid", Type: model.ChannelTypeOpen}, nil)
+		postList := model.NewPostList()
+		for _, post := range thread.Posts {
+			postList.AddPost(post)
+			postList.AddOrder(post.Id)
+		}
+		mmClient.EXPECT().GetPostThread("question1").Return(postList, nil)
+		mmClient.EXPECT().GetUser(mock.Anything).Return(&model.User{}, nil)
+
+		err := e.conversations.handleEdit(
+			&model.Post{Id: "question1", UserId: "userid", ChannelId: "channelid", Message: "@ai What is an agent?", CreateAt: 10},
+			&model.Post{Id: "question1", UserId: "userid", ChannelId: "channelid", Message: "@ai What is an agnet?", CreateAt: 10},
+		)
+		require.ErrorIs(t, err, ErrNoResponse)
+	})
+
+	t.Run("only the poster can fork the conversation", func(t *testing.T) {
+		err := e.conversations.ForkFromPost("otheruser", thread.Posts[0], &model.Channel{})
+		require.Error(t, err)
+	})
+}
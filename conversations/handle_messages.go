@@ -4,11 +4,12 @@
 package conversations
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 )
@@ -87,6 +88,11 @@ func (c *Conversations) handleMessages(post *model.Post) error {
 		return c.handleDMs(bot, channel, postingUser, post)
 	}
 
+	// Check if a bot answers questions like this one without being mentioned
+	if bot, rule := c.getAutoResponseRule(post, channel, time.Now()); bot != nil {
+		return c.handleAutoResponse(bot, rule, post, postingUser, channel)
+	}
+
 	return nil
 }
 
@@ -95,8 +101,15 @@ func (c *Conversations) handleMentions(bot *bots.Bot, post *model.Post, postingU
 		return err
 	}
 
-	stream, err := c.ProcessUserRequest(bot, postingUser, channel, post)
+	ctx, cancel := c.streamingService.NewRequestContext()
+	// The trace covers both generating the response and streaming it to the post
+	ctx, span := tracing.Start(ctx, "conversations.handleMentions")
+	defer span.End()
+	// Routers have another bot answer in their place
+	responder := c.routeMessage(ctx, bot, post, postingUser, channel)
+	stream, err := c.ProcessUserRequest(ctx, responder, postingUser, channel, post)
 	if err != nil {
+		cancel()
 		return fmt.Errorf("unable to process bot mention: %w", err)
 	}
 
@@ -109,7 +122,11 @@ func (c *Conversations) handleMentions(bot *bots.Bot, post *model.Post, postingU
 		ChannelId: channel.Id,
 		RootId:    responseRootID,
 	}
-	if err := c.streamingService.StreamToNewPost(context.Background(), bot.GetMMBot().UserId, postingUser.Id, stream, responsePost, post.Id); err != nil {
+	if responder != bot {
+		responsePost.AddProp(RoutedByProp, bot.GetMMBot().UserId)
+	}
+	if err := c.streamingService.StreamToNewPost(ctx, responder.GetMMBot().UserId, postingUser.Id, stream, responsePost, post.Id); err != nil {
+		cancel()
 		return fmt.Errorf("unable to stream response: %w", err)
 	}
 
@@ -121,8 +138,14 @@ func (c *Conversations) handleDMs(bot *bots.Bot, channel *model.Channel, posting
 		return err
 	}
 
-	stream, err := c.ProcessUserRequest(bot, postingUser, channel, post)
+	ctx, cancel := c.streamingService.NewRequestContext()
+	ctx, span := tracing.Start(ctx, "conversations.handleDMs")
+	defer span.End()
+	// Routers have another bot answer in their place
+	responder := c.routeMessage(ctx, bot, post, postingUser, channel)
+	stream, err := c.ProcessUserRequest(ctx, responder, postingUser, channel, post)
 	if err != nil {
+		cancel()
 		return fmt.Errorf("unable to process bot mention: %w", err)
 	}
 
@@ -135,7 +158,11 @@ func (c *Conversations) handleDMs(bot *bots.Bot, channel *model.Channel, posting
 		ChannelId: channel.Id,
 		RootId:    responseRootID,
 	}
-	if err := c.streamingService.StreamToNewPost(context.Background(), bot.GetMMBot().UserId, postingUser.Id, stream, responsePost, post.Id); err != nil {
+	if responder != bot {
+		responsePost.AddProp(RoutedByProp, bot.GetMMBot().UserId)
+	}
+	if err := c.streamingService.StreamToNewPost(ctx, responder.GetMMBot().UserId, postingUser.Id, stream, responsePost, post.Id); err != nil {
+		cancel()
 		return fmt.Errorf("unable to stream response: %w", err)
 	}
 
@@ -4,6 +4,7 @@
 package conversations
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
@@ -82,3 +83,15 @@ func TestHandleMessages(t *testing.T) {
 		require.ErrorIs(t, err, ErrNoResponse)
 	})
 }
+
+func TestTitleContext(t *testing.T) {
+	requestCtx, cancel := context.WithCancel(t.Context())
+	titleCtx, cancelTitle := titleContext(requestCtx)
+	defer cancelTitle()
+
+	// Responses finishing cancel their request context
+	cancel()
+	require.NoError(t, titleCtx.Err(), "titles are still generated after the response finished")
+	_, hasDeadline := titleCtx.Deadline()
+	require.True(t, hasDeadline)
+}
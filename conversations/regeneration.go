@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/mattermost/mattermost-plugin-ai/bots"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
@@ -51,7 +52,14 @@ func (c *Conversations) HandleRegenerate(userID string, post *model.Post, channe
 	analysisTypeProp := post.GetProp(AnalysisTypeProp)
 	referenceRecordingFileIDProp := post.GetProp(ReferencedRecordingFileID)
 	referencedTranscriptPostProp := post.GetProp(ReferencedTranscriptPostID)
-	post.DelProp(streaming.ToolCallProp)
+	// The previous response is kept as a version the user can switch back to
+	version, err := c.startPostVersion(post)
+	if err != nil {
+		return fmt.Errorf("unable to save previous response on regen: %w", err)
+	}
+	for _, key := range versionedProps {
+		post.DelProp(key)
+	}
 	var result *llm.TextStreamResult
 	switch {
 	case threadIDProp != nil:
@@ -76,11 +84,11 @@ func (c *Conversations) HandleRegenerate(userID string, post *model.Post, channe
 		analyzer := threads.New(bot.LLM(), c.prompts, c.mmClient)
 		switch analysisType {
 		case "summarize_thread":
-			result, err = analyzer.Summarize(threadID, llmContext)
+			result, err = analyzer.Summarize(ctx, threadID, llmContext)
 		case "action_items":
-			result, err = analyzer.FindActionItems(threadID, llmContext)
+			result, err = analyzer.FindActionItems(ctx, threadID, llmContext)
 		case "open_questions":
-			result, err = analyzer.FindOpenQuestions(threadID, llmContext)
+			result, err = analyzer.FindOpenQuestions(ctx, threadID, llmContext)
 		default:
 			return fmt.Errorf("invalid analysis type: %s", analysisType)
 		}
@@ -122,7 +130,7 @@ func (c *Conversations) HandleRegenerate(userID string, post *model.Post, channe
 			c.contextBuilder.WithLLMContextDefaultTools(bot),
 		)
 		var summaryErr error
-		result, summaryErr = c.meetingsService.SummarizeTranscription(bot, transcription, context)
+		result, summaryErr = c.meetingsService.SummarizeTranscription(ctx, bot, transcription, context)
 		if summaryErr != nil {
 			return fmt.Errorf("could not summarize transcription on regen: %w", summaryErr)
 		}
@@ -155,7 +163,7 @@ func (c *Conversations) HandleRegenerate(userID string, post *model.Post, channe
 			c.contextBuilder.WithLLMContextDefaultTools(bot),
 		)
 		var summaryErr error
-		result, summaryErr = c.meetingsService.SummarizeTranscription(bot, transcription, context)
+		result, summaryErr = c.meetingsService.SummarizeTranscription(ctx, bot, transcription, context)
 		if summaryErr != nil {
 			return fmt.Errorf("unable to summarize transcription: %w", summaryErr)
 		}
@@ -184,21 +192,30 @@ func (c *Conversations) HandleRegenerate(userID string, post *model.Post, channe
 		// Process the user request with the context that has the callback
 		// Note: ProcessUserRequestWithContext internally checks if this is a DM and applies WithToolsDisabled() if not
 		var processErr error
-		result, processErr = c.ProcessUserRequestWithContext(bot, user, channel, respondingToPost, contextWithCallback)
+		result, processErr = c.ProcessUserRequestWithContext(ctx, bot, user, channel, respondingToPost, contextWithCallback)
 		if processErr != nil {
 			return fmt.Errorf("could not continue conversation on regen: %w", processErr)
 		}
 	}
 
+	c.streamingService.StreamToPost(ctx, result, post, c.responseLocale(bot, user, channel))
+
+	if err := c.finishPostVersion(post, version); err != nil {
+		return fmt.Errorf("unable to save response on regen: %w", err)
+	}
+
+	return nil
+}
+
+// responseLocale returns the locale of responses to user in channel. Only DMs with the bot are in
+// the user's locale, other channels use the server's default locale.
+func (c *Conversations) responseLocale(bot *bots.Bot, user *model.User, channel *model.Channel) string {
 	if mmapi.IsDMWith(bot.GetMMBot().UserId, channel) {
 		if channel.Name == bot.GetMMBot().UserId+"__"+user.Id || channel.Name == user.Id+"__"+bot.GetMMBot().UserId {
-			c.streamingService.StreamToPost(ctx, result, post, user.Locale)
-			return nil
+			return user.Locale
 		}
 	}
 
 	config := c.mmClient.GetConfig()
-	c.streamingService.StreamToPost(ctx, result, post, *config.LocalizationSettings.DefaultServerLocale)
-
-	return nil
+	return *config.LocalizationSettings.DefaultServerLocale
 }
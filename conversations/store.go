@@ -4,9 +4,12 @@
 package conversations
 
 import (
+	"encoding/json"
 	"fmt"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
 )
 
 // SaveTitleAsync saves a title asynchronously
@@ -55,3 +58,113 @@ func (c *Conversations) getAIThreads(dmChannelIDs []string) ([]AIThread, error)
 
 	return dbPosts, nil
 }
+
+// SummaryStore caches rolling conversation summaries in the LLM_PostMeta table.
+type SummaryStore struct {
+	db *mmapi.DBClient
+}
+
+// NewSummaryStore creates a SummaryStore
+func NewSummaryStore(db *mmapi.DBClient) *SummaryStore {
+	return &SummaryStore{
+		db: db,
+	}
+}
+
+// GetConversationSummary gets the cached summary for a thread
+func (s *SummaryStore) GetConversationSummary(rootPostID string) (llm.ConversationSummary, error) {
+	if s.db == nil {
+		return llm.ConversationSummary{}, nil
+	}
+
+	var rows []struct {
+		Summary          string
+		SummaryPostCount int
+		SummaryHash      string
+	}
+	if err := s.db.DoQuery(&rows, s.db.Builder().
+		Select("Summary", "SummaryPostCount", "SummaryHash").
+		From("LLM_PostMeta").
+		Where(sq.Eq{"RootPostID": rootPostID}),
+	); err != nil {
+		return llm.ConversationSummary{}, fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+	if len(rows) == 0 {
+		return llm.ConversationSummary{}, nil
+	}
+
+	return llm.ConversationSummary{
+		Summary:   rows[0].Summary,
+		PostCount: rows[0].SummaryPostCount,
+		Hash:      rows[0].SummaryHash,
+	}, nil
+}
+
+// SaveConversationSummary saves the summary for a thread
+func (s *SummaryStore) SaveConversationSummary(rootPostID string, summary llm.ConversationSummary) error {
+	if s.db == nil {
+		return nil
+	}
+	_, err := s.db.ExecBuilder(s.db.Builder().Insert("LLM_PostMeta").
+		Columns("RootPostID", "Title", "Summary", "SummaryPostCount", "SummaryHash").
+		Values(rootPostID, "", summary.Summary, summary.PostCount, summary.Hash).
+		Suffix("ON CONFLICT (RootPostID) DO UPDATE SET Summary = ?, SummaryPostCount = ?, SummaryHash = ?", summary.Summary, summary.PostCount, summary.Hash))
+	return err
+}
+
+// getPostVersions gets the stored versions of a post, in order
+func (c *Conversations) getPostVersions(postID string) ([]PostVersion, error) {
+	var rows []struct {
+		Version         int
+		CreateAt        int64
+		Message         string
+		Props           string
+		FollowUpPostIDs string
+	}
+	if err := c.db.DoQuery(&rows, c.db.Builder().
+		Select("Version", "CreateAt", "Message", "Props", "FollowUpPostIDs").
+		From("LLM_PostVersions").
+		Where(sq.Eq{"PostID": postID}).
+		OrderBy("Version"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to get post versions: %w", err)
+	}
+
+	versions := make([]PostVersion, 0, len(rows))
+	for _, row := range rows {
+		version := PostVersion{
+			Version:  row.Version,
+			CreateAt: row.CreateAt,
+			Message:  row.Message,
+		}
+		if err := json.Unmarshal([]byte(row.Props), &version.Props); err != nil {
+			return nil, fmt.Errorf("post version %d props not valid JSON: %w", row.Version, err)
+		}
+		if err := json.Unmarshal([]byte(row.FollowUpPostIDs), &version.FollowUpPostIDs); err != nil {
+			return nil, fmt.Errorf("post version %d follow up posts not valid JSON: %w", row.Version, err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// savePostVersion saves a version of a post, keeping the creation time of a version saved before
+func (c *Conversations) savePostVersion(postID string, version PostVersion) error {
+	props, err := json.Marshal(version.Props)
+	if err != nil {
+		return fmt.Errorf("failed to marshal post version props: %w", err)
+	}
+	followUpPostIDs, err := json.Marshal(version.FollowUpPostIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal post version follow up posts: %w", err)
+	}
+
+	_, err = c.db.ExecBuilder(c.db.Builder().Insert("LLM_PostVersions").
+		Columns("PostID", "Version", "CreateAt", "Message", "Props", "FollowUpPostIDs").
+		Values(postID, version.Version, version.CreateAt, version.Message, string(props), string(followUpPostIDs)).
+		Suffix("ON CONFLICT (PostID, Version) DO UPDATE SET Message = ?, Props = ?, FollowUpPostIDs = ?", version.Message, string(props), string(followUpPostIDs)))
+	if err != nil {
+		return fmt.Errorf("failed to save post version: %w", err)
+	}
+	return nil
+}
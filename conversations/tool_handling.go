@@ -13,7 +13,10 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HandleToolCall handles tool call approval/rejection
@@ -38,6 +41,31 @@ func (c *Conversations) HandleToolCall(userID string, post *model.Post, channel
 	if unmarshalErr != nil {
 		return errors.New("post pending tool calls not valid JSON")
 	}
+	steps, err := streaming.GetAgentSteps(post)
+	if err != nil {
+		return err
+	}
+	// Only the step in progress is approved, the tool calls of completed steps were resolved
+	current := llm.Post{
+		Message: post.Message,
+		ToolUse: tools,
+	}
+	current.Reasoning, _ = post.GetProp(streaming.ReasoningSummaryProp).(string)
+	current.ReasoningSignature, _ = post.GetProp(streaming.ReasoningSignatureProp).(string)
+	current = currentAgentStep(current, steps)
+	tools = current.ToolUse
+	// The tool calls of a post are only approved once
+	if !slices.ContainsFunc(tools, func(tc llm.ToolCall) bool {
+		return tc.Status == llm.ToolCallStatusPending
+	}) {
+		return errors.New("post missing pending tool calls")
+	}
+
+	_, span := tracing.Start(context.Background(), "conversations.HandleToolCall",
+		attribute.String("bot", bot.GetConfig().Name),
+		attribute.Int("tool_calls", len(tools)),
+	)
+	defer span.End()
 
 	llmContext := c.contextBuilder.BuildLLMContextUserRequest(
 		bot,
@@ -46,53 +74,85 @@ func (c *Conversations) HandleToolCall(userID string, post *model.Post, channel
 		c.contextBuilder.WithLLMContextDefaultTools(bot),
 	)
 
+	botConfig := bot.GetConfig()
 	for i := range tools {
-		if slices.Contains(acceptedToolIDs, tools[i].ID) {
-			result, resolveErr := llmContext.Tools.ResolveTool(tools[i].Name, func(args any) error {
-				return json.Unmarshal(tools[i].Arguments, args)
-			}, llmContext)
-			if resolveErr != nil {
-				// Maybe in the future we can return this to the user and have a retry. For now just tell the LLM it failed.
-				tools[i].Result = "Tool call failed"
-				tools[i].Status = llm.ToolCallStatusError
-				continue
-			}
-			tools[i].Result = result
-			tools[i].Status = llm.ToolCallStatusSuccess
-		} else {
+		switch {
+		case toolPolicy(botConfig, llmContext.Tools, tools[i].Name).EffectivePolicy() == llm.ToolPolicyDeny:
+			tools[i].Result = toolCallDeniedResult
+			tools[i].Status = llm.ToolCallStatusRejected
+		case slices.Contains(acceptedToolIDs, tools[i].ID):
+			tools[i].Status = llm.ToolCallStatusAccepted
+		default:
 			tools[i].Result = "Tool call rejected by user"
 			tools[i].Status = llm.ToolCallStatusRejected
 		}
 	}
 
+	// The response continues in the same post, stopping it also stops the tool calls
+	requestCtx, cancel := c.streamingService.NewRequestContext()
+	// Continue the trace of the tool calls in the response to their results
+	requestCtx = trace.ContextWithSpan(requestCtx, span)
+	streamCtx, err := c.streamingService.GetStreamingContext(requestCtx, post.Id)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("unable to get post streaming context: %w", err)
+	}
+	started := false
+	defer func() {
+		if !started {
+			c.streamingService.FinishStreaming(post.Id)
+		}
+	}()
+
 	responseRootID := post.Id
 	if post.RootId != "" {
 		responseRootID = post.RootId
 	}
 
-	// Update post with the tool call results
-	resolvedToolsJSON, err := json.Marshal(tools)
+	previousConversation, err := mmapi.GetThreadData(c.mmClient, responseRootID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal tool call results: %w", err)
+		return fmt.Errorf("failed to get previous conversation: %w", err)
 	}
-	post.AddProp(streaming.ToolCallProp, string(resolvedToolsJSON))
+	previousConversation.CutoffBeforePostID(post.Id)
 
-	if updateErr := c.mmClient.UpdatePost(post); updateErr != nil {
-		return fmt.Errorf("failed to update post with tool call results: %w", updateErr)
+	// Tool results over their token limit are summarized with the question they answer
+	for i := len(previousConversation.Posts) - 1; i >= 0; i-- {
+		if !c.bots.IsAnyBot(previousConversation.Posts[i].UserId) {
+			llmContext.Question = previousConversation.Posts[i].Message
+			break
+		}
 	}
 
+	resolveToolCalls(streamCtx, bot, llmContext, tools)
+	streaming.AddDelegatedTo(post, c.delegatedBotIDs(tools))
+
 	// Only continue if at lest one tool call was successful
 	if !slices.ContainsFunc(tools, func(tc llm.ToolCall) bool {
 		return tc.Status == llm.ToolCallStatusSuccess
 	}) {
+		// Update post with the tool call results
+		if setErr := streaming.SetToolCalls(post, tools); setErr != nil {
+			return fmt.Errorf("failed to update tool call results: %w", setErr)
+		}
+		if updateErr := c.mmClient.UpdatePost(post); updateErr != nil {
+			return fmt.Errorf("failed to update post with tool call results: %w", updateErr)
+		}
 		return nil
 	}
 
-	previousConversation, err := mmapi.GetThreadData(c.mmClient, responseRootID)
-	if err != nil {
-		return fmt.Errorf("failed to get previous conversation: %w", err)
+	// The resolved tool calls complete a step of the response
+	step := llm.AgentStep{
+		Message:            current.Message,
+		Reasoning:          current.Reasoning,
+		ReasoningSignature: current.ReasoningSignature,
+		ToolCalls:          tools,
+	}
+	if stepErr := streaming.AddAgentStep(post, step); stepErr != nil {
+		return stepErr
+	}
+	if updateErr := c.mmClient.UpdatePost(post); updateErr != nil {
+		return fmt.Errorf("failed to update post with tool call results: %w", updateErr)
 	}
-	previousConversation.CutoffBeforePostID(post.Id)
 	previousConversation.Posts = append(previousConversation.Posts, post)
 
 	posts, err := c.existingConversationToLLMPosts(bot, previousConversation, llmContext)
@@ -100,22 +160,23 @@ func (c *Conversations) HandleToolCall(userID string, post *model.Post, channel
 		return fmt.Errorf("failed to convert existing conversation to LLM posts: %w", err)
 	}
 
+	llmContext.RootPostID = responseRootID
 	completionRequest := llm.CompletionRequest{
 		Posts:   posts,
 		Context: llmContext,
 	}
-	result, err := bot.LLM().ChatCompletion(completionRequest)
+	result, err := bot.LLM().ChatCompletion(streamCtx, completionRequest, llm.WithTask(llm.TaskToolFollowup))
 	if err != nil {
 		return fmt.Errorf("failed to get chat completion: %w", err)
 	}
-
-	responsePost := &model.Post{
-		ChannelId: channel.Id,
-		RootId:    responseRootID,
-	}
-	if err := c.streamingService.StreamToNewPost(context.Background(), bot.GetMMBot().UserId, user.Id, result, responsePost, post.Id); err != nil {
-		return fmt.Errorf("failed to stream result to new post: %w", err)
-	}
+	result = c.runAgentLoop(streamCtx, bot, completionRequest, result)
+
+	started = true
+	locale := c.responseLocale(bot, user, channel)
+	go func() {
+		defer c.streamingService.FinishStreaming(post.Id)
+		c.streamingService.StreamToPost(streamCtx, result, post, locale)
+	}()
 
 	return nil
 }
@@ -0,0 +1,50 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/bots"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+const toolCallDeniedResult = "Tool call denied by policy"
+
+// toolPolicy returns the rule of the bot's tool policies that applies to the tool called name.
+func toolPolicy(botConfig llm.BotConfig, tools *llm.ToolStore, name string) llm.ToolPolicyRule {
+	tool, ok := tools.GetTool(name)
+	if !ok {
+		tool = llm.Tool{Name: name}
+	}
+	return botConfig.ToolPolicyFor(tool)
+}
+
+// applyToolPolicies rejects the tool calls denied by the bot's tool policies and accepts the
+// auto-approved ones. It reports whether any of the calls still needs the user's approval.
+func applyToolPolicies(botConfig llm.BotConfig, tools *llm.ToolStore, toolCalls []llm.ToolCall) bool {
+	needsApproval := false
+	for i := range toolCalls {
+		switch toolPolicy(botConfig, tools, toolCalls[i].Name).EffectivePolicy() {
+		case llm.ToolPolicyDeny:
+			toolCalls[i].Result = toolCallDeniedResult
+			toolCalls[i].Status = llm.ToolCallStatusRejected
+		case llm.ToolPolicyAutoApprove:
+			toolCalls[i].Status = llm.ToolCallStatusAccepted
+		default:
+			toolCalls[i].Status = llm.ToolCallStatusPending
+			needsApproval = true
+		}
+	}
+	return needsApproval
+}
+
+// resolveToolCalls resolves the accepted tool calls concurrently, within the bot's limits.
+func resolveToolCalls(ctx context.Context, bot *bots.Bot, llmContext *llm.Context, toolCalls []llm.ToolCall) {
+	botConfig := bot.GetConfig()
+	llmContext.Tools.ResolveToolCalls(ctx, llmContext, toolCalls, botConfig.MaxParallelToolCalls, func(name string) time.Duration {
+		return toolPolicy(botConfig, llmContext.Tools, name).Timeout()
+	})
+}
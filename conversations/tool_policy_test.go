@@ -0,0 +1,43 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyToolPolicies(t *testing.T) {
+	botConfig := llm.BotConfig{
+		ToolPolicies: []llm.ToolPolicyRule{
+			{Tool: "search", Policy: llm.ToolPolicyAutoApprove},
+			{Tool: "delete", Policy: llm.ToolPolicyDeny},
+		},
+	}
+
+	t.Run("tools that need approval", func(t *testing.T) {
+		toolCalls := []llm.ToolCall{
+			{ID: "1", Name: "search"},
+			{ID: "2", Name: "post"},
+			{ID: "3", Name: "delete"},
+		}
+		assert.True(t, applyToolPolicies(botConfig, llm.NewNoTools(), toolCalls))
+		assert.Equal(t, llm.ToolCallStatusAccepted, toolCalls[0].Status)
+		assert.Equal(t, llm.ToolCallStatusPending, toolCalls[1].Status)
+		assert.Equal(t, llm.ToolCallStatusRejected, toolCalls[2].Status)
+		assert.Equal(t, toolCallDeniedResult, toolCalls[2].Result)
+	})
+
+	t.Run("tools that don't need approval", func(t *testing.T) {
+		toolCalls := []llm.ToolCall{
+			{ID: "1", Name: "search"},
+			{ID: "2", Name: "delete"},
+		}
+		assert.False(t, applyToolPolicies(botConfig, llm.NewNoTools(), toolCalls))
+		assert.Equal(t, llm.ToolCallStatusAccepted, toolCalls[0].Status)
+		assert.Equal(t, llm.ToolCallStatusRejected, toolCalls[1].Status)
+	})
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// ActiveVersionProp is the version of a regenerated response the post shows
+	ActiveVersionProp = "active_version"
+	// VersionCountProp is the number of responses generated for a post
+	VersionCountProp = "version_count"
+)
+
+// ErrPostVersionNotFound is returned when switching a post to a version it doesn't have.
+var ErrPostVersionNotFound = errors.New("post version not found")
+
+// versionedProps are the props of a response that belong to one of its versions
+var versionedProps = []string{
+	streaming.ReasoningSummaryProp,
+	streaming.ReasoningSignatureProp,
+	streaming.ToolCallProp,
+	streaming.AgentStepsProp,
+	streaming.AnnotationsProp,
+	streaming.ServiceProp,
+	streaming.DelegatedToProp,
+}
+
+// PostVersion is one of the responses generated for a bot post. Regenerating a response adds a
+// version, and the post shows the active one.
+type PostVersion struct {
+	Version  int    `json:"version"`
+	CreateAt int64  `json:"create_at"`
+	Message  string `json:"message"`
+	// Props are the reasoning, tool calls, agent steps, annotations, service and delegations of the response
+	Props map[string]any `json:"props"`
+	// FollowUpPostIDs are the posts of the thread written while the version was active, which
+	// belong to its branch of the conversation
+	FollowUpPostIDs []string `json:"-"`
+}
+
+// PostVersions are the versions of a post and the one it shows.
+type PostVersions struct {
+	ActiveVersion int           `json:"active_version"`
+	Versions      []PostVersion `json:"versions"`
+}
+
+func postVersionProp(post *model.Post, key string) int {
+	switch value := post.GetProp(key).(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+// activeVersion returns the version post shows, 1 for posts that were never regenerated.
+func activeVersion(post *model.Post) int {
+	if version := postVersionProp(post, ActiveVersionProp); version > 0 {
+		return version
+	}
+	return 1
+}
+
+// shownVersion returns the response post shows as a version.
+func shownVersion(post *model.Post) PostVersion {
+	props := make(map[string]any)
+	for _, key := range versionedProps {
+		if value := post.GetProp(key); value != nil {
+			props[key] = value
+		}
+	}
+	return PostVersion{
+		Version:  activeVersion(post),
+		CreateAt: post.CreateAt,
+		Message:  post.Message,
+		Props:    props,
+	}
+}
+
+// showVersion replaces the response post shows with version.
+func showVersion(post *model.Post, version PostVersion) {
+	post.Message = version.Message
+	for _, key := range versionedProps {
+		post.DelProp(key)
+	}
+	for key, value := range version.Props {
+		post.AddProp(key, value)
+	}
+	post.AddProp(ActiveVersionProp, version.Version)
+}
+
+// followUpPostIDs returns the posts of thread after post that aren't in the branch of another version.
+func followUpPostIDs(thread *mmapi.ThreadData, post *model.Post, versions []PostVersion, active int) []string {
+	otherBranches := make(map[string]bool)
+	for _, version := range versions {
+		if version.Version == active {
+			continue
+		}
+		for _, postID := range version.FollowUpPostIDs {
+			otherBranches[postID] = true
+		}
+	}
+
+	var postIDs []string
+	for _, threadPost := range thread.Posts {
+		if threadPost.CreateAt > post.CreateAt && !otherBranches[threadPost.Id] {
+			postIDs = append(postIDs, threadPost.Id)
+		}
+	}
+	return postIDs
+}
+
+// saveShownVersion saves the response post shows as its active version, along with the posts
+// written since it became active. It returns the highest version of the post.
+func (c *Conversations) saveShownVersion(post *model.Post, versions []PostVersion) (int, error) {
+	thread, err := mmapi.GetThreadData(c.mmClient, post.Id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get thread: %w", err)
+	}
+
+	shown := shownVersion(post)
+	shown.FollowUpPostIDs = followUpPostIDs(thread, post, versions, shown.Version)
+	if err := c.savePostVersion(post.Id, shown); err != nil {
+		return 0, err
+	}
+
+	latest := shown.Version
+	for _, version := range versions {
+		latest = max(latest, version.Version)
+	}
+	return latest, nil
+}
+
+// startPostVersion keeps the response post shows before it is regenerated, and makes the
+// response that is about to be generated a new version. The new version is returned to be saved
+// by savePostVersion once it has been generated, or nil when versions aren't available.
+func (c *Conversations) startPostVersion(post *model.Post) (*PostVersion, error) {
+	if c.db == nil {
+		return nil, nil
+	}
+
+	versions, err := c.getPostVersions(post.Id)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := c.saveShownVersion(post, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &PostVersion{
+		Version:  latest + 1,
+		CreateAt: model.GetMillis(),
+	}
+	post.AddProp(ActiveVersionProp, next.Version)
+	post.AddProp(VersionCountProp, next.Version)
+	return next, nil
+}
+
+// finishPostVersion saves the response generated for post since startPostVersion returned version.
+func (c *Conversations) finishPostVersion(post *model.Post, version *PostVersion) error {
+	if version == nil {
+		return nil
+	}
+
+	generated := shownVersion(post)
+	generated.Version = version.Version
+	generated.CreateAt = version.CreateAt
+	return c.savePostVersion(post.Id, generated)
+}
+
+// GetPostVersions returns the responses generated for a bot post.
+func (c *Conversations) GetPostVersions(post *model.Post) (PostVersions, error) {
+	shown := shownVersion(post)
+	if c.db == nil {
+		return PostVersions{ActiveVersion: shown.Version, Versions: []PostVersion{shown}}, nil
+	}
+
+	versions, err := c.getPostVersions(post.Id)
+	if err != nil {
+		return PostVersions{}, err
+	}
+
+	// The stored copy of the active version is only updated when another version becomes active,
+	// and a response that is still being generated isn't stored yet
+	result := PostVersions{ActiveVersion: shown.Version}
+	stored := false
+	for _, version := range versions {
+		if version.Version == shown.Version {
+			shown.CreateAt = version.CreateAt
+			version = shown
+			stored = true
+		}
+		result.Versions = append(result.Versions, version)
+	}
+	if !stored {
+		result.Versions = append(result.Versions, shown)
+	}
+	return result, nil
+}
+
+// SwitchPostVersion makes post show another of its versions. The conversation continues from the
+// branch of the version, without the posts written while other versions were active.
+func (c *Conversations) SwitchPostVersion(post *model.Post, versionNumber int) error {
+	if c.db == nil {
+		return errors.New("post versions are not available")
+	}
+
+	// Hold the post like a response being generated so it isn't switched during a regeneration
+	if _, err := c.streamingService.GetStreamingContext(context.Background(), post.Id); err != nil {
+		return err
+	}
+	defer c.streamingService.FinishStreaming(post.Id)
+
+	versions, err := c.getPostVersions(post.Id)
+	if err != nil {
+		return err
+	}
+	var target *PostVersion
+	for i := range versions {
+		if versions[i].Version == versionNumber {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return ErrPostVersionNotFound
+	}
+	if target.Version == activeVersion(post) {
+		return nil
+	}
+
+	if _, err := c.saveShownVersion(post, versions); err != nil {
+		return err
+	}
+	showVersion(post, *target)
+	if err := c.mmClient.UpdatePost(post); err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+	return nil
+}
+
+// activeBranch returns thread without the posts that followed inactive versions of its responses.
+func (c *Conversations) activeBranch(thread *mmapi.ThreadData) *mmapi.ThreadData {
+	if c.db == nil {
+		return thread
+	}
+
+	otherBranches := make(map[string]bool)
+	for _, post := range thread.Posts {
+		if otherBranches[post.Id] || postVersionProp(post, VersionCountProp) < 2 {
+			continue
+		}
+		versions, err := c.getPostVersions(post.Id)
+		if err != nil {
+			c.mmClient.LogError("Failed to get post versions", "post_id", post.Id, "error", err)
+			continue
+		}
+		active := activeVersion(post)
+		for _, version := range versions {
+			if version.Version == active {
+				continue
+			}
+			for _, postID := range version.FollowUpPostIDs {
+				otherBranches[postID] = true
+			}
+		}
+	}
+	if len(otherBranches) == 0 {
+		return thread
+	}
+
+	branch := &mmapi.ThreadData{UsersByID: thread.UsersByID}
+	for _, post := range thread.Posts {
+		if !otherBranches[post.Id] {
+			branch.Posts = append(branch.Posts, post)
+		}
+	}
+	return branch
+}
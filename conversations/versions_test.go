@@ -0,0 +1,81 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package conversations
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostVersions(t *testing.T) {
+	t.Run("switching versions replaces the response", func(t *testing.T) {
+		post := &model.Post{Id: "response", Message: "First answer", CreateAt: 10}
+		post.AddProp(streaming.ReasoningSummaryProp, "Thinking about it")
+		post.AddProp(streaming.ServiceProp, `{"id":"openai","model":"gpt-large"}`)
+		post.AddProp(streaming.LLMRequesterUserID, "user")
+
+		first := shownVersion(post)
+		assert.Equal(t, 1, first.Version)
+		assert.Equal(t, int64(10), first.CreateAt)
+
+		showVersion(post, PostVersion{
+			Version: 2,
+			Message: "Second answer",
+			Props:   map[string]any{streaming.ServiceProp: `{"id":"openai","model":"gpt-mini"}`},
+		})
+		assert.Equal(t, "Second answer", post.Message)
+		assert.Nil(t, post.GetProp(streaming.ReasoningSummaryProp))
+		assert.Equal(t, `{"id":"openai","model":"gpt-mini"}`, post.GetProp(streaming.ServiceProp))
+		assert.Equal(t, "user", post.GetProp(streaming.LLMRequesterUserID), "props of the post aren't versioned")
+		assert.Equal(t, 2, activeVersion(post))
+
+		showVersion(post, first)
+		assert.Equal(t, "First answer", post.Message)
+		assert.Equal(t, "Thinking about it", post.GetProp(streaming.ReasoningSummaryProp))
+		assert.Equal(t, 1, activeVersion(post))
+	})
+
+	t.Run("versions stored in props as JSON numbers", func(t *testing.T) {
+		post := &model.Post{}
+		post.AddProp(ActiveVersionProp, float64(3))
+		assert.Equal(t, 3, activeVersion(post))
+		assert.Equal(t, 1, activeVersion(&model.Post{}))
+	})
+
+	t.Run("follow up posts exclude the branches of other versions", func(t *testing.T) {
+		response := &model.Post{Id: "response", CreateAt: 20}
+		thread := &mmapi.ThreadData{Posts: []*model.Post{
+			{Id: "question", CreateAt: 10},
+			response,
+			{Id: "followup-v1", CreateAt: 30},
+			{Id: "answer-v1", CreateAt: 40},
+			{Id: "followup-v2", CreateAt: 50},
+		}}
+		versions := []PostVersion{
+			{Version: 1, FollowUpPostIDs: []string{"followup-v1", "answer-v1"}},
+			{Version: 2},
+		}
+
+		assert.Equal(t, []string{"followup-v2"}, followUpPostIDs(thread, response, versions, 2))
+		assert.Equal(t, []string{"followup-v1", "answer-v1", "followup-v2"}, followUpPostIDs(thread, response, versions[1:], 1))
+	})
+
+	t.Run("without a database only the shown version is listed", func(t *testing.T) {
+		c := &Conversations{}
+		post := &model.Post{Id: "response", Message: "Answer"}
+		versions, err := c.GetPostVersions(post)
+		require.NoError(t, err)
+		assert.Equal(t, 1, versions.ActiveVersion)
+		require.Len(t, versions.Versions, 1)
+		assert.Equal(t, "Answer", versions.Versions[0].Message)
+
+		thread := &mmapi.ThreadData{Posts: []*model.Post{post}}
+		assert.Same(t, thread, c.activeBranch(thread))
+	})
+}
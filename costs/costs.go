@@ -0,0 +1,173 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package costs prices the tokens used by bot requests and aggregates their cost into reports.
+package costs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+)
+
+// Dimension is a column a cost report can be grouped by.
+type Dimension string
+
+const (
+	DimensionDay         Dimension = "day"
+	DimensionTeam        Dimension = "team"
+	DimensionBot         Dimension = "bot"
+	DimensionUser        Dimension = "user"
+	DimensionChannelType Dimension = "channel_type"
+	DimensionModel       Dimension = "model"
+)
+
+// Dimensions lists every dimension a report can be grouped by.
+var Dimensions = []Dimension{DimensionDay, DimensionTeam, DimensionBot, DimensionUser, DimensionChannelType, DimensionModel}
+
+type Config interface {
+	GetModelPrices() []llm.ModelPrice
+}
+
+// ReportRow is the cost of the requests sharing the values of the report's dimensions.
+// Dimensions the report isn't grouped by are empty.
+type ReportRow struct {
+	Day          string  `json:"day,omitempty"`
+	TeamID       string  `json:"team_id,omitempty"`
+	BotName      string  `json:"bot_name,omitempty"`
+	UserID       string  `json:"user_id,omitempty"`
+	ChannelType  string  `json:"channel_type,omitempty"`
+	ServiceType  string  `json:"service_type,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost_usd"`
+}
+
+// csvColumns returns the CSV columns of a dimension.
+func csvColumns(dimension Dimension) []string {
+	if dimension == DimensionModel {
+		return []string{"service_type", "model"}
+	}
+	return []string{string(dimension)}
+}
+
+// dimensionValues returns the values of the CSV columns of a dimension.
+func (r ReportRow) dimensionValues(dimension Dimension) []string {
+	switch dimension {
+	case DimensionDay:
+		return []string{r.Day}
+	case DimensionTeam:
+		return []string{r.TeamID}
+	case DimensionBot:
+		return []string{r.BotName}
+	case DimensionUser:
+		return []string{r.UserID}
+	case DimensionChannelType:
+		return []string{r.ChannelType}
+	case DimensionModel:
+		return []string{r.ServiceType, r.Model}
+	default:
+		return []string{""}
+	}
+}
+
+// store persists costs aggregated per day, bot, team, channel type, user and model.
+type store interface {
+	addCost(day time.Time, record llm.CostRecord) error
+	getReport(groupBy []Dimension, since, until time.Time) ([]ReportRow, error)
+}
+
+// Service prices and records the cost of requests. It implements llm.CostTracker.
+type Service struct {
+	store  store
+	config Config
+	log    llm.Logger
+	now    func() time.Time
+}
+
+func New(db *mmapi.DBClient, config Config, log llm.Logger) *Service {
+	return &Service{
+		store:  &dbStore{db: db},
+		config: config,
+		log:    log,
+		now:    time.Now,
+	}
+}
+
+// LookupPrice returns the price of a model, preferring the prices configured by the admin over the defaults.
+func (s *Service) LookupPrice(serviceType, model string) (llm.ModelPrice, bool) {
+	return llm.NewPricingRegistry(s.config.GetModelPrices()).Lookup(serviceType, model)
+}
+
+// RecordCost adds the cost of a request to today's costs.
+func (s *Service) RecordCost(record llm.CostRecord) {
+	if err := s.store.addCost(s.now(), record); err != nil {
+		s.log.Warn("Failed to record token cost", "bot_name", record.BotName, "error", err.Error())
+	}
+}
+
+// ParseDimensions validates the dimensions a report is grouped by.
+func ParseDimensions(values []string) ([]Dimension, error) {
+	result := make([]Dimension, 0, len(values))
+	for _, value := range values {
+		dimension := Dimension(value)
+		valid := false
+		for _, known := range Dimensions {
+			if dimension == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown dimension %q", value)
+		}
+		result = append(result, dimension)
+	}
+	return result, nil
+}
+
+// GetReport returns the cost of the requests made between since and until grouped by groupBy.
+func (s *Service) GetReport(groupBy []Dimension, since, until time.Time) ([]ReportRow, error) {
+	return s.store.getReport(groupBy, since, until)
+}
+
+// WriteCSV writes rows as CSV with a column for each dimension in groupBy followed by the totals.
+func WriteCSV(w io.Writer, groupBy []Dimension, rows []ReportRow) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(groupBy)+4)
+	for _, dimension := range groupBy {
+		header = append(header, csvColumns(dimension)...)
+	}
+	header = append(header, "input_tokens", "output_tokens", "cost_usd")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write cost report: %w", err)
+	}
+
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+		for _, dimension := range groupBy {
+			record = append(record, row.dimensionValues(dimension)...)
+		}
+		record = append(record,
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write cost report: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write cost report: %w", err)
+	}
+	return nil
+}
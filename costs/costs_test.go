@@ -0,0 +1,101 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package costs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type costRow struct {
+	day    time.Time
+	record llm.CostRecord
+}
+
+type memoryStore struct {
+	rows []costRow
+}
+
+func (s *memoryStore) addCost(day time.Time, record llm.CostRecord) error {
+	s.rows = append(s.rows, costRow{day, record})
+	return nil
+}
+
+func (s *memoryStore) getReport([]Dimension, time.Time, time.Time) ([]ReportRow, error) {
+	return nil, nil
+}
+
+type staticConfig []llm.ModelPrice
+
+func (c staticConfig) GetModelPrices() []llm.ModelPrice {
+	return c
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Debug(string, ...any) {}
+
+func TestLookupPrice(t *testing.T) {
+	service := &Service{
+		config: staticConfig{{ServiceType: llm.ServiceTypeOpenAICompatible, Model: "llama3", InputPerMillion: 0.2}},
+		log:    noopLogger{},
+	}
+
+	price, ok := service.LookupPrice(llm.ServiceTypeOpenAICompatible, "llama3:70b")
+	require.True(t, ok)
+	assert.Equal(t, 0.2, price.InputPerMillion)
+
+	price, ok = service.LookupPrice(llm.ServiceTypeOpenAI, "gpt-4o")
+	require.True(t, ok)
+	assert.Equal(t, 2.50, price.InputPerMillion)
+}
+
+func TestRecordCost(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 23, 59, 0, 0, time.UTC)
+	store := &memoryStore{}
+	service := &Service{
+		store:  store,
+		config: staticConfig{},
+		log:    noopLogger{},
+		now:    func() time.Time { return now },
+	}
+
+	record := llm.CostRecord{BotName: "bot", TeamID: "team", UserID: "user", Model: "gpt-4o", Usage: llm.TokenUsage{InputTokens: 10}, Cost: 0.5}
+	service.RecordCost(record)
+
+	require.Len(t, store.rows, 1)
+	assert.Equal(t, now, store.rows[0].day)
+	assert.Equal(t, record, store.rows[0].record)
+}
+
+func TestParseDimensions(t *testing.T) {
+	dimensions, err := ParseDimensions([]string{"day", "team", "bot"})
+	require.NoError(t, err)
+	assert.Equal(t, []Dimension{DimensionDay, DimensionTeam, DimensionBot}, dimensions)
+
+	_, err = ParseDimensions([]string{"day", "channel"})
+	assert.Error(t, err)
+}
+
+func TestWriteCSV(t *testing.T) {
+	rows := []ReportRow{
+		{Day: "2024-03-01", BotName: "ai", InputTokens: 1000, OutputTokens: 200, Cost: 0.0045},
+		{Day: "2024-03-01", BotName: "helper, the second", InputTokens: 10, OutputTokens: 2, Cost: 0.0000001},
+		{Day: "2024-03-02", BotName: "ai", ServiceType: "openai", Model: "gpt-4o", InputTokens: 5, OutputTokens: 1, Cost: 1},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, []Dimension{DimensionDay, DimensionBot, DimensionModel}, rows))
+
+	assert.Equal(t, "day,bot,service_type,model,input_tokens,output_tokens,cost_usd\n"+
+		"2024-03-01,ai,,,1000,200,0.004500\n"+
+		"2024-03-01,\"helper, the second\",,,10,2,0.000000\n"+
+		"2024-03-02,ai,openai,gpt-4o,5,1,1.000000\n", buf.String())
+}
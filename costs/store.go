@@ -0,0 +1,88 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package costs
+
+import (
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+)
+
+const dayFormat = "2006-01-02"
+
+// dbStore stores costs in the LLM_TokenCost table.
+type dbStore struct {
+	db *mmapi.DBClient
+}
+
+// dimensionColumns returns the columns selected and grouped by for a dimension.
+func dimensionColumns(dimension Dimension) (selects []string, groupBy []string) {
+	switch dimension {
+	case DimensionDay:
+		return []string{"TO_CHAR(Day, 'YYYY-MM-DD') AS Day"}, []string{"Day"}
+	case DimensionTeam:
+		return []string{"TeamID"}, []string{"TeamID"}
+	case DimensionBot:
+		return []string{"BotName"}, []string{"BotName"}
+	case DimensionUser:
+		return []string{"UserID"}, []string{"UserID"}
+	case DimensionChannelType:
+		return []string{"ChannelType"}, []string{"ChannelType"}
+	case DimensionModel:
+		return []string{"ServiceType", "Model"}, []string{"ServiceType", "Model"}
+	default:
+		return nil, nil
+	}
+}
+
+func (s *dbStore) addCost(day time.Time, record llm.CostRecord) error {
+	_, err := s.db.ExecBuilder(s.db.Builder().Insert("LLM_TokenCost").
+		Columns("Day", "BotName", "TeamID", "ChannelType", "UserID", "ServiceType", "Model", "InputTokens", "OutputTokens", "Cost").
+		Values(day.UTC().Format(dayFormat), record.BotName, record.TeamID, record.ChannelType, record.UserID, record.ServiceType, record.Model,
+			record.Usage.InputTokens, record.Usage.OutputTokens, record.Cost).
+		Suffix("ON CONFLICT (Day, BotName, TeamID, ChannelType, UserID, ServiceType, Model) DO UPDATE SET " +
+			"InputTokens = LLM_TokenCost.InputTokens + EXCLUDED.InputTokens, " +
+			"OutputTokens = LLM_TokenCost.OutputTokens + EXCLUDED.OutputTokens, " +
+			"Cost = LLM_TokenCost.Cost + EXCLUDED.Cost"))
+	if err != nil {
+		return fmt.Errorf("failed to add token cost: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStore) getReport(groupBy []Dimension, since, until time.Time) ([]ReportRow, error) {
+	var selects, groupColumns []string
+	for _, dimension := range groupBy {
+		dimensionSelects, dimensionGroupBy := dimensionColumns(dimension)
+		if dimensionSelects == nil {
+			return nil, fmt.Errorf("unknown dimension %q", dimension)
+		}
+		selects = append(selects, dimensionSelects...)
+		groupColumns = append(groupColumns, dimensionGroupBy...)
+	}
+	selects = append(selects,
+		"COALESCE(SUM(InputTokens), 0) AS InputTokens",
+		"COALESCE(SUM(OutputTokens), 0) AS OutputTokens",
+		"COALESCE(SUM(Cost), 0) AS Cost",
+	)
+
+	query := s.db.Builder().
+		Select(selects...).
+		From("LLM_TokenCost").
+		Where(sq.GtOrEq{"Day": since.UTC().Format(dayFormat)}).
+		Where(sq.Lt{"Day": until.UTC().Format(dayFormat)})
+	if len(groupColumns) > 0 {
+		query = query.GroupBy(groupColumns...).OrderBy(groupColumns...)
+	}
+
+	var rows []ReportRow
+	if err := s.db.DoQuery(&rows, query); err != nil {
+		return nil, fmt.Errorf("failed to get token cost report: %w", err)
+	}
+
+	return rows, nil
+}
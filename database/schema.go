@@ -15,6 +15,26 @@ func SetupTables(db *sqlx.DB) error {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	if err := addConversationSummaryColumns(db); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	if err := createLLMTokenUsageTable(db); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	if err := createLLMTokenCostTable(db); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	if err := createLLMPostVersionsTable(db); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	if err := createLLMMemoriesTable(db); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
 	if err := migrateOldTables(db); err != nil {
 		return fmt.Errorf("failed to migrate old tables: %w", err)
 	}
@@ -36,6 +56,101 @@ func createLLMPostMetaTable(db *sqlx.DB) error {
 	return nil
 }
 
+// addConversationSummaryColumns adds the columns caching conversation summaries to the LLM_PostMeta table
+func addConversationSummaryColumns(db *sqlx.DB) error {
+	if _, err := db.Exec(`
+		ALTER TABLE LLM_PostMeta
+			ADD COLUMN IF NOT EXISTS Summary TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS SummaryPostCount INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS SummaryHash TEXT NOT NULL DEFAULT '';
+	`); err != nil {
+		return fmt.Errorf("can't add summary columns to llm postmeta table: %w", err)
+	}
+
+	return nil
+}
+
+// createLLMTokenUsageTable creates the LLM_TokenUsage table that aggregates token usage per user, team, bot and day
+func createLLMTokenUsageTable(db *sqlx.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS LLM_TokenUsage (
+			UserID TEXT NOT NULL,
+			TeamID TEXT NOT NULL,
+			BotName TEXT NOT NULL,
+			Day DATE NOT NULL,
+			InputTokens BIGINT NOT NULL DEFAULT 0,
+			OutputTokens BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (UserID, TeamID, BotName, Day)
+		);
+	`); err != nil {
+		return fmt.Errorf("can't create llm token usage table: %w", err)
+	}
+
+	return nil
+}
+
+// createLLMTokenCostTable creates the LLM_TokenCost table that aggregates the cost of requests per day,
+// bot, team, channel type, user and model
+func createLLMTokenCostTable(db *sqlx.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS LLM_TokenCost (
+			Day DATE NOT NULL,
+			BotName TEXT NOT NULL,
+			TeamID TEXT NOT NULL,
+			ChannelType TEXT NOT NULL,
+			UserID TEXT NOT NULL,
+			ServiceType TEXT NOT NULL,
+			Model TEXT NOT NULL,
+			InputTokens BIGINT NOT NULL DEFAULT 0,
+			OutputTokens BIGINT NOT NULL DEFAULT 0,
+			Cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+			PRIMARY KEY (Day, BotName, TeamID, ChannelType, UserID, ServiceType, Model)
+		);
+	`); err != nil {
+		return fmt.Errorf("can't create llm token cost table: %w", err)
+	}
+
+	return nil
+}
+
+// createLLMPostVersionsTable creates the LLM_PostVersions table that keeps the responses generated for a bot post.
+// Versions are deleted with their post, including by data retention.
+func createLLMPostVersionsTable(db *sqlx.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS LLM_PostVersions (
+			PostID TEXT NOT NULL REFERENCES Posts(ID) ON DELETE CASCADE,
+			Version INTEGER NOT NULL,
+			CreateAt BIGINT NOT NULL,
+			Message TEXT NOT NULL,
+			Props TEXT NOT NULL,
+			FollowUpPostIDs TEXT NOT NULL,
+			PRIMARY KEY (PostID, Version)
+		);
+	`); err != nil {
+		return fmt.Errorf("can't create llm post versions table: %w", err)
+	}
+
+	return nil
+}
+
+// createLLMMemoriesTable creates the LLM_Memories table that keeps the facts bots saved about users
+func createLLMMemoriesTable(db *sqlx.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS LLM_Memories (
+			ID TEXT NOT NULL PRIMARY KEY,
+			UserID TEXT NOT NULL,
+			BotID TEXT NOT NULL,
+			Content TEXT NOT NULL,
+			CreateAt BIGINT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS llm_memories_user_id_bot_id_idx ON LLM_Memories(UserID, BotID);
+	`); err != nil {
+		return fmt.Errorf("can't create llm memories table: %w", err)
+	}
+
+	return nil
+}
+
 // migrateOldTables handles migration from older table structures
 func migrateOldTables(db *sqlx.DB) error {
 	// This fixes data retention issues when a post is deleted for an older version of the postmeta table.
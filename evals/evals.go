@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/mattermost/mattermost-plugin-ai/anthropic"
 	"github.com/mattermost/mattermost-plugin-ai/bedrock"
+	"github.com/mattermost/mattermost-plugin-ai/gemini"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/openai"
 	"github.com/mattermost/mattermost-plugin-ai/prompts"
@@ -35,7 +37,48 @@ type Eval struct {
 
 // createProvider creates an LLM provider based on the provider name
 // Reads configuration from environment variables with optional model override
+//
+// The "replay" provider answers with responses recorded in the fixtures directory so evals can run
+// offline. Responses from other providers are recorded there when EVAL_RECORD is set to true.
 func createProvider(providerName string, modelOverride string) (llm.LanguageModel, error) {
+	if strings.EqualFold(providerName, "replay") {
+		dir, err := fixturesDir()
+		if err != nil {
+			return nil, err
+		}
+		return llm.NewReplayLanguageModel(dir, os.Getenv("EVAL_REPLAY_TIMING") == "true"), nil
+	}
+
+	provider, err := createLiveProvider(providerName, modelOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("EVAL_RECORD") == "true" {
+		dir, err := fixturesDir()
+		if err != nil {
+			return nil, err
+		}
+		return llm.NewRecordingWrapper(provider, dir), nil
+	}
+	return provider, nil
+}
+
+// fixturesDir returns the directory recorded responses are stored in, set by EVAL_FIXTURES_DIR
+// and defaulting to testdata/fixtures in the current module
+func fixturesDir() (string, error) {
+	if dir := os.Getenv("EVAL_FIXTURES_DIR"); dir != "" {
+		return dir, nil
+	}
+	root, err := findCurrentModuleRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to find fixtures directory: %w", err)
+	}
+	return filepath.Join(root, "evals", "testdata", "fixtures"), nil
+}
+
+// createLiveProvider creates a provider that calls the LLM service
+func createLiveProvider(providerName string, modelOverride string) (llm.LanguageModel, error) {
 	httpClient := &http.Client{}
 	timeout := 20 * time.Second
 
@@ -204,6 +247,29 @@ func createProvider(providerName string, modelOverride string) (llm.LanguageMode
 		}
 		return provider, nil
 
+	case "gemini":
+		apiKey := os.Getenv("GEMINI_API_KEY")
+		if apiKey == "" {
+			return nil, errors.New("GEMINI_API_KEY environment variable is not set")
+		}
+
+		model := modelOverride
+		if model == "" {
+			model = os.Getenv("GEMINI_MODEL")
+			if model == "" {
+				model = "gemini-2.5-flash"
+			}
+		}
+
+		provider := gemini.New(llm.ServiceConfig{
+			APIKey:       apiKey,
+			APIURL:       os.Getenv("GEMINI_API_URL"),
+			DefaultModel: model,
+		}, llm.BotConfig{
+			ReasoningEnabled: true,
+		}, httpClient)
+		return provider, nil
+
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", providerName)
 	}
@@ -229,7 +295,7 @@ func NewEvalWithProvider(providerName string) (*Eval, error) {
 	}
 
 	// Setup grader LLM (separate from main LLM)
-	graderLLM, err := createGraderLLM()
+	graderLLM, err := createGraderLLM(providerName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create grader LLM: %w", err)
 	}
@@ -242,12 +308,15 @@ func NewEvalWithProvider(providerName string) (*Eval, error) {
 }
 
 // createGraderLLM creates a separate LLM for grading based on environment variables
-// Defaults to OpenAI with gpt-5 model if not specified
-func createGraderLLM() (llm.LanguageModel, error) {
+// Defaults to OpenAI with gpt-5 model if not specified, or to the recorded grades when replaying
+func createGraderLLM(providerName string) (llm.LanguageModel, error) {
 	// Get grader provider name from environment, default to "openai"
 	graderProvider := os.Getenv("GRADER_LLM_PROVIDER")
 	if graderProvider == "" {
 		graderProvider = "openai"
+		if strings.EqualFold(providerName, "replay") {
+			graderProvider = "replay"
+		}
 	}
 
 	// Get grader model override, default to gpt-5 for OpenAI
@@ -257,7 +326,13 @@ func createGraderLLM() (llm.LanguageModel, error) {
 	}
 
 	// Create grader provider with model override
-	return createProvider(graderProvider, graderModel)
+	grader, err := createProvider(graderProvider, graderModel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Grades are JSON, which some providers don't enforce
+	return llm.NewJSONValidationWrapper(grader, llm.DefaultJSONValidationRetries), nil
 }
 
 func NumEvalsOrSkip(t *testing.T) int {
@@ -313,7 +388,7 @@ func getProvidersToTest() []string {
 
 	// Handle "all" case
 	if providerEnv == "all" {
-		return []string{"openai", "anthropic", "azure", "mistral", "bedrock"}
+		return []string{"openai", "anthropic", "azure", "mistral", "bedrock", "gemini"}
 	}
 
 	// Handle comma-separated list
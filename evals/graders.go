@@ -4,7 +4,7 @@
 package evals
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 
 	"github.com/mattermost/mattermost-plugin-ai/llm"
@@ -13,9 +13,9 @@ import (
 )
 
 type RubricResult struct {
-	Reasoning string
-	Score     float64
-	Pass      bool
+	Reasoning string  `json:"reasoning"`
+	Score     float64 `json:"score"`
+	Pass      bool    `json:"pass"`
 }
 
 const llmRubricSystem = `You are grading output according to the specificed rebric. If the statemnt in the rubric is true, then the output passes the test. You must respond with a JSON object with this structure: {reasoning: string, score: number, pass: boolean}
@@ -43,17 +43,11 @@ func (e *Eval) LLMRubric(rubric, output string) (*RubricResult, error) {
 		Context: llm.NewContext(),
 	}
 
-	llmResult, gradeErr := e.GraderLLM.ChatCompletionNoStream(req, llm.WithMaxGeneratedTokens(1000), llm.WithJSONOutput[RubricResult]())
+	rubricResult, gradeErr := llm.CompleteJSON[RubricResult](context.Background(), e.GraderLLM, req, llm.WithMaxGeneratedTokens(1000))
 	if gradeErr != nil {
 		return nil, fmt.Errorf("failed to grade with llm: %w", gradeErr)
 	}
 
-	rubricResult := RubricResult{}
-	unmarshalErr := json.Unmarshal([]byte(llmResult), &rubricResult)
-	if unmarshalErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal llm result: %w", unmarshalErr)
-	}
-
 	return &rubricResult, nil
 }
 
@@ -0,0 +1,98 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// cloudPlatformScope is the OAuth scope of Vertex AI requests
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// authenticator adds credentials to requests. API keys are sent as they are. Vertex AI is also
+// reached with OAuth access tokens of a service account, given as the JSON key of the account,
+// or of the Application Default Credentials of the server when no key is configured.
+type authenticator struct {
+	apiKey     string
+	useTokens  bool
+	httpClient *http.Client
+
+	once    sync.Once
+	tokens  oauth2.TokenSource
+	initErr error
+}
+
+func newAuthenticator(apiKey, apiURL string, httpClient *http.Client) *authenticator {
+	apiKey = strings.TrimSpace(apiKey)
+	return &authenticator{
+		apiKey:     apiKey,
+		useTokens:  isServiceAccountKey(apiKey) || (apiKey == "" && isVertexURL(apiURL)),
+		httpClient: httpClient,
+	}
+}
+
+// isServiceAccountKey reports whether key is the JSON key of a service account rather than an API key.
+func isServiceAccountKey(key string) bool {
+	return strings.HasPrefix(key, "{")
+}
+
+// isVertexURL reports whether apiURL is a Vertex AI endpoint.
+func isVertexURL(apiURL string) bool {
+	return strings.Contains(apiURL, "aiplatform.googleapis.com")
+}
+
+// tokenSource returns the source of access tokens, created on first use.
+func (a *authenticator) tokenSource() (oauth2.TokenSource, error) {
+	a.once.Do(func() {
+		// Tokens are fetched with the upstream HTTP client and outlive the request that needed them
+		ctx := context.Background()
+		if a.httpClient != nil {
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, a.httpClient)
+		}
+
+		if a.apiKey != "" {
+			config, err := google.JWTConfigFromJSON([]byte(a.apiKey), cloudPlatformScope)
+			if err != nil {
+				a.initErr = fmt.Errorf("invalid service account key: %w", err)
+				return
+			}
+			a.tokens = config.TokenSource(ctx)
+			return
+		}
+
+		credentials, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+		if err != nil {
+			a.initErr = fmt.Errorf("failed to find application default credentials: %w", err)
+			return
+		}
+		a.tokens = credentials.TokenSource
+	})
+	return a.tokens, a.initErr
+}
+
+// authorize adds the credentials to req.
+func (a *authenticator) authorize(req *http.Request) error {
+	if !a.useTokens {
+		req.Header.Set("x-goog-api-key", a.apiKey)
+		return nil
+	}
+
+	tokens, err := a.tokenSource()
+	if err != nil {
+		return err
+	}
+	token, err := tokens.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get vertex ai access token: %w", err)
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package gemini
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticator(t *testing.T) {
+	t.Run("api keys are sent as they are", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		require.NoError(t, newAuthenticator("test-key", "https://aiplatform.googleapis.com/v1/publishers/google", http.DefaultClient).authorize(req))
+		assert.Equal(t, "test-key", req.Header.Get("x-goog-api-key"))
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("service account keys get access tokens", func(t *testing.T) {
+		var tokenRequests atomic.Int32
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenRequests.Add(1)
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"vertex-token","token_type":"Bearer","expires_in":3600}`))
+		}))
+		defer tokenServer.Close()
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		key, err := json.Marshal(map[string]string{
+			"type":           "service_account",
+			"client_email":   "agents@project.iam.gserviceaccount.com",
+			"private_key_id": "key-id",
+			"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+			"token_uri":      tokenServer.URL,
+		})
+		require.NoError(t, err)
+
+		auth := newAuthenticator(string(key), "https://aiplatform.googleapis.com/v1/publishers/google", http.DefaultClient)
+		for range 2 {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			require.NoError(t, auth.authorize(req))
+			assert.Equal(t, "Bearer vertex-token", req.Header.Get("Authorization"))
+			assert.Empty(t, req.Header.Get("x-goog-api-key"))
+		}
+		assert.Equal(t, int32(1), tokenRequests.Load(), "tokens are reused until they expire")
+	})
+
+	t.Run("invalid service account keys are errors", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		require.Error(t, newAuthenticator(`{"type":"authorized_user"}`, "", http.DefaultClient).authorize(req))
+	})
+}
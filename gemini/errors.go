@@ -0,0 +1,120 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// maxErrorBodySize bounds how much of an error response is read
+const maxErrorBodySize = 1 << 20
+
+// APIError is an error response from the Gemini API.
+type APIError struct {
+	StatusCode int
+	// Status is the canonical error code, e.g. RESOURCE_EXHAUSTED
+	Status  string
+	Message string
+	Header  http.Header
+	// RetryDelay is the delay requested in the RetryInfo details of the error, if any
+	RetryDelay time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gemini API error %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// errorBody is the JSON body of an error response.
+type errorBody struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type       string `json:"@type"`
+			RetryDelay string `json:"retryDelay"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// toAPIError converts the error body to an APIError, returning nil if the body holds no error.
+func (b errorBody) toAPIError(statusCode int, header http.Header) *APIError {
+	if b.Error == nil {
+		return nil
+	}
+
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Status:     b.Error.Status,
+		Message:    b.Error.Message,
+		Header:     header,
+	}
+	if apiErr.StatusCode == 0 {
+		apiErr.StatusCode = b.Error.Code
+	}
+	for _, detail := range b.Error.Details {
+		if detail.RetryDelay == "" {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil {
+			apiErr.RetryDelay = delay
+		}
+	}
+	return apiErr
+}
+
+// newAPIError reads the error from a non 200 response.
+func newAPIError(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read gemini error response: %w", err)
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(data, &body); err == nil {
+		if apiErr := body.toAPIError(resp.StatusCode, resp.Header); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    string(data),
+		Header:     resp.Header,
+	}
+}
+
+// ClassifyError categorises errors returned by the Gemini API.
+func ClassifyError(err error) llm.ErrorClass {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return llm.ClassifyHTTPStatus(apiErr.StatusCode)
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by the Gemini API, either in the Retry-After
+// header or in the RetryInfo details of a rate limit error.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	if delay, ok := llm.ParseRetryAfter(apiErr.Header); ok {
+		return delay, true
+	}
+	if apiErr.RetryDelay > 0 {
+		return apiErr.RetryDelay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,726 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package gemini implements llm.LanguageModel for Google Gemini using the Gemini API or Vertex AI.
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
+)
+
+const (
+	// DefaultAPIURL is the Gemini API endpoint. Vertex AI is used by configuring its
+	// publisher endpoint instead, e.g. https://aiplatform.googleapis.com/v1/publishers/google,
+	// with an API key, the JSON key of a service account, or no key for Application Default Credentials
+	DefaultAPIURL          = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultMaxTokens       = 8192
+	DefaultInputTokenLimit = 1000000
+
+	// maxEventSize bounds the size of a single server sent event
+	maxEventSize = 10 * 1024 * 1024
+)
+
+type Gemini struct {
+	httpClient         *http.Client
+	auth               *authenticator
+	apiURL             string
+	defaultModel       string
+	inputTokenLimit    int
+	outputTokenLimit   int
+	enabledNativeTools []string
+	reasoningEnabled   bool
+	thinkingBudget     int
+}
+
+func New(llmService llm.ServiceConfig, botConfig llm.BotConfig, httpClient *http.Client) *Gemini {
+	apiURL := strings.TrimSuffix(llmService.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	return &Gemini{
+		httpClient:         httpClient,
+		auth:               newAuthenticator(llmService.APIKey, apiURL, httpClient),
+		apiURL:             apiURL,
+		defaultModel:       llmService.DefaultModel,
+		inputTokenLimit:    llmService.InputTokenLimit,
+		outputTokenLimit:   llmService.OutputTokenLimit,
+		enabledNativeTools: botConfig.EnabledNativeTools,
+		reasoningEnabled:   botConfig.ReasoningEnabled,
+		thinkingBudget:     botConfig.ThinkingBudget,
+	}
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text    string `json:"text,omitempty"`
+	Thought bool   `json:"thought,omitempty"`
+	// ThoughtSignature is an opaque field that must be sent back with the part it was received on
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *blob             `json:"inlineData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type functionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations,omitempty"`
+	GoogleSearch         *struct{}             `json:"googleSearch,omitempty"`
+}
+
+type functionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	ParametersJSONSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+type thinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema any             `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *thinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type generateContentRequest struct {
+	Contents          []content        `json:"contents"`
+	SystemInstruction *content         `json:"systemInstruction,omitempty"`
+	Tools             []tool           `json:"tools,omitempty"`
+	GenerationConfig  generationConfig `json:"generationConfig"`
+}
+
+type generateContentResponse struct {
+	Candidates     []candidate     `json:"candidates"`
+	UsageMetadata  *usageMetadata  `json:"usageMetadata"`
+	PromptFeedback *promptFeedback `json:"promptFeedback"`
+}
+
+type candidate struct {
+	Content           content            `json:"content"`
+	FinishReason      string             `json:"finishReason"`
+	GroundingMetadata *groundingMetadata `json:"groundingMetadata"`
+}
+
+type promptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int64 `json:"promptTokenCount"`
+	CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int64 `json:"thoughtsTokenCount"`
+}
+
+type groundingMetadata struct {
+	GroundingChunks   []groundingChunk   `json:"groundingChunks"`
+	GroundingSupports []groundingSupport `json:"groundingSupports"`
+}
+
+type groundingChunk struct {
+	Web *struct {
+		URI   string `json:"uri"`
+		Title string `json:"title"`
+	} `json:"web"`
+}
+
+type groundingSupport struct {
+	Segment struct {
+		StartIndex int    `json:"startIndex"`
+		EndIndex   int    `json:"endIndex"`
+		Text       string `json:"text"`
+	} `json:"segment"`
+	GroundingChunkIndices []int `json:"groundingChunkIndices"`
+}
+
+// blockedFinishReasons are the finish reasons of responses stopped by Gemini's content filters
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// isValidImageType checks if the MIME type is supported by the Gemini API
+func isValidImageType(mimeType string) bool {
+	validTypes := map[string]bool{
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/webp": true,
+		"image/heic": true,
+		"image/heif": true,
+	}
+	return validTypes[mimeType]
+}
+
+// conversationToContents creates a system instruction and the contents of the conversation from posts.
+func conversationToContents(posts []llm.Post) (*content, []content) {
+	var system []string
+	contents := make([]content, 0, len(posts))
+
+	var currentParts []part
+	var currentRole string
+
+	flushCurrentContent := func() {
+		if len(currentParts) > 0 {
+			contents = append(contents, content{
+				Role:  currentRole,
+				Parts: currentParts,
+			})
+			currentParts = nil
+		}
+	}
+
+	for _, post := range posts {
+		switch post.Role {
+		case llm.PostRoleSystem:
+			system = append(system, post.Message)
+			continue
+		case llm.PostRoleBot:
+			if currentRole != "model" {
+				flushCurrentContent()
+				currentRole = "model"
+			}
+		case llm.PostRoleUser:
+			if currentRole != "user" {
+				flushCurrentContent()
+				currentRole = "user"
+			}
+		default:
+			continue
+		}
+
+		if post.Message != "" {
+			currentParts = append(currentParts, part{Text: post.Message})
+		}
+
+		for _, file := range post.Files {
+			if !isValidImageType(file.MimeType) {
+				currentParts = append(currentParts, part{Text: fmt.Sprintf("[Unsupported image type: %s]", file.MimeType)})
+				continue
+			}
+
+			data, err := io.ReadAll(file.Reader)
+			if err != nil {
+				currentParts = append(currentParts, part{Text: "[Error reading image data]"})
+				continue
+			}
+
+			currentParts = append(currentParts, part{InlineData: &blob{
+				MimeType: file.MimeType,
+				Data:     base64.StdEncoding.EncodeToString(data),
+			}})
+		}
+
+		if len(post.ToolUse) > 0 {
+			responseParts := make([]part, 0, len(post.ToolUse))
+			for i, toolCall := range post.ToolUse {
+				args := toolCall.Arguments
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				callPart := part{FunctionCall: &functionCall{
+					ID:   toolCall.ID,
+					Name: toolCall.Name,
+					Args: args,
+				}}
+				// Gemini attaches the thought signature to the first function call of a turn
+				if i == 0 {
+					callPart.ThoughtSignature = post.ReasoningSignature
+				}
+				currentParts = append(currentParts, callPart)
+
+				response := map[string]any{"result": toolCall.Result}
+				if toolCall.Status != llm.ToolCallStatusSuccess {
+					response = map[string]any{"error": toolCall.Result}
+				}
+				responseParts = append(responseParts, part{FunctionResponse: &functionResponse{
+					ID:       toolCall.ID,
+					Name:     toolCall.Name,
+					Response: response,
+				}})
+			}
+
+			flushCurrentContent()
+			currentRole = "user"
+			currentParts = responseParts
+			flushCurrentContent()
+		}
+	}
+
+	flushCurrentContent()
+
+	if len(system) == 0 {
+		return nil, contents
+	}
+	return &content{Parts: []part{{Text: strings.Join(system, "\n\n")}}}, contents
+}
+
+// convertTools converts from llm.Tool to Gemini function declarations
+func convertTools(tools []llm.Tool) []functionDeclaration {
+	converted := make([]functionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		converted = append(converted, functionDeclaration{
+			Name:                 t.Name,
+			Description:          t.Description,
+			ParametersJSONSchema: t.Schema,
+		})
+	}
+	return converted
+}
+
+func (g *Gemini) GetDefaultConfig() llm.LanguageModelConfig {
+	config := llm.LanguageModelConfig{
+		Model: g.defaultModel,
+	}
+	if g.outputTokenLimit == 0 {
+		config.MaxGeneratedTokens = DefaultMaxTokens
+	} else {
+		config.MaxGeneratedTokens = g.outputTokenLimit
+	}
+	return config
+}
+
+func (g *Gemini) createConfig(opts []llm.LanguageModelOption) llm.LanguageModelConfig {
+	cfg := g.GetDefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (g *Gemini) buildRequest(request llm.CompletionRequest, cfg llm.LanguageModelConfig) generateContentRequest {
+	system, contents := conversationToContents(request.Posts)
+
+	result := generateContentRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+		GenerationConfig: generationConfig{
+			MaxOutputTokens: cfg.MaxGeneratedTokens,
+		},
+	}
+
+	if !cfg.ToolsDisabled {
+		if request.Context != nil && request.Context.Tools != nil {
+			if declarations := convertTools(request.Context.Tools.GetTools()); len(declarations) > 0 {
+				result.Tools = append(result.Tools, tool{FunctionDeclarations: declarations})
+			}
+		}
+		if g.isNativeToolEnabled("web_search") {
+			result.Tools = append(result.Tools, tool{GoogleSearch: &struct{}{}})
+		}
+	}
+
+	if cfg.JSONOutputFormat != nil {
+		result.GenerationConfig.ResponseMimeType = "application/json"
+		result.GenerationConfig.ResponseJSONSchema = cfg.JSONOutputFormat
+	}
+
+	if g.reasoningEnabled && !cfg.ReasoningDisabled {
+		thinking := &thinkingConfig{IncludeThoughts: true}
+		if g.thinkingBudget > 0 {
+			budget := g.thinkingBudget
+			thinking.ThinkingBudget = &budget
+		}
+		result.GenerationConfig.ThinkingConfig = thinking
+	}
+
+	return result
+}
+
+// modelURL returns the URL of a method of a model, e.g. streamGenerateContent.
+func (g *Gemini) modelURL(model, method string) string {
+	return g.apiURL + "/models/" + url.PathEscape(strings.TrimPrefix(model, "models/")) + ":" + method
+}
+
+func (g *Gemini) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+	cfg := g.createConfig(opts)
+
+	body, err := json.Marshal(g.buildRequest(request, cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gemini request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.modelURL(cfg.Model, "streamGenerateContent")+"?alt=sse", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := g.auth.authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send gemini request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+
+	eventStream := make(chan llm.TextStreamEvent)
+	go func() {
+		defer close(eventStream)
+		defer resp.Body.Close()
+		readStream(ctx, resp.Body, eventStream)
+	}()
+
+	return &llm.TextStreamResult{Stream: eventStream}, nil
+}
+
+func (g *Gemini) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+	// This could perform better if we didn't use the streaming API here, but the complexity is not worth it.
+	result, err := g.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+// readStream converts the server sent events of a streamGenerateContent response to stream events.
+func readStream(ctx context.Context, body io.Reader, output chan<- llm.TextStreamEvent) {
+	sendError := func(err error) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeError,
+			Value: fmt.Errorf("error from gemini stream: %w", err),
+		}
+	}
+
+	// Reasoning ends with the first answer text, but the thought signature can arrive later
+	// on a function call, in which case the reasoning is sent again with the signature.
+	var thinking strings.Builder
+	var signature string
+	reasoningSent := false
+	sentSignature := ""
+	sendReasoning := func() {
+		if thinking.Len() == 0 && signature == "" {
+			return
+		}
+		if reasoningSent && signature == sentSignature {
+			return
+		}
+		output <- llm.TextStreamEvent{
+			Type: llm.EventTypeReasoningEnd,
+			Value: llm.ReasoningData{
+				Text:      thinking.String(),
+				Signature: signature,
+			},
+		}
+		reasoningSent = true
+		sentSignature = signature
+	}
+
+	var toolCalls []llm.ToolCall
+	var grounding *groundingMetadata
+	var usage *usageMetadata
+	var finishReason string
+	hasText := false
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var chunk struct {
+			generateContentResponse
+			errorBody
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			sendError(fmt.Errorf("failed to parse event: %w", err))
+			return
+		}
+		if apiErr := chunk.toAPIError(0, nil); apiErr != nil {
+			sendError(apiErr)
+			return
+		}
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			sendError(fmt.Errorf("prompt blocked: %s", chunk.PromptFeedback.BlockReason))
+			return
+		}
+
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+
+		candidate := chunk.Candidates[0]
+		if candidate.GroundingMetadata != nil {
+			grounding = candidate.GroundingMetadata
+		}
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+
+		for _, p := range candidate.Content.Parts {
+			if p.ThoughtSignature != "" {
+				signature = p.ThoughtSignature
+			}
+
+			switch {
+			case p.Thought:
+				thinking.WriteString(p.Text)
+				output <- llm.TextStreamEvent{
+					Type:  llm.EventTypeReasoning,
+					Value: p.Text,
+				}
+			case p.FunctionCall != nil:
+				id := p.FunctionCall.ID
+				if id == "" {
+					id = model.NewId()
+				}
+				args := p.FunctionCall.Args
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				toolCalls = append(toolCalls, llm.ToolCall{
+					ID:        id,
+					Name:      p.FunctionCall.Name,
+					Arguments: args,
+				})
+			case p.Text != "":
+				if !reasoningSent {
+					sendReasoning()
+				}
+				hasText = true
+				output <- llm.TextStreamEvent{
+					Type:  llm.EventTypeText,
+					Value: p.Text,
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		sendError(err)
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		sendError(err)
+		return
+	}
+
+	if !hasText && len(toolCalls) == 0 && blockedFinishReasons[finishReason] {
+		sendError(fmt.Errorf("response blocked: %s", finishReason))
+		return
+	}
+
+	sendReasoning()
+
+	if len(toolCalls) > 0 {
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeToolCalls,
+			Value: toolCalls,
+		}
+	}
+
+	if annotations := groundingToAnnotations(grounding); len(annotations) > 0 {
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeAnnotations,
+			Value: annotations,
+		}
+	}
+
+	if usage != nil {
+		output <- llm.TextStreamEvent{
+			Type: llm.EventTypeUsage,
+			Value: llm.TokenUsage{
+				InputTokens:  usage.PromptTokenCount,
+				OutputTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			},
+		}
+	}
+
+	output <- llm.TextStreamEvent{
+		Type:  llm.EventTypeEnd,
+		Value: nil,
+	}
+}
+
+// groundingToAnnotations converts Google Search grounding metadata to citations. Every source
+// keeps the same display index across the segments it supports.
+func groundingToAnnotations(grounding *groundingMetadata) []llm.Annotation {
+	if grounding == nil {
+		return nil
+	}
+
+	var annotations []llm.Annotation
+	for _, support := range grounding.GroundingSupports {
+		for _, chunkIndex := range support.GroundingChunkIndices {
+			if chunkIndex < 0 || chunkIndex >= len(grounding.GroundingChunks) {
+				continue
+			}
+			web := grounding.GroundingChunks[chunkIndex].Web
+			if web == nil {
+				continue
+			}
+			annotations = append(annotations, llm.Annotation{
+				Type:       llm.AnnotationTypeURLCitation,
+				StartIndex: support.Segment.StartIndex,
+				EndIndex:   support.Segment.EndIndex,
+				URL:        web.URI,
+				Title:      web.Title,
+				CitedText:  support.Segment.Text,
+				Index:      chunkIndex + 1,
+			})
+		}
+	}
+	return annotations
+}
+
+func (g *Gemini) CountTokens(text string) int {
+	// Gemini doesn't publish its tokenizer, cl100k_base is a close approximation
+	return tokenizer.ForModel(g.defaultModel).CountTokens(text)
+}
+
+func (g *Gemini) InputTokenLimit() int {
+	if g.inputTokenLimit > 0 {
+		return g.inputTokenLimit
+	}
+	return DefaultInputTokenLimit
+}
+
+// isNativeToolEnabled checks if a specific native tool is enabled in the configuration
+func (g *Gemini) isNativeToolEnabled(toolName string) bool {
+	for _, enabledTool := range g.enabledNativeTools {
+		if enabledTool == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchModels retrieves the list of models that can generate content from the Gemini API
+func FetchModels(apiKey string, apiURL string, httpClient *http.Client) ([]llm.ModelInfo, error) {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	auth := newAuthenticator(apiKey, apiURL, httpClient)
+	var models []llm.ModelInfo
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, apiURL+"/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gemini request: %w", err)
+		}
+		if err := auth.authorize(req); err != nil {
+			return nil, err
+		}
+
+		page, err := fetchModelsPage(httpClient, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Models {
+			if !m.supports("generateContent") {
+				continue
+			}
+			models = append(models, llm.ModelInfo{
+				ID:          strings.TrimPrefix(m.Name, "models/"),
+				DisplayName: m.DisplayName,
+			})
+		}
+
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+type modelsPageModel struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+type modelsPage struct {
+	Models        []modelsPageModel `json:"models"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+func fetchModelsPage(httpClient *http.Client, req *http.Request) (*modelsPage, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gemini models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var page modelsPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode gemini models: %w", err)
+	}
+	return &page, nil
+}
+
+// supports reports whether the model supports a generation method. Vertex AI doesn't list
+// the supported methods so models without any are assumed to support everything.
+func (m modelsPageModel) supports(method string) bool {
+	if len(m.SupportedGenerationMethods) == 0 {
+		return true
+	}
+	for _, supported := range m.SupportedGenerationMethods {
+		if supported == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolStoreWith returns a tool store holding tools.
+func toolStoreWith(tools ...llm.Tool) *llm.ToolStore {
+	store := llm.NewToolStore(nil, false)
+	store.AddTools(tools)
+	return store
+}
+
+// sseServer serves the given events as a streamGenerateContent response and records the request.
+func sseServer(t *testing.T, events []string, captured *generateContentRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models/gemini-2.5-flash:streamGenerateContent", r.URL.Path)
+		assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		if captured != nil {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(captured))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", event)
+		}
+	}))
+}
+
+func newTestGemini(serverURL string, botConfig llm.BotConfig) *Gemini {
+	return New(llm.ServiceConfig{
+		APIKey:       "test-key",
+		APIURL:       serverURL,
+		DefaultModel: "gemini-2.5-flash",
+	}, botConfig, http.DefaultClient)
+}
+
+func collectEvents(t *testing.T, result *llm.TextStreamResult) []llm.TextStreamEvent {
+	t.Helper()
+	var events []llm.TextStreamEvent
+	for event := range result.Stream {
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestConversationToContents(t *testing.T) {
+	t.Run("system posts become the system instruction", func(t *testing.T) {
+		system, contents := conversationToContents([]llm.Post{
+			{Role: llm.PostRoleSystem, Message: "You are helpful"},
+			{Role: llm.PostRoleSystem, Message: "Be brief"},
+			{Role: llm.PostRoleUser, Message: "Hello"},
+			{Role: llm.PostRoleBot, Message: "Hi"},
+		})
+
+		require.NotNil(t, system)
+		assert.Equal(t, "You are helpful\n\nBe brief", system.Parts[0].Text)
+		require.Len(t, contents, 2)
+		assert.Equal(t, "user", contents[0].Role)
+		assert.Equal(t, "model", contents[1].Role)
+	})
+
+	t.Run("consecutive posts of the same role are merged", func(t *testing.T) {
+		system, contents := conversationToContents([]llm.Post{
+			{Role: llm.PostRoleUser, Message: "One"},
+			{Role: llm.PostRoleUser, Message: "Two"},
+		})
+
+		assert.Nil(t, system)
+		require.Len(t, contents, 1)
+		assert.Len(t, contents[0].Parts, 2)
+	})
+
+	t.Run("images are sent inline", func(t *testing.T) {
+		_, contents := conversationToContents([]llm.Post{{
+			Role:    llm.PostRoleUser,
+			Message: "Look",
+			Files: []llm.File{
+				{MimeType: "image/png", Reader: bytes.NewReader([]byte("png"))},
+				{MimeType: "image/tiff", Reader: bytes.NewReader([]byte("tiff"))},
+			},
+		}})
+
+		require.Len(t, contents, 1)
+		parts := contents[0].Parts
+		require.Len(t, parts, 3)
+		require.NotNil(t, parts[1].InlineData)
+		assert.Equal(t, "image/png", parts[1].InlineData.MimeType)
+		assert.Equal(t, "cG5n", parts[1].InlineData.Data)
+		assert.Equal(t, "[Unsupported image type: image/tiff]", parts[2].Text)
+	})
+
+	t.Run("tool use is sent as function calls followed by their responses", func(t *testing.T) {
+		_, contents := conversationToContents([]llm.Post{
+			{Role: llm.PostRoleUser, Message: "Weather?"},
+			{
+				Role:               llm.PostRoleBot,
+				Message:            "Let me check",
+				ReasoningSignature: "sig",
+				ToolUse: []llm.ToolCall{
+					{ID: "1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`), Result: "Sunny", Status: llm.ToolCallStatusSuccess},
+					{ID: "2", Name: "forecast", Result: "Rejected", Status: llm.ToolCallStatusRejected},
+				},
+			},
+		})
+
+		require.Len(t, contents, 3)
+		model := contents[1]
+		assert.Equal(t, "model", model.Role)
+		require.Len(t, model.Parts, 3)
+		assert.Equal(t, "weather", model.Parts[1].FunctionCall.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(model.Parts[1].FunctionCall.Args))
+		assert.Equal(t, "sig", model.Parts[1].ThoughtSignature)
+		assert.JSONEq(t, `{}`, string(model.Parts[2].FunctionCall.Args))
+		assert.Empty(t, model.Parts[2].ThoughtSignature)
+
+		responses := contents[2]
+		assert.Equal(t, "user", responses.Role)
+		require.Len(t, responses.Parts, 2)
+		assert.Equal(t, map[string]any{"result": "Sunny"}, responses.Parts[0].FunctionResponse.Response)
+		assert.Equal(t, map[string]any{"error": "Rejected"}, responses.Parts[1].FunctionResponse.Response)
+	})
+}
+
+func TestChatCompletion(t *testing.T) {
+	t.Run("streams text, reasoning, tool calls, citations and usage", func(t *testing.T) {
+		var captured generateContentRequest
+		server := sseServer(t, []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Thinking about it","thought":true}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Paris is "}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"sunny."},{"functionCall":{"name":"weather","args":{"city":"Paris"}},"thoughtSignature":"sig"}]},"finishReason":"STOP",` +
+				`"groundingMetadata":{"groundingChunks":[{"web":{"uri":"https://example.com","title":"Example"}}],"groundingSupports":[{"segment":{"startIndex":0,"endIndex":15,"text":"Paris is sunny."},"groundingChunkIndices":[0]}]}}],` +
+				`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3}}`,
+		}, &captured)
+		defer server.Close()
+
+		g := newTestGemini(server.URL, llm.BotConfig{
+			ReasoningEnabled:   true,
+			ThinkingBudget:     2048,
+			EnabledNativeTools: []string{"web_search"},
+		})
+		result, err := g.ChatCompletion(t.Context(), llm.CompletionRequest{
+			Posts: []llm.Post{
+				{Role: llm.PostRoleSystem, Message: "You are helpful"},
+				{Role: llm.PostRoleUser, Message: "Weather in Paris?"},
+			},
+			Context: &llm.Context{Tools: toolStoreWith(llm.Tool{
+				Name:        "weather",
+				Description: "Get the weather",
+				Schema:      &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{"city": {Type: "string"}}},
+			})},
+		})
+		require.NoError(t, err)
+		events := collectEvents(t, result)
+
+		require.Len(t, captured.Tools, 2)
+		require.Len(t, captured.Tools[0].FunctionDeclarations, 1)
+		assert.Equal(t, "weather", captured.Tools[0].FunctionDeclarations[0].Name)
+		assert.NotNil(t, captured.Tools[0].FunctionDeclarations[0].ParametersJSONSchema)
+		assert.NotNil(t, captured.Tools[1].GoogleSearch)
+		require.NotNil(t, captured.GenerationConfig.ThinkingConfig)
+		assert.True(t, captured.GenerationConfig.ThinkingConfig.IncludeThoughts)
+		assert.Equal(t, 2048, *captured.GenerationConfig.ThinkingConfig.ThinkingBudget)
+		assert.Equal(t, DefaultMaxTokens, captured.GenerationConfig.MaxOutputTokens)
+		assert.Equal(t, "You are helpful", captured.SystemInstruction.Parts[0].Text)
+
+		require.Len(t, events, 9)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoning, Value: "Thinking about it"}, events[0])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoningEnd, Value: llm.ReasoningData{Text: "Thinking about it"}}, events[1])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Paris is "}, events[2])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeText, Value: "sunny."}, events[3])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoningEnd, Value: llm.ReasoningData{Text: "Thinking about it", Signature: "sig"}}, events[4])
+
+		assert.Equal(t, llm.EventTypeToolCalls, events[5].Type)
+		toolCalls := events[5].Value.([]llm.ToolCall)
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "weather", toolCalls[0].Name)
+		assert.NotEmpty(t, toolCalls[0].ID)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(toolCalls[0].Arguments))
+
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeAnnotations, Value: []llm.Annotation{{
+			Type:       llm.AnnotationTypeURLCitation,
+			StartIndex: 0,
+			EndIndex:   15,
+			URL:        "https://example.com",
+			Title:      "Example",
+			CitedText:  "Paris is sunny.",
+			Index:      1,
+		}}}, events[6])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 8}}, events[7])
+		assert.Equal(t, llm.EventTypeEnd, events[8].Type)
+	})
+
+	t.Run("sends the thought signature of tool calls without thoughts", func(t *testing.T) {
+		server := sseServer(t, []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"id":"call-1","name":"weather"},"thoughtSignature":"sig"}]}}]}`,
+		}, nil)
+		defer server.Close()
+
+		result, err := newTestGemini(server.URL, llm.BotConfig{}).ChatCompletion(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		require.NoError(t, err)
+		events := collectEvents(t, result)
+
+		require.Len(t, events, 3)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoningEnd, Value: llm.ReasoningData{Signature: "sig"}}, events[0])
+		assert.Equal(t, "call-1", events[1].Value.([]llm.ToolCall)[0].ID)
+		assert.Equal(t, llm.EventTypeEnd, events[2].Type)
+	})
+
+	t.Run("tools and reasoning can be disabled per request", func(t *testing.T) {
+		var captured generateContentRequest
+		server := sseServer(t, []string{`{"candidates":[{"content":{"parts":[{"text":"Hi"}]}}]}`}, &captured)
+		defer server.Close()
+
+		g := newTestGemini(server.URL, llm.BotConfig{ReasoningEnabled: true, EnabledNativeTools: []string{"web_search"}})
+		text, err := g.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{
+			Posts:   []llm.Post{{Role: llm.PostRoleUser, Message: "Hello"}},
+			Context: llm.NewContext(),
+		}, llm.WithToolsDisabled(), llm.WithReasoningDisabled(), llm.WithJSONOutput[struct{ Answer string }]())
+		require.NoError(t, err)
+		assert.Equal(t, "Hi", text)
+
+		assert.Empty(t, captured.Tools)
+		assert.Nil(t, captured.GenerationConfig.ThinkingConfig)
+		assert.Equal(t, "application/json", captured.GenerationConfig.ResponseMimeType)
+		assert.NotNil(t, captured.GenerationConfig.ResponseJSONSchema)
+	})
+
+	t.Run("blocked responses are errors", func(t *testing.T) {
+		server := sseServer(t, []string{`{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`}, nil)
+		defer server.Close()
+
+		_, err := newTestGemini(server.URL, llm.BotConfig{}).ChatCompletionNoStream(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		assert.ErrorContains(t, err, "response blocked: SAFETY")
+	})
+
+	t.Run("errors in the stream are reported", func(t *testing.T) {
+		server := sseServer(t, []string{
+			`{"candidates":[{"content":{"parts":[{"text":"Partial"}]}}]}`,
+			`{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`,
+		}, nil)
+		defer server.Close()
+
+		_, err := newTestGemini(server.URL, llm.BotConfig{}).ChatCompletionNoStream(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		require.Error(t, err)
+		assert.Equal(t, llm.ErrorClassServer, ClassifyError(err))
+	})
+
+	t.Run("error responses are classified", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"code":429,"message":"quota exceeded","status":"RESOURCE_EXHAUSTED",` +
+				`"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"37s"}]}}`))
+		}))
+		defer server.Close()
+
+		_, err := newTestGemini(server.URL, llm.BotConfig{}).ChatCompletion(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "quota exceeded")
+		assert.Equal(t, llm.ErrorClassRateLimit, ClassifyError(err))
+
+		delay, ok := RetryAfter(err)
+		require.True(t, ok)
+		assert.Equal(t, 37*time.Second, delay)
+	})
+}
+
+func TestFetchModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"models":[` +
+				`{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash","supportedGenerationMethods":["generateContent","countTokens"]},` +
+				`{"name":"models/text-embedding-004","displayName":"Text Embedding 004","supportedGenerationMethods":["embedContent"]}` +
+				`],"nextPageToken":"next"}`))
+			return
+		}
+		assert.Equal(t, "next", r.URL.Query().Get("pageToken"))
+		_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-pro","displayName":"Gemini 2.5 Pro","supportedGenerationMethods":["generateContent"]}]}`))
+	}))
+	defer server.Close()
+
+	models, err := FetchModels("test-key", server.URL, http.DefaultClient)
+	require.NoError(t, err)
+	assert.Equal(t, []llm.ModelInfo{
+		{ID: "gemini-2.5-flash", DisplayName: "Gemini 2.5 Flash"},
+		{ID: "gemini-2.5-pro", DisplayName: "Gemini 2.5 Pro"},
+	}, models)
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultAutoResponseConfidence is the confidence below which a bot doesn't answer a message
+	// matching the intent of a rule
+	DefaultAutoResponseConfidence = 0.7
+	// DefaultAutoResponsesPerHour is how many messages a bot answers in a channel per hour
+	DefaultAutoResponsesPerHour = 10
+)
+
+// AutoResponseRule makes a bot answer questions posted in channels without being mentioned.
+// A message is answered when it matches the channels, pattern, business hours and intent of the rule.
+type AutoResponseRule struct {
+	// ChannelIDs are the channels the rule watches. Rules without channels never match.
+	ChannelIDs []string `json:"channelIDs"`
+	// Pattern is a regular expression messages must match, any message when empty
+	Pattern string `json:"pattern"`
+	// Intent describes the questions the bot answers, such as "questions about the VPN". The LLM
+	// classifies messages against it when set.
+	Intent string `json:"intent"`
+	// MinConfidence is the confidence of the intent classification, between 0 and 1, below which
+	// the bot stays silent
+	// Default: 0.7
+	MinConfidence float64 `json:"minConfidence"`
+	// BusinessHours limits the rule to some days and times, always active when nil
+	BusinessHours *BusinessHours `json:"businessHours"`
+	// MaxResponsesPerHour limits how many messages the bot answers in each channel
+	// Default: 10
+	MaxResponsesPerHour int `json:"maxResponsesPerHour"`
+
+	// pattern is Pattern compiled, set by Compile
+	pattern *regexp.Regexp
+}
+
+// BusinessHours are the days and times an auto response rule is active.
+type BusinessHours struct {
+	// Timezone is the IANA time zone of the hours, UTC when empty
+	Timezone string `json:"timezone"`
+	// Days are the days of the week the rule is active, 0 being Sunday. Every day when empty.
+	Days []time.Weekday `json:"days"`
+	// Start and End are the times of day the rule is active, formatted as "15:04". The rule is
+	// active all day when both are empty, and overnight when End is before Start.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Watches reports whether the rule applies to messages posted in channelID.
+func (r AutoResponseRule) Watches(channelID string) bool {
+	return slices.Contains(r.ChannelIDs, channelID)
+}
+
+// Compile compiles the pattern of the rule once, so messages are matched without compiling it again.
+func (r *AutoResponseRule) Compile() error {
+	r.pattern = nil
+	if r.Pattern == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid auto response pattern %q: %w", r.Pattern, err)
+	}
+	r.pattern = pattern
+	return nil
+}
+
+// MatchesMessage reports whether message matches the pattern of the rule. The pattern of rules that
+// weren't compiled is compiled on every call.
+func (r AutoResponseRule) MatchesMessage(message string) (bool, error) {
+	if r.Pattern == "" {
+		return true, nil
+	}
+	if r.pattern == nil {
+		if err := r.Compile(); err != nil {
+			return false, err
+		}
+	}
+	return r.pattern.MatchString(message), nil
+}
+
+// Confidence returns the minimum confidence of the intent classification.
+func (r AutoResponseRule) Confidence() float64 {
+	if r.MinConfidence <= 0 {
+		return DefaultAutoResponseConfidence
+	}
+	return r.MinConfidence
+}
+
+// ResponsesPerHour returns how many messages the bot answers in each channel per hour.
+func (r AutoResponseRule) ResponsesPerHour() int {
+	if r.MaxResponsesPerHour <= 0 {
+		return DefaultAutoResponsesPerHour
+	}
+	return r.MaxResponsesPerHour
+}
+
+// IsActive reports whether the rule is active at now.
+func (r AutoResponseRule) IsActive(now time.Time) (bool, error) {
+	if r.BusinessHours == nil {
+		return true, nil
+	}
+	return r.BusinessHours.Contains(now)
+}
+
+// Contains reports whether now is within the business hours.
+func (h BusinessHours) Contains(now time.Time) (bool, error) {
+	location := time.UTC
+	if h.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(h.Timezone); err != nil {
+			return false, fmt.Errorf("invalid business hours timezone: %w", err)
+		}
+	}
+	now = now.In(location)
+
+	if len(h.Days) > 0 && !slices.Contains(h.Days, now.Weekday()) {
+		return false, nil
+	}
+	if h.Start == "" && h.End == "" {
+		return true, nil
+	}
+
+	start, err := minuteOfDay(h.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := minuteOfDay(h.End)
+	if err != nil {
+		return false, err
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if end < start {
+		return minute >= start || minute < end, nil
+	}
+	return minute >= start && minute < end, nil
+}
+
+func minuteOfDay(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid business hours time %q: %w", value, err)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoResponseRule(t *testing.T) {
+	t.Run("rules only watch their channels", func(t *testing.T) {
+		rule := AutoResponseRule{ChannelIDs: []string{"support"}}
+		assert.True(t, rule.Watches("support"))
+		assert.False(t, rule.Watches("town-square"))
+		assert.False(t, AutoResponseRule{}.Watches("support"))
+	})
+
+	t.Run("messages match the pattern", func(t *testing.T) {
+		matches, err := AutoResponseRule{}.MatchesMessage("anything")
+		require.NoError(t, err)
+		assert.True(t, matches)
+
+		rule := AutoResponseRule{Pattern: `(?i)\bvpn\b.*\?`}
+		matches, err = rule.MatchesMessage("How do I connect to the VPN from home?")
+		require.NoError(t, err)
+		assert.True(t, matches)
+		matches, err = rule.MatchesMessage("The VPN is back up")
+		require.NoError(t, err)
+		assert.False(t, matches)
+
+		_, err = AutoResponseRule{Pattern: "("}.MatchesMessage("anything")
+		require.Error(t, err)
+	})
+
+	t.Run("invalid patterns are dropped when rules are compiled", func(t *testing.T) {
+		rules := []AutoResponseRule{{Pattern: "("}, {Pattern: `(?i)\bvpn\b`}, {}}
+		cfg := BotConfig{AutoResponseRules: rules}
+		require.Error(t, cfg.CompileAutoResponseRules())
+		require.Len(t, cfg.AutoResponseRules, 2)
+		assert.Equal(t, "(", rules[0].Pattern, "the configured rules are left as they are")
+
+		compiled := cfg.AutoResponseRules[0]
+		require.NotNil(t, compiled.pattern)
+		matches, err := compiled.MatchesMessage("Is the VPN down?")
+		require.NoError(t, err)
+		assert.True(t, matches)
+		assert.Nil(t, cfg.AutoResponseRules[1].pattern)
+	})
+
+	t.Run("defaults apply to unset limits", func(t *testing.T) {
+		assert.Equal(t, DefaultAutoResponseConfidence, AutoResponseRule{}.Confidence())
+		assert.Equal(t, DefaultAutoResponsesPerHour, AutoResponseRule{}.ResponsesPerHour())
+		assert.Equal(t, 0.9, AutoResponseRule{MinConfidence: 0.9}.Confidence())
+		assert.Equal(t, 3, AutoResponseRule{MaxResponsesPerHour: 3}.ResponsesPerHour())
+	})
+}
+
+func TestBusinessHours(t *testing.T) {
+	// Wednesday
+	morning := time.Date(2024, time.March, 6, 8, 30, 0, 0, time.UTC)
+	afternoon := time.Date(2024, time.March, 6, 14, 0, 0, 0, time.UTC)
+	saturday := time.Date(2024, time.March, 9, 14, 0, 0, 0, time.UTC)
+
+	t.Run("office hours on weekdays", func(t *testing.T) {
+		hours := BusinessHours{
+			Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			Start: "09:00",
+			End:   "17:00",
+		}
+		for now, expected := range map[time.Time]bool{morning: false, afternoon: true, saturday: false} {
+			active, err := hours.Contains(now)
+			require.NoError(t, err)
+			assert.Equal(t, expected, active, now.String())
+		}
+	})
+
+	t.Run("hours are in the timezone", func(t *testing.T) {
+		hours := BusinessHours{Timezone: "America/Toronto", Start: "09:00", End: "17:00"}
+		active, err := hours.Contains(afternoon)
+		require.NoError(t, err)
+		assert.True(t, active, "9:00 in Toronto")
+		active, err = hours.Contains(morning)
+		require.NoError(t, err)
+		assert.False(t, active, "3:30 in Toronto")
+	})
+
+	t.Run("hours can span midnight", func(t *testing.T) {
+		hours := BusinessHours{Start: "17:00", End: "09:00"}
+		active, err := hours.Contains(morning)
+		require.NoError(t, err)
+		assert.True(t, active)
+		active, err = hours.Contains(afternoon)
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
+
+	t.Run("invalid hours are an error", func(t *testing.T) {
+		_, err := BusinessHours{Timezone: "Mars/Olympus"}.Contains(morning)
+		require.Error(t, err)
+		_, err = BusinessHours{Start: "9am", End: "5pm"}.Contains(morning)
+		require.Error(t, err)
+	})
+}
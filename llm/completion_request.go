@@ -49,8 +49,7 @@ func (b *CompletionRequest) Truncate(maxTokens int, countTokens func(string) int
 		}
 		postTokens := countTokens(post.Message)
 		if (totalTokens + postTokens) > maxTokens {
-			charactersToCut := (postTokens - (maxTokens - totalTokens)) * 4
-			post.Message = strings.TrimSpace(post.Message[charactersToCut:])
+			post.Message = strings.TrimSpace(KeepLastTokens(post.Message, maxTokens-totalTokens, countTokens))
 			b.Posts = append(b.Posts, post)
 			slices.Reverse(b.Posts)
 			return true
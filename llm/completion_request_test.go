@@ -6,6 +6,7 @@ package llm
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -89,4 +90,20 @@ func TestCompletionRequestTruncate(t *testing.T) {
 		tokenCount := mockTokenCounter(req.Posts[0].Message)
 		assert.LessOrEqual(t, tokenCount, 20, "Truncated message should be within token limit")
 	})
+
+	t.Run("Truncate multi-byte message on rune boundaries", func(t *testing.T) {
+		longMessage := strings.Repeat("这是一条需要被截断的很长的消息。", 20)
+		req := CompletionRequest{
+			Posts: []Post{
+				{Role: PostRoleUser, Message: longMessage},
+			},
+		}
+
+		wasTruncated := req.Truncate(25, mockTokenCounter)
+
+		assert.True(t, wasTruncated, "Should truncate message")
+		assert.True(t, utf8.ValidString(req.Posts[0].Message), "Truncated message should be valid UTF-8")
+		assert.True(t, strings.HasSuffix(longMessage, req.Posts[0].Message), "Should keep the end of the message")
+		assert.LessOrEqual(t, mockTokenCounter(req.Posts[0].Message), 25, "Truncated message should be within token limit")
+	})
 }
@@ -3,6 +3,11 @@
 
 package llm
 
+import (
+	"errors"
+	"strings"
+)
+
 type ServiceConfig struct {
 	ID           string `json:"id"`
 	Name         string `json:"name"`
@@ -55,10 +60,18 @@ type BotConfig struct {
 	CustomInstructions string `json:"customInstructions"`
 	ServiceID          string `json:"serviceID"`
 
+	// FallbackServiceIDs is an ordered list of services to fail over to
+	// when the primary service is unavailable.
+	FallbackServiceIDs []string `json:"fallbackServiceIDs"`
+
 	// Model is the optional model override for this bot.
 	// If not specified, the service's DefaultModel will be used.
 	Model string `json:"model"`
 
+	// ModelRoutes send the requests of some tasks, such as titles and emoji reactions,
+	// to another service or model. Other tasks use the bot's service and model.
+	ModelRoutes []ModelRoute `json:"modelRoutes"`
+
 	// Service is deprecated and kept only for backwards compatibility during migration.
 	Service *ServiceConfig `json:"service,omitempty"`
 
@@ -91,6 +104,77 @@ type BotConfig struct {
 	// Only applicable to Anthropic
 	// Default: 1/4 of OutputTokenLimit, capped at 8192
 	ThinkingBudget int `json:"thinkingBudget"`
+
+	// TruncationStrategy determines how conversations that exceed the input token limit are shortened
+	// Valid values: "drop_oldest", "summarize"
+	// Default: "drop_oldest"
+	TruncationStrategy TruncationStrategy `json:"truncationStrategy"`
+
+	// EnableRedaction replaces personal data and secrets in requests with placeholders
+	// before they are sent to the service. The detectors are configured globally.
+	EnableRedaction bool `json:"enableRedaction"`
+
+	// DefaultToolPolicy applies to tools without a rule in ToolPolicies
+	// Valid values: "ask", "auto_approve", "deny"
+	// Default: "ask"
+	DefaultToolPolicy ToolPolicy `json:"defaultToolPolicy"`
+
+	// ToolPolicies sets the policy and timeout of individual tools and MCP servers
+	ToolPolicies []ToolPolicyRule `json:"toolPolicies"`
+
+	// MaxParallelToolCalls limits how many tool calls of a response are resolved at once
+	// Default: 4
+	MaxParallelToolCalls int `json:"maxParallelToolCalls"`
+
+	// ToolResultTokenLimit limits the size of tool results given to the model, unlimited when negative
+	// Default: 8000
+	ToolResultTokenLimit int `json:"toolResultTokenLimit"`
+
+	// ToolResultStrategy determines how tool results over their token limit are shortened
+	// Valid values: "truncate", "summarize"
+	// Default: "truncate"
+	ToolResultStrategy ToolResultStrategy `json:"toolResultStrategy"`
+
+	// MaxAgentIterations limits how many requests to the model a response can make while
+	// resolving tools before the remaining tool calls are left to the user to approve
+	// Default: 10
+	MaxAgentIterations int `json:"maxAgentIterations"`
+
+	// MaxAgentDurationSeconds limits how long a response can keep resolving tools
+	// Default: 300
+	MaxAgentDurationSeconds int `json:"maxAgentDurationSeconds"`
+
+	// AgentTokenBudget limits the input and output tokens a response can use while resolving tools
+	// Default: 0 (unlimited)
+	AgentTokenBudget int64 `json:"agentTokenBudget"`
+
+	// JSONValidationRetries limits how many times a response that doesn't match the requested
+	// JSON schema is retried with the validation error, none when negative
+	// Default: 2
+	JSONValidationRetries int `json:"jsonValidationRetries"`
+
+	// EnableMemory lets the bot save facts about users with the remember, recall and forget tools,
+	// and adds the facts saved about the requesting user to direct message prompts
+	EnableMemory bool `json:"enableMemory"`
+
+	// MemoryTokenBudget limits the tokens of the facts added to a prompt, the most recent first
+	// Default: 500
+	MemoryTokenBudget int `json:"memoryTokenBudget"`
+
+	// AutoResponseRules make the bot answer questions in channels without being mentioned
+	AutoResponseRules []AutoResponseRule `json:"autoResponseRules"`
+
+	// Description says what the bot is good at, such as "Answers questions about HR policies".
+	// Other bots read it to decide which bot to ask or forward a message to.
+	Description string `json:"description"`
+
+	// DelegateBots are the names of the bots this bot can ask questions with the ask_agent tool,
+	// and the bots it forwards messages to in router mode
+	DelegateBots []string `json:"delegateBots"`
+
+	// RouterMode makes the bot forward the first message of each conversation to the delegate bot
+	// best suited to answer it. The bot answers itself when none of them is.
+	RouterMode bool `json:"routerMode"`
 }
 
 func (c *BotConfig) IsValid() bool {
@@ -110,6 +194,22 @@ func (c *BotConfig) IsValid() bool {
 	return true
 }
 
+// CompileAutoResponseRules compiles the patterns of the auto response rules of the bot. Rules with an
+// invalid pattern are removed and reported in the returned error.
+func (c *BotConfig) CompileAutoResponseRules() error {
+	rules := make([]AutoResponseRule, 0, len(c.AutoResponseRules))
+	var errs []error
+	for _, rule := range c.AutoResponseRules {
+		if err := rule.Compile(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	c.AutoResponseRules = rules
+	return errors.Join(errs...)
+}
+
 // IsValidService validates a service configuration
 func IsValidService(service ServiceConfig) bool {
 	// Basic validation
@@ -137,6 +237,11 @@ func IsValidService(service ServiceConfig) bool {
 		return service.Region != ""
 	case ServiceTypeMistral:
 		return service.APIKey != ""
+	case ServiceTypeGemini:
+		// Vertex AI can authenticate with the Application Default Credentials of the server
+		return service.APIKey != "" || strings.Contains(service.APIURL, "aiplatform.googleapis.com")
+	case ServiceTypeOllama:
+		return service.APIURL != ""
 	default:
 		return false
 	}
@@ -31,8 +31,15 @@ type Context struct {
 	Channel *model.Channel
 	Thread  []Post // Normalized posts that already have been formatted. nil if not in a thread or a root post
 
+	// RootPostID is the root post of the conversation the request belongs to. Empty if unknown.
+	RootPostID string
+
 	// User that is making the request
 	RequestingUser *model.User
+	// Question is the latest message of the requesting user in the conversation. Empty if unknown.
+	Question string
+	// Memories are the facts the bot saved about the requesting user in previous conversations
+	Memories []string
 
 	// Bot Specific
 	BotName            string
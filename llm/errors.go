@@ -0,0 +1,158 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorClass is a coarse, provider-agnostic categorisation of an upstream LLM error.
+type ErrorClass int
+
+const (
+	// ErrorClassUnknown is used when the error could not be classified
+	ErrorClassUnknown ErrorClass = iota
+	// ErrorClassAuth represents authentication or authorization failures (401/403)
+	ErrorClassAuth
+	// ErrorClassRateLimit represents rate limiting or overload responses (429/529)
+	ErrorClassRateLimit
+	// ErrorClassServer represents upstream server failures (5xx)
+	ErrorClassServer
+	// ErrorClassTimeout represents connection or streaming timeouts
+	ErrorClassTimeout
+	// ErrorClassInvalidRequest represents requests rejected as malformed (4xx)
+	ErrorClassInvalidRequest
+	// ErrorClassCanceled represents requests canceled by the caller
+	ErrorClassCanceled
+	// ErrorClassContextLength represents requests rejected for exceeding the model's context window
+	ErrorClassContextLength
+	// ErrorClassContentFilter represents requests or responses blocked by the provider's content filter
+	ErrorClassContentFilter
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassAuth:
+		return "auth"
+	case ErrorClassRateLimit:
+		return "rate_limit"
+	case ErrorClassServer:
+		return "server"
+	case ErrorClassTimeout:
+		return "timeout"
+	case ErrorClassInvalidRequest:
+		return "invalid_request"
+	case ErrorClassCanceled:
+		return "canceled"
+	case ErrorClassContextLength:
+		return "context_length"
+	case ErrorClassContentFilter:
+		return "content_filter"
+	default:
+		return "unknown"
+	}
+}
+
+// ShouldFailover reports whether a request failing with this class of error
+// may succeed when sent to a different service.
+func (c ErrorClass) ShouldFailover() bool {
+	switch c {
+	case ErrorClassInvalidRequest, ErrorClassCanceled, ErrorClassContextLength, ErrorClassContentFilter:
+		return false
+	default:
+		return true
+	}
+}
+
+// ErrorClassifier maps an error returned by a provider to an ErrorClass.
+type ErrorClassifier func(err error) ErrorClass
+
+// ClassifyHTTPStatus maps an HTTP status code returned by an upstream API to an ErrorClass.
+func ClassifyHTTPStatus(statusCode int) ErrorClass {
+	switch {
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrorClassAuth
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case statusCode == 529: // Anthropic "overloaded"
+		return ErrorClassRateLimit
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case statusCode >= 500:
+		return ErrorClassServer
+	case statusCode >= 400:
+		return ErrorClassInvalidRequest
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// ClassifyError is the generic ErrorClassifier. It understands context and
+// network errors; providers supply their own classifiers for SDK specific errors.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	return ErrorClassUnknown
+}
+
+// Providers report these as ordinary invalid requests, only the error messages tell them apart
+var (
+	contextLengthMessages = []string{
+		"context_length_exceeded",
+		"maximum context length",
+		"context window",
+		"prompt is too long",
+		"input is too long",
+		"input token count",
+	}
+	contentFilterMessages = []string{
+		"content_filter",
+		"content filter",
+		"content management policy",
+		"content_policy",
+		"blocked by safety",
+		"safety settings",
+		"guardrail",
+	}
+)
+
+// RefineErrorClass narrows down invalid request and unknown errors to context length and
+// content filter errors based on the error message, which works for every provider.
+func RefineErrorClass(class ErrorClass, err error) ErrorClass {
+	if err == nil || (class != ErrorClassInvalidRequest && class != ErrorClassUnknown) {
+		return class
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range contextLengthMessages {
+		if strings.Contains(message, pattern) {
+			return ErrorClassContextLength
+		}
+	}
+	for _, pattern := range contentFilterMessages {
+		if strings.Contains(message, pattern) {
+			return ErrorClassContentFilter
+		}
+	}
+	return class
+}
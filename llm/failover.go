@@ -0,0 +1,144 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// Logger is the minimal logging interface used by LanguageModel wrappers.
+// *pluginapi.LogService satisfies it.
+type Logger interface {
+	Warn(message string, keyValuePairs ...any)
+	Debug(message string, keyValuePairs ...any)
+}
+
+// FailoverTarget is one of the services a FailoverWrapper can send a request to.
+type FailoverTarget struct {
+	Service ServiceInfo
+	Model   LanguageModel
+	// Classify categorises errors from Model. Defaults to ClassifyError.
+	Classify ErrorClassifier
+}
+
+func (t FailoverTarget) classify(err error) ErrorClass {
+	if t.Classify != nil {
+		return t.Classify(err)
+	}
+	return ClassifyError(err)
+}
+
+// FailoverWrapper sends requests to an ordered list of services, moving on to the
+// next one when a service fails before it has produced any output.
+// Once reasoning, text, tool calls or annotations have been streamed the response is committed
+// to the answering service and later errors are passed through unchanged.
+type FailoverWrapper struct {
+	targets []FailoverTarget
+	log     Logger
+}
+
+// NewFailoverWrapper creates a FailoverWrapper. The first target is the primary service
+// and is used for token counting and limits. log may be nil.
+func NewFailoverWrapper(targets []FailoverTarget, log Logger) *FailoverWrapper {
+	return &FailoverWrapper{
+		targets: targets,
+		log:     log,
+	}
+}
+
+func (w *FailoverWrapper) logFailover(target FailoverTarget, class ErrorClass, err error) {
+	if w.log == nil {
+		return
+	}
+	w.log.Warn("LLM service failed, failing over to next service",
+		"service_id", target.Service.ID,
+		"service_name", target.Service.Name,
+		"error_class", class.String(),
+		"error", err.Error(),
+	)
+}
+
+// start calls ChatCompletion on each target beginning at from until one accepts the request.
+// lastErr is returned if there are no targets left to try.
+func (w *FailoverWrapper) start(ctx context.Context, from int, lastErr error, request CompletionRequest, opts []LanguageModelOption) (int, *TextStreamResult, error) {
+	for i := from; i < len(w.targets); i++ {
+		target := w.targets[i]
+		result, err := target.Model.ChatCompletion(ctx, request, opts...)
+		if err == nil {
+			return i, result, nil
+		}
+
+		lastErr = err
+		class := target.classify(err)
+		if !class.ShouldFailover() || i == len(w.targets)-1 {
+			return i, nil, err
+		}
+		w.logFailover(target, class, err)
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no LLM services configured")
+	}
+	return len(w.targets), nil, lastErr
+}
+
+func (w *FailoverWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	idx, result, err := w.start(ctx, 0, nil, request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+		for {
+			target := w.targets[idx]
+			hasNext := idx < len(w.targets)-1
+			streamErr := relayUncommitted(result, output, func() {
+				output <- TextStreamEvent{
+					Type:  EventTypeServiceInfo,
+					Value: target.Service,
+				}
+			}, func(err error) bool {
+				class := target.classify(err)
+				if !hasNext || !class.ShouldFailover() {
+					return false
+				}
+				w.logFailover(target, class, err)
+				return true
+			})
+			if streamErr == nil {
+				return
+			}
+
+			idx, result, err = w.start(ctx, idx+1, streamErr, request, opts)
+			if err != nil {
+				output <- TextStreamEvent{
+					Type:  EventTypeError,
+					Value: err,
+				}
+				return
+			}
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *FailoverWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *FailoverWrapper) CountTokens(text string) int {
+	return w.targets[0].Model.CountTokens(text)
+}
+
+func (w *FailoverWrapper) InputTokenLimit() int {
+	return w.targets[0].Model.InputTokenLimit()
+}
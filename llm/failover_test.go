@@ -0,0 +1,168 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func streamOf(events ...llm.TextStreamEvent) *llm.TextStreamResult {
+	stream := make(chan llm.TextStreamEvent, len(events))
+	for _, event := range events {
+		stream <- event
+	}
+	close(stream)
+	return &llm.TextStreamResult{Stream: stream}
+}
+
+func collect(t *testing.T, result *llm.TextStreamResult) []llm.TextStreamEvent {
+	t.Helper()
+	var events []llm.TextStreamEvent
+	for event := range result.Stream {
+		events = append(events, event)
+	}
+	return events
+}
+
+func classifyAs(class llm.ErrorClass) llm.ErrorClassifier {
+	return func(error) llm.ErrorClass {
+		return class
+	}
+}
+
+func TestFailoverWrapper(t *testing.T) {
+	primaryInfo := llm.ServiceInfo{ID: "primary", Name: "Primary", Type: llm.ServiceTypeOpenAI}
+	fallbackInfo := llm.ServiceInfo{ID: "fallback", Name: "Fallback", Type: llm.ServiceTypeAnthropic}
+
+	t.Run("fails over when the request is rejected", func(t *testing.T) {
+		primary := mocks.NewMockLanguageModel(t)
+		fallback := mocks.NewMockLanguageModel(t)
+		primary.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("401 unauthorized"))
+		fallback.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		wrapper := llm.NewFailoverWrapper([]llm.FailoverTarget{
+			{Service: primaryInfo, Model: primary, Classify: classifyAs(llm.ErrorClassAuth)},
+			{Service: fallbackInfo, Model: fallback},
+		}, nil)
+
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 3)
+		assert.Equal(t, llm.EventTypeServiceInfo, events[0].Type)
+		assert.Equal(t, fallbackInfo, events[0].Value)
+		assert.Equal(t, "Hello", events[1].Value)
+		assert.Equal(t, llm.EventTypeEnd, events[2].Type)
+	})
+
+	t.Run("fails over on stream error before any output", func(t *testing.T) {
+		primary := mocks.NewMockLanguageModel(t)
+		fallback := mocks.NewMockLanguageModel(t)
+		primary.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10}},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("429 rate limited")},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+		fallback.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Answer"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		wrapper := llm.NewFailoverWrapper([]llm.FailoverTarget{
+			{Service: primaryInfo, Model: primary, Classify: classifyAs(llm.ErrorClassRateLimit)},
+			{Service: fallbackInfo, Model: fallback},
+		}, nil)
+
+		text, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "Answer", text)
+	})
+
+	t.Run("does not fail over once text was streamed", func(t *testing.T) {
+		primary := mocks.NewMockLanguageModel(t)
+		fallback := mocks.NewMockLanguageModel(t)
+		primary.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Partial"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("500 server error")},
+		), nil)
+
+		wrapper := llm.NewFailoverWrapper([]llm.FailoverTarget{
+			{Service: primaryInfo, Model: primary, Classify: classifyAs(llm.ErrorClassServer)},
+			{Service: fallbackInfo, Model: fallback},
+		}, nil)
+
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 3)
+		assert.Equal(t, primaryInfo, events[0].Value)
+		assert.Equal(t, "Partial", events[1].Value)
+		assert.Equal(t, llm.EventTypeError, events[2].Type)
+		fallback.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("does not fail over on invalid requests", func(t *testing.T) {
+		primary := mocks.NewMockLanguageModel(t)
+		fallback := mocks.NewMockLanguageModel(t)
+		primary.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("400 bad request"))
+
+		wrapper := llm.NewFailoverWrapper([]llm.FailoverTarget{
+			{Service: primaryInfo, Model: primary, Classify: classifyAs(llm.ErrorClassInvalidRequest)},
+			{Service: fallbackInfo, Model: fallback},
+		}, nil)
+
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400")
+	})
+
+	t.Run("returns the last error when every service fails", func(t *testing.T) {
+		primary := mocks.NewMockLanguageModel(t)
+		fallback := mocks.NewMockLanguageModel(t)
+		primary.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("primary down")},
+		), nil)
+		fallback.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("fallback down"))
+
+		wrapper := llm.NewFailoverWrapper([]llm.FailoverTarget{
+			{Service: primaryInfo, Model: primary, Classify: classifyAs(llm.ErrorClassServer)},
+			{Service: fallbackInfo, Model: fallback, Classify: classifyAs(llm.ErrorClassServer)},
+		}, nil)
+
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+		assert.Equal(t, "fallback down", err.Error())
+	})
+}
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	assert.Equal(t, llm.ErrorClassAuth, llm.ClassifyHTTPStatus(401))
+	assert.Equal(t, llm.ErrorClassAuth, llm.ClassifyHTTPStatus(403))
+	assert.Equal(t, llm.ErrorClassRateLimit, llm.ClassifyHTTPStatus(429))
+	assert.Equal(t, llm.ErrorClassRateLimit, llm.ClassifyHTTPStatus(529))
+	assert.Equal(t, llm.ErrorClassServer, llm.ClassifyHTTPStatus(503))
+	assert.Equal(t, llm.ErrorClassTimeout, llm.ClassifyHTTPStatus(504))
+	assert.Equal(t, llm.ErrorClassInvalidRequest, llm.ClassifyHTTPStatus(400))
+}
+
+func TestRefineErrorClass(t *testing.T) {
+	assert.Equal(t, llm.ErrorClassContextLength, llm.RefineErrorClass(llm.ErrorClassInvalidRequest, errors.New("This model's maximum context length is 8192 tokens")))
+	assert.Equal(t, llm.ErrorClassContextLength, llm.RefineErrorClass(llm.ErrorClassUnknown, errors.New("prompt is too long: 210000 tokens > 200000 maximum")))
+	assert.Equal(t, llm.ErrorClassContentFilter, llm.RefineErrorClass(llm.ErrorClassInvalidRequest, errors.New("The response was filtered due to the prompt triggering content management policy")))
+	assert.Equal(t, llm.ErrorClassInvalidRequest, llm.RefineErrorClass(llm.ErrorClassInvalidRequest, errors.New("invalid model")))
+	// Other classes are already precise
+	assert.Equal(t, llm.ErrorClassRateLimit, llm.RefineErrorClass(llm.ErrorClassRateLimit, errors.New("maximum context length")))
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// requestFingerprint is the part of a request and its options that determines the response.
+// Requests with equal fingerprints are expected to get equivalent responses.
+type requestFingerprint struct {
+	Model              string             `json:"model"`
+	MaxGeneratedTokens int                `json:"max_generated_tokens"`
+	EnableVision       bool               `json:"enable_vision"`
+	JSONOutputFormat   *jsonschema.Schema `json:"json_output_format,omitempty"`
+	ToolsDisabled      bool               `json:"tools_disabled"`
+	ReasoningDisabled  bool               `json:"reasoning_disabled"`
+	// Tools are the names of the tools the model can call
+	Tools []string          `json:"tools,omitempty"`
+	Posts []fingerprintPost `json:"posts"`
+}
+
+type fingerprintPost struct {
+	Role      PostRole          `json:"role"`
+	Message   string            `json:"message"`
+	Reasoning string            `json:"reasoning,omitempty"`
+	ToolUse   []ToolCall        `json:"tool_use,omitempty"`
+	Files     []fingerprintFile `json:"files,omitempty"`
+}
+
+// fingerprintFile describes a file without its content, which can only be read once.
+type fingerprintFile struct {
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+func newRequestFingerprint(request CompletionRequest, cfg LanguageModelConfig) requestFingerprint {
+	fingerprint := requestFingerprint{
+		Model:              cfg.Model,
+		MaxGeneratedTokens: cfg.MaxGeneratedTokens,
+		EnableVision:       cfg.EnableVision,
+		JSONOutputFormat:   cfg.JSONOutputFormat,
+		ToolsDisabled:      cfg.ToolsDisabled,
+		ReasoningDisabled:  cfg.ReasoningDisabled,
+		Posts:              make([]fingerprintPost, 0, len(request.Posts)),
+	}
+	if !cfg.ToolsDisabled && request.Context != nil && request.Context.Tools != nil {
+		for _, tool := range request.Context.Tools.GetTools() {
+			fingerprint.Tools = append(fingerprint.Tools, tool.Name)
+		}
+		slices.Sort(fingerprint.Tools)
+	}
+	for _, post := range request.Posts {
+		fp := fingerprintPost{
+			Role:      post.Role,
+			Message:   post.Message,
+			Reasoning: post.Reasoning,
+			ToolUse:   post.ToolUse,
+		}
+		for _, file := range post.Files {
+			fp.Files = append(fp.Files, fingerprintFile{MimeType: file.MimeType, Size: file.Size})
+		}
+		fingerprint.Posts = append(fingerprint.Posts, fp)
+	}
+	return fingerprint
+}
+
+// hashJSON returns the hex encoded SHA-256 hash of the JSON encoding of v.
+func hashJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
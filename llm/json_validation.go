@@ -0,0 +1,211 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// DefaultJSONValidationRetries is how many times a response that doesn't match the requested JSON
+// schema is retried when the bot doesn't configure it.
+const DefaultJSONValidationRetries = 2
+
+const repairJSONPrompt = `Your previous response is not valid for the required JSON schema: %s
+
+The response must be a single JSON value matching this schema:
+%s
+
+Respond again with only the corrected JSON and no other text.`
+
+// JSONValidationError is returned when a response still doesn't match the requested JSON schema
+// after all retries.
+type JSONValidationError struct {
+	// Response is the last response of the model
+	Response string
+	// Attempts is the number of responses that were validated
+	Attempts int
+	// Err is the validation error of the last response
+	Err error
+}
+
+func (e *JSONValidationError) Error() string {
+	return fmt.Sprintf("response does not match the JSON schema after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *JSONValidationError) Unwrap() error {
+	return e.Err
+}
+
+// JSONValidationWrapper checks that responses to requests made with a JSON output format match
+// the schema. Providers may ignore the schema, so invalid responses are retried with the validation
+// error given to the model. Text of those requests is only streamed once the response is valid.
+type JSONValidationWrapper struct {
+	wrapped    LanguageModel
+	maxRetries int
+}
+
+// NewJSONValidationWrapper creates a JSONValidationWrapper. maxRetries is the number of times an
+// invalid response is retried, DefaultJSONValidationRetries when 0 and none when negative.
+func NewJSONValidationWrapper(wrapped LanguageModel, maxRetries int) *JSONValidationWrapper {
+	if maxRetries == 0 {
+		maxRetries = DefaultJSONValidationRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &JSONValidationWrapper{
+		wrapped:    wrapped,
+		maxRetries: maxRetries,
+	}
+}
+
+func (w *JSONValidationWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.JSONOutputFormat == nil {
+		return w.wrapped.ChatCompletion(ctx, request, opts...)
+	}
+
+	schema, err := cfg.JSONOutputFormat.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON output schema: %w", err)
+	}
+	schemaJSON, err := json.Marshal(cfg.JSONOutputFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON output schema: %w", err)
+	}
+
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		for attempt := 1; ; attempt++ {
+			var response strings.Builder
+			finished, passedOn := false, false
+			for event := range result.Stream {
+				// The rest of the stream is drained so the wrapped model doesn't block
+				if finished || passedOn {
+					continue
+				}
+				switch event.Type {
+				case EventTypeText:
+					if text, ok := event.Value.(string); ok {
+						response.WriteString(text)
+					}
+				case EventTypeEnd:
+					finished = true
+				case EventTypeError, EventTypeToolCalls:
+					// Tool calls aren't the final response, so they are passed on for the caller to resolve
+					if response.Len() > 0 {
+						output <- TextStreamEvent{Type: EventTypeText, Value: response.String()}
+					}
+					output <- event
+					passedOn = true
+				default:
+					output <- event
+				}
+			}
+			if !finished {
+				return
+			}
+
+			text := extractJSON(response.String())
+			validationErr := validateJSON(schema, text)
+			if validationErr == nil {
+				output <- TextStreamEvent{Type: EventTypeText, Value: text}
+				output <- TextStreamEvent{Type: EventTypeEnd}
+				return
+			}
+			if attempt > w.maxRetries {
+				output <- TextStreamEvent{Type: EventTypeError, Value: &JSONValidationError{
+					Response: response.String(),
+					Attempts: attempt,
+					Err:      validationErr,
+				}}
+				return
+			}
+
+			request.Posts = append(slices.Clone(request.Posts),
+				Post{Role: PostRoleBot, Message: response.String()},
+				Post{Role: PostRoleUser, Message: fmt.Sprintf(repairJSONPrompt, validationErr, schemaJSON)},
+			)
+			result, err = w.wrapped.ChatCompletion(ctx, request, opts...)
+			if err != nil {
+				output <- TextStreamEvent{Type: EventTypeError, Value: err}
+				return
+			}
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *JSONValidationWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *JSONValidationWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *JSONValidationWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
+
+// extractJSON removes the whitespace and markdown code fence models often put around JSON.
+func extractJSON(response string) string {
+	text := strings.TrimSpace(response)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	text = strings.TrimSuffix(text[3:], "```")
+	// The opening fence can be followed by a language, such as ```json
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+func validateJSON(schema *jsonschema.Resolved, text string) error {
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return schema.Validate(value)
+}
+
+// CompleteJSON requests a response matching the JSON schema of T and unmarshals it. A response
+// that can't be unmarshalled into T returns a JSONValidationError.
+func CompleteJSON[T any](ctx context.Context, model LanguageModel, request CompletionRequest, opts ...LanguageModelOption) (T, error) {
+	var value T
+	response, err := model.ChatCompletionNoStream(ctx, request, append(slices.Clone(opts), WithJSONOutput[T]())...)
+	if err != nil {
+		return value, err
+	}
+
+	if err := json.Unmarshal([]byte(extractJSON(response)), &value); err != nil {
+		return value, &JSONValidationError{
+			Response: response,
+			Attempts: 1,
+			Err:      err,
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type weather struct {
+	City        string `json:"city"`
+	Temperature int    `json:"temperature"`
+}
+
+func textResponse(text string) *llm.TextStreamResult {
+	return streamOf(
+		llm.TextStreamEvent{Type: llm.EventTypeText, Value: text},
+		llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 5}},
+		llm.TextStreamEvent{Type: llm.EventTypeEnd},
+	)
+}
+
+func TestJSONValidationWrapper(t *testing.T) {
+	request := llm.CompletionRequest{
+		Posts: []llm.Post{{Role: llm.PostRoleUser, Message: "What's the weather in Toronto?"}},
+	}
+
+	t.Run("requests without a JSON output format are passed through", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(textResponse("Sunny"), nil).Once()
+
+		response, err := llm.NewJSONValidationWrapper(mockLLM, 0).ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "Sunny", response)
+	})
+
+	t.Run("valid responses are unwrapped from code fences", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+			Return(textResponse("```json\n{\"city\": \"Toronto\", \"temperature\": 21}\n```"), nil).Once()
+
+		result, err := llm.NewJSONValidationWrapper(mockLLM, 0).ChatCompletion(t.Context(), request, llm.WithJSONOutput[weather]())
+		require.NoError(t, err)
+		events := collect(t, result)
+		assert.Equal(t, []llm.TextStreamEvent{
+			{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 5}},
+			{Type: llm.EventTypeText, Value: `{"city": "Toronto", "temperature": 21}`},
+			{Type: llm.EventTypeEnd},
+		}, events)
+	})
+
+	t.Run("invalid responses are retried with the validation error", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+			Return(textResponse(`{"city": "Toronto", "temperature": "warm"}`), nil).Once()
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+			RunAndReturn(func(_ context.Context, retry llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+				require.Len(t, retry.Posts, 3)
+				assert.Equal(t, llm.PostRoleBot, retry.Posts[1].Role)
+				assert.Equal(t, `{"city": "Toronto", "temperature": "warm"}`, retry.Posts[1].Message)
+				assert.Equal(t, llm.PostRoleUser, retry.Posts[2].Role)
+				assert.Contains(t, retry.Posts[2].Message, "temperature")
+				return textResponse(`{"city": "Toronto", "temperature": 21}`), nil
+			}).Once()
+
+		value, err := llm.CompleteJSON[weather](t.Context(), llm.NewJSONValidationWrapper(mockLLM, 0), request)
+		require.NoError(t, err)
+		assert.Equal(t, weather{City: "Toronto", Temperature: 21}, value)
+		assert.Len(t, request.Posts, 1, "the caller's request is not modified")
+	})
+
+	t.Run("a typed error is returned once retries are exhausted", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).
+			RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+				return textResponse("It's sunny in Toronto"), nil
+			}).Times(2)
+
+		_, err := llm.NewJSONValidationWrapper(mockLLM, 1).ChatCompletionNoStream(t.Context(), request, llm.WithJSONOutput[weather]())
+		var validationErr *llm.JSONValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, 2, validationErr.Attempts)
+		assert.Equal(t, "It's sunny in Toronto", validationErr.Response)
+	})
+
+	t.Run("tool calls and errors are passed on", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Checking"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: assert.AnError},
+		), nil).Once()
+
+		_, err := llm.NewJSONValidationWrapper(mockLLM, 0).ChatCompletionNoStream(t.Context(), request, llm.WithJSONOutput[weather]())
+		require.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestCompleteJSON(t *testing.T) {
+	mockLLM := mocks.NewMockLanguageModel(t)
+	mockLLM.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything).Return("not json", nil).Once()
+
+	_, err := llm.CompleteJSON[weather](t.Context(), mockLLM, llm.CompletionRequest{})
+	var validationErr *llm.JSONValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "not json", validationErr.Response)
+}
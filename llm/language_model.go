@@ -21,12 +21,16 @@
 package llm
 
 import (
+	"context"
+
 	"github.com/google/jsonschema-go/jsonschema"
 )
 
+// LanguageModel is implemented by every LLM provider and by the wrappers that add behavior on top of them.
+// Cancelling ctx aborts the upstream request, including a stream that is still being read.
 type LanguageModel interface {
-	ChatCompletion(conversation CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error)
-	ChatCompletionNoStream(conversation CompletionRequest, opts ...LanguageModelOption) (string, error)
+	ChatCompletion(ctx context.Context, conversation CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error)
+	ChatCompletionNoStream(ctx context.Context, conversation CompletionRequest, opts ...LanguageModelOption) (string, error)
 
 	CountTokens(text string) int
 	InputTokenLimit() int
@@ -39,6 +43,10 @@ type LanguageModelConfig struct {
 	JSONOutputFormat   *jsonschema.Schema
 	ToolsDisabled      bool
 	ReasoningDisabled  bool
+	// ResponseCache allows the response to be served from and stored in the response cache
+	ResponseCache bool
+	// Task is the kind of work the request does, used to route it to the model configured for the task
+	Task Task
 }
 
 type LanguageModelOption func(*LanguageModelConfig)
@@ -72,4 +80,20 @@ func WithReasoningDisabled() LanguageModelOption {
 	}
 }
 
+// WithResponseCache allows a repeated request to be answered from the response cache when one is
+// configured. Only use it for requests whose response doesn't depend on who is asking or when.
+func WithResponseCache() LanguageModelOption {
+	return func(cfg *LanguageModelConfig) {
+		cfg.ResponseCache = true
+	}
+}
+
+// WithTask tags the request with the kind of work it does, so it is sent to the model the bot
+// routes the task to, or the bot's model when the task isn't routed.
+func WithTask(task Task) LanguageModelOption {
+	return func(cfg *LanguageModelConfig) {
+		cfg.Task = task
+	}
+}
+
 type LanguageModelWrapper func(LanguageModel) LanguageModel
@@ -0,0 +1,115 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"slices"
+)
+
+// Task is the kind of work a request does. Bots can route tasks that don't need their main
+// model, such as generating titles, to a cheaper service or model.
+type Task string
+
+const (
+	// TaskChat is a response to a user in a conversation
+	TaskChat Task = "chat"
+	// TaskToolFollowup continues a response with the results of its tool calls
+	TaskToolFollowup Task = "tool_followup"
+	// TaskTitle generates the title of a conversation
+	TaskTitle Task = "title"
+	// TaskEmoji picks an emoji reaction for a post
+	TaskEmoji Task = "emoji"
+	// TaskSummary summarizes a thread, channel or meeting
+	TaskSummary Task = "summary"
+	// TaskChunkSummary summarizes part of a larger text, such as a meeting transcript chunk or a tool result
+	TaskChunkSummary Task = "chunk_summary"
+	// TaskSearch answers a search query from the search results
+	TaskSearch Task = "search"
+	// TaskClassify decides whether a message posted in a channel is one a bot answers on its own
+	TaskClassify Task = "classify"
+)
+
+// ModelRoute sends the requests of a task to another service or model.
+type ModelRoute struct {
+	Task Task `json:"task"`
+	// ServiceID is the service handling the task, the bot's service when empty
+	ServiceID string `json:"serviceID"`
+	// Model overrides the default model of the service
+	Model string `json:"model"`
+}
+
+// RouteTarget is where the requests of a task are sent.
+type RouteTarget struct {
+	// Service is the service handling the requests, with Model set to the model they use
+	Service ServiceInfo
+	Model   LanguageModel
+}
+
+// ModelRoutingWrapper sends requests made with WithTask to the model routed for the task, and all
+// other requests to the wrapped model. Routed responses start with the service info of the route,
+// so usage logs and posts record the model that answered.
+type ModelRoutingWrapper struct {
+	wrapped LanguageModel
+	routes  map[Task]RouteTarget
+}
+
+func NewModelRoutingWrapper(wrapped LanguageModel, routes map[Task]RouteTarget) *ModelRoutingWrapper {
+	return &ModelRoutingWrapper{
+		wrapped: wrapped,
+		routes:  routes,
+	}
+}
+
+func (w *ModelRoutingWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	target, ok := w.routes[cfg.Task]
+	if cfg.Task == "" || !ok {
+		return w.wrapped.ChatCompletion(ctx, request, opts...)
+	}
+
+	if target.Service.Model != "" {
+		opts = append(slices.Clone(opts), WithModel(target.Service.Model))
+	}
+	result, err := target.Model.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		output <- TextStreamEvent{Type: EventTypeServiceInfo, Value: target.Service}
+		for event := range result.Stream {
+			// Services that fail over report themselves with their default model, not the routed one
+			if info, ok := event.Value.(ServiceInfo); ok && event.Type == EventTypeServiceInfo && target.Service.Model != "" {
+				info.Model = target.Service.Model
+				event.Value = info
+			}
+			output <- event
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *ModelRoutingWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *ModelRoutingWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *ModelRoutingWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelRoutingWrapper(t *testing.T) {
+	request := llm.CompletionRequest{
+		Posts: []llm.Post{{Role: llm.PostRoleUser, Message: "Hello"}},
+	}
+	routedService := llm.ServiceInfo{ID: "cheap", Name: "Cheap", Type: llm.ServiceTypeOpenAI, Model: "gpt-mini"}
+
+	t.Run("unrouted requests use the bot's model", func(t *testing.T) {
+		defaultLLM := mocks.NewMockLanguageModel(t)
+		routedLLM := mocks.NewMockLanguageModel(t)
+		defaultLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(textResponse("Untagged"), nil).Once()
+		defaultLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(textResponse("Chat"), nil).Once()
+
+		router := llm.NewModelRoutingWrapper(defaultLLM, map[llm.Task]llm.RouteTarget{
+			llm.TaskTitle: {Service: routedService, Model: routedLLM},
+		})
+
+		response, err := router.ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "Untagged", response)
+
+		response, err = router.ChatCompletionNoStream(t.Context(), request, llm.WithTask(llm.TaskChat))
+		require.NoError(t, err)
+		assert.Equal(t, "Chat", response)
+	})
+
+	t.Run("routed requests use the route's model", func(t *testing.T) {
+		defaultLLM := mocks.NewMockLanguageModel(t)
+		routedLLM := mocks.NewMockLanguageModel(t)
+		routedLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+				cfg := llm.LanguageModelConfig{Model: "gpt-large"}
+				for _, opt := range opts {
+					opt(&cfg)
+				}
+				assert.Equal(t, "gpt-mini", cfg.Model)
+				assert.Equal(t, 25, cfg.MaxGeneratedTokens)
+				return streamOf(
+					llm.TextStreamEvent{Type: llm.EventTypeServiceInfo, Value: llm.ServiceInfo{ID: "cheap", Name: "Cheap", Model: "gpt-default"}},
+					llm.TextStreamEvent{Type: llm.EventTypeText, Value: "A title"},
+					llm.TextStreamEvent{Type: llm.EventTypeEnd},
+				), nil
+			}).Once()
+
+		router := llm.NewModelRoutingWrapper(defaultLLM, map[llm.Task]llm.RouteTarget{
+			llm.TaskTitle: {Service: routedService, Model: routedLLM},
+		})
+
+		result, err := router.ChatCompletion(t.Context(), request, llm.WithMaxGeneratedTokens(25), llm.WithTask(llm.TaskTitle))
+		require.NoError(t, err)
+		assert.Equal(t, []llm.TextStreamEvent{
+			{Type: llm.EventTypeServiceInfo, Value: routedService},
+			{Type: llm.EventTypeServiceInfo, Value: llm.ServiceInfo{ID: "cheap", Name: "Cheap", Model: "gpt-mini"}},
+			{Type: llm.EventTypeText, Value: "A title"},
+			{Type: llm.EventTypeEnd},
+		}, collect(t, result))
+	})
+}
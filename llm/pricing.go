@@ -0,0 +1,133 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"strings"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	// ServiceType restricts the price to one service type, empty applies it to every service
+	ServiceType string `json:"serviceType"`
+	// Model matches model names that start with it, such as "gpt-4o" for "gpt-4o-2024-08-06",
+	// and provider qualified names such as "us.anthropic.claude-sonnet-4-20250514-v1:0"
+	Model            string  `json:"model"`
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+	// CachedInputPerMillion is the price of input read from the prompt cache, InputPerMillion when unset
+	CachedInputPerMillion float64 `json:"cachedInputPerMillion"`
+	// CacheWriteInputPerMillion is the price of input written to the prompt cache, InputPerMillion when unset
+	CacheWriteInputPerMillion float64 `json:"cacheWriteInputPerMillion"`
+}
+
+// Cost returns the cost of usage in USD.
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	cachedPrice := p.CachedInputPerMillion
+	if cachedPrice == 0 {
+		cachedPrice = p.InputPerMillion
+	}
+	cacheWritePrice := p.CacheWriteInputPerMillion
+	if cacheWritePrice == 0 {
+		cacheWritePrice = p.InputPerMillion
+	}
+
+	uncached := max(usage.InputTokens-usage.CacheReadInputTokens-usage.CacheCreationInputTokens, 0)
+	return (float64(uncached)*p.InputPerMillion +
+		float64(usage.CacheReadInputTokens)*cachedPrice +
+		float64(usage.CacheCreationInputTokens)*cacheWritePrice +
+		float64(usage.OutputTokens)*p.OutputPerMillion) / 1_000_000
+}
+
+func (p ModelPrice) matches(serviceType, model string) bool {
+	if p.Model == "" || (p.ServiceType != "" && p.ServiceType != serviceType) {
+		return false
+	}
+	return strings.HasPrefix(model, p.Model) || strings.Contains(model, "."+p.Model)
+}
+
+// DefaultModelPrices are the list prices of well known models. Admins can override them in the plugin configuration.
+var DefaultModelPrices = []ModelPrice{
+	{Model: "gpt-4o", InputPerMillion: 2.50, OutputPerMillion: 10.00, CachedInputPerMillion: 1.25},
+	{Model: "gpt-4o-mini", InputPerMillion: 0.15, OutputPerMillion: 0.60, CachedInputPerMillion: 0.075},
+	{Model: "gpt-4.1", InputPerMillion: 2.00, OutputPerMillion: 8.00, CachedInputPerMillion: 0.50},
+	{Model: "gpt-4.1-mini", InputPerMillion: 0.40, OutputPerMillion: 1.60, CachedInputPerMillion: 0.10},
+	{Model: "gpt-4.1-nano", InputPerMillion: 0.10, OutputPerMillion: 0.40, CachedInputPerMillion: 0.025},
+	{Model: "gpt-5", InputPerMillion: 1.25, OutputPerMillion: 10.00, CachedInputPerMillion: 0.125},
+	{Model: "gpt-5-mini", InputPerMillion: 0.25, OutputPerMillion: 2.00, CachedInputPerMillion: 0.025},
+	{Model: "gpt-5-nano", InputPerMillion: 0.05, OutputPerMillion: 0.40, CachedInputPerMillion: 0.005},
+	{Model: "o3", InputPerMillion: 2.00, OutputPerMillion: 8.00, CachedInputPerMillion: 0.50},
+	{Model: "o4-mini", InputPerMillion: 1.10, OutputPerMillion: 4.40, CachedInputPerMillion: 0.275},
+	{Model: "claude-opus-4", InputPerMillion: 15.00, OutputPerMillion: 75.00, CachedInputPerMillion: 1.50, CacheWriteInputPerMillion: 18.75},
+	{Model: "claude-opus-4-5", InputPerMillion: 5.00, OutputPerMillion: 25.00, CachedInputPerMillion: 0.50, CacheWriteInputPerMillion: 6.25},
+	{Model: "claude-sonnet-4", InputPerMillion: 3.00, OutputPerMillion: 15.00, CachedInputPerMillion: 0.30, CacheWriteInputPerMillion: 3.75},
+	{Model: "claude-3-7-sonnet", InputPerMillion: 3.00, OutputPerMillion: 15.00, CachedInputPerMillion: 0.30, CacheWriteInputPerMillion: 3.75},
+	{Model: "claude-3-5-sonnet", InputPerMillion: 3.00, OutputPerMillion: 15.00, CachedInputPerMillion: 0.30, CacheWriteInputPerMillion: 3.75},
+	{Model: "claude-haiku-4-5", InputPerMillion: 1.00, OutputPerMillion: 5.00, CachedInputPerMillion: 0.10, CacheWriteInputPerMillion: 1.25},
+	{Model: "claude-3-5-haiku", InputPerMillion: 0.80, OutputPerMillion: 4.00, CachedInputPerMillion: 0.08, CacheWriteInputPerMillion: 1.00},
+	{Model: "gemini-2.5-pro", InputPerMillion: 1.25, OutputPerMillion: 10.00, CachedInputPerMillion: 0.31},
+	{Model: "gemini-2.5-flash", InputPerMillion: 0.30, OutputPerMillion: 2.50, CachedInputPerMillion: 0.075},
+	{Model: "gemini-2.5-flash-lite", InputPerMillion: 0.10, OutputPerMillion: 0.40, CachedInputPerMillion: 0.025},
+	{Model: "gemini-2.0-flash", InputPerMillion: 0.10, OutputPerMillion: 0.40, CachedInputPerMillion: 0.025},
+}
+
+// PricingRegistry looks up model prices. Overrides take precedence over DefaultModelPrices.
+type PricingRegistry struct {
+	overrides []ModelPrice
+}
+
+func NewPricingRegistry(overrides []ModelPrice) *PricingRegistry {
+	return &PricingRegistry{
+		overrides: overrides,
+	}
+}
+
+// Lookup returns the price of model on a service of serviceType. When several prices match, a price for
+// the service type wins over one for every service and a longer model name wins over a shorter one.
+func (r *PricingRegistry) Lookup(serviceType, model string) (ModelPrice, bool) {
+	if price, ok := bestPrice(r.overrides, serviceType, model); ok {
+		return price, true
+	}
+	return bestPrice(DefaultModelPrices, serviceType, model)
+}
+
+func bestPrice(prices []ModelPrice, serviceType, model string) (ModelPrice, bool) {
+	var best ModelPrice
+	found := false
+	for _, price := range prices {
+		if !price.matches(serviceType, model) {
+			continue
+		}
+		if found {
+			if best.ServiceType != "" && price.ServiceType == "" {
+				continue
+			}
+			if (best.ServiceType == "") == (price.ServiceType == "") && len(price.Model) <= len(best.Model) {
+				continue
+			}
+		}
+		best = price
+		found = true
+	}
+	return best, found
+}
+
+// CostRecord is the cost of the tokens used by a single request.
+type CostRecord struct {
+	BotName     string
+	TeamID      string
+	ChannelType string
+	UserID      string
+	ServiceType string
+	Model       string
+	Usage       TokenUsage
+	// Cost is in USD, 0 if the model has no price
+	Cost float64
+}
+
+// CostTracker prices models and stores the cost of requests.
+type CostTracker interface {
+	LookupPrice(serviceType, model string) (ModelPrice, bool)
+	RecordCost(record CostRecord)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingRegistryLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		overrides   []llm.ModelPrice
+		serviceType string
+		model       string
+		wantFound   bool
+		wantInput   float64
+	}{
+		{
+			name:        "exact model",
+			serviceType: llm.ServiceTypeOpenAI,
+			model:       "gpt-4o",
+			wantFound:   true,
+			wantInput:   2.50,
+		},
+		{
+			name:        "longest matching prefix wins",
+			serviceType: llm.ServiceTypeOpenAI,
+			model:       "gpt-4o-mini-2024-07-18",
+			wantFound:   true,
+			wantInput:   0.15,
+		},
+		{
+			name:        "provider qualified bedrock model",
+			serviceType: llm.ServiceTypeBedrock,
+			model:       "us.anthropic.claude-sonnet-4-20250514-v1:0",
+			wantFound:   true,
+			wantInput:   3.00,
+		},
+		{
+			name:        "unknown model",
+			serviceType: llm.ServiceTypeOpenAICompatible,
+			model:       "llama3.1:8b",
+			wantFound:   false,
+		},
+		{
+			name:        "override replaces the default price",
+			overrides:   []llm.ModelPrice{{Model: "gpt-4o", InputPerMillion: 1}},
+			serviceType: llm.ServiceTypeOpenAI,
+			model:       "gpt-4o-2024-08-06",
+			wantFound:   true,
+			wantInput:   1,
+		},
+		{
+			name: "override for the service type wins over one for every service",
+			overrides: []llm.ModelPrice{
+				{Model: "gpt-4o-mini", InputPerMillion: 1},
+				{ServiceType: llm.ServiceTypeAzure, Model: "gpt-4o", InputPerMillion: 2},
+			},
+			serviceType: llm.ServiceTypeAzure,
+			model:       "gpt-4o-mini",
+			wantFound:   true,
+			wantInput:   2,
+		},
+		{
+			name:        "override for another service type is ignored",
+			overrides:   []llm.ModelPrice{{ServiceType: llm.ServiceTypeAzure, Model: "gpt-4o", InputPerMillion: 2}},
+			serviceType: llm.ServiceTypeOpenAI,
+			model:       "gpt-4o",
+			wantFound:   true,
+			wantInput:   2.50,
+		},
+		{
+			name:        "override prices local models",
+			overrides:   []llm.ModelPrice{{ServiceType: llm.ServiceTypeOpenAICompatible, Model: "llama3.1", InputPerMillion: 0.01}},
+			serviceType: llm.ServiceTypeOpenAICompatible,
+			model:       "llama3.1:8b",
+			wantFound:   true,
+			wantInput:   0.01,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, found := llm.NewPricingRegistry(tt.overrides).Lookup(tt.serviceType, tt.model)
+			require.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantInput, price.InputPerMillion)
+		})
+	}
+}
+
+func TestModelPriceCost(t *testing.T) {
+	price := llm.ModelPrice{InputPerMillion: 3, OutputPerMillion: 15}
+	assert.InDelta(t, 0.0105, price.Cost(llm.TokenUsage{InputTokens: 1000, OutputTokens: 500}), 1e-12)
+	assert.Zero(t, price.Cost(llm.TokenUsage{}))
+}
+
+func TestModelPriceCostWithPromptCache(t *testing.T) {
+	usage := llm.TokenUsage{InputTokens: 10000, OutputTokens: 500, CacheReadInputTokens: 6000, CacheCreationInputTokens: 3000}
+
+	price := llm.ModelPrice{InputPerMillion: 3, OutputPerMillion: 15, CachedInputPerMillion: 0.3, CacheWriteInputPerMillion: 3.75}
+	// 1000 uncached, 6000 read and 3000 written input tokens
+	assert.InDelta(t, (1000*3+6000*0.3+3000*3.75+500*15)/1_000_000.0, price.Cost(usage), 1e-12)
+
+	// Cache prices default to the input price
+	price = llm.ModelPrice{InputPerMillion: 3, OutputPerMillion: 15}
+	assert.InDelta(t, (10000*3+500*15)/1_000_000.0, price.Cost(usage), 1e-12)
+}
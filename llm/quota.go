@@ -0,0 +1,97 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QuotaExceededError is returned when a request is rejected because a token quota has been used up.
+type QuotaExceededError struct {
+	// Scope is "bot", "team" or "user"
+	Scope string
+	// ScopeID is the bot name, team ID or user ID the quota applies to
+	ScopeID string
+	// Period is "daily" or "monthly"
+	Period  string
+	ResetAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s %s token quota exceeded for %s, resets at %s", e.Period, e.Scope, e.ScopeID, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// QuotaEnforcer checks requests against token quotas and accumulates the tokens they use.
+type QuotaEnforcer interface {
+	// CheckQuota returns a *QuotaExceededError if the request may not be made.
+	CheckQuota(botName string, context *Context) error
+	RecordUsage(botName string, context *Context, usage TokenUsage)
+}
+
+// QuotaWrapper rejects requests once a quota has been used up and records the usage of accepted requests.
+// Rejected requests return a stream that fails with a *QuotaExceededError so the refusal reaches the
+// user through the same path as other streaming errors.
+type QuotaWrapper struct {
+	wrapped  LanguageModel
+	botName  string
+	enforcer QuotaEnforcer
+}
+
+func NewQuotaWrapper(wrapped LanguageModel, botName string, enforcer QuotaEnforcer) *QuotaWrapper {
+	return &QuotaWrapper{
+		wrapped:  wrapped,
+		botName:  botName,
+		enforcer: enforcer,
+	}
+}
+
+func (w *QuotaWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	if err := w.enforcer.CheckQuota(w.botName, request.Context); err != nil {
+		output := make(chan TextStreamEvent, 1)
+		output <- TextStreamEvent{
+			Type:  EventTypeError,
+			Value: err,
+		}
+		close(output)
+		return &TextStreamResult{Stream: output}, nil
+	}
+
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+		for event := range result.Stream {
+			if event.Type == EventTypeUsage {
+				if usage, ok := event.Value.(TokenUsage); ok {
+					w.enforcer.RecordUsage(w.botName, request.Context, usage)
+				}
+			}
+			output <- event
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *QuotaWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *QuotaWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *QuotaWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQuotaEnforcer struct {
+	err      error
+	recorded []llm.TokenUsage
+}
+
+func (f *fakeQuotaEnforcer) CheckQuota(string, *llm.Context) error {
+	return f.err
+}
+
+func (f *fakeQuotaEnforcer) RecordUsage(_ string, _ *llm.Context, usage llm.TokenUsage) {
+	f.recorded = append(f.recorded, usage)
+}
+
+func TestQuotaWrapper(t *testing.T) {
+	t.Run("records usage and passes events through", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+			llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 5}},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		enforcer := &fakeQuotaEnforcer{}
+		wrapper := llm.NewQuotaWrapper(mockLLM, "bot", enforcer)
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 3)
+		assert.Equal(t, llm.EventTypeUsage, events[1].Type)
+		assert.Equal(t, []llm.TokenUsage{{InputTokens: 10, OutputTokens: 5}}, enforcer.recorded)
+	})
+
+	t.Run("rejects requests over quota without calling the model", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		quotaErr := &llm.QuotaExceededError{Scope: "user", ScopeID: "user1", Period: "daily", ResetAt: time.Now()}
+
+		wrapper := llm.NewQuotaWrapper(mockLLM, "bot", &fakeQuotaEnforcer{err: quotaErr})
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 1)
+		assert.Equal(t, llm.EventTypeError, events[0].Type)
+		assert.ErrorIs(t, events[0].Value.(error), quotaErr)
+
+		_, err = wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		assert.ErrorIs(t, err, quotaErr)
+	})
+}
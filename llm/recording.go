@@ -0,0 +1,339 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrFixtureNotFound is returned by ReplayLanguageModel when no response was recorded for a request.
+var ErrFixtureNotFound = errors.New("no recorded response for request")
+
+// timePlaceholder replaces the request time in recorded prompts so recordings match on later days
+const timePlaceholder = "{{.Time}}"
+
+// fixture is a recorded request and the events streamed in response, stored as JSON.
+type fixture struct {
+	Request requestFingerprint `json:"request"`
+	Events  []fixtureEvent     `json:"events"`
+}
+
+type fixtureEvent struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	// Offset is the time since the request was made, in milliseconds
+	Offset int64 `json:"offset_ms"`
+}
+
+var fixtureEventTypes = map[EventType]string{
+	EventTypeText:         "text",
+	EventTypeEnd:          "end",
+	EventTypeError:        "error",
+	EventTypeToolCalls:    "tool_calls",
+	EventTypeReasoning:    "reasoning",
+	EventTypeReasoningEnd: "reasoning_end",
+	EventTypeAnnotations:  "annotations",
+	EventTypeUsage:        "usage",
+	EventTypeServiceInfo:  "service_info",
+}
+
+// fixtureRequest returns the fingerprint of request with the request time replaced by a placeholder,
+// and the name of the fixture file it is recorded in.
+func fixtureRequest(request CompletionRequest, opts []LanguageModelOption) (requestFingerprint, string, error) {
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	fingerprint := newRequestFingerprint(request, cfg)
+	if request.Context != nil && request.Context.Time != "" {
+		for i := range fingerprint.Posts {
+			fingerprint.Posts[i].Message = strings.ReplaceAll(fingerprint.Posts[i].Message, request.Context.Time, timePlaceholder)
+		}
+	}
+
+	hash, err := hashJSON(fingerprint)
+	if err != nil {
+		return requestFingerprint{}, "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	return fingerprint, hash + ".json", nil
+}
+
+func encodeFixtureEvent(event TextStreamEvent, offset time.Duration) (fixtureEvent, error) {
+	encoded := fixtureEvent{
+		Type:   fixtureEventTypes[event.Type],
+		Offset: offset.Milliseconds(),
+	}
+	if encoded.Type == "" {
+		return fixtureEvent{}, fmt.Errorf("unknown event type %d", event.Type)
+	}
+
+	value := event.Value
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fixtureEvent{}, fmt.Errorf("failed to encode %s event: %w", encoded.Type, err)
+		}
+		encoded.Value = data
+	}
+	return encoded, nil
+}
+
+func decodeFixtureEvent(encoded fixtureEvent) (TextStreamEvent, error) {
+	decode := func(value any) error {
+		if len(encoded.Value) == 0 {
+			return nil
+		}
+		return json.Unmarshal(encoded.Value, value)
+	}
+
+	var event TextStreamEvent
+	var err error
+	switch encoded.Type {
+	case "text", "reasoning":
+		var text string
+		err = decode(&text)
+		event = TextStreamEvent{Type: EventTypeText, Value: text}
+		if encoded.Type == "reasoning" {
+			event.Type = EventTypeReasoning
+		}
+	case "end":
+		event = TextStreamEvent{Type: EventTypeEnd}
+	case "error":
+		var message string
+		err = decode(&message)
+		event = TextStreamEvent{Type: EventTypeError, Value: errors.New(message)}
+	case "tool_calls":
+		var toolCalls []ToolCall
+		err = decode(&toolCalls)
+		event = TextStreamEvent{Type: EventTypeToolCalls, Value: toolCalls}
+	case "reasoning_end":
+		var reasoning ReasoningData
+		err = decode(&reasoning)
+		event = TextStreamEvent{Type: EventTypeReasoningEnd, Value: reasoning}
+	case "annotations":
+		var annotations []Annotation
+		err = decode(&annotations)
+		event = TextStreamEvent{Type: EventTypeAnnotations, Value: annotations}
+	case "usage":
+		var usage TokenUsage
+		err = decode(&usage)
+		event = TextStreamEvent{Type: EventTypeUsage, Value: usage}
+	case "service_info":
+		var info ServiceInfo
+		err = decode(&info)
+		event = TextStreamEvent{Type: EventTypeServiceInfo, Value: info}
+	default:
+		return TextStreamEvent{}, fmt.Errorf("unknown event type %q", encoded.Type)
+	}
+	if err != nil {
+		return TextStreamEvent{}, fmt.Errorf("failed to decode %s event: %w", encoded.Type, err)
+	}
+	return event, nil
+}
+
+// RecordingWrapper saves every request and the events streamed in response to a fixture file,
+// so ReplayLanguageModel can answer the same requests later without calling the model.
+type RecordingWrapper struct {
+	wrapped LanguageModel
+	dir     string
+}
+
+// NewRecordingWrapper creates a RecordingWrapper that writes fixtures to dir, creating it if needed.
+// A request that was recorded before is overwritten.
+func NewRecordingWrapper(wrapped LanguageModel, dir string) *RecordingWrapper {
+	return &RecordingWrapper{
+		wrapped: wrapped,
+		dir:     dir,
+	}
+}
+
+func (w *RecordingWrapper) save(name string, recorded fixture) error {
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	// Write to a temporary file first so a concurrent replay never reads a partial fixture
+	tmp, err := os.CreateTemp(w.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create fixture: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(w.dir, name))
+}
+
+func (w *RecordingWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	fingerprint, name, err := fixtureRequest(request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		recorded := fixture{Request: fingerprint}
+		saved := false
+		for event := range result.Stream {
+			encoded, err := encodeFixtureEvent(event, time.Since(start))
+			if err == nil {
+				recorded.Events = append(recorded.Events, encoded)
+			}
+
+			// Save before the final event so the fixture exists once the caller has the response
+			if !saved && (event.Type == EventTypeEnd || event.Type == EventTypeError) {
+				saved = true
+				if err := w.save(name, recorded); err != nil {
+					output <- TextStreamEvent{Type: EventTypeError, Value: fmt.Errorf("failed to record response: %w", err)}
+					continue
+				}
+			}
+			output <- event
+		}
+		if !saved {
+			if err := w.save(name, recorded); err != nil {
+				output <- TextStreamEvent{Type: EventTypeError, Value: fmt.Errorf("failed to record response: %w", err)}
+			}
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *RecordingWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *RecordingWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *RecordingWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
+
+// ReplayLanguageModel answers requests with the responses recorded by a RecordingWrapper.
+// Requests are matched on their posts, options and available tools. The request time is
+// ignored so recordings keep matching prompts that include the current time.
+type ReplayLanguageModel struct {
+	dir string
+	// realTiming replays events with the delays they were recorded with
+	realTiming      bool
+	tokenizer       Tokenizer
+	inputTokenLimit int
+}
+
+// DefaultReplayInputTokenLimit is the input token limit reported by ReplayLanguageModel.
+const DefaultReplayInputTokenLimit = 128000
+
+// NewReplayLanguageModel creates a ReplayLanguageModel reading fixtures from dir.
+// With realTiming, events are streamed with the delays they were recorded with instead of all at once.
+func NewReplayLanguageModel(dir string, realTiming bool) *ReplayLanguageModel {
+	return &ReplayLanguageModel{
+		dir:             dir,
+		realTiming:      realTiming,
+		tokenizer:       ApproximateTokenizer{},
+		inputTokenLimit: DefaultReplayInputTokenLimit,
+	}
+}
+
+func (r *ReplayLanguageModel) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	_, name, err := fixtureRequest(request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: fixture %s not found in %s", ErrFixtureNotFound, name, r.dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", name, err)
+	}
+	events := make([]TextStreamEvent, 0, len(recorded.Events))
+	offsets := make([]time.Duration, 0, len(recorded.Events))
+	for _, encoded := range recorded.Events {
+		event, err := decodeFixtureEvent(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+		}
+		events = append(events, event)
+		offsets = append(offsets, time.Duration(encoded.Offset)*time.Millisecond)
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		start := time.Now()
+		for i, event := range events {
+			if r.realTiming {
+				select {
+				case <-time.After(time.Until(start.Add(offsets[i]))):
+				case <-ctx.Done():
+					output <- TextStreamEvent{Type: EventTypeError, Value: ctx.Err()}
+					return
+				}
+			}
+			select {
+			case output <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (r *ReplayLanguageModel) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := r.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (r *ReplayLanguageModel) CountTokens(text string) int {
+	return r.tokenizer.CountTokens(text)
+}
+
+func (r *ReplayLanguageModel) InputTokenLimit() int {
+	return r.inputTokenLimit
+}
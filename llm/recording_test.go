@@ -0,0 +1,114 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	newRequest := func(now string) llm.CompletionRequest {
+		context := llm.NewContext()
+		context.Time = now
+		return llm.CompletionRequest{
+			Posts: []llm.Post{
+				{Role: llm.PostRoleSystem, Message: "The time is " + now},
+				{Role: llm.PostRoleUser, Message: "What's the weather?"},
+			},
+			Context: context,
+		}
+	}
+
+	recordedEvents := []llm.TextStreamEvent{
+		{Type: llm.EventTypeReasoning, Value: "Checking"},
+		{Type: llm.EventTypeReasoningEnd, Value: llm.ReasoningData{Text: "Checking", Signature: "sig"}},
+		{Type: llm.EventTypeText, Value: "Sunny"},
+		{Type: llm.EventTypeAnnotations, Value: []llm.Annotation{{Type: llm.AnnotationTypeURLCitation, URL: "https://example.com", Title: "Forecast"}}},
+		{Type: llm.EventTypeToolCalls, Value: []llm.ToolCall{{ID: "1", Name: "lookup", Arguments: json.RawMessage(`{"city":"Toronto"}`)}}},
+		{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 2}},
+		{Type: llm.EventTypeEnd},
+	}
+
+	t.Run("replays recorded streams for matching requests", func(t *testing.T) {
+		dir := t.TempDir()
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(streamOf(recordedEvents...), nil).Once()
+
+		recorder := llm.NewRecordingWrapper(mockLLM, dir)
+		result, err := recorder.ChatCompletion(t.Context(), newRequest("Monday"), llm.WithMaxGeneratedTokens(100))
+		require.NoError(t, err)
+		assert.Equal(t, recordedEvents, collect(t, result))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		// The request time changes between runs and doesn't prevent a match
+		replay := llm.NewReplayLanguageModel(dir, false)
+		result, err = replay.ChatCompletion(t.Context(), newRequest("Tuesday"), llm.WithMaxGeneratedTokens(100))
+		require.NoError(t, err)
+		events := collect(t, result)
+		require.Len(t, events, len(recordedEvents))
+		for i, event := range events {
+			assert.Equal(t, recordedEvents[i].Type, event.Type)
+		}
+		assert.Equal(t, recordedEvents[1].Value, events[1].Value)
+		assert.Equal(t, recordedEvents[3].Value, events[3].Value)
+		assert.Equal(t, recordedEvents[5].Value, events[5].Value)
+		toolCalls := events[4].Value.([]llm.ToolCall)
+		assert.JSONEq(t, `{"city":"Toronto"}`, string(toolCalls[0].Arguments))
+
+		_, err = replay.ChatCompletion(t.Context(), newRequest("Tuesday"), llm.WithMaxGeneratedTokens(200))
+		assert.ErrorIs(t, err, llm.ErrFixtureNotFound)
+	})
+
+	t.Run("errors are recorded", func(t *testing.T) {
+		dir := t.TempDir()
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Partial"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: assert.AnError},
+		), nil)
+
+		_, err := llm.NewRecordingWrapper(mockLLM, dir).ChatCompletionNoStream(t.Context(), newRequest("Monday"))
+		require.ErrorIs(t, err, assert.AnError)
+
+		_, err = llm.NewReplayLanguageModel(dir, false).ChatCompletionNoStream(t.Context(), newRequest("Monday"))
+		assert.EqualError(t, err, assert.AnError.Error())
+	})
+
+	t.Run("replays with recorded timing", func(t *testing.T) {
+		dir := t.TempDir()
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			stream := make(chan llm.TextStreamEvent)
+			go func() {
+				defer close(stream)
+				time.Sleep(50 * time.Millisecond)
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Slow"}
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeEnd}
+			}()
+			return &llm.TextStreamResult{Stream: stream}, nil
+		})
+
+		_, err := llm.NewRecordingWrapper(mockLLM, dir).ChatCompletionNoStream(t.Context(), newRequest("Monday"))
+		require.NoError(t, err)
+
+		start := time.Now()
+		response, err := llm.NewReplayLanguageModel(dir, true).ChatCompletionNoStream(t.Context(), newRequest("Monday"))
+		require.NoError(t, err)
+		assert.Equal(t, "Slow", response)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
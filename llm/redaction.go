@@ -0,0 +1,287 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RedactedSpan is a sensitive value found in a text.
+type RedactedSpan struct {
+	// Start and End are the byte offsets of the value
+	Start int
+	End   int
+	// Category is the kind of value, such as "email" or "credit_card"
+	Category string
+}
+
+// Redactor finds sensitive values that must not be sent to a model.
+type Redactor interface {
+	// Find returns the sensitive values in text ordered by position. Spans don't overlap.
+	Find(text string) []RedactedSpan
+}
+
+// RedactionObserver counts the values redacted from requests.
+type RedactionObserver interface {
+	ObserveRedactions(botName, category string, count int)
+}
+
+// RedactionWrapper replaces sensitive values in requests with placeholders such as [REDACTED_EMAIL_1]
+// and restores the original values where the model repeats the placeholders in its response.
+// A value gets the same placeholder everywhere in a request, so the model can still refer to it.
+type RedactionWrapper struct {
+	wrapped  LanguageModel
+	botName  string
+	redactor Redactor
+	observer RedactionObserver
+}
+
+// NewRedactionWrapper creates a RedactionWrapper. observer may be nil.
+func NewRedactionWrapper(wrapped LanguageModel, botName string, redactor Redactor, observer RedactionObserver) *RedactionWrapper {
+	return &RedactionWrapper{
+		wrapped:  wrapped,
+		botName:  botName,
+		redactor: redactor,
+		observer: observer,
+	}
+}
+
+// redactionSession holds the placeholders used for a single request.
+type redactionSession struct {
+	redactor     Redactor
+	placeholders map[string]string // value by placeholder
+	byValue      map[string]string // placeholder by value
+	next         map[string]int    // last placeholder number by category
+	counts       map[string]int    // redacted values by category, including repeats
+	replacer     *strings.Replacer
+}
+
+func newRedactionSession(redactor Redactor) *redactionSession {
+	return &redactionSession{
+		redactor:     redactor,
+		placeholders: map[string]string{},
+		byValue:      map[string]string{},
+		next:         map[string]int{},
+		counts:       map[string]int{},
+	}
+}
+
+func (s *redactionSession) redact(text string) string {
+	spans := s.redactor.Find(text)
+	if len(spans) == 0 {
+		return text
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span.Start < last || span.End > len(text) || span.Start >= span.End {
+			continue
+		}
+		result.WriteString(text[last:span.Start])
+		result.WriteString(s.placeholderFor(span.Category, text[span.Start:span.End]))
+		s.counts[span.Category]++
+		last = span.End
+	}
+	result.WriteString(text[last:])
+	return result.String()
+}
+
+func (s *redactionSession) placeholderFor(category, value string) string {
+	if placeholder, ok := s.byValue[value]; ok {
+		return placeholder
+	}
+	s.next[category]++
+	placeholder := fmt.Sprintf("[REDACTED_%s_%d]", placeholderCategory(category), s.next[category])
+	s.byValue[value] = placeholder
+	s.placeholders[placeholder] = value
+	s.replacer = nil
+	return placeholder
+}
+
+// placeholderCategory turns a category into the upper case identifier used in placeholders.
+func placeholderCategory(category string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, category)
+}
+
+func (s *redactionSession) restore(text string) string {
+	if len(s.placeholders) == 0 {
+		return text
+	}
+	if s.replacer == nil {
+		pairs := make([]string, 0, len(s.placeholders)*2)
+		for placeholder, value := range s.placeholders {
+			pairs = append(pairs, placeholder, value)
+		}
+		s.replacer = strings.NewReplacer(pairs...)
+	}
+	return s.replacer.Replace(text)
+}
+
+// isPartialPlaceholder reports whether text is the beginning of one of the session's placeholders.
+func (s *redactionSession) isPartialPlaceholder(text string) bool {
+	for placeholder := range s.placeholders {
+		if len(text) < len(placeholder) && strings.HasPrefix(placeholder, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderRestorer restores placeholders in streamed text. A placeholder can be split across chunks,
+// so text that could be the start of one is held back until the next chunk shows whether it is.
+type placeholderRestorer struct {
+	session *redactionSession
+	pending string
+}
+
+func (r *placeholderRestorer) push(text string) string {
+	text = r.session.restore(r.pending + text)
+	r.pending = ""
+	if i := strings.LastIndexByte(text, '['); i >= 0 && r.session.isPartialPlaceholder(text[i:]) {
+		r.pending = text[i:]
+		text = text[:i]
+	}
+	return text
+}
+
+func (r *placeholderRestorer) flush() string {
+	pending := r.pending
+	r.pending = ""
+	return pending
+}
+
+func (s *redactionSession) redactRequest(request CompletionRequest) CompletionRequest {
+	posts := make([]Post, len(request.Posts))
+	for i, post := range request.Posts {
+		post.Message = s.redact(post.Message)
+		post.Reasoning = s.redact(post.Reasoning)
+		if len(post.ToolUse) > 0 {
+			toolUse := make([]ToolCall, len(post.ToolUse))
+			for j, tool := range post.ToolUse {
+				tool.Arguments = json.RawMessage(s.redact(string(tool.Arguments)))
+				tool.Result = s.redact(tool.Result)
+				toolUse[j] = tool
+			}
+			post.ToolUse = toolUse
+		}
+		posts[i] = post
+	}
+	request.Posts = posts
+	return request
+}
+
+func (w *RedactionWrapper) observe(session *redactionSession) {
+	if w.observer == nil {
+		return
+	}
+	categories := make([]string, 0, len(session.counts))
+	for category := range session.counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		w.observer.ObserveRedactions(w.botName, category, session.counts[category])
+	}
+}
+
+func (w *RedactionWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	session := newRedactionSession(w.redactor)
+	redacted := session.redactRequest(request)
+	w.observe(session)
+
+	result, err := w.wrapped.ChatCompletion(ctx, redacted, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(session.placeholders) == 0 {
+		return result, nil
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		text := &placeholderRestorer{session: session}
+		reasoning := &placeholderRestorer{session: session}
+		flush := func(restorer *placeholderRestorer, eventType EventType) {
+			if pending := restorer.flush(); pending != "" {
+				output <- TextStreamEvent{Type: eventType, Value: pending}
+			}
+		}
+
+		for event := range result.Stream {
+			switch event.Type {
+			case EventTypeText:
+				if chunk, ok := event.Value.(string); ok {
+					if restored := text.push(chunk); restored != "" {
+						output <- TextStreamEvent{Type: EventTypeText, Value: restored}
+					}
+					continue
+				}
+			case EventTypeReasoning:
+				if chunk, ok := event.Value.(string); ok {
+					if restored := reasoning.push(chunk); restored != "" {
+						output <- TextStreamEvent{Type: EventTypeReasoning, Value: restored}
+					}
+					continue
+				}
+			}
+
+			flush(text, EventTypeText)
+			flush(reasoning, EventTypeReasoning)
+			output <- session.restoreEvent(event)
+		}
+		flush(text, EventTypeText)
+		flush(reasoning, EventTypeReasoning)
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+// restoreEvent restores placeholders in the values of events other than streamed text.
+func (s *redactionSession) restoreEvent(event TextStreamEvent) TextStreamEvent {
+	switch value := event.Value.(type) {
+	case ReasoningData:
+		value.Text = s.restore(value.Text)
+		event.Value = value
+	case []ToolCall:
+		toolCalls := make([]ToolCall, len(value))
+		for i, tool := range value {
+			tool.Arguments = json.RawMessage(s.restore(string(tool.Arguments)))
+			toolCalls[i] = tool
+		}
+		event.Value = toolCalls
+	}
+	return event
+}
+
+func (w *RedactionWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *RedactionWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *RedactionWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
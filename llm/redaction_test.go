@@ -0,0 +1,125 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// emailRedactor finds email addresses
+type emailRedactor struct{}
+
+var emailPattern = regexp.MustCompile(`[a-z]+@example\.com`)
+
+func (emailRedactor) Find(text string) []llm.RedactedSpan {
+	var spans []llm.RedactedSpan
+	for _, loc := range emailPattern.FindAllStringIndex(text, -1) {
+		spans = append(spans, llm.RedactedSpan{Start: loc[0], End: loc[1], Category: "email"})
+	}
+	return spans
+}
+
+type fakeRedactionObserver map[string]int
+
+func (f fakeRedactionObserver) ObserveRedactions(_, category string, count int) {
+	f[category] += count
+}
+
+func TestRedactionWrapper(t *testing.T) {
+	request := llm.CompletionRequest{
+		Posts: []llm.Post{
+			{Role: llm.PostRoleSystem, Message: "The user is alice@example.com"},
+			{Role: llm.PostRoleUser, Message: "Email bob@example.com and alice@example.com", ToolUse: []llm.ToolCall{{
+				Name:      "lookup",
+				Arguments: json.RawMessage(`{"email":"bob@example.com"}`),
+				Result:    "bob@example.com is Bob",
+			}}},
+		},
+		Context: llm.NewContext(),
+	}
+
+	t.Run("redacts the request and restores placeholders split across chunks", func(t *testing.T) {
+		var sent llm.CompletionRequest
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, req llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			sent = req
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Sent to [REDAC"},
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "TED_EMAIL_2] and [REDACTED_EMAIL_1"},
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "], see [1]"},
+				llm.TextStreamEvent{Type: llm.EventTypeToolCalls, Value: []llm.ToolCall{{
+					Name:      "send",
+					Arguments: json.RawMessage(`{"to":"[REDACTED_EMAIL_2]"}`),
+				}}},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+			), nil
+		})
+
+		observer := fakeRedactionObserver{}
+		wrapper := llm.NewRedactionWrapper(mockLLM, "bot", emailRedactor{}, observer)
+		result, err := wrapper.ChatCompletion(t.Context(), request)
+		require.NoError(t, err)
+		events := collect(t, result)
+
+		assert.Equal(t, "The user is [REDACTED_EMAIL_1]", sent.Posts[0].Message)
+		assert.Equal(t, "Email [REDACTED_EMAIL_2] and [REDACTED_EMAIL_1]", sent.Posts[1].Message)
+		assert.JSONEq(t, `{"email":"[REDACTED_EMAIL_2]"}`, string(sent.Posts[1].ToolUse[0].Arguments))
+		assert.Equal(t, "[REDACTED_EMAIL_2] is Bob", sent.Posts[1].ToolUse[0].Result)
+		assert.Equal(t, fakeRedactionObserver{"email": 5}, observer)
+
+		// The caller's request is left untouched
+		assert.Equal(t, "The user is alice@example.com", request.Posts[0].Message)
+		assert.Equal(t, "bob@example.com is Bob", request.Posts[1].ToolUse[0].Result)
+
+		var text string
+		for _, event := range events {
+			if event.Type == llm.EventTypeText {
+				text += event.Value.(string)
+			}
+		}
+		assert.Equal(t, "Sent to bob@example.com and alice@example.com, see [1]", text)
+
+		require.Equal(t, llm.EventTypeToolCalls, events[len(events)-2].Type)
+		toolCalls := events[len(events)-2].Value.([]llm.ToolCall)
+		assert.JSONEq(t, `{"to":"bob@example.com"}`, string(toolCalls[0].Arguments))
+	})
+
+	t.Run("text that only looks like a placeholder is released at the end", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Reply to [REDACTED_EM"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		wrapper := llm.NewRedactionWrapper(mockLLM, "bot", emailRedactor{}, nil)
+		response, err := wrapper.ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "Reply to [REDACTED_EM", response)
+	})
+
+	t.Run("requests without sensitive values pass through", func(t *testing.T) {
+		plain := llm.CompletionRequest{Posts: []llm.Post{{Role: llm.PostRoleUser, Message: "Hello"}}}
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, plain).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hi [there]"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		observer := fakeRedactionObserver{}
+		wrapper := llm.NewRedactionWrapper(mockLLM, "bot", emailRedactor{}, observer)
+		response, err := wrapper.ChatCompletionNoStream(t.Context(), plain)
+		require.NoError(t, err)
+		assert.Equal(t, "Hi [there]", response)
+		assert.Empty(t, observer)
+	})
+}
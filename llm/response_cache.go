@@ -0,0 +1,140 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// ResponseCache stores completed responses by request key.
+type ResponseCache interface {
+	// Get returns the response stored for key, if any.
+	Get(key string) (string, bool)
+	// Set stores response for key. The cache may decline to store it, for example if it is too large.
+	Set(key string, response string)
+}
+
+// ResponseCacheWrapper answers repeated requests from a ResponseCache instead of the wrapped model.
+// Only requests made with WithResponseCache are cached, and requests that can call tools or attach
+// files always go to the wrapped model. Cache hits stream the stored response followed by a usage
+// event with no tokens, so usage logging and metrics account for every request.
+type ResponseCacheWrapper struct {
+	wrapped LanguageModel
+	service ServiceInfo
+	cache   ResponseCache
+}
+
+// NewResponseCacheWrapper creates a ResponseCacheWrapper. service is the service requests are sent
+// to and is part of the cache key, so services configured with different models don't share responses.
+func NewResponseCacheWrapper(wrapped LanguageModel, service ServiceInfo, cache ResponseCache) *ResponseCacheWrapper {
+	return &ResponseCacheWrapper{
+		wrapped: wrapped,
+		service: service,
+		cache:   cache,
+	}
+}
+
+type cacheKey struct {
+	ServiceID string `json:"service_id"`
+	requestFingerprint
+}
+
+// responseCacheKey returns the key of request, or false if the request must not be cached.
+func (w *ResponseCacheWrapper) responseCacheKey(request CompletionRequest, cfg LanguageModelConfig) (string, bool) {
+	if !cfg.ResponseCache {
+		return "", false
+	}
+	// Tool results depend on the requesting user's permissions and on data that changes over time
+	if !cfg.ToolsDisabled && request.Context != nil && request.Context.Tools != nil && len(request.Context.Tools.GetTools()) > 0 {
+		return "", false
+	}
+	// Files are streamed from readers that can only be consumed once, so their content isn't part of the key
+	for _, post := range request.Posts {
+		if len(post.Files) > 0 {
+			return "", false
+		}
+	}
+
+	key := cacheKey{
+		ServiceID:          w.service.ID,
+		requestFingerprint: newRequestFingerprint(request, cfg),
+	}
+	if key.Model == "" {
+		key.Model = w.service.Model
+	}
+
+	hash, err := hashJSON(key)
+	if err != nil {
+		return "", false
+	}
+	return hash, true
+}
+
+func (w *ResponseCacheWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	key, cacheable := w.responseCacheKey(request, cfg)
+	if !cacheable {
+		return w.wrapped.ChatCompletion(ctx, request, opts...)
+	}
+
+	if response, ok := w.cache.Get(key); ok {
+		output := make(chan TextStreamEvent, 3)
+		output <- TextStreamEvent{Type: EventTypeText, Value: response}
+		output <- TextStreamEvent{Type: EventTypeUsage, Value: TokenUsage{}}
+		output <- TextStreamEvent{Type: EventTypeEnd}
+		close(output)
+		return &TextStreamResult{Stream: output}, nil
+	}
+
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		var response strings.Builder
+		complete := true
+		for event := range result.Stream {
+			switch event.Type {
+			case EventTypeText:
+				if text, ok := event.Value.(string); ok {
+					response.WriteString(text)
+				}
+			case EventTypeError, EventTypeToolCalls:
+				complete = false
+			case EventTypeEnd:
+				if complete && response.Len() > 0 {
+					w.cache.Set(key, response.String())
+				}
+			}
+			output <- event
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *ResponseCacheWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *ResponseCacheWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *ResponseCacheWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
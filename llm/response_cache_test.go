@@ -0,0 +1,148 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResponseCache map[string]string
+
+func (f fakeResponseCache) Get(key string) (string, bool) {
+	response, ok := f[key]
+	return response, ok
+}
+
+func (f fakeResponseCache) Set(key string, response string) {
+	f[key] = response
+}
+
+func TestResponseCacheWrapper(t *testing.T) {
+	service := llm.ServiceInfo{ID: "service1", Type: llm.ServiceTypeOpenAI, Model: "gpt-4o"}
+	request := llm.CompletionRequest{
+		Posts:   []llm.Post{{Role: llm.PostRoleUser, Message: "Pick an emoji"}},
+		Context: llm.NewContext(),
+	}
+
+	t.Run("repeated requests are answered from the cache", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "thumbs"},
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "up"},
+			llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 2}},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil).Once()
+
+		cache := fakeResponseCache{}
+		wrapper := llm.NewResponseCacheWrapper(mockLLM, service, cache)
+
+		response, err := wrapper.ChatCompletionNoStream(t.Context(), request, llm.WithResponseCache())
+		require.NoError(t, err)
+		assert.Equal(t, "thumbsup", response)
+		require.Len(t, cache, 1)
+
+		result, err := wrapper.ChatCompletion(t.Context(), request, llm.WithResponseCache())
+		require.NoError(t, err)
+		events := collect(t, result)
+		require.Len(t, events, 3)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeText, Value: "thumbsup"}, events[0])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{}}, events[1])
+		assert.Equal(t, llm.EventTypeEnd, events[2].Type)
+	})
+
+	t.Run("requests without the option are not cached", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+			), nil
+		}).Twice()
+
+		cache := fakeResponseCache{}
+		wrapper := llm.NewResponseCacheWrapper(mockLLM, service, cache)
+		for range 2 {
+			_, err := wrapper.ChatCompletionNoStream(t.Context(), request)
+			require.NoError(t, err)
+		}
+		assert.Empty(t, cache)
+	})
+
+	t.Run("failed responses are not cached", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Partial"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("connection reset")},
+		), nil).Once()
+
+		cache := fakeResponseCache{}
+		wrapper := llm.NewResponseCacheWrapper(mockLLM, service, cache)
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), request, llm.WithResponseCache())
+		require.Error(t, err)
+		assert.Empty(t, cache)
+	})
+
+	t.Run("requests with tools or files bypass the cache", func(t *testing.T) {
+		tools := llm.NewNoTools()
+		tools.AddTools([]llm.Tool{{Name: "search"}})
+		withTools := llm.CompletionRequest{
+			Posts:   request.Posts,
+			Context: llm.NewContext(func(c *llm.Context) { c.Tools = tools }),
+		}
+		withFiles := llm.CompletionRequest{
+			Posts: []llm.Post{{Role: llm.PostRoleUser, Message: "Describe", Files: []llm.File{{
+				MimeType: "image/png",
+				Reader:   bytes.NewReader([]byte("image")),
+			}}}},
+			Context: llm.NewContext(),
+		}
+
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+			), nil
+		}).Times(2)
+
+		cache := fakeResponseCache{}
+		wrapper := llm.NewResponseCacheWrapper(mockLLM, service, cache)
+		for _, req := range []llm.CompletionRequest{withTools, withFiles} {
+			_, err := wrapper.ChatCompletionNoStream(t.Context(), req, llm.WithResponseCache())
+			require.NoError(t, err)
+		}
+		assert.Empty(t, cache)
+	})
+
+	t.Run("the key depends on the model and options", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+			), nil
+		}).Times(3)
+
+		cache := fakeResponseCache{}
+		wrapper := llm.NewResponseCacheWrapper(mockLLM, service, cache)
+		for _, opts := range [][]llm.LanguageModelOption{
+			{llm.WithResponseCache()},
+			{llm.WithResponseCache(), llm.WithModel("gpt-4o-mini")},
+			{llm.WithResponseCache(), llm.WithMaxGeneratedTokens(25)},
+		} {
+			_, err := wrapper.ChatCompletionNoStream(t.Context(), request, opts...)
+			require.NoError(t, err)
+		}
+		assert.Len(t, cache, 3)
+	})
+}
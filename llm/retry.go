@@ -0,0 +1,230 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 20 * time.Second
+)
+
+// RetryAfterFunc extracts the delay an upstream service asked clients to wait
+// before retrying from a provider error.
+type RetryAfterFunc func(err error) (time.Duration, bool)
+
+// ParseRetryAfter reads the retry delay from response headers. It understands the
+// non-standard retry-after-ms header used by OpenAI and Anthropic as well as both
+// forms of the standard Retry-After header.
+func ParseRetryAfter(header http.Header) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if value, err := strconv.ParseFloat(ms, 64); err == nil && value >= 0 {
+			return time.Duration(value * float64(time.Millisecond)), true
+		}
+	}
+
+	retryAfter := strings.TrimSpace(header.Get("Retry-After"))
+	if retryAfter == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(retryAfter, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// RetryConfig configures a RetryWrapper.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialBackoff is the upper bound of the first jittered backoff
+	InitialBackoff time.Duration
+	// MaxBackoff caps the upper bound of any single backoff
+	MaxBackoff time.Duration
+	// MaxTotalWait caps the total time spent waiting between attempts
+	MaxTotalWait time.Duration
+
+	Classify   ErrorClassifier
+	RetryAfter RetryAfterFunc
+}
+
+// RetryWrapper retries requests that fail with transient errors (rate limits,
+// server errors and timeouts) using jittered exponential backoff.
+// Requests are never retried once reasoning, text, tool calls or annotations have been streamed.
+type RetryWrapper struct {
+	wrapped LanguageModel
+	config  RetryConfig
+	log     Logger
+}
+
+// NewRetryWrapper creates a RetryWrapper. Zero values in config are replaced with defaults.
+// log may be nil.
+func NewRetryWrapper(wrapped LanguageModel, config RetryConfig, log Logger) *RetryWrapper {
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = DefaultInitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.Classify == nil {
+		config.Classify = ClassifyError
+	}
+
+	return &RetryWrapper{
+		wrapped: wrapped,
+		config:  config,
+		log:     log,
+	}
+}
+
+// isTransient reports whether a request failing with this class of error is worth retrying
+// against the same service.
+func isTransient(class ErrorClass) bool {
+	return class == ErrorClassRateLimit || class == ErrorClassServer || class == ErrorClassTimeout
+}
+
+// retryState tracks the attempts and waiting done for a single request.
+type retryState struct {
+	attempt    int
+	totalWaits time.Duration
+}
+
+// nextDelay returns how long to wait before retrying err, or false if the request
+// should not be retried.
+func (w *RetryWrapper) nextDelay(state *retryState, err error) (time.Duration, bool) {
+	class := w.config.Classify(err)
+	if !isTransient(class) || state.attempt >= w.config.MaxRetries {
+		return 0, false
+	}
+
+	backoffCap := min(w.config.InitialBackoff<<state.attempt, w.config.MaxBackoff)
+	delay := time.Duration(rand.Int64N(int64(backoffCap) + 1))
+	if w.config.RetryAfter != nil {
+		if retryAfter, ok := w.config.RetryAfter(err); ok {
+			delay = retryAfter
+		}
+	}
+
+	if w.config.MaxTotalWait > 0 && state.totalWaits+delay > w.config.MaxTotalWait {
+		return 0, false
+	}
+
+	state.attempt++
+	state.totalWaits += delay
+
+	if w.log != nil {
+		w.log.Debug("Retrying LLM request after transient error",
+			"attempt", state.attempt,
+			"delay", delay.String(),
+			"error_class", class.String(),
+			"error", err.Error(),
+		)
+	}
+
+	return delay, true
+}
+
+// sleepContext waits for delay or until ctx is done, in which case it returns the context's error.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// start calls ChatCompletion until the request is accepted or can no longer be retried.
+func (w *RetryWrapper) start(ctx context.Context, state *retryState, request CompletionRequest, opts []LanguageModelOption) (*TextStreamResult, error) {
+	for {
+		result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+		if err == nil {
+			return result, nil
+		}
+
+		delay, ok := w.nextDelay(state, err)
+		if !ok {
+			return nil, err
+		}
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+func (w *RetryWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	state := &retryState{}
+	result, err := w.start(ctx, state, request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+		for {
+			var delay time.Duration
+			streamErr := relayUncommitted(result, output, nil, func(err error) bool {
+				var ok bool
+				delay, ok = w.nextDelay(state, err)
+				return ok
+			})
+			if streamErr == nil {
+				return
+			}
+
+			if err = sleepContext(ctx, delay); err == nil {
+				result, err = w.start(ctx, state, request, opts)
+			}
+			if err != nil {
+				output <- TextStreamEvent{
+					Type:  EventTypeError,
+					Value: err,
+				}
+				return
+			}
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *RetryWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *RetryWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *RetryWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
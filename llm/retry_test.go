@@ -0,0 +1,186 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryWrapper(t *testing.T) {
+	fastRetries := func(class llm.ErrorClass) llm.RetryConfig {
+		return llm.RetryConfig{
+			MaxRetries:     2,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Classify:       classifyAs(class),
+		}
+	}
+
+	t.Run("retries transient errors until the request succeeds", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("429")).Once()
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("529 overloaded")},
+		), nil).Once()
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil).Once()
+
+		wrapper := llm.NewRetryWrapper(mockLLM, fastRetries(llm.ErrorClassRateLimit), nil)
+		text, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", text)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("503")).Times(3)
+
+		wrapper := llm.NewRetryWrapper(mockLLM, fastRetries(llm.ErrorClassServer), nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+	})
+
+	t.Run("does not retry non transient errors", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("401")).Once()
+
+		wrapper := llm.NewRetryWrapper(mockLLM, fastRetries(llm.ErrorClassAuth), nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+	})
+
+	t.Run("never retries once text was streamed", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Partial"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("500")},
+		), nil).Once()
+
+		wrapper := llm.NewRetryWrapper(mockLLM, fastRetries(llm.ErrorClassServer), nil)
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 2)
+		assert.Equal(t, "Partial", events[0].Value)
+		assert.Equal(t, llm.EventTypeError, events[1].Type)
+	})
+
+	t.Run("reasoning streams live and is never retried", func(t *testing.T) {
+		stream := make(chan llm.TextStreamEvent)
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(&llm.TextStreamResult{Stream: stream}, nil).Once()
+
+		wrapper := llm.NewRetryWrapper(mockLLM, fastRetries(llm.ErrorClassServer), nil)
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+
+		stream <- llm.TextStreamEvent{Type: llm.EventTypeReasoning, Value: "thinking"}
+		event := <-result.Stream
+		assert.Equal(t, "thinking", event.Value, "reasoning is sent before the first text")
+
+		stream <- llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("500")}
+		close(stream)
+		events := collect(t, result)
+		require.Len(t, events, 1)
+		assert.Equal(t, llm.EventTypeError, events[0].Type)
+	})
+
+	t.Run("stops when Retry-After exceeds the wait budget", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("429")).Once()
+
+		config := fastRetries(llm.ErrorClassRateLimit)
+		config.MaxTotalWait = time.Second
+		config.RetryAfter = func(error) (time.Duration, bool) {
+			return time.Minute, true
+		}
+
+		wrapper := llm.NewRetryWrapper(mockLLM, config, nil)
+		start := time.Now()
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("honours Retry-After", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("429")).Once()
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil).Once()
+
+		config := fastRetries(llm.ErrorClassRateLimit)
+		config.RetryAfter = func(error) (time.Duration, bool) {
+			return 50 * time.Millisecond, true
+		}
+
+		wrapper := llm.NewRetryWrapper(mockLLM, config, nil)
+		start := time.Now()
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("stops waiting when the context is cancelled", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, errors.New("429")).Once()
+
+		config := fastRetries(llm.ErrorClassRateLimit)
+		config.RetryAfter = func(error) (time.Duration, bool) {
+			return 10 * time.Second, true
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		wrapper := llm.NewRetryWrapper(mockLLM, config, nil)
+		start := time.Now()
+		_, err := wrapper.ChatCompletion(ctx, llm.CompletionRequest{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Run("seconds", func(t *testing.T) {
+		delay, ok := llm.ParseRetryAfter(http.Header{"Retry-After": []string{"2"}})
+		require.True(t, ok)
+		assert.Equal(t, 2*time.Second, delay)
+	})
+
+	t.Run("milliseconds header takes precedence", func(t *testing.T) {
+		delay, ok := llm.ParseRetryAfter(http.Header{
+			"Retry-After":    []string{"2"},
+			"Retry-After-Ms": []string{"150"},
+		})
+		require.True(t, ok)
+		assert.Equal(t, 150*time.Millisecond, delay)
+	})
+
+	t.Run("http date", func(t *testing.T) {
+		date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+		delay, ok := llm.ParseRetryAfter(http.Header{"Retry-After": []string{date}})
+		require.True(t, ok)
+		assert.InDelta(t, 10*time.Second, delay, float64(2*time.Second))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, ok := llm.ParseRetryAfter(http.Header{})
+		assert.False(t, ok)
+	})
+}
@@ -12,4 +12,6 @@ const (
 	ServiceTypeCohere           = "cohere"
 	ServiceTypeBedrock          = "bedrock"
 	ServiceTypeMistral          = "mistral"
+	ServiceTypeGemini           = "gemini"
+	ServiceTypeOllama           = "ollama"
 )
@@ -3,7 +3,10 @@
 
 package llm
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // EventType represents the type of event in the text stream
 type EventType int
@@ -25,12 +28,33 @@ const (
 	EventTypeAnnotations
 	// EventTypeUsage represents token usage data
 	EventTypeUsage
+	// EventTypeServiceInfo identifies the service that is answering the request
+	EventTypeServiceInfo
+	// EventTypeAgentStep represents a step of the response that called tools which have been resolved.
+	// The text streamed since the previous step belongs to the step.
+	EventTypeAgentStep
+	// EventTypeDelegation carries the IDs of the bots that answered questions the response asked them
+	EventTypeDelegation
 )
 
 // TokenUsage represents token usage statistics for an LLM request
 type TokenUsage struct {
+	// InputTokens includes the tokens read from and written to the prompt cache
 	InputTokens  int64 `json:"input_tokens"`
 	OutputTokens int64 `json:"output_tokens"`
+	// CacheReadInputTokens are the input tokens served from the prompt cache
+	CacheReadInputTokens int64 `json:"cache_read_input_tokens,omitempty"`
+	// CacheCreationInputTokens are the input tokens written to the prompt cache
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens,omitempty"`
+}
+
+// ServiceInfo identifies the configured service that produced a response
+type ServiceInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Model is the default model of the service
+	Model string `json:"model,omitempty"`
 }
 
 // ReasoningData represents the complete reasoning/thinking data including signature
@@ -39,6 +63,15 @@ type ReasoningData struct {
 	Signature string // Opaque verification signature from the model
 }
 
+// AgentStep is a completed step of a response that continued after calling tools: the text and
+// reasoning of the model before it called the tools, and the resolved tool calls.
+type AgentStep struct {
+	Message            string     `json:"message"`
+	Reasoning          string     `json:"reasoning,omitempty"`
+	ReasoningSignature string     `json:"reasoning_signature,omitempty"`
+	ToolCalls          []ToolCall `json:"tool_calls"`
+}
+
 // TextStreamEvent represents an event in the text stream
 type TextStreamEvent struct {
 	Type  EventType
@@ -90,7 +123,7 @@ func (t *TextStreamResult) ReadAll() (string, error) {
 			return result, nil
 		case EventTypeToolCalls:
 			return result, fmt.Errorf("Tool calls are not supported for read all")
-		case EventTypeAnnotations, EventTypeReasoning, EventTypeReasoningEnd, EventTypeUsage:
+		case EventTypeAnnotations, EventTypeReasoning, EventTypeReasoningEnd, EventTypeUsage, EventTypeServiceInfo, EventTypeAgentStep:
 			// These event types are ignored in ReadAll, continue reading text
 			continue
 		}
@@ -98,3 +131,56 @@ func (t *TextStreamResult) ReadAll() (string, error) {
 
 	return result, nil
 }
+
+// relayUncommitted copies events from result to output for wrappers that may retry a request.
+// The response commits on its first reasoning, text, tool call, annotation or end event, at which
+// point onCommit is called before the event is sent. Usage events are always passed through. If
+// the stream errors before committing and retry returns true, the rest of the stream is drained
+// and the error is returned without being sent.
+func relayUncommitted(result *TextStreamResult, output chan<- TextStreamEvent, onCommit func(), retry func(error) bool) error {
+	committed := false
+	commit := func() {
+		if onCommit != nil {
+			onCommit()
+		}
+		committed = true
+	}
+
+	for event := range result.Stream {
+		if committed {
+			output <- event
+			continue
+		}
+
+		switch event.Type {
+		case EventTypeUsage:
+			// Usage is billed even if the attempt later fails, so always report it
+			output <- event
+		case EventTypeError:
+			err, ok := event.Value.(error)
+			if !ok {
+				err = errors.New("unknown error from LLM")
+			}
+			if retry(err) {
+				// Drain so the provider goroutine can finish
+				go func() {
+					for range result.Stream { //nolint:revive
+					}
+				}()
+				return err
+			}
+			commit()
+			output <- event
+		default:
+			// Reasoning streams live, so retrying after it started is no safer than after text
+			commit()
+			output <- event
+		}
+	}
+
+	if !committed {
+		commit()
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StreamMetricsObserver defines the interface for observing the latency and failures of LLM requests
+type StreamMetricsObserver interface {
+	ObserveTimeToFirstToken(botName, serviceName string, elapsed float64)
+	ObserveGenerationDuration(botName, serviceName string, elapsed float64)
+	ObserveTokensPerSecond(botName, serviceName string, tokensPerSecond float64)
+	ObserveToolCallRoundTrip(botName, serviceName string, elapsed float64)
+	IncrementLLMErrors(botName, serviceName, errorClass string)
+}
+
+// StreamMetricsWrapper measures every request sent to a service from its response stream,
+// so the metrics are the same for every provider.
+type StreamMetricsWrapper struct {
+	wrapped     LanguageModel
+	botName     string
+	serviceName string
+	classify    ErrorClassifier
+	observer    StreamMetricsObserver
+}
+
+// NewStreamMetricsWrapper creates a StreamMetricsWrapper. classify is the classifier of the service's
+// provider, the generic ClassifyError when nil.
+func NewStreamMetricsWrapper(wrapped LanguageModel, botName, serviceName string, classify ErrorClassifier, observer StreamMetricsObserver) *StreamMetricsWrapper {
+	if classify == nil {
+		classify = ClassifyError
+	}
+	return &StreamMetricsWrapper{
+		wrapped:     wrapped,
+		botName:     botName,
+		serviceName: serviceName,
+		classify:    classify,
+		observer:    observer,
+	}
+}
+
+func (w *StreamMetricsWrapper) observeError(err error) {
+	w.observer.IncrementLLMErrors(w.botName, w.serviceName, RefineErrorClass(w.classify(err), err).String())
+}
+
+func (w *StreamMetricsWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	start := time.Now()
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		w.observeError(err)
+		return nil, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		var firstToken time.Time
+		var outputTokens int64
+		for event := range result.Stream {
+			switch event.Type {
+			case EventTypeText, EventTypeReasoning:
+				if firstToken.IsZero() {
+					firstToken = time.Now()
+					w.observer.ObserveTimeToFirstToken(w.botName, w.serviceName, firstToken.Sub(start).Seconds())
+				}
+			case EventTypeToolCalls:
+				// The model answers with tool calls, which are resolved before the conversation continues
+				w.observer.ObserveToolCallRoundTrip(w.botName, w.serviceName, time.Since(start).Seconds())
+			case EventTypeUsage:
+				if usage, ok := event.Value.(TokenUsage); ok {
+					outputTokens = usage.OutputTokens
+				}
+			case EventTypeError:
+				streamErr, ok := event.Value.(error)
+				if !ok {
+					streamErr = errors.New("unknown error from LLM")
+				}
+				w.observeError(streamErr)
+			case EventTypeEnd:
+				end := time.Now()
+				w.observer.ObserveGenerationDuration(w.botName, w.serviceName, end.Sub(start).Seconds())
+				// Generation speed is measured from the first token so it doesn't include the prompt processing
+				if generating := end.Sub(firstToken); !firstToken.IsZero() && outputTokens > 0 && generating > 0 {
+					w.observer.ObserveTokensPerSecond(w.botName, w.serviceName, float64(outputTokens)/generating.Seconds())
+				}
+			}
+			output <- event
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *StreamMetricsWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *StreamMetricsWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *StreamMetricsWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedStreamMetrics struct {
+	mu                 sync.Mutex
+	timeToFirstToken   []float64
+	generationDuration []float64
+	tokensPerSecond    []float64
+	toolCallRoundTrip  []float64
+	errors             []string
+}
+
+func (r *recordedStreamMetrics) ObserveTimeToFirstToken(_, _ string, elapsed float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeToFirstToken = append(r.timeToFirstToken, elapsed)
+}
+
+func (r *recordedStreamMetrics) ObserveGenerationDuration(_, _ string, elapsed float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generationDuration = append(r.generationDuration, elapsed)
+}
+
+func (r *recordedStreamMetrics) ObserveTokensPerSecond(_, _ string, tokensPerSecond float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokensPerSecond = append(r.tokensPerSecond, tokensPerSecond)
+}
+
+func (r *recordedStreamMetrics) ObserveToolCallRoundTrip(_, _ string, elapsed float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.toolCallRoundTrip = append(r.toolCallRoundTrip, elapsed)
+}
+
+func (r *recordedStreamMetrics) IncrementLLMErrors(botName, serviceName, errorClass string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, botName+"/"+serviceName+"/"+errorClass)
+}
+
+func TestStreamMetricsWrapper(t *testing.T) {
+	t.Run("observes latency of responses", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			stream := make(chan llm.TextStreamEvent)
+			go func() {
+				defer close(stream)
+				time.Sleep(20 * time.Millisecond)
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"}
+				time.Sleep(20 * time.Millisecond)
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeText, Value: " world"}
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 2}}
+				stream <- llm.TextStreamEvent{Type: llm.EventTypeEnd}
+			}()
+			return &llm.TextStreamResult{Stream: stream}, nil
+		})
+
+		observer := &recordedStreamMetrics{}
+		wrapper := llm.NewStreamMetricsWrapper(mockLLM, "bot", "service", nil, observer)
+		response, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello world", response)
+
+		require.Len(t, observer.timeToFirstToken, 1)
+		assert.GreaterOrEqual(t, observer.timeToFirstToken[0], 0.02)
+		require.Len(t, observer.generationDuration, 1)
+		assert.GreaterOrEqual(t, observer.generationDuration[0], 0.04)
+		require.Len(t, observer.tokensPerSecond, 1)
+		// Two tokens generated in at least 20ms after the first one
+		assert.Greater(t, observer.tokensPerSecond[0], 0.0)
+		assert.LessOrEqual(t, observer.tokensPerSecond[0], 100.0)
+		assert.Empty(t, observer.toolCallRoundTrip)
+		assert.Empty(t, observer.errors)
+	})
+
+	t.Run("observes tool call round trips", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeToolCalls, Value: []llm.ToolCall{{ID: "1", Name: "lookup"}}},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		observer := &recordedStreamMetrics{}
+		wrapper := llm.NewStreamMetricsWrapper(mockLLM, "bot", "service", nil, observer)
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{})
+		require.NoError(t, err)
+		collect(t, result)
+
+		assert.Len(t, observer.toolCallRoundTrip, 1)
+		assert.Empty(t, observer.timeToFirstToken)
+		assert.Empty(t, observer.tokensPerSecond)
+	})
+
+	t.Run("counts errors by class", func(t *testing.T) {
+		rateLimited := errors.New("rate limited")
+		classify := func(err error) llm.ErrorClass {
+			if errors.Is(err, rateLimited) {
+				return llm.ErrorClassRateLimit
+			}
+			return llm.ErrorClassInvalidRequest
+		}
+
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(nil, rateLimited).Once()
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Partial"},
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: errors.New("prompt is too long")},
+		), nil).Once()
+
+		observer := &recordedStreamMetrics{}
+		wrapper := llm.NewStreamMetricsWrapper(mockLLM, "bot", "service", classify, observer)
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.ErrorIs(t, err, rateLimited)
+		_, err = wrapper.ChatCompletionNoStream(t.Context(), llm.CompletionRequest{})
+		require.Error(t, err)
+
+		assert.Equal(t, []string{"bot/service/rate_limit", "bot/service/context_length"}, observer.errors)
+		assert.Empty(t, observer.generationDuration)
+	})
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TruncationStrategy selects how conversations that exceed the input token limit are shortened.
+type TruncationStrategy string
+
+const (
+	// TruncationStrategyDropOldest drops the oldest posts until the conversation fits. This is the default.
+	TruncationStrategyDropOldest TruncationStrategy = "drop_oldest"
+	// TruncationStrategySummarize keeps system posts and the latest user turn and replaces
+	// older posts that don't fit with a rolling summary.
+	TruncationStrategySummarize TruncationStrategy = "summarize"
+)
+
+const summarizeConversationPrompt = `You maintain a running summary of an earlier part of a conversation between a user and an AI assistant. The summary replaces those messages for the assistant, so keep every fact, decision, name, number, open question and instruction from the user that may matter later. Write in the third person and be concise. Only include the summary no other text.`
+
+// ConversationSummary is a rolling summary of the oldest posts of a conversation.
+type ConversationSummary struct {
+	Summary string
+	// PostCount is the number of leading non-system posts covered by the summary
+	PostCount int
+	// Hash identifies the content of the covered posts so edited conversations are re-summarized
+	Hash string
+}
+
+// ConversationSummaryStore caches conversation summaries by the root post of the thread.
+type ConversationSummaryStore interface {
+	// GetConversationSummary returns the zero value if no summary has been saved.
+	GetConversationSummary(rootPostID string) (ConversationSummary, error)
+	SaveConversationSummary(rootPostID string, summary ConversationSummary) error
+}
+
+// SummarizingTruncationWrapper truncates requests like TruncationWrapper but never drops system
+// posts or the latest user turn. Posts that don't fit are replaced with a summary generated by the
+// wrapped model, which is cached on the thread root post and extended as the conversation grows.
+// The token usage of the summaries is sent at the start of the response stream, so the wrappers
+// logging usage and enforcing quotas account for it like the usage of the response.
+type SummarizingTruncationWrapper struct {
+	wrapped LanguageModel
+	store   ConversationSummaryStore
+	log     Logger
+}
+
+// NewSummarizingTruncationWrapper creates a SummarizingTruncationWrapper. store and log may be nil,
+// in which case summaries are not cached.
+func NewSummarizingTruncationWrapper(wrapped LanguageModel, store ConversationSummaryStore, log Logger) *SummarizingTruncationWrapper {
+	return &SummarizingTruncationWrapper{
+		wrapped: wrapped,
+		store:   store,
+		log:     log,
+	}
+}
+
+func (w *SummarizingTruncationWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	request, summaryUsage := w.truncate(ctx, request)
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil || len(summaryUsage) == 0 {
+		return result, err
+	}
+
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+		for _, usage := range summaryUsage {
+			output <- TextStreamEvent{Type: EventTypeUsage, Value: usage}
+		}
+		for event := range result.Stream {
+			output <- event
+		}
+	}()
+	return &TextStreamResult{Stream: output}, nil
+}
+
+func (w *SummarizingTruncationWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *SummarizingTruncationWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *SummarizingTruncationWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
+
+// summaryTokenBudget returns the number of tokens reserved for the summary of dropped posts.
+func summaryTokenBudget(tokenLimit int) int {
+	return min(tokenLimit/8, 2000)
+}
+
+func (w *SummarizingTruncationWrapper) postTokens(posts []Post) int {
+	total := 0
+	for _, post := range posts {
+		total += w.wrapped.CountTokens(post.Message)
+	}
+	return total
+}
+
+// truncate returns request shortened to fit the token limit, and the token usage of the summaries
+// generated for it. The result contains, in order: the system posts, a system post with the summary
+// of the dropped posts, the most recent posts that fit and the latest user turn. If the request can't
+// be summarized the oldest posts after the system posts are dropped instead.
+func (w *SummarizingTruncationWrapper) truncate(ctx context.Context, request CompletionRequest) (CompletionRequest, []TokenUsage) {
+	tokenLimit := truncationTokenLimit(w.wrapped)
+	if w.postTokens(request.Posts) <= tokenLimit {
+		return request, nil
+	}
+
+	var system, conversation []Post
+	for _, post := range request.Posts {
+		if post.Role == PostRoleSystem {
+			system = append(system, post)
+		} else {
+			conversation = append(conversation, post)
+		}
+	}
+
+	// The latest user turn is the last user post and anything that follows it, such as tool calls
+	latestTurn := len(conversation)
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if conversation[i].Role == PostRoleUser {
+			latestTurn = i
+			break
+		}
+	}
+
+	budget := tokenLimit - w.postTokens(system) - w.postTokens(conversation[latestTurn:]) - summaryTokenBudget(tokenLimit)
+	if budget < 0 {
+		request.Posts = w.dropOldest(system, conversation, tokenLimit)
+		return request, nil
+	}
+
+	// Keep as many of the most recent posts before the latest turn as fit
+	keepFrom := latestTurn
+	for keepFrom > 0 {
+		postTokens := w.wrapped.CountTokens(conversation[keepFrom-1].Message)
+		if postTokens > budget {
+			break
+		}
+		budget -= postTokens
+		keepFrom--
+	}
+
+	rootPostID := ""
+	if request.Context != nil {
+		rootPostID = request.Context.RootPostID
+	}
+
+	summary, usage, err := w.summarize(ctx, rootPostID, conversation[:keepFrom], request.Context, tokenLimit)
+	if err != nil {
+		if w.log != nil {
+			w.log.Warn("Failed to summarize conversation, dropping oldest posts instead", "error", err.Error())
+		}
+		request.Posts = w.dropOldest(system, conversation, tokenLimit)
+		return request, usage
+	}
+
+	posts := make([]Post, 0, len(system)+1+len(conversation)-keepFrom)
+	posts = append(posts, system...)
+	posts = append(posts, Post{
+		Role:    PostRoleSystem,
+		Message: "Summary of the earlier part of this conversation:\n" + KeepFirstTokens(summary, summaryTokenBudget(tokenLimit), w.wrapped.CountTokens),
+	})
+	posts = append(posts, conversation[keepFrom:]...)
+	request.Posts = posts
+
+	return request, usage
+}
+
+// dropOldest returns the system posts followed by the most recent conversation posts that fit the
+// rest of tokenLimit.
+func (w *SummarizingTruncationWrapper) dropOldest(system, conversation []Post, tokenLimit int) []Post {
+	truncated := CompletionRequest{Posts: conversation}
+	truncated.Truncate(max(tokenLimit-w.postTokens(system), 0), w.wrapped.CountTokens)
+	return append(slices.Clone(system), truncated.Posts...)
+}
+
+// hashPosts identifies the content of posts.
+func hashPosts(posts []Post) string {
+	hash := sha256.New()
+	for _, post := range posts {
+		fmt.Fprintf(hash, "%d\x00%s\x00", post.Role, post.Message)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// summarize returns a summary of posts and the token usage of generating it, reusing and extending
+// the cached summary for rootPostID when it covers a prefix of posts.
+func (w *SummarizingTruncationWrapper) summarize(ctx context.Context, rootPostID string, posts []Post, context *Context, tokenLimit int) (string, []TokenUsage, error) {
+	var cached ConversationSummary
+	if w.store != nil && rootPostID != "" {
+		var err error
+		cached, err = w.store.GetConversationSummary(rootPostID)
+		if err != nil && w.log != nil {
+			w.log.Warn("Failed to get cached conversation summary", "root_post_id", rootPostID, "error", err.Error())
+		}
+	}
+
+	summary := ""
+	from := 0
+	if cached.PostCount > 0 && cached.PostCount <= len(posts) && cached.Hash == hashPosts(posts[:cached.PostCount]) {
+		summary = cached.Summary
+		from = cached.PostCount
+	}
+	if from == len(posts) {
+		return summary, nil, nil
+	}
+
+	// Fold the remaining posts into the summary in chunks that fit the model's context
+	var usage []TokenUsage
+	chunkBudget := tokenLimit - w.wrapped.CountTokens(summarizeConversationPrompt)
+	for from < len(posts) {
+		chunk := summaryTranscriptHeader(summary)
+		chunkTokens := w.wrapped.CountTokens(chunk)
+		to := from
+		for to < len(posts) {
+			entry := formatPostForSummary(posts[to])
+			entryTokens := w.wrapped.CountTokens(entry)
+			if to > from && chunkTokens+entryTokens > chunkBudget {
+				break
+			}
+			chunk += entry
+			chunkTokens += entryTokens
+			to++
+		}
+
+		chunkSummary, chunkUsage, err := w.summarizeChunk(ctx, chunk, context, chunkBudget, tokenLimit)
+		usage = append(usage, chunkUsage...)
+		if err != nil {
+			return "", usage, err
+		}
+		summary = chunkSummary
+		from = to
+	}
+
+	if w.store != nil && rootPostID != "" {
+		if err := w.store.SaveConversationSummary(rootPostID, ConversationSummary{
+			Summary:   summary,
+			PostCount: len(posts),
+			Hash:      hashPosts(posts),
+		}); err != nil && w.log != nil {
+			w.log.Warn("Failed to save conversation summary", "root_post_id", rootPostID, "error", err.Error())
+		}
+	}
+
+	return summary, usage, nil
+}
+
+// summaryTranscriptHeader introduces the posts to summarize, including the summary so far if there is one.
+func summaryTranscriptHeader(summary string) string {
+	if summary == "" {
+		return ""
+	}
+	return "Summary so far:\n" + summary + "\n\nExtend the summary with these messages:\n"
+}
+
+func formatPostForSummary(post Post) string {
+	var result strings.Builder
+	if post.Role == PostRoleBot {
+		result.WriteString("\n--- Assistant ---\n")
+	} else {
+		result.WriteString("\n--- User ---\n")
+	}
+	result.WriteString(post.Message)
+	for _, toolCall := range post.ToolUse {
+		result.WriteString("\n[Used tool " + toolCall.Name + "]")
+	}
+	return result.String()
+}
+
+// summarizeChunk returns the summary of transcript and the token usage of generating it.
+func (w *SummarizingTruncationWrapper) summarizeChunk(ctx context.Context, transcript string, context *Context, chunkBudget int, tokenLimit int) (string, []TokenUsage, error) {
+	// A single post can still exceed the budget, keep its end which is closest to the kept posts
+	transcript = KeepLastTokens(transcript, chunkBudget, w.wrapped.CountTokens)
+
+	result, err := w.wrapped.ChatCompletion(ctx, CompletionRequest{
+		Posts: []Post{
+			{Role: PostRoleSystem, Message: summarizeConversationPrompt},
+			{Role: PostRoleUser, Message: transcript},
+		},
+		Context: context,
+	}, WithMaxGeneratedTokens(summaryTokenBudget(tokenLimit)), WithToolsDisabled(), WithReasoningDisabled())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	// The whole stream is read for the usage, which can follow the end of the text
+	var summary strings.Builder
+	var usage []TokenUsage
+	var streamErr error
+	for event := range result.Stream {
+		switch event.Type {
+		case EventTypeText:
+			if text, ok := event.Value.(string); ok {
+				summary.WriteString(text)
+			}
+		case EventTypeUsage:
+			if eventUsage, ok := event.Value.(TokenUsage); ok {
+				usage = append(usage, eventUsage)
+			}
+		case EventTypeError:
+			if eventErr, ok := event.Value.(error); ok && streamErr == nil {
+				streamErr = eventErr
+			}
+		}
+	}
+	if streamErr != nil {
+		return "", usage, fmt.Errorf("failed to summarize conversation: %w", streamErr)
+	}
+
+	return strings.TrimSpace(summary.String()), usage, nil
+}
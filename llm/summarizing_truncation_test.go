@@ -0,0 +1,238 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySummaryStore struct {
+	summaries map[string]llm.ConversationSummary
+}
+
+func (s *memorySummaryStore) GetConversationSummary(rootPostID string) (llm.ConversationSummary, error) {
+	return s.summaries[rootPostID], nil
+}
+
+func (s *memorySummaryStore) SaveConversationSummary(rootPostID string, summary llm.ConversationSummary) error {
+	s.summaries[rootPostID] = summary
+	return nil
+}
+
+func words(word string, n int) string {
+	return strings.TrimSpace(strings.Repeat(word+" ", n))
+}
+
+func TestSummarizingTruncationWrapper(t *testing.T) {
+	// An input limit of 400 leaves 180 tokens for posts, 22 of which are reserved for the summary
+	newModel := func(t *testing.T) *mocks.MockLanguageModel {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().InputTokenLimit().Return(400).Maybe()
+		mockLLM.EXPECT().CountTokens(mock.Anything).RunAndReturn(func(text string) int {
+			return len(strings.Fields(text))
+		}).Maybe()
+		return mockLLM
+	}
+
+	// captureRequest records the request sent on to the wrapped model
+	captureRequest := func(mockLLM *mocks.MockLanguageModel) *llm.CompletionRequest {
+		var sent llm.CompletionRequest
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, request llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			sent = request
+			return streamOf(llm.TextStreamEvent{Type: llm.EventTypeEnd}), nil
+		})
+		return &sent
+	}
+
+	// summarizeWith makes the wrapped model return summary and records the transcripts it was asked to summarize.
+	// Summaries are requested with three options, which tells them apart from the request they shorten.
+	summarizeWith := func(mockLLM *mocks.MockLanguageModel, summary string) *[]string {
+		var transcripts []string
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, request llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			transcripts = append(transcripts, request.Posts[len(request.Posts)-1].Message)
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: summary},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+				llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 100, OutputTokens: 10}},
+			), nil
+		})
+		return &transcripts
+	}
+
+	context := &llm.Context{RootPostID: "root"}
+	conversation := []llm.Post{
+		{Role: llm.PostRoleSystem, Message: words("system", 10)},
+		{Role: llm.PostRoleUser, Message: words("u1", 50)},
+		{Role: llm.PostRoleBot, Message: words("b1", 50)},
+		{Role: llm.PostRoleUser, Message: words("u2", 50)},
+		{Role: llm.PostRoleBot, Message: words("b2", 50)},
+		{Role: llm.PostRoleUser, Message: words("u3", 20)},
+	}
+
+	t.Run("leaves requests that fit unchanged", func(t *testing.T) {
+		mockLLM := newModel(t)
+		sent := captureRequest(mockLLM)
+
+		posts := []llm.Post{conversation[0], conversation[5]}
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: posts, Context: context})
+		require.NoError(t, err)
+		assert.Equal(t, posts, sent.Posts)
+	})
+
+	t.Run("pins system posts and the latest user turn and summarizes the middle", func(t *testing.T) {
+		mockLLM := newModel(t)
+		sent := captureRequest(mockLLM)
+		transcripts := summarizeWith(mockLLM, "they talked about u1 and b1")
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+		require.NoError(t, err)
+
+		require.Len(t, sent.Posts, 5)
+		assert.Equal(t, conversation[0], sent.Posts[0])
+		assert.Equal(t, llm.PostRoleSystem, sent.Posts[1].Role)
+		assert.Contains(t, sent.Posts[1].Message, "they talked about u1 and b1")
+		assert.Equal(t, conversation[3:], sent.Posts[2:])
+
+		require.Len(t, *transcripts, 1)
+		assert.Contains(t, (*transcripts)[0], "u1")
+		assert.Contains(t, (*transcripts)[0], "b1")
+		assert.NotContains(t, (*transcripts)[0], "u2")
+	})
+
+	t.Run("keeps system posts when the conversation is far over the limit", func(t *testing.T) {
+		mockLLM := newModel(t)
+		sent := captureRequest(mockLLM)
+		summarizeWith(mockLLM, "summary")
+
+		posts := []llm.Post{conversation[0]}
+		for range 20 {
+			posts = append(posts, llm.Post{Role: llm.PostRoleUser, Message: words("question", 40)}, llm.Post{Role: llm.PostRoleBot, Message: words("answer", 40)})
+		}
+		posts = append(posts, conversation[5])
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: posts, Context: context})
+		require.NoError(t, err)
+
+		assert.Equal(t, conversation[0], sent.Posts[0])
+		assert.Equal(t, conversation[5], sent.Posts[len(sent.Posts)-1])
+	})
+
+	t.Run("keeps system posts when the latest turn leaves no room for a summary", func(t *testing.T) {
+		mockLLM := newModel(t)
+		sent := captureRequest(mockLLM)
+
+		posts := append(append([]llm.Post{}, conversation[:5]...), llm.Post{Role: llm.PostRoleUser, Message: words("u3", 160)})
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: posts, Context: context})
+		require.NoError(t, err)
+
+		assert.Equal(t, conversation[0], sent.Posts[0])
+		assert.Equal(t, posts[5], sent.Posts[len(sent.Posts)-1])
+		total := 0
+		for _, post := range sent.Posts {
+			total += len(strings.Fields(post.Message))
+		}
+		assert.LessOrEqual(t, total, 180)
+	})
+
+	t.Run("the usage of summaries is sent with the response", func(t *testing.T) {
+		mockLLM := newModel(t)
+		captureRequest(mockLLM)
+		summarizeWith(mockLLM, "summary")
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		result, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+		require.NoError(t, err)
+
+		events := collect(t, result)
+		require.Len(t, events, 2)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 100, OutputTokens: 10}}, events[0])
+		assert.Equal(t, llm.EventTypeEnd, events[1].Type)
+	})
+
+	t.Run("reuses the cached summary", func(t *testing.T) {
+		mockLLM := newModel(t)
+		captureRequest(mockLLM)
+		transcripts := summarizeWith(mockLLM, "summary")
+		store := &memorySummaryStore{summaries: map[string]llm.ConversationSummary{}}
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, store, nil)
+		for range 2 {
+			_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+			require.NoError(t, err)
+		}
+
+		assert.Len(t, *transcripts, 1)
+		assert.Equal(t, 2, store.summaries["root"].PostCount)
+	})
+
+	t.Run("extends the cached summary as the conversation grows", func(t *testing.T) {
+		mockLLM := newModel(t)
+		captureRequest(mockLLM)
+		transcripts := summarizeWith(mockLLM, "summary of the start")
+		store := &memorySummaryStore{summaries: map[string]llm.ConversationSummary{}}
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, store, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+		require.NoError(t, err)
+
+		longer := append(append([]llm.Post{}, conversation...),
+			llm.Post{Role: llm.PostRoleBot, Message: words("b3", 50)},
+			llm.Post{Role: llm.PostRoleUser, Message: words("u4", 20)},
+		)
+		_, err = wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: longer, Context: context})
+		require.NoError(t, err)
+
+		require.Len(t, *transcripts, 2)
+		assert.Contains(t, (*transcripts)[1], "summary of the start")
+		assert.Contains(t, (*transcripts)[1], "u2")
+		assert.NotContains(t, (*transcripts)[1], "u1")
+		assert.Equal(t, 3, store.summaries["root"].PostCount)
+	})
+
+	t.Run("summarizes again when earlier posts changed", func(t *testing.T) {
+		mockLLM := newModel(t)
+		captureRequest(mockLLM)
+		transcripts := summarizeWith(mockLLM, "summary")
+		store := &memorySummaryStore{summaries: map[string]llm.ConversationSummary{}}
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, store, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+		require.NoError(t, err)
+
+		edited := append([]llm.Post{}, conversation...)
+		edited[1] = llm.Post{Role: llm.PostRoleUser, Message: words("edited", 50)}
+		_, err = wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: edited, Context: context})
+		require.NoError(t, err)
+
+		require.Len(t, *transcripts, 2)
+		assert.Contains(t, (*transcripts)[1], "edited")
+		assert.NotContains(t, (*transcripts)[1], "summary")
+	})
+
+	t.Run("drops the oldest posts when summarizing fails", func(t *testing.T) {
+		mockLLM := newModel(t)
+		sent := captureRequest(mockLLM)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("unavailable"))
+
+		wrapper := llm.NewSummarizingTruncationWrapper(mockLLM, nil, nil)
+		_, err := wrapper.ChatCompletion(t.Context(), llm.CompletionRequest{Posts: conversation, Context: context})
+		require.NoError(t, err)
+
+		assert.Equal(t, conversation[5], sent.Posts[len(sent.Posts)-1])
+		assert.Less(t, len(sent.Posts), len(conversation))
+	})
+}
@@ -4,6 +4,7 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,10 @@ import (
 // MetricsObserver defines the interface for observing token usage metrics
 type MetricsObserver interface {
 	ObserveTokenUsage(botName, teamID, userID string, inputTokens, outputTokens int)
+	ObserveTokenCost(botName, teamID, model string, cost float64)
+	ObserveTokenCache(botName, teamID string, cacheReadTokens, cacheCreationTokens int)
+	ObserveRedactions(botName, category string, count int)
+	StreamMetricsObserver
 }
 
 // TokenUsageLoggingWrapper wraps a LanguageModel to log token usage
@@ -23,6 +28,8 @@ type TokenUsageLoggingWrapper struct {
 	botUsername string
 	tokenLogger *mlog.Logger
 	metrics     MetricsObserver
+	service     ServiceInfo
+	costTracker CostTracker
 }
 
 // NewTokenUsageLoggingWrapper creates a new wrapper that logs token usage
@@ -35,8 +42,22 @@ func NewTokenUsageLoggingWrapper(wrapped LanguageModel, botUsername string, toke
 	}
 }
 
+// WithCostTracking prices the usage of each request and records its cost with costTracker.
+// service is the service requests are sent to unless the stream reports another one.
+// The token logger may be nil when cost tracking is enabled.
+func (w *TokenUsageLoggingWrapper) WithCostTracking(service ServiceInfo, costTracker CostTracker) *TokenUsageLoggingWrapper {
+	w.service = service
+	w.costTracker = costTracker
+	return w
+}
+
 // CreateTokenLogger creates a dedicated logger for token usage metrics
 func CreateTokenLogger() (*mlog.Logger, error) {
+	return createTokenLogger("logs/agents/token_usage.log")
+}
+
+// createTokenLogger creates a token usage logger writing to filename
+func createTokenLogger(filename string) (*mlog.Logger, error) {
 	logger, err := mlog.NewLogger()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token logger: %w", err)
@@ -48,7 +69,7 @@ func CreateTokenLogger() (*mlog.Logger, error) {
 		Levels: []mlog.Level{mlog.LvlInfo, mlog.LvlDebug},
 	}
 	jsonFileOptions := map[string]interface{}{
-		"filename": "logs/agents/token_usage.log",
+		"filename": filename,
 		"max_size": 100,  // MB
 		"compress": true, // compress rotated files
 	}
@@ -69,22 +90,40 @@ func CreateTokenLogger() (*mlog.Logger, error) {
 }
 
 // ChatCompletion intercepts the streaming response to extract and log token usage
-func (w *TokenUsageLoggingWrapper) ChatCompletion(request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
-	result, err := w.wrapped.ChatCompletion(request, opts...)
+func (w *TokenUsageLoggingWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if w.tokenLogger == nil {
+	if w.tokenLogger == nil && w.costTracker == nil {
 		return nil, errors.New("token logger is nil")
 	}
 
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	service := w.service
+	if cfg.Model != "" {
+		service.Model = cfg.Model
+	}
+
 	interceptedStream := make(chan TextStreamEvent)
 
 	go func() {
 		defer close(interceptedStream)
 
 		for event := range result.Stream {
+			if event.Type == EventTypeServiceInfo {
+				// With failover the answering service can differ from the configured one
+				if info, ok := event.Value.(ServiceInfo); ok {
+					service = info
+					if cfg.Model != "" {
+						service.Model = cfg.Model
+					}
+				}
+			}
 			if event.Type != EventTypeUsage {
 				interceptedStream <- event
 				continue
@@ -97,6 +136,10 @@ func (w *TokenUsageLoggingWrapper) ChatCompletion(request CompletionRequest, opt
 
 			userID := "unknown"
 			teamID := "unknown"
+			channelType := ""
+			if request.Context != nil && request.Context.Channel != nil {
+				channelType = string(request.Context.Channel.Type)
+			}
 			if request.Context != nil {
 				if request.Context.RequestingUser != nil {
 					userID = request.Context.RequestingUser.Id
@@ -117,14 +160,54 @@ func (w *TokenUsageLoggingWrapper) ChatCompletion(request CompletionRequest, opt
 				}
 			}
 
-			w.tokenLogger.Info("Token Usage",
-				mlog.String("user_id", userID),
-				mlog.String("team_id", teamID),
-				mlog.String("bot_username", w.botUsername),
-				mlog.Int("input_tokens", usage.InputTokens),
-				mlog.Int("output_tokens", usage.OutputTokens),
-				mlog.Int("total_tokens", usage.InputTokens+usage.OutputTokens),
-			)
+			cost := 0.0
+			priced := false
+			if w.costTracker != nil {
+				var price ModelPrice
+				if price, priced = w.costTracker.LookupPrice(service.Type, service.Model); priced {
+					cost = price.Cost(usage)
+				}
+				w.costTracker.RecordCost(CostRecord{
+					BotName:     w.botUsername,
+					TeamID:      teamID,
+					ChannelType: channelType,
+					UserID:      userID,
+					ServiceType: service.Type,
+					Model:       service.Model,
+					Usage:       usage,
+					Cost:        cost,
+				})
+			}
+
+			if w.tokenLogger != nil {
+				fields := []mlog.Field{
+					mlog.String("user_id", userID),
+					mlog.String("team_id", teamID),
+					mlog.String("bot_username", w.botUsername),
+					mlog.Int("input_tokens", usage.InputTokens),
+					mlog.Int("output_tokens", usage.OutputTokens),
+					mlog.Int("total_tokens", usage.InputTokens+usage.OutputTokens),
+				}
+				if usage.CacheReadInputTokens > 0 || usage.CacheCreationInputTokens > 0 {
+					fields = append(fields,
+						mlog.Int("cache_read_input_tokens", usage.CacheReadInputTokens),
+						mlog.Int("cache_creation_input_tokens", usage.CacheCreationInputTokens),
+					)
+				}
+				if cfg.Task != "" {
+					fields = append(fields, mlog.String("task", string(cfg.Task)))
+				}
+				if service.Name != "" {
+					fields = append(fields, mlog.String("service", service.Name))
+				}
+				if service.Model != "" {
+					fields = append(fields, mlog.String("model", service.Model))
+				}
+				if priced {
+					fields = append(fields, mlog.Float("cost_usd", cost))
+				}
+				w.tokenLogger.Info("Token Usage", fields...)
+			}
 
 			// Emit metrics if available (user_id not included in metrics)
 			if w.metrics != nil {
@@ -135,6 +218,15 @@ func (w *TokenUsageLoggingWrapper) ChatCompletion(request CompletionRequest, opt
 					int(usage.InputTokens),
 					int(usage.OutputTokens),
 				)
+				w.metrics.ObserveTokenCache(
+					w.botUsername,
+					teamID,
+					int(usage.CacheReadInputTokens),
+					int(usage.CacheCreationInputTokens),
+				)
+				if priced {
+					w.metrics.ObserveTokenCost(w.botUsername, teamID, service.Model, cost)
+				}
 			}
 		}
 	}()
@@ -144,8 +236,8 @@ func (w *TokenUsageLoggingWrapper) ChatCompletion(request CompletionRequest, opt
 
 // ChatCompletionNoStream uses the streaming method internally, so token usage
 // logging happens automatically when ReadAll() processes the intercepted stream
-func (w *TokenUsageLoggingWrapper) ChatCompletionNoStream(request CompletionRequest, opts ...LanguageModelOption) (string, error) {
-	result, err := w.ChatCompletion(request, opts...)
+func (w *TokenUsageLoggingWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -4,6 +4,8 @@
 package llm
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -17,13 +19,13 @@ type MockLanguageModel struct {
 	mock.Mock
 }
 
-func (m *MockLanguageModel) ChatCompletion(request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
-	args := m.Called(request, opts)
+func (m *MockLanguageModel) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	args := m.Called(ctx, request, opts)
 	return args.Get(0).(*TextStreamResult), args.Error(1)
 }
 
-func (m *MockLanguageModel) ChatCompletionNoStream(request CompletionRequest, opts ...LanguageModelOption) (string, error) {
-	args := m.Called(request, opts)
+func (m *MockLanguageModel) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	args := m.Called(ctx, request, opts)
 	return args.String(0), args.Error(1)
 }
 
@@ -40,7 +42,7 @@ func (m *MockLanguageModel) InputTokenLimit() int {
 func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 	t.Run("filters usage events from stream", func(t *testing.T) {
 		mockLLM := &MockLanguageModel{}
-		logger, _ := CreateTokenLogger()
+		logger, _ := createTokenLogger(filepath.Join(t.TempDir(), "token_usage.log"))
 		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", logger, nil)
 
 		// Create a mock stream with usage event
@@ -51,7 +53,7 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 		close(mockStream)
 
 		mockResult := &TextStreamResult{Stream: mockStream}
-		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything).Return(mockResult, nil)
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(mockResult, nil)
 
 		request := CompletionRequest{
 			Context: &Context{
@@ -60,7 +62,7 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 			},
 		}
 
-		result, err := wrapper.ChatCompletion(request)
+		result, err := wrapper.ChatCompletion(t.Context(), request)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 
@@ -81,7 +83,7 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 
 	t.Run("handles nil context gracefully", func(t *testing.T) {
 		mockLLM := &MockLanguageModel{}
-		logger, _ := CreateTokenLogger()
+		logger, _ := createTokenLogger(filepath.Join(t.TempDir(), "token_usage.log"))
 		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", logger, nil)
 
 		mockStream := make(chan TextStreamEvent, 2)
@@ -90,10 +92,10 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 		close(mockStream)
 
 		mockResult := &TextStreamResult{Stream: mockStream}
-		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything).Return(mockResult, nil)
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(mockResult, nil)
 
 		request := CompletionRequest{Context: &Context{}}
-		result, err := wrapper.ChatCompletion(request)
+		result, err := wrapper.ChatCompletion(t.Context(), request)
 		require.NoError(t, err)
 
 		// Should complete without panic even with nil context
@@ -110,7 +112,7 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 
 	t.Run("handles invalid usage event value", func(t *testing.T) {
 		mockLLM := &MockLanguageModel{}
-		logger, _ := CreateTokenLogger()
+		logger, _ := createTokenLogger(filepath.Join(t.TempDir(), "token_usage.log"))
 		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", logger, nil)
 
 		mockStream := make(chan TextStreamEvent, 2)
@@ -119,10 +121,10 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 		close(mockStream)
 
 		mockResult := &TextStreamResult{Stream: mockStream}
-		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything).Return(mockResult, nil)
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(mockResult, nil)
 
 		request := CompletionRequest{Context: &Context{}}
-		result, err := wrapper.ChatCompletion(request)
+		result, err := wrapper.ChatCompletion(t.Context(), request)
 		require.NoError(t, err)
 
 		// Should complete without calling metrics (invalid value ignored)
@@ -136,10 +138,134 @@ func TestTokenTrackingWrapper_ChatCompletion(t *testing.T) {
 	})
 }
 
+type fakeCostTracker struct {
+	prices  map[string]ModelPrice
+	records []CostRecord
+}
+
+func (f *fakeCostTracker) LookupPrice(serviceType, model string) (ModelPrice, bool) {
+	price, ok := f.prices[model]
+	return price, ok
+}
+
+func (f *fakeCostTracker) RecordCost(record CostRecord) {
+	f.records = append(f.records, record)
+}
+
+func TestTokenTrackingWrapper_CostTracking(t *testing.T) {
+	newTracker := func() *fakeCostTracker {
+		return &fakeCostTracker{prices: map[string]ModelPrice{
+			"primary-model":  {InputPerMillion: 1, OutputPerMillion: 2},
+			"fallback-model": {InputPerMillion: 10, OutputPerMillion: 20},
+		}}
+	}
+	service := ServiceInfo{ID: "primary", Type: ServiceTypeOpenAI, Model: "primary-model"}
+	request := CompletionRequest{
+		Context: &Context{
+			RequestingUser: &model.User{Id: "user123"},
+			Team:           &model.Team{Id: "team456"},
+			Channel:        &model.Channel{Id: "channel789", Type: model.ChannelTypeOpen, TeamId: "team456"},
+		},
+	}
+	streamWith := func(events ...TextStreamEvent) *TextStreamResult {
+		stream := make(chan TextStreamEvent, len(events))
+		for _, event := range events {
+			stream <- event
+		}
+		close(stream)
+		return &TextStreamResult{Stream: stream}
+	}
+
+	t.Run("records the cost without a token logger", func(t *testing.T) {
+		mockLLM := &MockLanguageModel{}
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(streamWith(
+			TextStreamEvent{Type: EventTypeUsage, Value: TokenUsage{InputTokens: 1_000_000, OutputTokens: 500_000}},
+			TextStreamEvent{Type: EventTypeEnd},
+		), nil)
+		tracker := newTracker()
+		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", nil, nil).WithCostTracking(service, tracker)
+
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+
+		require.Len(t, tracker.records, 1)
+		assert.Equal(t, CostRecord{
+			BotName:     "test-bot",
+			TeamID:      "team456",
+			ChannelType: string(model.ChannelTypeOpen),
+			UserID:      "user123",
+			ServiceType: ServiceTypeOpenAI,
+			Model:       "primary-model",
+			Usage:       TokenUsage{InputTokens: 1_000_000, OutputTokens: 500_000},
+			Cost:        2,
+		}, tracker.records[0])
+	})
+
+	t.Run("prices the service that answered after failover", func(t *testing.T) {
+		mockLLM := &MockLanguageModel{}
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(streamWith(
+			TextStreamEvent{Type: EventTypeServiceInfo, Value: ServiceInfo{ID: "fallback", Type: ServiceTypeAnthropic, Model: "fallback-model"}},
+			TextStreamEvent{Type: EventTypeUsage, Value: TokenUsage{InputTokens: 1_000_000}},
+			TextStreamEvent{Type: EventTypeEnd},
+		), nil)
+		tracker := newTracker()
+		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", nil, nil).WithCostTracking(service, tracker)
+
+		result, err := wrapper.ChatCompletion(t.Context(), request)
+		require.NoError(t, err)
+		var events []TextStreamEvent
+		for event := range result.Stream {
+			events = append(events, event)
+		}
+
+		require.Len(t, events, 2)
+		assert.Equal(t, EventTypeServiceInfo, events[0].Type)
+		require.Len(t, tracker.records, 1)
+		assert.Equal(t, ServiceTypeAnthropic, tracker.records[0].ServiceType)
+		assert.Equal(t, "fallback-model", tracker.records[0].Model)
+		assert.Equal(t, 10.0, tracker.records[0].Cost)
+	})
+
+	t.Run("uses the model requested in the options", func(t *testing.T) {
+		mockLLM := &MockLanguageModel{}
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(streamWith(
+			TextStreamEvent{Type: EventTypeUsage, Value: TokenUsage{OutputTokens: 1_000_000}},
+			TextStreamEvent{Type: EventTypeEnd},
+		), nil)
+		tracker := newTracker()
+		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", nil, nil).WithCostTracking(service, tracker)
+
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), request, WithModel("fallback-model"))
+		require.NoError(t, err)
+
+		require.Len(t, tracker.records, 1)
+		assert.Equal(t, "fallback-model", tracker.records[0].Model)
+		assert.Equal(t, 20.0, tracker.records[0].Cost)
+	})
+
+	t.Run("records usage of models without a price at no cost", func(t *testing.T) {
+		mockLLM := &MockLanguageModel{}
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(streamWith(
+			TextStreamEvent{Type: EventTypeUsage, Value: TokenUsage{InputTokens: 100, OutputTokens: 50}},
+			TextStreamEvent{Type: EventTypeEnd},
+		), nil)
+		tracker := newTracker()
+		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", nil, nil).
+			WithCostTracking(ServiceInfo{Type: ServiceTypeOpenAICompatible, Model: "local-model"}, tracker)
+
+		_, err := wrapper.ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+
+		require.Len(t, tracker.records, 1)
+		assert.Equal(t, TokenUsage{InputTokens: 100, OutputTokens: 50}, tracker.records[0].Usage)
+		assert.Zero(t, tracker.records[0].Cost)
+	})
+}
+
 func TestTokenTrackingWrapper_ChatCompletionNoStream(t *testing.T) {
 	t.Run("delegates to streaming method", func(t *testing.T) {
 		mockLLM := &MockLanguageModel{}
-		logger, _ := CreateTokenLogger()
+		logger, _ := createTokenLogger(filepath.Join(t.TempDir(), "token_usage.log"))
 		wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-bot", logger, nil)
 
 		mockStream := make(chan TextStreamEvent, 3)
@@ -149,10 +275,10 @@ func TestTokenTrackingWrapper_ChatCompletionNoStream(t *testing.T) {
 		close(mockStream)
 
 		mockResult := &TextStreamResult{Stream: mockStream}
-		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything).Return(mockResult, nil)
+		mockLLM.On("ChatCompletion", mock.Anything, mock.Anything, mock.Anything).Return(mockResult, nil)
 
 		request := CompletionRequest{Context: &Context{}}
-		result, err := wrapper.ChatCompletionNoStream(request)
+		result, err := wrapper.ChatCompletionNoStream(t.Context(), request)
 		require.NoError(t, err)
 		assert.Equal(t, "Hello world", result)
 
@@ -162,7 +288,7 @@ func TestTokenTrackingWrapper_ChatCompletionNoStream(t *testing.T) {
 
 func TestTokenTrackingWrapper_DelegatedMethods(t *testing.T) {
 	mockLLM := &MockLanguageModel{}
-	logger, _ := CreateTokenLogger()
+	logger, _ := createTokenLogger(filepath.Join(t.TempDir(), "token_usage.log"))
 	wrapper := NewTokenUsageLoggingWrapper(mockLLM, "test-llm", logger, nil)
 
 	t.Run("CountTokens delegates to wrapped model", func(t *testing.T) {
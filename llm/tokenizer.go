@@ -0,0 +1,172 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model would use to represent text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// ApproximateTokenizer estimates token counts from character and word counts.
+// It is used when no real tokenizer is available for a model.
+type ApproximateTokenizer struct{}
+
+func (ApproximateTokenizer) CountTokens(text string) int {
+	charCount := float64(len(text)) / 4.0
+	wordCount := float64(len(strings.Fields(text))) / 0.75
+
+	// Average the two
+	return int((charCount + wordCount) / 2.0)
+}
+
+// runeOffsets returns the byte offset of every rune in text followed by len(text).
+func runeOffsets(text string) []int {
+	offsets := make([]int, 0, utf8.RuneCountInString(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(text))
+}
+
+// tokenChunkSize is the size in bytes of the chunks text is counted in when it is cut to a number
+// of tokens. Each chunk is counted once, so long texts aren't tokenized again for every cut tried.
+const tokenChunkSize = 2048
+
+// tokenChunkEnds splits text into chunks of about tokenChunkSize bytes and returns their end offsets.
+// Chunks end after whitespace when possible so words aren't split, and always on rune boundaries.
+func tokenChunkEnds(text string) []int {
+	var ends []int
+	for start := 0; start < len(text); {
+		end := start + tokenChunkSize
+		if end >= len(text) {
+			return append(ends, len(text))
+		}
+		if i := strings.LastIndexAny(text[start:end], " \t\n"); i > 0 {
+			end = start + i + 1
+		} else {
+			for end > start+1 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+		ends = append(ends, end)
+		start = end
+	}
+	return ends
+}
+
+// fitTokens calls keep with smaller budgets until its result fits in maxTokens. Counting text in
+// chunks can count a few more or less tokens than counting it whole.
+func fitTokens(maxTokens int, countTokens func(string) int, keep func(budget int) string) string {
+	budget := maxTokens
+	for {
+		result := keep(budget)
+		over := countTokens(result) - maxTokens
+		if over <= 0 || budget <= 0 {
+			return result
+		}
+		budget -= over
+	}
+}
+
+// KeepLastTokens returns the longest suffix of text that fits in maxTokens, give or take the
+// tokens merged across chunk boundaries. The text is only ever cut on rune boundaries.
+func KeepLastTokens(text string, maxTokens int, countTokens func(string) int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if countTokens(text) <= maxTokens {
+		return text
+	}
+
+	ends := tokenChunkEnds(text)
+	return fitTokens(maxTokens, countTokens, func(budget int) string {
+		// Only the chunk crossing the budget is searched for the cut
+		used := 0
+		for i := len(ends) - 1; i >= 0; i-- {
+			start := 0
+			if i > 0 {
+				start = ends[i-1]
+			}
+			tokens := countTokens(text[start:ends[i]])
+			if used+tokens > budget {
+				return searchLastTokens(text[start:ends[i]], budget-used, countTokens) + text[ends[i]:]
+			}
+			used += tokens
+		}
+		return text
+	})
+}
+
+// KeepFirstTokens returns the longest prefix of text that fits in maxTokens, give or take the
+// tokens merged across chunk boundaries. The text is only ever cut on rune boundaries.
+func KeepFirstTokens(text string, maxTokens int, countTokens func(string) int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if countTokens(text) <= maxTokens {
+		return text
+	}
+
+	ends := tokenChunkEnds(text)
+	return fitTokens(maxTokens, countTokens, func(budget int) string {
+		// Only the chunk crossing the budget is searched for the cut
+		used, start := 0, 0
+		for _, end := range ends {
+			tokens := countTokens(text[start:end])
+			if used+tokens > budget {
+				return text[:start] + searchFirstTokens(text[start:end], budget-used, countTokens)
+			}
+			used += tokens
+			start = end
+		}
+		return text
+	})
+}
+
+// searchLastTokens returns the longest suffix of text that fits in maxTokens.
+func searchLastTokens(text string, maxTokens int, countTokens func(string) int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+
+	offsets := runeOffsets(text)
+	// Binary search for the smallest start offset whose suffix fits
+	lo, hi := 0, len(offsets)-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if countTokens(text[offsets[mid]:]) <= maxTokens {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	return text[offsets[lo]:]
+}
+
+// searchFirstTokens returns the longest prefix of text that fits in maxTokens.
+func searchFirstTokens(text string, maxTokens int, countTokens func(string) int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+
+	offsets := runeOffsets(text)
+	// Binary search for the largest end offset whose prefix fits
+	lo, hi := 0, len(offsets)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if countTokens(text[:offsets[mid]]) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return text[:offsets[lo]]
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepTokens(t *testing.T) {
+	// A megabyte of text, one token per word
+	text := strings.Repeat("héllo wörld ", 1<<20/14)
+	var counted int
+	countTokens := func(s string) int {
+		counted += len(s)
+		return len(strings.Fields(s))
+	}
+
+	t.Run("first tokens", func(t *testing.T) {
+		counted = 0
+		result := KeepFirstTokens(text, 1000, countTokens)
+		assert.True(t, strings.HasPrefix(text, result))
+		assert.Equal(t, 1000, len(strings.Fields(result)))
+		assert.Less(t, counted, 4*len(text), "the text is tokenized a few times, not once per cut tried")
+	})
+
+	t.Run("last tokens", func(t *testing.T) {
+		counted = 0
+		result := KeepLastTokens(text, 1000, countTokens)
+		assert.True(t, strings.HasSuffix(text, result))
+		assert.Equal(t, 1000, len(strings.Fields(result)))
+		assert.Less(t, counted, 4*len(text), "the text is tokenized a few times, not once per cut tried")
+	})
+
+	t.Run("text without whitespace is cut on rune boundaries", func(t *testing.T) {
+		runes := strings.Repeat("é", 5000)
+		countRunes := func(s string) int {
+			return len([]rune(s))
+		}
+		assert.Equal(t, strings.Repeat("é", 3000), KeepFirstTokens(runes, 3000, countRunes))
+		assert.Equal(t, strings.Repeat("é", 3000), KeepLastTokens(runes, 3000, countRunes))
+	})
+
+	t.Run("text that fits is kept whole", func(t *testing.T) {
+		assert.Equal(t, "hello world", KeepFirstTokens("hello world", 2, countTokens))
+		assert.Empty(t, KeepLastTokens("hello world", 0, countTokens))
+	})
+}
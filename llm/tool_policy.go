@@ -0,0 +1,146 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ToolPolicy decides whether a tool call requested by the model needs the user's approval.
+type ToolPolicy string
+
+const (
+	// ToolPolicyAsk waits for the user to accept or reject the tool call. This is the default.
+	ToolPolicyAsk ToolPolicy = "ask"
+	// ToolPolicyAutoApprove resolves the tool call without asking, for tools that only read data
+	ToolPolicyAutoApprove ToolPolicy = "auto_approve"
+	// ToolPolicyDeny rejects the tool call without asking
+	ToolPolicyDeny ToolPolicy = "deny"
+)
+
+const (
+	// DefaultToolTimeout is how long a tool call can take when its rule doesn't set a timeout
+	DefaultToolTimeout = 60 * time.Second
+	// DefaultMaxParallelToolCalls is how many tool calls of a response are resolved at once
+	DefaultMaxParallelToolCalls = 4
+)
+
+// ToolPolicyRule sets the policy of a tool, or of every tool of an MCP server when Tool is empty.
+// Rules naming a tool take precedence over rules naming its MCP server.
+type ToolPolicyRule struct {
+	Tool      string     `json:"tool"`
+	MCPServer string     `json:"mcpServer"`
+	Policy    ToolPolicy `json:"policy"`
+	// TimeoutSeconds limits how long the tool can run, DefaultToolTimeout when 0
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// MaxResultTokens overrides the bot's ToolResultTokenLimit for the tool when not 0
+	MaxResultTokens int `json:"maxResultTokens"`
+}
+
+// ToolPolicyFor returns the rule that applies to tool. Tools without a rule get the bot's default policy.
+func (c *BotConfig) ToolPolicyFor(tool Tool) ToolPolicyRule {
+	var serverRule *ToolPolicyRule
+	for i, rule := range c.ToolPolicies {
+		if rule.Tool != "" && rule.Tool == tool.Name && (rule.MCPServer == "" || rule.MCPServer == tool.MCPServer) {
+			return c.ToolPolicies[i]
+		}
+		if rule.Tool == "" && rule.MCPServer != "" && rule.MCPServer == tool.MCPServer && serverRule == nil {
+			serverRule = &c.ToolPolicies[i]
+		}
+	}
+	if serverRule != nil {
+		return *serverRule
+	}
+	return ToolPolicyRule{Tool: tool.Name, Policy: c.DefaultToolPolicy}
+}
+
+// Timeout returns how long a tool call governed by the rule can take.
+func (r ToolPolicyRule) Timeout() time.Duration {
+	if r.TimeoutSeconds > 0 {
+		return time.Duration(r.TimeoutSeconds) * time.Second
+	}
+	return DefaultToolTimeout
+}
+
+// EffectivePolicy returns the policy, treating unset and unknown policies as ToolPolicyAsk.
+func (r ToolPolicyRule) EffectivePolicy() ToolPolicy {
+	switch r.Policy {
+	case ToolPolicyAutoApprove, ToolPolicyDeny:
+		return r.Policy
+	default:
+		return ToolPolicyAsk
+	}
+}
+
+// ResolveToolCalls resolves the accepted tool calls with at most maxParallel running at once,
+// each limited to the timeout returned by timeoutFor. The calls are updated with their results
+// and a success or error status; calls with any other status are left untouched. Calls that time
+// out are marked as such without waiting for the tool to return, so a tool that ignores its
+// context can't hold up the response.
+func (s *ToolStore) ResolveToolCalls(ctx context.Context, llmContext *Context, toolCalls []ToolCall, maxParallel int, timeoutFor func(name string) time.Duration) {
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallelToolCalls
+	}
+
+	workers := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i := range toolCalls {
+		if toolCalls[i].Status != ToolCallStatusAccepted {
+			continue
+		}
+
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(toolCall *ToolCall) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			timeout := timeoutFor(toolCall.Name)
+			toolCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type toolResult struct {
+				result string
+				tokens int
+				err    error
+			}
+			// Buffered so the resolver can finish after the call has timed out
+			results := make(chan toolResult, 1)
+			arguments := toolCall.Arguments
+			go func() {
+				result, resultTokens, err := s.resolveTool(toolCtx, toolCall.Name, func(args any) error {
+					return json.Unmarshal(arguments, args)
+				}, llmContext)
+				results <- toolResult{result: result, tokens: resultTokens, err: err}
+			}()
+
+			var res toolResult
+			select {
+			case res = <-results:
+			case <-toolCtx.Done():
+				res.err = toolCtx.Err()
+			}
+			if res.err != nil {
+				// Maybe in the future we can return this to the user and have a retry. For now just tell the LLM it failed.
+				toolCall.Result = "Tool call failed"
+				if errors.Is(toolCtx.Err(), context.DeadlineExceeded) {
+					toolCall.Result = fmt.Sprintf("Tool call timed out after %s", timeout)
+				}
+				toolCall.Status = ToolCallStatusError
+				return
+			}
+			toolCall.Result = res.result
+			toolCall.ResultTokens = res.tokens
+			toolCall.Status = ToolCallStatusSuccess
+		}(&toolCalls[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolPolicyFor(t *testing.T) {
+	botConfig := llm.BotConfig{
+		DefaultToolPolicy: llm.ToolPolicyDeny,
+		ToolPolicies: []llm.ToolPolicyRule{
+			{MCPServer: "jira", Policy: llm.ToolPolicyAutoApprove},
+			{Tool: "create_issue", MCPServer: "jira", Policy: llm.ToolPolicyAsk, TimeoutSeconds: 5},
+			{Tool: "SearchServer", Policy: llm.ToolPolicyAutoApprove},
+		},
+	}
+
+	t.Run("tool rules take precedence over server rules", func(t *testing.T) {
+		rule := botConfig.ToolPolicyFor(llm.Tool{Name: "create_issue", MCPServer: "jira"})
+		assert.Equal(t, llm.ToolPolicyAsk, rule.EffectivePolicy())
+		assert.Equal(t, 5*time.Second, rule.Timeout())
+	})
+
+	t.Run("server rules apply to every tool of the server", func(t *testing.T) {
+		rule := botConfig.ToolPolicyFor(llm.Tool{Name: "get_issue", MCPServer: "jira"})
+		assert.Equal(t, llm.ToolPolicyAutoApprove, rule.EffectivePolicy())
+		assert.Equal(t, llm.DefaultToolTimeout, rule.Timeout())
+	})
+
+	t.Run("tools without a rule get the default policy", func(t *testing.T) {
+		assert.Equal(t, llm.ToolPolicyAutoApprove, botConfig.ToolPolicyFor(llm.Tool{Name: "SearchServer"}).EffectivePolicy())
+		assert.Equal(t, llm.ToolPolicyDeny, botConfig.ToolPolicyFor(llm.Tool{Name: "create_issue", MCPServer: "github"}).EffectivePolicy())
+		assert.Equal(t, llm.ToolPolicyAsk, (&llm.BotConfig{}).ToolPolicyFor(llm.Tool{Name: "SearchServer"}).EffectivePolicy())
+	})
+}
+
+func TestResolveToolCalls(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	store := llm.NewNoTools()
+	store.AddTools([]llm.Tool{
+		{
+			Name: "slow",
+			Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					observed := maxRunning.Load()
+					if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				return "done", nil
+			},
+		},
+		{
+			Name: "stuck",
+			Resolver: func(ctx context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+		},
+	})
+
+	toolCalls := []llm.ToolCall{
+		{ID: "1", Name: "slow", Status: llm.ToolCallStatusAccepted},
+		{ID: "2", Name: "slow", Status: llm.ToolCallStatusAccepted},
+		{ID: "3", Name: "slow", Status: llm.ToolCallStatusAccepted},
+		{ID: "4", Name: "stuck", Status: llm.ToolCallStatusAccepted},
+		{ID: "5", Name: "slow", Status: llm.ToolCallStatusRejected, Result: "Tool call rejected by user"},
+	}
+	store.ResolveToolCalls(t.Context(), llm.NewContext(), toolCalls, 2, func(name string) time.Duration {
+		return 50 * time.Millisecond
+	})
+
+	for _, toolCall := range toolCalls[:3] {
+		assert.Equal(t, llm.ToolCallStatusSuccess, toolCall.Status)
+		assert.Equal(t, "done", toolCall.Result)
+	}
+	assert.Equal(t, llm.ToolCallStatusError, toolCalls[3].Status)
+	assert.Equal(t, "Tool call timed out after 50ms", toolCalls[3].Result)
+	assert.Equal(t, llm.ToolCallStatusRejected, toolCalls[4].Status)
+	assert.Equal(t, "Tool call rejected by user", toolCalls[4].Result)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestResolveToolCallsDoesNotWaitForHungTools(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	store := llm.NewNoTools()
+	store.AddTools([]llm.Tool{{
+		Name: "hung",
+		Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+			// Ignores its context
+			<-release
+			return "done", nil
+		},
+	}})
+
+	toolCalls := []llm.ToolCall{{ID: "1", Name: "hung", Status: llm.ToolCallStatusAccepted}}
+	resolved := make(chan struct{})
+	go func() {
+		store.ResolveToolCalls(t.Context(), llm.NewContext(), toolCalls, 1, func(name string) time.Duration {
+			return 20 * time.Millisecond
+		})
+		close(resolved)
+	}()
+
+	select {
+	case <-resolved:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ResolveToolCalls waited for a hung tool")
+	}
+	assert.Equal(t, llm.ToolCallStatusError, toolCalls[0].Status)
+	assert.Equal(t, "Tool call timed out after 20ms", toolCalls[0].Result)
+}
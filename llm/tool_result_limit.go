@@ -0,0 +1,103 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ToolResultStrategy selects how tool results over their token limit are shortened.
+type ToolResultStrategy string
+
+const (
+	// ToolResultStrategyTruncate keeps the start of the result with a marker saying it was truncated. This is the default.
+	ToolResultStrategyTruncate ToolResultStrategy = "truncate"
+	// ToolResultStrategySummarize replaces the result with a summary focused on the user's question.
+	// Results that can't be summarized are truncated.
+	ToolResultStrategySummarize ToolResultStrategy = "summarize"
+)
+
+// DefaultToolResultTokenLimit is the token limit of tool results when the bot doesn't set one
+const DefaultToolResultTokenLimit = 8000
+
+const summarizeToolResultPrompt = `You condense the result of a tool called by an AI assistant. The result is too long to give to the assistant as is, so your summary replaces it. Keep every detail that helps answer the user's question, including names, numbers, dates, identifiers and links, and leave out the rest. Only include the summary no other text.`
+
+// ToolResultLimiter shortens tool results that are over their token limit before they are given to the model.
+type ToolResultLimiter struct {
+	model     LanguageModel
+	botConfig BotConfig
+}
+
+// NewToolResultLimiter creates a ToolResultLimiter applying the limits of botConfig. model counts
+// the tokens of results and summarizes them.
+func NewToolResultLimiter(model LanguageModel, botConfig BotConfig) *ToolResultLimiter {
+	return &ToolResultLimiter{
+		model:     model,
+		botConfig: botConfig,
+	}
+}
+
+// tokenLimit returns the token limit of the results of tool, 0 if they are unlimited.
+func (l *ToolResultLimiter) tokenLimit(tool Tool) int {
+	limit := l.botConfig.ToolResultTokenLimit
+	if ruleLimit := l.botConfig.ToolPolicyFor(tool).MaxResultTokens; ruleLimit != 0 {
+		limit = ruleLimit
+	}
+	switch {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return DefaultToolResultTokenLimit
+	default:
+		return limit
+	}
+}
+
+// Limit returns result shortened to the token limit of tool, and the number of tokens of the
+// original result if it was shortened or 0 if it fit.
+func (l *ToolResultLimiter) Limit(ctx context.Context, tool Tool, result string, llmContext *Context) (string, int) {
+	limit := l.tokenLimit(tool)
+	if limit == 0 {
+		return result, 0
+	}
+	tokens := l.model.CountTokens(result)
+	if tokens <= limit {
+		return result, 0
+	}
+
+	if l.botConfig.ToolResultStrategy == ToolResultStrategySummarize {
+		summary, err := l.summarize(ctx, result, llmContext, limit)
+		if err == nil {
+			return fmt.Sprintf("[Summary of a %d token result]\n%s", tokens, summary), tokens
+		}
+	}
+
+	marker := fmt.Sprintf("\n\n[Result truncated from %d tokens to %d tokens]", tokens, limit)
+	return KeepFirstTokens(result, limit-l.model.CountTokens(marker), l.model.CountTokens) + marker, tokens
+}
+
+func (l *ToolResultLimiter) summarize(ctx context.Context, result string, llmContext *Context, limit int) (string, error) {
+	var request strings.Builder
+	if llmContext != nil && llmContext.Question != "" {
+		request.WriteString("The user's question:\n" + llmContext.Question + "\n\n")
+	}
+	request.WriteString("The tool result:\n")
+	// The result can be too long for the model as well
+	request.WriteString(KeepFirstTokens(result, truncationTokenLimit(l.model)-l.model.CountTokens(summarizeToolResultPrompt)-l.model.CountTokens(request.String()), l.model.CountTokens))
+
+	summary, err := l.model.ChatCompletionNoStream(ctx, CompletionRequest{
+		Posts: []Post{
+			{Role: PostRoleSystem, Message: summarizeToolResultPrompt},
+			{Role: PostRoleUser, Message: request.String()},
+		},
+		Context: llmContext,
+	}, WithMaxGeneratedTokens(limit), WithToolsDisabled(), WithReasoningDisabled(), WithTask(TaskChunkSummary))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize tool result: %w", err)
+	}
+
+	return strings.TrimSpace(summary), nil
+}
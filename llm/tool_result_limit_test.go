@@ -0,0 +1,105 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// wordCount counts one token per word so the tests can reason about token limits
+func wordCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestToolResultLimiter(t *testing.T) {
+	newModel := func(t *testing.T) *mocks.MockLanguageModel {
+		model := mocks.NewMockLanguageModel(t)
+		model.EXPECT().CountTokens(mock.Anything).RunAndReturn(wordCount).Maybe()
+		model.EXPECT().InputTokenLimit().Return(100000).Maybe()
+		return model
+	}
+	result := strings.Repeat("word ", 100)
+
+	t.Run("results within the limit are unchanged", func(t *testing.T) {
+		limiter := llm.NewToolResultLimiter(newModel(t), llm.BotConfig{ToolResultTokenLimit: 100})
+		limited, tokens := limiter.Limit(t.Context(), llm.Tool{Name: "search"}, result, nil)
+		assert.Equal(t, result, limited)
+		assert.Zero(t, tokens)
+	})
+
+	t.Run("results over the limit are truncated with a marker", func(t *testing.T) {
+		limiter := llm.NewToolResultLimiter(newModel(t), llm.BotConfig{ToolResultTokenLimit: 50})
+		limited, tokens := limiter.Limit(t.Context(), llm.Tool{Name: "search"}, result, nil)
+		assert.Equal(t, 100, tokens)
+		assert.True(t, strings.HasSuffix(limited, "[Result truncated from 100 tokens to 50 tokens]"))
+		assert.LessOrEqual(t, wordCount(limited), 50)
+	})
+
+	t.Run("tool rules override the bot's limit", func(t *testing.T) {
+		limiter := llm.NewToolResultLimiter(newModel(t), llm.BotConfig{
+			ToolResultTokenLimit: 50,
+			ToolPolicies:         []llm.ToolPolicyRule{{Tool: "read_channel", MaxResultTokens: -1}},
+		})
+		limited, tokens := limiter.Limit(t.Context(), llm.Tool{Name: "read_channel"}, result, nil)
+		assert.Equal(t, result, limited)
+		assert.Zero(t, tokens)
+	})
+
+	t.Run("results are summarized with the user's question", func(t *testing.T) {
+		model := newModel(t)
+		model.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, request llm.CompletionRequest, _ ...llm.LanguageModelOption) (string, error) {
+			require.Len(t, request.Posts, 2)
+			assert.Contains(t, request.Posts[1].Message, "When is the release?")
+			assert.Contains(t, request.Posts[1].Message, result)
+			return " The release is on Friday. ", nil
+		})
+
+		llmContext := llm.NewContext()
+		llmContext.Question = "When is the release?"
+		limiter := llm.NewToolResultLimiter(model, llm.BotConfig{ToolResultTokenLimit: 50, ToolResultStrategy: llm.ToolResultStrategySummarize})
+		limited, tokens := limiter.Limit(t.Context(), llm.Tool{Name: "search"}, result, llmContext)
+		assert.Equal(t, "[Summary of a 100 token result]\nThe release is on Friday.", limited)
+		assert.Equal(t, 100, tokens)
+	})
+
+	t.Run("results that can't be summarized are truncated", func(t *testing.T) {
+		model := newModel(t)
+		model.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", assert.AnError)
+
+		limiter := llm.NewToolResultLimiter(model, llm.BotConfig{ToolResultTokenLimit: 50, ToolResultStrategy: llm.ToolResultStrategySummarize})
+		limited, tokens := limiter.Limit(t.Context(), llm.Tool{Name: "search"}, result, nil)
+		assert.Contains(t, limited, "[Result truncated from 100 tokens to 50 tokens]")
+		assert.Equal(t, 100, tokens)
+	})
+
+	t.Run("tool calls record the original size of their results", func(t *testing.T) {
+		store := llm.NewNoTools()
+		store.AddTools([]llm.Tool{{
+			Name: "search",
+			Resolver: func(_ context.Context, _ *llm.Context, _ llm.ToolArgumentGetter) (string, error) {
+				return result, nil
+			},
+		}})
+		store.SetResultLimiter(llm.NewToolResultLimiter(newModel(t), llm.BotConfig{ToolResultTokenLimit: 50}))
+
+		toolCalls := []llm.ToolCall{{ID: "1", Name: "search", Arguments: []byte(`{}`), Status: llm.ToolCallStatusAccepted}}
+		store.ResolveToolCalls(t.Context(), llm.NewContext(), toolCalls, 1, func(string) time.Duration { return time.Second })
+		assert.Equal(t, llm.ToolCallStatusSuccess, toolCalls[0].Status)
+		assert.Equal(t, 100, toolCalls[0].ResultTokens)
+		assert.Contains(t, toolCalls[0].Result, "[Result truncated")
+
+		limited, err := store.ResolveTool(t.Context(), "search", func(any) error { return nil }, llm.NewContext())
+		require.NoError(t, err)
+		assert.Equal(t, toolCalls[0].Result, limited)
+	})
+}
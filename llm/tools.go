@@ -4,6 +4,7 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,8 @@ import (
 	"unicode"
 
 	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Tool represents a function that can be called by the language model during a conversation.
@@ -19,16 +22,18 @@ import (
 // It is the Resolver function that implements the actual functionality.
 //
 // The Schema field should contain a JSONSchema that defines the expected structure of the tool's arguments.
-// The Resolver function receives the conversation context and a way to access the parsed arguments,
+// The Resolver function receives the request context, the conversation context and a way to access the parsed arguments,
 // and returns either a result that will be passed to the LLM or an error.
 type Tool struct {
 	Name        string
 	Description string
 	Schema      any
 	Resolver    ToolResolver
+	// MCPServer is the name of the MCP server providing the tool, empty for built-in tools
+	MCPServer string
 }
 
-type ToolResolver func(context *Context, argsGetter ToolArgumentGetter) (string, error)
+type ToolResolver func(ctx context.Context, llmContext *Context, argsGetter ToolArgumentGetter) (string, error)
 
 // ToolCallStatus represents the current status of a tool call
 type ToolCallStatus int
@@ -54,6 +59,8 @@ type ToolCall struct {
 	Arguments   json.RawMessage `json:"arguments"`
 	Result      string          `json:"result"`
 	Status      ToolCallStatus  `json:"status"`
+	// ResultTokens is the size of the result before it was shortened to the tool's token limit, 0 if it wasn't
+	ResultTokens int `json:"result_tokens,omitempty"`
 }
 
 // SanitizeNonPrintableChars replaces non-printable Unicode characters with their
@@ -127,10 +134,11 @@ type ToolAuthError struct {
 }
 
 type ToolStore struct {
-	tools      map[string]Tool
-	log        TraceLog
-	doTrace    bool
-	authErrors []ToolAuthError
+	tools         map[string]Tool
+	log           TraceLog
+	doTrace       bool
+	authErrors    []ToolAuthError
+	resultLimiter *ToolResultLimiter
 }
 
 type TraceLog interface {
@@ -172,15 +180,57 @@ func (s *ToolStore) AddTools(tools []Tool) {
 	}
 }
 
-func (s *ToolStore) ResolveTool(name string, argsGetter ToolArgumentGetter, context *Context) (string, error) {
+// RemoveTool removes the tool called name from the store.
+func (s *ToolStore) RemoveTool(name string) {
+	if s == nil {
+		return
+	}
+	delete(s.tools, name)
+}
+
+// SetResultLimiter makes the store shorten tool results over their token limit with limiter.
+func (s *ToolStore) SetResultLimiter(limiter *ToolResultLimiter) {
+	s.resultLimiter = limiter
+}
+
+func (s *ToolStore) ResolveTool(ctx context.Context, name string, argsGetter ToolArgumentGetter, llmContext *Context) (string, error) {
+	results, _, err := s.resolveTool(ctx, name, argsGetter, llmContext)
+	return results, err
+}
+
+// resolveTool resolves a tool call like ResolveTool, also returning the number of tokens of the
+// result before it was shortened to the tool's token limit, or 0 if it wasn't.
+func (s *ToolStore) resolveTool(ctx context.Context, name string, argsGetter ToolArgumentGetter, llmContext *Context) (results string, resultTokens int, err error) {
+	ctx, span := tracing.Start(ctx, "llm.ResolveTool", attribute.String("tool.name", name))
+	defer func() {
+		if resultTokens > 0 {
+			span.SetAttributes(attribute.Int("tool.result_tokens", resultTokens))
+		}
+		tracing.End(span, err)
+	}()
+
 	tool, ok := s.tools[name]
 	if !ok {
 		s.TraceUnknown(name, argsGetter)
-		return "", errors.New("unknown tool " + name)
+		return "", 0, errors.New("unknown tool " + name)
 	}
-	results, err := tool.Resolver(context, argsGetter)
+	results, err = tool.Resolver(ctx, llmContext, argsGetter)
 	s.TraceResolved(name, argsGetter, results, err)
-	return results, err
+	if err != nil || s.resultLimiter == nil {
+		return results, 0, err
+	}
+
+	results, resultTokens = s.resultLimiter.Limit(ctx, tool, results, llmContext)
+	return results, resultTokens, nil
+}
+
+// GetTool returns the tool called name, if the store has one.
+func (s *ToolStore) GetTool(name string) (Tool, bool) {
+	if s == nil {
+		return Tool{}, false
+	}
+	tool, ok := s.tools[name]
+	return tool, ok
 }
 
 func (s *ToolStore) GetTools() []Tool {
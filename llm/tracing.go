@@ -0,0 +1,136 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingWrapper records a span for each request to a provider, from sending the request until
+// the end of the response stream. The span's context is passed to the provider so the trace is
+// propagated in the HTTP request. Responses start with an EventTypeServiceInfo event naming the
+// service and model that answered them.
+type TracingWrapper struct {
+	wrapped LanguageModel
+	service ServiceInfo
+	botName string
+}
+
+func NewTracingWrapper(wrapped LanguageModel, service ServiceInfo, botName string) *TracingWrapper {
+	return &TracingWrapper{
+		wrapped: wrapped,
+		service: service,
+		botName: botName,
+	}
+}
+
+func (w *TracingWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	cfg := LanguageModelConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	model := cfg.Model
+	if model == "" {
+		model = w.service.Model
+	}
+
+	ctx, span := tracing.Start(ctx, "llm.ChatCompletion",
+		attribute.String("gen_ai.system", w.service.Type),
+		attribute.String("gen_ai.request.model", model),
+		attribute.String("llm.service", w.service.Name),
+		attribute.String("llm.bot", w.botName),
+		attribute.Int("llm.posts", len(request.Posts)),
+	)
+	start := time.Now()
+	result, err := w.wrapped.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	service := w.service
+	service.Model = model
+	return withServiceInfo(TraceStream(result, span, start), service), nil
+}
+
+// withServiceInfo sends an EventTypeServiceInfo event for service ahead of the response. Usage and
+// errors come before it, so a request that fails before answering can still be retried or failed over.
+func withServiceInfo(result *TextStreamResult, service ServiceInfo) *TextStreamResult {
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		sent := false
+		for event := range result.Stream {
+			if !sent && event.Type != EventTypeUsage && event.Type != EventTypeError {
+				output <- TextStreamEvent{Type: EventTypeServiceInfo, Value: service}
+				sent = true
+			}
+			output <- event
+		}
+	}()
+
+	return &TextStreamResult{Stream: output}
+}
+
+// TraceStream passes the events of result through, ending span when the stream ends.
+// The time to the first event of the response and the token usage are recorded on the span.
+func TraceStream(result *TextStreamResult, span trace.Span, start time.Time) *TextStreamResult {
+	output := make(chan TextStreamEvent)
+	go func() {
+		defer close(output)
+
+		var streamErr error
+		firstEvent := true
+		for event := range result.Stream {
+			switch event.Type {
+			case EventTypeText, EventTypeReasoning, EventTypeToolCalls:
+				if firstEvent {
+					firstEvent = false
+					span.AddEvent("first_token")
+					span.SetAttributes(attribute.Int64("llm.time_to_first_token_ms", time.Since(start).Milliseconds()))
+				}
+			case EventTypeUsage:
+				if usage, ok := event.Value.(TokenUsage); ok {
+					span.SetAttributes(
+						attribute.Int64("gen_ai.usage.input_tokens", usage.InputTokens),
+						attribute.Int64("gen_ai.usage.output_tokens", usage.OutputTokens),
+					)
+				}
+			case EventTypeError:
+				if err, ok := event.Value.(error); ok {
+					streamErr = err
+				} else {
+					streamErr = errors.New("unknown error from LLM")
+				}
+			}
+			output <- event
+		}
+		tracing.End(span, streamErr)
+	}()
+
+	return &TextStreamResult{Stream: output}
+}
+
+func (w *TracingWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	result, err := w.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+func (w *TracingWrapper) CountTokens(text string) int {
+	return w.wrapped.CountTokens(text)
+}
+
+func (w *TracingWrapper) InputTokenLimit() int {
+	return w.wrapped.InputTokenLimit()
+}
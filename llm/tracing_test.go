@@ -0,0 +1,109 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/llm/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingWrapper(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	service := llm.ServiceInfo{ID: "service", Name: "Service", Type: llm.ServiceTypeAnthropic, Model: "claude"}
+	request := llm.CompletionRequest{Posts: []llm.Post{{Role: llm.PostRoleUser, Message: "Hello"}}}
+
+	t.Run("span covers the response stream", func(t *testing.T) {
+		var providerSpan trace.SpanContext
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, request).RunAndReturn(func(ctx context.Context, _ llm.CompletionRequest, _ ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+			providerSpan = trace.SpanContextFromContext(ctx)
+			return streamOf(
+				llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hi"},
+				llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10, OutputTokens: 2}},
+				llm.TextStreamEvent{Type: llm.EventTypeEnd},
+			), nil
+		})
+
+		response, err := llm.NewTracingWrapper(mockLLM, service, "bot").ChatCompletionNoStream(t.Context(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "Hi", response)
+
+		spans := recorder.Ended()
+		require.NotEmpty(t, spans)
+		span := spans[len(spans)-1]
+		assert.Equal(t, "llm.ChatCompletion", span.Name())
+		assert.Equal(t, providerSpan.SpanID(), span.SpanContext().SpanID(), "the provider gets the span's context")
+		assert.Contains(t, span.Attributes(), attribute.String("gen_ai.request.model", "claude"))
+		assert.Contains(t, span.Attributes(), attribute.Int64("gen_ai.usage.input_tokens", 10))
+		assert.Contains(t, span.Attributes(), attribute.Int64("gen_ai.usage.output_tokens", 2))
+		require.Len(t, span.Events(), 1)
+		assert.Equal(t, "first_token", span.Events()[0].Name)
+		assert.Equal(t, codes.Unset, span.Status().Code)
+	})
+
+	t.Run("responses report the service that answered", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, request, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10}},
+			llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hi"},
+			llm.TextStreamEvent{Type: llm.EventTypeEnd},
+		), nil)
+
+		result, err := llm.NewTracingWrapper(mockLLM, service, "bot").ChatCompletion(t.Context(), request, llm.WithModel("claude-haiku"))
+		require.NoError(t, err)
+
+		answering := service
+		answering.Model = "claude-haiku"
+		assert.Equal(t, []llm.TextStreamEvent{
+			{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 10}},
+			{Type: llm.EventTypeServiceInfo, Value: answering},
+			{Type: llm.EventTypeText, Value: "Hi"},
+			{Type: llm.EventTypeEnd},
+		}, collect(t, result))
+	})
+
+	t.Run("failed requests don't report a service", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, request).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: assert.AnError},
+		), nil)
+
+		result, err := llm.NewTracingWrapper(mockLLM, service, "bot").ChatCompletion(t.Context(), request)
+		require.NoError(t, err)
+		assert.Equal(t, []llm.TextStreamEvent{{Type: llm.EventTypeError, Value: assert.AnError}}, collect(t, result))
+	})
+
+	t.Run("stream errors fail the span", func(t *testing.T) {
+		mockLLM := mocks.NewMockLanguageModel(t)
+		mockLLM.EXPECT().ChatCompletion(mock.Anything, request, mock.Anything).Return(streamOf(
+			llm.TextStreamEvent{Type: llm.EventTypeError, Value: assert.AnError},
+		), nil)
+
+		_, err := llm.NewTracingWrapper(mockLLM, service, "bot").ChatCompletionNoStream(t.Context(), request, llm.WithModel("claude-haiku"))
+		require.ErrorIs(t, err, assert.AnError)
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		assert.Contains(t, span.Attributes(), attribute.String("gen_ai.request.model", "claude-haiku"))
+		assert.Equal(t, codes.Error, span.Status().Code)
+	})
+}
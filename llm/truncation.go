@@ -4,6 +4,7 @@
 package llm
 
 import (
+	"context"
 	"math"
 )
 
@@ -21,16 +22,19 @@ func NewLLMTruncationWrapper(llm LanguageModel) *TruncationWrapper {
 	}
 }
 
-func (w *TruncationWrapper) ChatCompletion(request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
-	tokenLimit := int(math.Max(math.Floor(float64(w.wrapped.InputTokenLimit()-FunctionsTokenBudget)*TokenLimitBufferSize), MinTokens))
-	request.Truncate(tokenLimit, w.wrapped.CountTokens)
-	return w.wrapped.ChatCompletion(request, opts...)
+// truncationTokenLimit returns the number of tokens available for posts sent to model.
+func truncationTokenLimit(model LanguageModel) int {
+	return int(math.Max(math.Floor(float64(model.InputTokenLimit()-FunctionsTokenBudget)*TokenLimitBufferSize), MinTokens))
 }
 
-func (w *TruncationWrapper) ChatCompletionNoStream(request CompletionRequest, opts ...LanguageModelOption) (string, error) {
-	tokenLimit := int(math.Max(math.Floor(float64(w.wrapped.InputTokenLimit()-FunctionsTokenBudget)*TokenLimitBufferSize), MinTokens))
-	request.Truncate(tokenLimit, w.wrapped.CountTokens)
-	return w.wrapped.ChatCompletionNoStream(request, opts...)
+func (w *TruncationWrapper) ChatCompletion(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (*TextStreamResult, error) {
+	request.Truncate(truncationTokenLimit(w.wrapped), w.wrapped.CountTokens)
+	return w.wrapped.ChatCompletion(ctx, request, opts...)
+}
+
+func (w *TruncationWrapper) ChatCompletionNoStream(ctx context.Context, request CompletionRequest, opts ...LanguageModelOption) (string, error) {
+	request.Truncate(truncationTokenLimit(w.wrapped), w.wrapped.CountTokens)
+	return w.wrapped.ChatCompletionNoStream(ctx, request, opts...)
 }
 
 func (w *TruncationWrapper) CountTokens(text string) int {
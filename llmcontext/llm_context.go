@@ -23,6 +23,17 @@ type MCPToolProvider interface {
 	GetToolsForUser(userID string) ([]llm.Tool, *mcp.Errors)
 }
 
+// MemoryProvider provides the facts bots saved about users and the tools to manage them
+type MemoryProvider interface {
+	GetTools() []llm.Tool
+	GetPromptMemories(userID, botID string, budget int, countTokens func(string) int) ([]string, error)
+}
+
+// DelegationProvider provides the tools bots use to ask other bots
+type DelegationProvider interface {
+	GetDelegationTools(bot *bots.Bot) []llm.Tool
+}
+
 // ConfigProvider provides configuration access
 type ConfigProvider interface {
 	GetEnableLLMTrace() bool
@@ -34,7 +45,10 @@ type Builder struct {
 	pluginAPI       *pluginapi.Client
 	toolProvider    ToolProvider
 	mcpToolProvider MCPToolProvider
+	memoryProvider  MemoryProvider
 	configProvider  ConfigProvider
+
+	delegationProvider DelegationProvider
 }
 
 // NewLLMContextBuilder creates a new LLM context builder
@@ -42,16 +56,24 @@ func NewLLMContextBuilder(
 	pluginAPI *pluginapi.Client,
 	toolProvider ToolProvider,
 	mcpToolProvider MCPToolProvider,
+	memoryProvider MemoryProvider,
 	configProvider ConfigProvider,
 ) *Builder {
 	return &Builder{
 		pluginAPI:       pluginAPI,
 		toolProvider:    toolProvider,
 		mcpToolProvider: mcpToolProvider,
+		memoryProvider:  memoryProvider,
 		configProvider:  configProvider,
 	}
 }
 
+// SetDelegationProvider sets the provider of the delegation tools.
+// Set after construction because the provider builds contexts with this builder.
+func (b *Builder) SetDelegationProvider(provider DelegationProvider) {
+	b.delegationProvider = provider
+}
+
 // BuildLLMContextUserRequest is a helper function to collect the required context for a user request.
 func (b *Builder) BuildLLMContextUserRequest(bot *bots.Bot, requestingUser *model.User, channel *model.Channel, opts ...llm.ContextOption) *llm.Context {
 	allOpts := []llm.ContextOption{
@@ -132,10 +154,23 @@ func (b *Builder) getToolsStoreForUser(c *llm.Context, bot *bots.Bot, userID str
 
 	// Create a tool store that requires user approval for tool calls
 	store := llm.NewToolStore(&b.pluginAPI.Log, b.configProvider.GetEnableLLMTrace())
+	if model := bot.LLM(); model != nil {
+		store.SetResultLimiter(llm.NewToolResultLimiter(model, bot.GetConfig()))
+	}
 
 	// Add built-in tools (always add for LLM awareness; execution controlled via WithToolsDisabled)
 	store.AddTools(b.toolProvider.GetTools(bot))
 
+	// Add memory tools if the bot can remember facts about users
+	if b.memoryProvider != nil && bot.GetConfig().EnableMemory {
+		store.AddTools(b.memoryProvider.GetTools())
+	}
+
+	// Add the tool to ask other bots if the bot delegates to any
+	if b.delegationProvider != nil {
+		store.AddTools(b.delegationProvider.GetDelegationTools(bot))
+	}
+
 	// Add MCP tools if available and enabled
 	// Note: MCP tools are only executable in DMs, but we always add them to the store
 	// so that GetToolsInfo() can inform the LLM about their availability.
@@ -190,6 +225,22 @@ func (b *Builder) WithLLMContextNoTools() llm.ContextOption {
 	}
 }
 
+// WithLLMContextMemories adds the facts the bot saved about the requesting user, within the bot's memory token budget.
+func (b *Builder) WithLLMContextMemories(bot *bots.Bot) llm.ContextOption {
+	return func(c *llm.Context) {
+		if b.memoryProvider == nil || !bot.GetConfig().EnableMemory || c.RequestingUser == nil || bot.GetMMBot() == nil || bot.LLM() == nil {
+			return
+		}
+
+		memories, err := b.memoryProvider.GetPromptMemories(c.RequestingUser.Id, bot.GetMMBot().UserId, bot.GetConfig().MemoryTokenBudget, bot.LLM().CountTokens)
+		if err != nil {
+			b.pluginAPI.Log.Error("Unable to get memories for context", "error", err.Error(), "user_id", c.RequestingUser.Id)
+			return
+		}
+		c.Memories = memories
+	}
+}
+
 func (b *Builder) WithLLMContextParameters(params map[string]interface{}) llm.ContextOption {
 	return func(c *llm.Context) {
 		c.Parameters = params
@@ -12,8 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -347,7 +349,19 @@ func (c *Client) CallTool(ctx context.Context, toolName string, args map[string]
 }
 
 // CallToolWithMetadata calls a tool on this MCP server with optional metadata
-func (c *Client) CallToolWithMetadata(ctx context.Context, toolName string, args map[string]any, metadata map[string]any) (string, error) {
+func (c *Client) CallToolWithMetadata(ctx context.Context, toolName string, args map[string]any, metadata map[string]any) (result string, err error) {
+	ctx, span := tracing.Start(ctx, "mcp.CallToolWithMetadata",
+		attribute.String("mcp.server", c.config.Name),
+		attribute.String("tool.name", toolName),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	return c.callToolWithMetadata(ctx, toolName, args, metadata)
+}
+
+func (c *Client) callToolWithMetadata(ctx context.Context, toolName string, args map[string]any, metadata map[string]any) (string, error) {
 	if c.session == nil {
 		return "", fmt.Errorf("MCP client not connected")
 	}
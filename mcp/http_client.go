@@ -3,7 +3,11 @@
 
 package mcp
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
+)
 
 // headerTransport is a custom RoundTripper that adds headers to requests
 type headerTransport struct {
@@ -24,13 +28,13 @@ func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 }
 
 func (c *Client) httpClientForMCP(headers map[string]string) *http.Client {
-	// Wrap with discovery-aware transport for 401 handling
+	// Wrap with discovery-aware transport for 401 handling, propagating trace context to the server
 	authenticationTransport := &authenticationTransport{
 		userID:     c.userID,
 		serverName: c.config.Name,
 		manager:    c.oauthManager,
 		serverURL:  c.config.BaseURL,
-		base:       c.httpClient.Transport,
+		base:       tracing.NewTransport(c.httpClient.Transport),
 	}
 
 	// Create HTTP client with discovery-aware transport
@@ -171,6 +171,7 @@ func (c *UserClients) GetTools() []llm.Tool {
 				Description: tool.Description,
 				Schema:      tool.InputSchema,
 				Resolver:    c.createToolResolver(client, toolName),
+				MCPServer:   serverID,
 			})
 		}
 	}
@@ -198,8 +199,8 @@ func (c *UserClients) prepareToolCallMetadata(client *Client, llmContext *llm.Co
 }
 
 // createToolResolver creates a resolver function for the given tool
-func (c *UserClients) createToolResolver(client *Client, toolName string) func(llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
-	return func(llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+func (c *UserClients) createToolResolver(client *Client, toolName string) llm.ToolResolver {
+	return func(ctx context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
 		var args map[string]any
 		if err := argsGetter(&args); err != nil {
 			return "", fmt.Errorf("failed to get arguments for tool %s: %w", toolName, err)
@@ -208,6 +209,6 @@ func (c *UserClients) createToolResolver(client *Client, toolName string) func(l
 		// Prepare metadata for the tool call
 		metadata := c.prepareToolCallMetadata(client, llmContext)
 
-		return client.CallToolWithMetadata(context.Background(), toolName, args, metadata)
+		return client.CallToolWithMetadata(ctx, toolName, args, metadata)
 	}
 }
@@ -197,8 +197,10 @@ func (s *Service) newCallTranscriptionSummaryThread(bot *bots.Bot, requestingUse
 			channel,
 			s.contextBuilder.WithLLMContextDefaultTools(bot),
 		)
-		summaryStream, err := s.SummarizeTranscription(bot, text, requestContext)
+		ctx, cancel := s.streamingService.NewRequestContext()
+		summaryStream, err := s.SummarizeTranscription(ctx, bot, text, requestContext)
 		if err != nil {
+			cancel()
 			return fmt.Errorf("unable to summarize transcription: %w", err)
 		}
 
@@ -208,7 +210,8 @@ func (s *Service) newCallTranscriptionSummaryThread(bot *bots.Bot, requestingUse
 			Message:   "",
 		}
 		summaryPost.AddProp(ReferencedTranscriptPostID, transcriptionPost.Id)
-		if err := s.streamingService.StreamToNewPost(context.Background(), bot.GetMMBot().UserId, requestingUser.Id, summaryStream, summaryPost, transcriptionPost.Id); err != nil {
+		if err := s.streamingService.StreamToNewPost(ctx, bot.GetMMBot().UserId, requestingUser.Id, summaryStream, summaryPost, transcriptionPost.Id); err != nil {
+			cancel()
 			return fmt.Errorf("unable to stream result to post: %w", err)
 		}
 
@@ -258,7 +261,10 @@ func (s *Service) summarizeCallRecording(bot *bots.Bot, rootID string, requestin
 			channel,
 			s.contextBuilder.WithLLMContextDefaultTools(bot),
 		)
-		summaryStream, err := s.SummarizeTranscription(bot, transcription, llmContext)
+		requestCtx, cancel := s.streamingService.NewRequestContext()
+		defer cancel()
+
+		summaryStream, err := s.SummarizeTranscription(requestCtx, bot, transcription, llmContext)
 		if err != nil {
 			return fmt.Errorf("unable to summarize transcription: %w", err)
 		}
@@ -267,7 +273,7 @@ func (s *Service) summarizeCallRecording(bot *bots.Bot, rootID string, requestin
 			return fmt.Errorf("unable to update transcript post: %w", err)
 		}
 
-		ctx, err := s.streamingService.GetStreamingContext(context.Background(), transcriptPost.Id)
+		ctx, err := s.streamingService.GetStreamingContext(requestCtx, transcriptPost.Id)
 		if err != nil {
 			return fmt.Errorf("unable to get post streaming context: %w", err)
 		}
@@ -281,7 +287,7 @@ func (s *Service) summarizeCallRecording(bot *bots.Bot, rootID string, requestin
 	return nil
 }
 
-func (s *Service) SummarizeTranscription(bot *bots.Bot, transcription *subtitles.Subtitles, context *llm.Context) (*llm.TextStreamResult, error) {
+func (s *Service) SummarizeTranscription(ctx context.Context, bot *bots.Bot, transcription *subtitles.Subtitles, context *llm.Context) (*llm.TextStreamResult, error) {
 	llmFormattedTranscription := transcription.FormatForLLM()
 	tokens := bot.LLM().CountTokens(llmFormattedTranscription)
 	tokenLimitWithMargin := int(float64(bot.LLM().InputTokenLimit())*0.75) - ContextTokenMargin
@@ -291,7 +297,7 @@ func (s *Service) SummarizeTranscription(bot *bots.Bot, transcription *subtitles
 	isChunked := false
 	if tokens > tokenLimitWithMargin {
 		s.pluginAPI.Log.Debug("Transcription too long, summarizing in chunks.", "tokens", tokens, "limit", tokenLimitWithMargin)
-		chunks := chunking.SplitPlaintextOnSentences(llmFormattedTranscription, tokenLimitWithMargin*4)
+		chunks := chunking.SplitPlaintextOnSentencesByTokens(llmFormattedTranscription, tokenLimitWithMargin, bot.LLM().CountTokens)
 		summarizedChunks := make([]string, 0, len(chunks))
 		s.pluginAPI.Log.Debug("Split into chunks", "chunks", len(chunks))
 		for _, chunk := range chunks {
@@ -313,7 +319,7 @@ func (s *Service) SummarizeTranscription(bot *bots.Bot, transcription *subtitles
 				Context: context,
 			}
 
-			summarizedChunk, err := bot.LLM().ChatCompletionNoStream(request)
+			summarizedChunk, err := bot.LLM().ChatCompletionNoStream(ctx, request, llm.WithResponseCache(), llm.WithTask(llm.TaskChunkSummary))
 			if err != nil {
 				return nil, fmt.Errorf("unable to get summarized chunk: %w", err)
 			}
@@ -346,7 +352,7 @@ func (s *Service) SummarizeTranscription(bot *bots.Bot, transcription *subtitles
 		Context: context,
 	}
 
-	summaryStream, err := bot.LLM().ChatCompletion(completionRequest, llm.WithToolsDisabled())
+	summaryStream, err := bot.LLM().ChatCompletion(ctx, completionRequest, llm.WithToolsDisabled(), llm.WithTask(llm.TaskSummary))
 	if err != nil {
 		return nil, fmt.Errorf("unable to get meeting summary: %w", err)
 	}
@@ -0,0 +1,183 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package memory keeps durable facts bots save about users, such as their preferences, role and
+// projects, so later conversations don't start from scratch. Facts are stored per user and bot,
+// and users can list and delete everything saved about them.
+package memory
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// DefaultTokenBudget is how many tokens of facts are added to a prompt when the bot doesn't set a budget
+	DefaultTokenBudget = 500
+	// MaxMemoryLength is the longest fact a bot can save, in characters
+	MaxMemoryLength = 1000
+	// MaxMemoriesPerUser is how many facts a bot can save about a user
+	MaxMemoriesPerUser = 200
+)
+
+var (
+	// ErrMemoryNotFound is returned when deleting a fact that doesn't exist or belongs to another user.
+	ErrMemoryNotFound = errors.New("memory not found")
+	// ErrMemoryFull is returned when a bot saved as many facts about a user as it can.
+	ErrMemoryFull = errors.New("too many memories saved for the user")
+)
+
+// Memory is a fact a bot saved about a user.
+type Memory struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	BotID    string `json:"bot_id"`
+	Content  string `json:"content"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// store persists memories per user and bot, most recent first.
+type store interface {
+	saveMemory(memory Memory) error
+	// getMemories returns the memories of a user saved by botID, or by every bot when botID is empty
+	getMemories(userID, botID string) ([]Memory, error)
+	deleteMemory(userID, id string) (bool, error)
+	// deleteMemories deletes the memories of a user saved by botID, or by every bot when botID is empty
+	deleteMemories(userID, botID string) error
+}
+
+// Service saves, recalls and deletes the memories of users.
+type Service struct {
+	store store
+}
+
+func New(db *mmapi.DBClient) *Service {
+	return &Service{
+		store: &dbStore{db: db},
+	}
+}
+
+// Remember saves content as a fact about a user. Saving a fact the bot already knows returns the existing memory.
+func (s *Service) Remember(userID, botID, content string) (Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Memory{}, errors.New("memory is empty")
+	}
+	if len(content) > MaxMemoryLength {
+		return Memory{}, errors.New("memory is too long")
+	}
+
+	existing, err := s.store.getMemories(userID, botID)
+	if err != nil {
+		return Memory{}, err
+	}
+	for _, memory := range existing {
+		if strings.EqualFold(memory.Content, content) {
+			return memory, nil
+		}
+	}
+	if len(existing) >= MaxMemoriesPerUser {
+		return Memory{}, ErrMemoryFull
+	}
+
+	memory := Memory{
+		ID:       model.NewId(),
+		UserID:   userID,
+		BotID:    botID,
+		Content:  content,
+		CreateAt: model.GetMillis(),
+	}
+	if err := s.store.saveMemory(memory); err != nil {
+		return Memory{}, err
+	}
+	return memory, nil
+}
+
+// Recall returns the facts a bot saved about a user that contain any word of query, or all of them
+// when query is empty.
+func (s *Service) Recall(userID, botID, query string) ([]Memory, error) {
+	memories, err := s.store.getMemories(userID, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return memories, nil
+	}
+
+	var matches []Memory
+	for _, memory := range memories {
+		content := strings.ToLower(memory.Content)
+		for _, word := range words {
+			if strings.Contains(content, word) {
+				matches = append(matches, memory)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// Forget deletes a fact a bot saved about a user.
+func (s *Service) Forget(userID, botID, id string) error {
+	memories, err := s.store.getMemories(userID, botID)
+	if err != nil {
+		return err
+	}
+	for _, memory := range memories {
+		if memory.ID == id {
+			return s.DeleteMemory(userID, id)
+		}
+	}
+	return ErrMemoryNotFound
+}
+
+// GetMemories returns everything the bots saved about a user.
+func (s *Service) GetMemories(userID string) ([]Memory, error) {
+	return s.store.getMemories(userID, "")
+}
+
+// DeleteMemory deletes one of the facts saved about a user.
+func (s *Service) DeleteMemory(userID, id string) error {
+	deleted, err := s.store.deleteMemory(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrMemoryNotFound
+	}
+	return nil
+}
+
+// DeleteMemories deletes the facts saved about a user by botID, or by every bot when botID is empty.
+func (s *Service) DeleteMemories(userID, botID string) error {
+	return s.store.deleteMemories(userID, botID)
+}
+
+// GetPromptMemories returns the most recent facts a bot saved about a user that fit in budget tokens.
+func (s *Service) GetPromptMemories(userID, botID string, budget int, countTokens func(string) int) ([]string, error) {
+	if budget <= 0 {
+		budget = DefaultTokenBudget
+	}
+
+	memories, err := s.store.getMemories(userID, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	var facts []string
+	used := 0
+	for _, memory := range memories {
+		tokens := countTokens(memory.Content)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		facts = append(facts, memory.Content)
+	}
+	return facts, nil
+}
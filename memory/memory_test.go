@@ -0,0 +1,169 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package memory
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	memories []Memory
+}
+
+func (s *memoryStore) saveMemory(memory Memory) error {
+	// Most recent first, like the database store
+	s.memories = append([]Memory{memory}, s.memories...)
+	return nil
+}
+
+func (s *memoryStore) getMemories(userID, botID string) ([]Memory, error) {
+	var result []Memory
+	for _, memory := range s.memories {
+		if memory.UserID == userID && (botID == "" || memory.BotID == botID) {
+			result = append(result, memory)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) deleteMemory(userID, id string) (bool, error) {
+	before := len(s.memories)
+	s.memories = slices.DeleteFunc(s.memories, func(memory Memory) bool {
+		return memory.UserID == userID && memory.ID == id
+	})
+	return len(s.memories) < before, nil
+}
+
+func (s *memoryStore) deleteMemories(userID, botID string) error {
+	s.memories = slices.DeleteFunc(s.memories, func(memory Memory) bool {
+		return memory.UserID == userID && (botID == "" || memory.BotID == botID)
+	})
+	return nil
+}
+
+func countTokens(text string) int {
+	return len(text) / 4
+}
+
+func TestService(t *testing.T) {
+	t.Run("facts are saved per user and bot", func(t *testing.T) {
+		s := &Service{store: &memoryStore{}}
+		_, err := s.Remember("user", "hrbot", "Works in the Berlin office")
+		require.NoError(t, err)
+		_, err = s.Remember("user", "codebot", "Prefers Go examples")
+		require.NoError(t, err)
+		_, err = s.Remember("other", "codebot", "Prefers Python examples")
+		require.NoError(t, err)
+
+		memories, err := s.Recall("user", "codebot", "")
+		require.NoError(t, err)
+		require.Len(t, memories, 1)
+		assert.Equal(t, "Prefers Go examples", memories[0].Content)
+
+		memories, err = s.GetMemories("user")
+		require.NoError(t, err)
+		assert.Len(t, memories, 2)
+	})
+
+	t.Run("saving a known fact returns the existing memory", func(t *testing.T) {
+		s := &Service{store: &memoryStore{}}
+		first, err := s.Remember("user", "bot", "Prefers Go examples")
+		require.NoError(t, err)
+		second, err := s.Remember("user", "bot", " prefers go examples ")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, second.ID)
+
+		_, err = s.Remember("user", "bot", "  ")
+		require.Error(t, err)
+	})
+
+	t.Run("recall matches any word of the query", func(t *testing.T) {
+		s := &Service{store: &memoryStore{}}
+		_, err := s.Remember("user", "bot", "Works on the billing service")
+		require.NoError(t, err)
+		_, err = s.Remember("user", "bot", "Prefers Go examples")
+		require.NoError(t, err)
+
+		memories, err := s.Recall("user", "bot", "Billing project")
+		require.NoError(t, err)
+		require.Len(t, memories, 1)
+		assert.Equal(t, "Works on the billing service", memories[0].Content)
+	})
+
+	t.Run("bots can only forget their own facts about the user", func(t *testing.T) {
+		s := &Service{store: &memoryStore{}}
+		memory, err := s.Remember("user", "hrbot", "Works in the Berlin office")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.Forget("user", "codebot", memory.ID), ErrMemoryNotFound)
+		require.ErrorIs(t, s.DeleteMemory("other", memory.ID), ErrMemoryNotFound)
+		require.NoError(t, s.Forget("user", "hrbot", memory.ID))
+
+		memories, err := s.GetMemories("user")
+		require.NoError(t, err)
+		assert.Empty(t, memories)
+	})
+
+	t.Run("users can delete the facts of one bot or all of them", func(t *testing.T) {
+		s := &Service{store: &memoryStore{}}
+		for _, botID := range []string{"hrbot", "codebot"} {
+			_, err := s.Remember("user", botID, "Works in the Berlin office")
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, s.DeleteMemories("user", "hrbot"))
+		memories, err := s.GetMemories("user")
+		require.NoError(t, err)
+		require.Len(t, memories, 1)
+		assert.Equal(t, "codebot", memories[0].BotID)
+
+		require.NoError(t, s.DeleteMemories("user", ""))
+		memories, err = s.GetMemories("user")
+		require.NoError(t, err)
+		assert.Empty(t, memories)
+	})
+
+	t.Run("prompts get the most recent facts within the budget", func(t *testing.T) {
+		store := &memoryStore{}
+		s := &Service{store: store}
+		for i, content := range []string{"Oldest fact of the user", "Middle fact of the user", "Latest fact of the user"} {
+			require.NoError(t, store.saveMemory(Memory{ID: model.NewId(), UserID: "user", BotID: "bot", Content: content, CreateAt: int64(i)}))
+		}
+
+		facts, err := s.GetPromptMemories("user", "bot", 10, countTokens)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Latest fact of the user", "Middle fact of the user"}, facts)
+	})
+}
+
+func TestTools(t *testing.T) {
+	s := &Service{store: &memoryStore{}}
+	tools := llm.NewNoTools()
+	tools.AddTools(s.GetTools())
+	llmContext := &llm.Context{RequestingUser: &model.User{Id: "user"}, BotUserID: "bot"}
+
+	result, err := tools.ResolveTool(t.Context(), "remember", func(args any) error {
+		args.(*RememberArgs).Fact = "Prefers Go examples"
+		return nil
+	}, llmContext)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Remembered with ID")
+
+	result, err = tools.ResolveTool(t.Context(), "recall", func(any) error {
+		return nil
+	}, llmContext)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Prefers Go examples")
+
+	_, err = tools.ResolveTool(t.Context(), "recall", func(any) error {
+		return nil
+	}, &llm.Context{})
+	require.Error(t, err, "memories can't be recalled without a requesting user")
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package memory
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+)
+
+// dbStore stores memories in the LLM_Memories table.
+type dbStore struct {
+	db *mmapi.DBClient
+}
+
+func (s *dbStore) saveMemory(memory Memory) error {
+	_, err := s.db.ExecBuilder(s.db.Builder().Insert("LLM_Memories").
+		Columns("ID", "UserID", "BotID", "Content", "CreateAt").
+		Values(memory.ID, memory.UserID, memory.BotID, memory.Content, memory.CreateAt))
+	if err != nil {
+		return fmt.Errorf("failed to save memory: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStore) getMemories(userID, botID string) ([]Memory, error) {
+	query := s.db.Builder().
+		Select("ID", "UserID", "BotID", "Content", "CreateAt").
+		From("LLM_Memories").
+		Where(sq.Eq{"UserID": userID}).
+		OrderBy("CreateAt DESC")
+	if botID != "" {
+		query = query.Where(sq.Eq{"BotID": botID})
+	}
+
+	var memories []Memory
+	if err := s.db.DoQuery(&memories, query); err != nil {
+		return nil, fmt.Errorf("failed to get memories: %w", err)
+	}
+	return memories, nil
+}
+
+func (s *dbStore) deleteMemory(userID, id string) (bool, error) {
+	result, err := s.db.ExecBuilder(s.db.Builder().Delete("LLM_Memories").
+		Where(sq.Eq{"UserID": userID}).
+		Where(sq.Eq{"ID": id}))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete memory: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete memory: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+func (s *dbStore) deleteMemories(userID, botID string) error {
+	query := s.db.Builder().Delete("LLM_Memories").
+		Where(sq.Eq{"UserID": userID})
+	if botID != "" {
+		query = query.Where(sq.Eq{"BotID": botID})
+	}
+
+	if _, err := s.db.ExecBuilder(query); err != nil {
+		return fmt.Errorf("failed to delete memories: %w", err)
+	}
+	return nil
+}
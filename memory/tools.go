@@ -0,0 +1,121 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+type RememberArgs struct {
+	Fact string `jsonschema_description:"A durable fact about the user written as a short sentence, such as a preference, their role or a project they work on. Example: 'Prefers answers with code examples in Go'"`
+}
+
+type RecallArgs struct {
+	Query string `jsonschema_description:"Words to look for in the saved facts. Leave empty to list every fact saved about the user."`
+}
+
+type ForgetArgs struct {
+	ID string `jsonschema_description:"The ID of the fact to forget, as listed by the recall tool"`
+}
+
+// GetTools returns the tools bots use to save, look up and delete facts about the requesting user.
+func (s *Service) GetTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        "remember",
+			Description: "Save a durable fact about the user to remember in future conversations. Only use this tool when the user asks you to remember something, or shares a lasting preference, role or project. Never save secrets, passwords or sensitive personal data.",
+			Schema:      llm.NewJSONSchemaFromStruct[RememberArgs](),
+			Resolver:    s.toolRemember,
+		},
+		{
+			Name:        "recall",
+			Description: "Look up the facts saved about the user in previous conversations, along with their IDs.",
+			Schema:      llm.NewJSONSchemaFromStruct[RecallArgs](),
+			Resolver:    s.toolRecall,
+		},
+		{
+			Name:        "forget",
+			Description: "Delete a fact saved about the user, when the user asks you to forget it or it is no longer true. Use the recall tool to find its ID.",
+			Schema:      llm.NewJSONSchemaFromStruct[ForgetArgs](),
+			Resolver:    s.toolForget,
+		},
+	}
+}
+
+func requestSubject(llmContext *llm.Context) (string, string, error) {
+	if llmContext.RequestingUser == nil || llmContext.BotUserID == "" {
+		return "", "", errors.New("memory tools need a requesting user and bot")
+	}
+	return llmContext.RequestingUser.Id, llmContext.BotUserID, nil
+}
+
+func (s *Service) toolRemember(_ context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+	var args RememberArgs
+	if err := argsGetter(&args); err != nil {
+		return "invalid parameters to function", fmt.Errorf("failed to get arguments for tool remember: %w", err)
+	}
+	userID, botID, err := requestSubject(llmContext)
+	if err != nil {
+		return "unable to remember", err
+	}
+
+	memory, err := s.Remember(userID, botID, args.Fact)
+	if errors.Is(err, ErrMemoryFull) {
+		return "too many facts are saved about the user, forget one before remembering another", nil
+	}
+	if err != nil {
+		return "unable to remember", fmt.Errorf("failed to remember fact: %w", err)
+	}
+	return fmt.Sprintf("Remembered with ID %s", memory.ID), nil
+}
+
+func (s *Service) toolRecall(_ context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+	var args RecallArgs
+	if err := argsGetter(&args); err != nil {
+		return "invalid parameters to function", fmt.Errorf("failed to get arguments for tool recall: %w", err)
+	}
+	userID, botID, err := requestSubject(llmContext)
+	if err != nil {
+		return "unable to recall", err
+	}
+
+	memories, err := s.Recall(userID, botID, args.Query)
+	if err != nil {
+		return "unable to recall", fmt.Errorf("failed to recall facts: %w", err)
+	}
+	if len(memories) == 0 {
+		return "No facts saved about the user match the query", nil
+	}
+
+	var result strings.Builder
+	for _, memory := range memories {
+		fmt.Fprintf(&result, "- ID %s: %s\n", memory.ID, memory.Content)
+	}
+	return result.String(), nil
+}
+
+func (s *Service) toolForget(_ context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+	var args ForgetArgs
+	if err := argsGetter(&args); err != nil {
+		return "invalid parameters to function", fmt.Errorf("failed to get arguments for tool forget: %w", err)
+	}
+	userID, botID, err := requestSubject(llmContext)
+	if err != nil {
+		return "unable to forget", err
+	}
+
+	err = s.Forget(userID, botID, args.ID)
+	if errors.Is(err, ErrMemoryNotFound) {
+		return "no fact with this ID is saved about the user", nil
+	}
+	if err != nil {
+		return "unable to forget", fmt.Errorf("failed to forget fact: %w", err)
+	}
+	return "Forgotten", nil
+}
@@ -30,6 +30,15 @@ type Metrics interface {
 	GetMetricsForAIService(llmName string) *llmMetrics
 
 	ObserveTokenUsage(botName, teamID, userID string, inputTokens, outputTokens int)
+	ObserveTokenCost(botName, teamID, model string, cost float64)
+	ObserveTokenCache(botName, teamID string, cacheReadTokens, cacheCreationTokens int)
+	ObserveRedactions(botName, category string, count int)
+
+	ObserveTimeToFirstToken(botName, serviceName string, elapsed float64)
+	ObserveGenerationDuration(botName, serviceName string, elapsed float64)
+	ObserveTokensPerSecond(botName, serviceName string, tokensPerSecond float64)
+	ObserveToolCallRoundTrip(botName, serviceName string, elapsed float64)
+	IncrementLLMErrors(botName, serviceName, errorClass string)
 }
 
 type InstanceInfo struct {
@@ -54,6 +63,18 @@ type metrics struct {
 
 	llmInputTokensTotal  *prometheus.CounterVec
 	llmOutputTokensTotal *prometheus.CounterVec
+	llmCostTotal         *prometheus.CounterVec
+
+	llmCacheReadTokensTotal     *prometheus.CounterVec
+	llmCacheCreationTokensTotal *prometheus.CounterVec
+
+	llmRedactionsTotal *prometheus.CounterVec
+
+	llmTimeToFirstToken   *prometheus.HistogramVec
+	llmGenerationDuration *prometheus.HistogramVec
+	llmTokensPerSecond    *prometheus.HistogramVec
+	llmToolCallRoundTrip  *prometheus.HistogramVec
+	llmErrorsTotal        *prometheus.CounterVec
 }
 
 // NewMetrics Factory method to create a new metrics collector.
@@ -152,6 +173,92 @@ func NewMetrics(info InstanceInfo) Metrics {
 	}, []string{"bot_name", "team_id"})
 	m.registry.MustRegister(m.llmOutputTokensTotal)
 
+	m.llmCostTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "cost_usd_total",
+		Help:        "The total estimated cost in USD of LLM requests.",
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "team_id", "model"})
+	m.registry.MustRegister(m.llmCostTotal)
+
+	// The cache hit rate is cache_read_input_tokens_total / input_tokens_total
+	m.llmCacheReadTokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "cache_read_input_tokens_total",
+		Help:        "The total number of input tokens served from the prompt cache.",
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "team_id"})
+	m.registry.MustRegister(m.llmCacheReadTokensTotal)
+
+	m.llmCacheCreationTokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "cache_creation_input_tokens_total",
+		Help:        "The total number of input tokens written to the prompt cache.",
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "team_id"})
+	m.registry.MustRegister(m.llmCacheCreationTokensTotal)
+
+	m.llmRedactionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "redactions_total",
+		Help:        "The total number of sensitive values redacted from LLM requests.",
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "category"})
+	m.registry.MustRegister(m.llmRedactionsTotal)
+
+	m.llmTimeToFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "time_to_first_token_seconds",
+		Help:        "Time from sending an LLM request until the first token of the response (seconds).",
+		Buckets:     []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "service"})
+	m.registry.MustRegister(m.llmTimeToFirstToken)
+
+	m.llmGenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "generation_duration_seconds",
+		Help:        "Time from sending an LLM request until the end of the response (seconds).",
+		Buckets:     []float64{0.5, 1, 2, 5, 10, 20, 40, 60, 120, 300},
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "service"})
+	m.registry.MustRegister(m.llmGenerationDuration)
+
+	m.llmTokensPerSecond = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "tokens_per_second",
+		Help:        "Output tokens generated per second after the first token of LLM responses.",
+		Buckets:     []float64{5, 10, 20, 40, 60, 80, 100, 150, 200, 400},
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "service"})
+	m.registry.MustRegister(m.llmTokensPerSecond)
+
+	m.llmToolCallRoundTrip = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "tool_call_round_trip_seconds",
+		Help:        "Time from sending an LLM request until the model requests tool calls (seconds).",
+		Buckets:     []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "service"})
+	m.registry.MustRegister(m.llmToolCallRoundTrip)
+
+	m.llmErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemLLM,
+		Name:        "errors_total",
+		Help:        "The total number of failed LLM requests by error class.",
+		ConstLabels: additionalLabels,
+	}, []string{"bot_name", "service", "error_class"})
+	m.registry.MustRegister(m.llmErrorsTotal)
+
 	return m
 }
 
@@ -227,3 +334,112 @@ func (m *metrics) ObserveTokenUsage(botName, teamID, userID string, inputTokens,
 		m.llmOutputTokensTotal.With(labels).Add(float64(outputTokens))
 	}
 }
+
+func (m *metrics) ObserveTokenCost(botName, teamID, model string, cost float64) {
+	if m == nil || cost <= 0 {
+		return
+	}
+
+	if teamID == "" {
+		teamID = "unknown"
+	}
+	if botName == "" {
+		botName = "unknown"
+	}
+
+	m.llmCostTotal.With(prometheus.Labels{
+		"bot_name": botName,
+		"team_id":  teamID,
+		"model":    model,
+	}).Add(cost)
+}
+
+func (m *metrics) ObserveTokenCache(botName, teamID string, cacheReadTokens, cacheCreationTokens int) {
+	if m == nil {
+		return
+	}
+
+	if teamID == "" {
+		teamID = "unknown"
+	}
+	if botName == "" {
+		botName = "unknown"
+	}
+
+	labels := prometheus.Labels{
+		"bot_name": botName,
+		"team_id":  teamID,
+	}
+
+	if cacheReadTokens > 0 {
+		m.llmCacheReadTokensTotal.With(labels).Add(float64(cacheReadTokens))
+	}
+	if cacheCreationTokens > 0 {
+		m.llmCacheCreationTokensTotal.With(labels).Add(float64(cacheCreationTokens))
+	}
+}
+
+func (m *metrics) ObserveRedactions(botName, category string, count int) {
+	if m == nil || count <= 0 {
+		return
+	}
+
+	if botName == "" {
+		botName = "unknown"
+	}
+
+	m.llmRedactionsTotal.With(prometheus.Labels{
+		"bot_name": botName,
+		"category": category,
+	}).Add(float64(count))
+}
+
+func llmServiceLabels(botName, serviceName string) prometheus.Labels {
+	if botName == "" {
+		botName = "unknown"
+	}
+	if serviceName == "" {
+		serviceName = "unknown"
+	}
+	return prometheus.Labels{
+		"bot_name": botName,
+		"service":  serviceName,
+	}
+}
+
+func (m *metrics) ObserveTimeToFirstToken(botName, serviceName string, elapsed float64) {
+	if m == nil {
+		return
+	}
+	m.llmTimeToFirstToken.With(llmServiceLabels(botName, serviceName)).Observe(elapsed)
+}
+
+func (m *metrics) ObserveGenerationDuration(botName, serviceName string, elapsed float64) {
+	if m == nil {
+		return
+	}
+	m.llmGenerationDuration.With(llmServiceLabels(botName, serviceName)).Observe(elapsed)
+}
+
+func (m *metrics) ObserveTokensPerSecond(botName, serviceName string, tokensPerSecond float64) {
+	if m == nil {
+		return
+	}
+	m.llmTokensPerSecond.With(llmServiceLabels(botName, serviceName)).Observe(tokensPerSecond)
+}
+
+func (m *metrics) ObserveToolCallRoundTrip(botName, serviceName string, elapsed float64) {
+	if m == nil {
+		return
+	}
+	m.llmToolCallRoundTrip.With(llmServiceLabels(botName, serviceName)).Observe(elapsed)
+}
+
+func (m *metrics) IncrementLLMErrors(botName, serviceName, errorClass string) {
+	if m == nil {
+		return
+	}
+	labels := llmServiceLabels(botName, serviceName)
+	labels["error_class"] = errorClass
+	m.llmErrorsTotal.With(labels).Inc()
+}
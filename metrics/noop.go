@@ -45,3 +45,43 @@ func (m *NoopMetrics) GetMetricsForAIService(llmName string) *llmMetrics { //nol
 func (m *NoopMetrics) ObserveTokenUsage(botName, teamID, userID string, inputTokens, outputTokens int) {
 	// No-op
 }
+
+// ObserveTokenCost is a no-op implementation.
+func (m *NoopMetrics) ObserveTokenCost(botName, teamID, model string, cost float64) {
+	// No-op
+}
+
+// ObserveTokenCache is a no-op implementation.
+func (m *NoopMetrics) ObserveTokenCache(botName, teamID string, cacheReadTokens, cacheCreationTokens int) {
+	// No-op
+}
+
+// ObserveRedactions is a no-op implementation.
+func (m *NoopMetrics) ObserveRedactions(botName, category string, count int) {
+	// No-op
+}
+
+// ObserveTimeToFirstToken is a no-op implementation.
+func (m *NoopMetrics) ObserveTimeToFirstToken(botName, serviceName string, elapsed float64) {
+	// No-op
+}
+
+// ObserveGenerationDuration is a no-op implementation.
+func (m *NoopMetrics) ObserveGenerationDuration(botName, serviceName string, elapsed float64) {
+	// No-op
+}
+
+// ObserveTokensPerSecond is a no-op implementation.
+func (m *NoopMetrics) ObserveTokensPerSecond(botName, serviceName string, tokensPerSecond float64) {
+	// No-op
+}
+
+// ObserveToolCallRoundTrip is a no-op implementation.
+func (m *NoopMetrics) ObserveToolCallRoundTrip(botName, serviceName string, elapsed float64) {
+	// No-op
+}
+
+// IncrementLLMErrors is a no-op implementation.
+func (m *NoopMetrics) IncrementLLMErrors(botName, serviceName, errorClass string) {
+	// No-op
+}
@@ -4,6 +4,7 @@
 package mmtools
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,7 +35,7 @@ func formatGithubIssue(issue *github.Issue) string {
 		issue.GetBody())
 }
 
-func (p *MMToolProvider) toolGetGithubIssue(context *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+func (p *MMToolProvider) toolGetGithubIssue(_ context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
 	var args GetGithubIssueArgs
 	err := argsGetter(&args)
 	if err != nil {
@@ -67,7 +68,7 @@ func (p *MMToolProvider) toolGetGithubIssue(context *llm.Context, argsGetter llm
 	if err != nil {
 		return "internal failure", fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("Mattermost-User-ID", context.RequestingUser.Id)
+	req.Header.Set("Mattermost-User-ID", llmContext.RequestingUser.Id)
 
 	resp := p.pluginAPI.PluginHTTP(req)
 	if resp == nil {
@@ -4,6 +4,7 @@
 package mmtools
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -156,7 +157,7 @@ func (p *MMToolProvider) getPublicJiraIssues(instanceURL string, issueKeys []str
 	return issues, nil
 }
 
-func (p *MMToolProvider) toolGetJiraIssue(context *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+func (p *MMToolProvider) toolGetJiraIssue(_ context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
 	var args GetJiraIssueArgs
 	err := argsGetter(&args)
 	if err != nil {
@@ -133,7 +133,7 @@ func TestMMToolProvider_toolSearchServer(t *testing.T) {
 			}
 
 			// Execute the tool
-			result, err := provider.toolSearchServer(llmContext, argsGetter)
+			result, err := provider.toolSearchServer(t.Context(), llmContext, argsGetter)
 
 			// Verify results
 			if test.expectError {
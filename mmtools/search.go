@@ -23,7 +23,7 @@ type SearchServerArgs struct {
 	Term string `jsonschema_description:"The terms to search for in the server. Must be more than 3 and less than 300 characters."`
 }
 
-func (p *MMToolProvider) toolSearchServer(llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
+func (p *MMToolProvider) toolSearchServer(ctx context.Context, llmContext *llm.Context, argsGetter llm.ToolArgumentGetter) (string, error) {
 	var args SearchServerArgs
 	err := argsGetter(&args)
 	if err != nil {
@@ -44,7 +44,6 @@ func (p *MMToolProvider) toolSearchServer(llmContext *llm.Context, argsGetter ll
 	}
 
 	// Perform the search
-	ctx := context.Background()
 	searchResults, err := p.search.Search(ctx, args.Term, embeddings.SearchOptions{
 		Limit:  10,
 		UserID: llmContext.RequestingUser.Id,
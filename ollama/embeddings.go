@@ -0,0 +1,86 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// EmbeddingConfig is the configuration of the Ollama embedding provider.
+type EmbeddingConfig struct {
+	APIURL              string `json:"apiURL"`
+	APIKey              string `json:"apiKey"`
+	EmbeddingModel      string `json:"embeddingModel"`
+	EmbeddingDimensions int    `json:"embeddingDimensions"`
+}
+
+// Embeddings generates embeddings with a model served by Ollama.
+type Embeddings struct {
+	client
+	model      string
+	dimensions int
+}
+
+func NewEmbeddings(config EmbeddingConfig, httpClient *http.Client) *Embeddings {
+	return &Embeddings{
+		client:     newClient(config.APIURL, config.APIKey, httpClient),
+		model:      config.EmbeddingModel,
+		dimensions: config.EmbeddingDimensions,
+	}
+}
+
+type embedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Truncate   bool     `json:"truncate"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// CreateEmbedding generates an embedding for the given text
+func (e *Embeddings) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := e.BatchCreateEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// BatchCreateEmbeddings generates embeddings for multiple texts in a single API call
+func (e *Embeddings) BatchCreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	var resp embedResponse
+	if err := e.doJSON(ctx, http.MethodPost, "/api/embed", embedRequest{
+		Model:      e.model,
+		Input:      texts,
+		Truncate:   true,
+		Dimensions: e.dimensions,
+	}, e.model, &resp); err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	for _, embedding := range resp.Embeddings {
+		// The vector store column has a fixed size, so a model with other dimensions can't be used
+		if e.dimensions > 0 && len(embedding) != e.dimensions {
+			return nil, fmt.Errorf("model %q returned embeddings with %d dimensions, expected %d", e.model, len(embedding), e.dimensions)
+		}
+	}
+
+	return resp.Embeddings, nil
+}
+
+func (e *Embeddings) Dimensions() int {
+	return e.dimensions
+}
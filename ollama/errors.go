@@ -0,0 +1,88 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// maxErrorBodySize bounds how much of an error response is read
+const maxErrorBodySize = 1 << 20
+
+// APIError is an error response from the Ollama API.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Header     http.Header
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ollama API error %d: %s", e.StatusCode, e.Message)
+}
+
+// ModelNotPulledError is returned when the requested model has not been pulled on the Ollama server.
+type ModelNotPulledError struct {
+	Model string
+	Err   *APIError
+}
+
+func (e *ModelNotPulledError) Error() string {
+	return fmt.Sprintf("model %q is not available on the Ollama server, pull it with `ollama pull %s`", e.Model, e.Model)
+}
+
+func (e *ModelNotPulledError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError reads the error from a non 200 response for a request using model.
+func newAPIError(resp *http.Response, model string) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read ollama error response: %w", err)
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(data)),
+		Header:     resp.Header,
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	}
+
+	if resp.StatusCode == http.StatusNotFound && model != "" && strings.Contains(apiErr.Message, "not found") {
+		return &ModelNotPulledError{Model: model, Err: apiErr}
+	}
+	return apiErr
+}
+
+// ClassifyError categorises errors returned by the Ollama API.
+func ClassifyError(err error) llm.ErrorClass {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return llm.ClassifyHTTPStatus(apiErr.StatusCode)
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by the Ollama server or a proxy in front of it, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return llm.ParseRetryAfter(apiErr.Header)
+	}
+	return 0, false
+}
@@ -0,0 +1,538 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package ollama implements llm.LanguageModel and embeddings.EmbeddingProvider using Ollama's native API.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
+)
+
+const (
+	DefaultAPIURL = "http://localhost:11434"
+	// DefaultContextLength is the context length Ollama uses when a model doesn't report one
+	DefaultContextLength = 4096
+	// MaxContextLength caps the context length of models trained with longer ones. Ollama
+	// allocates memory for the whole context, so larger ones have to be configured.
+	MaxContextLength = 32768
+
+	// contextLengthRetryInterval is how long a context length that couldn't be read isn't read again
+	contextLengthRetryInterval = time.Minute
+
+	// showTimeout bounds the request reading a model's details
+	showTimeout = 10 * time.Second
+	// maxLineSize bounds the size of a single line of a streamed response
+	maxLineSize = 10 * 1024 * 1024
+)
+
+// client holds the connection details shared by the chat and embedding APIs.
+type client struct {
+	httpClient *http.Client
+	apiURL     string
+	apiKey     string
+}
+
+func newClient(apiURL, apiKey string, httpClient *http.Client) client {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return client{
+		httpClient: httpClient,
+		apiURL:     apiURL,
+		apiKey:     apiKey,
+	}
+}
+
+// do sends a request to an API path. The caller must close the body of the response.
+// Non 200 responses are returned as errors; model names the model the request uses, if any.
+func (c client) do(ctx context.Context, method, path string, body any, model string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ollama request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Ollama doesn't require authentication, but proxies in front of it may
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send ollama request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp, model)
+	}
+	return resp, nil
+}
+
+// doJSON sends a request to an API path and decodes the response into out.
+func (c client) doJSON(ctx context.Context, method, path string, body any, model string, out any) error {
+	resp, err := c.do(ctx, method, path, body, model)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	return nil
+}
+
+type Ollama struct {
+	client
+	defaultModel     string
+	inputTokenLimit  int
+	outputTokenLimit int
+	reasoningEnabled bool
+
+	contextLengthsLock sync.Mutex
+	contextLengths     map[string]contextLength
+}
+
+// contextLength is the context length used for a model, until expires if it couldn't be read.
+type contextLength struct {
+	length  int
+	expires time.Time
+}
+
+func New(llmService llm.ServiceConfig, botConfig llm.BotConfig, httpClient *http.Client) *Ollama {
+	return &Ollama{
+		client:           newClient(llmService.APIURL, llmService.APIKey, httpClient),
+		defaultModel:     llmService.DefaultModel,
+		inputTokenLimit:  llmService.InputTokenLimit,
+		outputTokenLimit: llmService.OutputTokenLimit,
+		reasoningEnabled: botConfig.ReasoningEnabled,
+		contextLengths:   make(map[string]contextLength),
+	}
+}
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type tool struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []message      `json:"messages"`
+	Tools    []tool         `json:"tools,omitempty"`
+	Stream   bool           `json:"stream"`
+	Think    *bool          `json:"think,omitempty"`
+	Format   any            `json:"format,omitempty"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+type chatResponse struct {
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int64   `json:"prompt_eval_count"`
+	EvalCount       int64   `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+// conversationToMessages converts conversation posts to chat messages. Tool results
+// follow the assistant message that called the tools as messages with the tool role.
+func conversationToMessages(posts []llm.Post) []message {
+	messages := make([]message, 0, len(posts))
+	for _, post := range posts {
+		switch post.Role {
+		case llm.PostRoleSystem:
+			messages = append(messages, message{Role: "system", Content: post.Message})
+		case llm.PostRoleUser:
+			msg := message{Role: "user", Content: post.Message}
+			for _, file := range post.Files {
+				if !strings.HasPrefix(file.MimeType, "image/") {
+					msg.Content += fmt.Sprintf("\n[Unsupported file type: %s]", file.MimeType)
+					continue
+				}
+				data, err := io.ReadAll(file.Reader)
+				if err != nil {
+					msg.Content += "\n[Error reading image data]"
+					continue
+				}
+				msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(data))
+			}
+			messages = append(messages, msg)
+		case llm.PostRoleBot:
+			msg := message{Role: "assistant", Content: post.Message}
+			for _, call := range post.ToolUse {
+				converted := toolCall{}
+				converted.Function.Name = call.Name
+				converted.Function.Arguments = call.Arguments
+				if len(converted.Function.Arguments) == 0 {
+					converted.Function.Arguments = json.RawMessage("{}")
+				}
+				msg.ToolCalls = append(msg.ToolCalls, converted)
+			}
+			messages = append(messages, msg)
+
+			for _, call := range post.ToolUse {
+				messages = append(messages, message{Role: "tool", Content: call.Result, ToolName: call.Name})
+			}
+		}
+	}
+	return messages
+}
+
+// convertTools converts from llm.Tool to Ollama's function tools
+func convertTools(tools []llm.Tool) []tool {
+	converted := make([]tool, 0, len(tools))
+	for _, t := range tools {
+		converted = append(converted, tool{
+			Type: "function",
+			Function: toolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Schema,
+			},
+		})
+	}
+	return converted
+}
+
+func (o *Ollama) GetDefaultConfig() llm.LanguageModelConfig {
+	return llm.LanguageModelConfig{
+		Model:              o.defaultModel,
+		MaxGeneratedTokens: o.outputTokenLimit,
+	}
+}
+
+func (o *Ollama) createConfig(opts []llm.LanguageModelOption) llm.LanguageModelConfig {
+	cfg := o.GetDefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (o *Ollama) buildRequest(ctx context.Context, request llm.CompletionRequest, cfg llm.LanguageModelConfig) chatRequest {
+	result := chatRequest{
+		Model:    cfg.Model,
+		Messages: conversationToMessages(request.Posts),
+		Stream:   true,
+		Options: map[string]any{
+			// Ollama silently truncates prompts to a small default context unless told otherwise
+			"num_ctx": o.contextLengthFor(ctx, cfg.Model),
+		},
+	}
+
+	if cfg.MaxGeneratedTokens > 0 {
+		result.Options["num_predict"] = cfg.MaxGeneratedTokens
+	}
+
+	if !cfg.ToolsDisabled && request.Context != nil && request.Context.Tools != nil {
+		result.Tools = convertTools(request.Context.Tools.GetTools())
+	}
+
+	if cfg.JSONOutputFormat != nil {
+		result.Format = cfg.JSONOutputFormat
+	}
+
+	// Only models that support thinking accept the flag, so it is only sent when reasoning is enabled
+	if o.reasoningEnabled {
+		think := !cfg.ReasoningDisabled
+		result.Think = &think
+	}
+
+	return result
+}
+
+func (o *Ollama) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+	cfg := o.createConfig(opts)
+
+	resp, err := o.do(ctx, http.MethodPost, "/api/chat", o.buildRequest(ctx, request, cfg), cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	eventStream := make(chan llm.TextStreamEvent)
+	go func() {
+		defer close(eventStream)
+		defer resp.Body.Close()
+		readStream(ctx, resp.Body, eventStream)
+	}()
+
+	return &llm.TextStreamResult{Stream: eventStream}, nil
+}
+
+func (o *Ollama) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+	result, err := o.ChatCompletion(ctx, request, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.ReadAll()
+}
+
+// readStream converts the newline delimited JSON of a streamed chat response to stream events.
+func readStream(ctx context.Context, body io.Reader, output chan<- llm.TextStreamEvent) {
+	sendError := func(err error) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeError,
+			Value: fmt.Errorf("error from ollama stream: %w", err),
+		}
+	}
+
+	var thinking strings.Builder
+	reasoningSent := false
+	sendReasoning := func() {
+		if reasoningSent || thinking.Len() == 0 {
+			return
+		}
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeReasoningEnd,
+			Value: llm.ReasoningData{Text: thinking.String()},
+		}
+		reasoningSent = true
+	}
+
+	var toolCalls []llm.ToolCall
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk chatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			sendError(fmt.Errorf("failed to parse response: %w", err))
+			return
+		}
+		if chunk.Error != "" {
+			sendError(&APIError{StatusCode: http.StatusInternalServerError, Message: chunk.Error})
+			return
+		}
+
+		if chunk.Message.Thinking != "" {
+			thinking.WriteString(chunk.Message.Thinking)
+			output <- llm.TextStreamEvent{
+				Type:  llm.EventTypeReasoning,
+				Value: chunk.Message.Thinking,
+			}
+		}
+
+		if chunk.Message.Content != "" {
+			sendReasoning()
+			output <- llm.TextStreamEvent{
+				Type:  llm.EventTypeText,
+				Value: chunk.Message.Content,
+			}
+		}
+
+		for _, call := range chunk.Message.ToolCalls {
+			args := call.Function.Arguments
+			if len(args) == 0 || string(args) == "null" {
+				args = json.RawMessage("{}")
+			}
+			toolCalls = append(toolCalls, llm.ToolCall{
+				ID:        model.NewId(),
+				Name:      call.Function.Name,
+				Arguments: args,
+			})
+		}
+
+		if !chunk.Done {
+			continue
+		}
+
+		sendReasoning()
+
+		if len(toolCalls) > 0 {
+			output <- llm.TextStreamEvent{
+				Type:  llm.EventTypeToolCalls,
+				Value: toolCalls,
+			}
+		}
+
+		output <- llm.TextStreamEvent{
+			Type: llm.EventTypeUsage,
+			Value: llm.TokenUsage{
+				InputTokens:  chunk.PromptEvalCount,
+				OutputTokens: chunk.EvalCount,
+			},
+		}
+
+		output <- llm.TextStreamEvent{
+			Type:  llm.EventTypeEnd,
+			Value: nil,
+		}
+		return
+	}
+
+	if err := scanner.Err(); err != nil {
+		sendError(err)
+		return
+	}
+	sendError(io.ErrUnexpectedEOF)
+}
+
+func (o *Ollama) CountTokens(text string) int {
+	return tokenizer.ForModel(o.defaultModel).CountTokens(text)
+}
+
+// InputTokenLimit returns the configured token limit or the context length of the default model.
+func (o *Ollama) InputTokenLimit() int {
+	ctx, cancel := context.WithTimeout(context.Background(), showTimeout)
+	defer cancel()
+	return o.contextLengthFor(ctx, o.defaultModel)
+}
+
+// contextLengthFor returns the configured token limit or the context length of a model, capped
+// at MaxContextLength unless set in the model's Modelfile. Context lengths are cached once read.
+// If they can't be read the Ollama default is used, and they aren't read again for a while so
+// requests don't wait on an unreachable server.
+func (o *Ollama) contextLengthFor(ctx context.Context, model string) int {
+	if o.inputTokenLimit > 0 {
+		return o.inputTokenLimit
+	}
+
+	o.contextLengthsLock.Lock()
+	cached, ok := o.contextLengths[model]
+	o.contextLengthsLock.Unlock()
+	if ok && (cached.expires.IsZero() || time.Now().Before(cached.expires)) {
+		return cached.length
+	}
+
+	length, fromModelfile, err := o.readContextLength(ctx, model)
+	switch {
+	case err != nil:
+		cached = contextLength{length: DefaultContextLength, expires: time.Now().Add(contextLengthRetryInterval)}
+	case fromModelfile:
+		cached = contextLength{length: length}
+	default:
+		cached = contextLength{length: min(length, MaxContextLength)}
+	}
+
+	o.contextLengthsLock.Lock()
+	o.contextLengths[model] = cached
+	o.contextLengthsLock.Unlock()
+	return cached.length
+}
+
+type showResponse struct {
+	Parameters string         `json:"parameters"`
+	ModelInfo  map[string]any `json:"model_info"`
+}
+
+// ContextLength reads the context length of a model. A num_ctx parameter set in the model's
+// Modelfile takes precedence over the context length the model was trained with.
+func (o *Ollama) ContextLength(ctx context.Context, model string) (int, error) {
+	length, _, err := o.readContextLength(ctx, model)
+	return length, err
+}
+
+// readContextLength reads the context length of a model, and whether it was set in its Modelfile.
+func (o *Ollama) readContextLength(ctx context.Context, model string) (int, bool, error) {
+	var show showResponse
+	if err := o.doJSON(ctx, http.MethodPost, "/api/show", map[string]string{"model": model}, model, &show); err != nil {
+		return 0, false, err
+	}
+
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if numCtx, err := strconv.Atoi(fields[1]); err == nil && numCtx > 0 {
+				return numCtx, true, nil
+			}
+		}
+	}
+
+	if architecture, ok := show.ModelInfo["general.architecture"].(string); ok {
+		if length, ok := show.ModelInfo[architecture+".context_length"].(float64); ok && length > 0 {
+			return int(length), false, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf("model %q does not report a context length", model)
+}
+
+type tagsResponse struct {
+	Models []struct {
+		Name    string `json:"name"`
+		Details struct {
+			ParameterSize     string `json:"parameter_size"`
+			QuantizationLevel string `json:"quantization_level"`
+		} `json:"details"`
+	} `json:"models"`
+}
+
+// FetchModels retrieves the list of models pulled on the Ollama server
+func FetchModels(apiURL string, apiKey string, httpClient *http.Client) ([]llm.ModelInfo, error) {
+	var tags tagsResponse
+	if err := newClient(apiURL, apiKey, httpClient).doJSON(context.Background(), http.MethodGet, "/api/tags", nil, "", &tags); err != nil {
+		return nil, err
+	}
+
+	models := make([]llm.ModelInfo, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		displayName := m.Name
+		var details []string
+		for _, detail := range []string{m.Details.ParameterSize, m.Details.QuantizationLevel} {
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+		if len(details) > 0 {
+			displayName += " (" + strings.Join(details, ", ") + ")"
+		}
+		models = append(models, llm.ModelInfo{
+			ID:          m.Name,
+			DisplayName: displayName,
+		})
+	}
+	return models, nil
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOllama serves /api/show with the given model details and /api/chat with the given lines.
+func fakeOllama(t *testing.T, show string, lines []string, captured *chatRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			_, _ = w.Write([]byte(show))
+		case "/api/chat":
+			if captured != nil {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(captured))
+			}
+			for _, line := range lines {
+				fmt.Fprintln(w, line)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newTestOllama(serverURL string, botConfig llm.BotConfig) *Ollama {
+	return New(llm.ServiceConfig{
+		APIURL:       serverURL,
+		DefaultModel: "qwen3:8b",
+	}, botConfig, http.DefaultClient)
+}
+
+func TestConversationToMessages(t *testing.T) {
+	messages := conversationToMessages([]llm.Post{
+		{Role: llm.PostRoleSystem, Message: "You are helpful"},
+		{Role: llm.PostRoleUser, Message: "What is this?", Files: []llm.File{
+			{MimeType: "image/png", Reader: bytes.NewReader([]byte("png"))},
+			{MimeType: "application/pdf", Reader: bytes.NewReader([]byte("pdf"))},
+		}},
+		{Role: llm.PostRoleBot, Message: "Let me check", ToolUse: []llm.ToolCall{
+			{ID: "1", Name: "lookup", Arguments: json.RawMessage(`{"q":"png"}`), Result: "An image", Status: llm.ToolCallStatusSuccess},
+		}},
+	})
+
+	require.Len(t, messages, 4)
+	assert.Equal(t, message{Role: "system", Content: "You are helpful"}, messages[0])
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Equal(t, []string{"cG5n"}, messages[1].Images)
+	assert.Contains(t, messages[1].Content, "[Unsupported file type: application/pdf]")
+	assert.Equal(t, "assistant", messages[2].Role)
+	require.Len(t, messages[2].ToolCalls, 1)
+	assert.Equal(t, "lookup", messages[2].ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"q":"png"}`, string(messages[2].ToolCalls[0].Function.Arguments))
+	assert.Equal(t, message{Role: "tool", Content: "An image", ToolName: "lookup"}, messages[3])
+}
+
+func TestChatCompletion(t *testing.T) {
+	t.Run("streams reasoning, text, tool calls and usage", func(t *testing.T) {
+		var captured chatRequest
+		server := fakeOllama(t, `{"model_info":{"general.architecture":"qwen3","qwen3.context_length":40960}}`, []string{
+			`{"message":{"role":"assistant","content":"","thinking":"Hmm"}}`,
+			`{"message":{"role":"assistant","content":"Hello"}}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"lookup","arguments":{"q":"x"}}}]}}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":7}`,
+		}, &captured)
+		defer server.Close()
+
+		o := newTestOllama(server.URL, llm.BotConfig{ReasoningEnabled: true})
+		result, err := o.ChatCompletion(t.Context(), llm.CompletionRequest{
+			Posts:   []llm.Post{{Role: llm.PostRoleUser, Message: "Hi"}},
+			Context: llm.NewContext(),
+		})
+		require.NoError(t, err)
+
+		var events []llm.TextStreamEvent
+		for event := range result.Stream {
+			events = append(events, event)
+		}
+
+		assert.Equal(t, "qwen3:8b", captured.Model)
+		assert.True(t, captured.Stream)
+		require.NotNil(t, captured.Think)
+		assert.True(t, *captured.Think)
+		assert.EqualValues(t, MaxContextLength, captured.Options["num_ctx"], "long trained context lengths are capped")
+
+		require.Len(t, events, 6)
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoning, Value: "Hmm"}, events[0])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeReasoningEnd, Value: llm.ReasoningData{Text: "Hmm"}}, events[1])
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeText, Value: "Hello"}, events[2])
+		toolCalls := events[3].Value.([]llm.ToolCall)
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "lookup", toolCalls[0].Name)
+		assert.NotEmpty(t, toolCalls[0].ID)
+		assert.JSONEq(t, `{"q":"x"}`, string(toolCalls[0].Arguments))
+		assert.Equal(t, llm.TextStreamEvent{Type: llm.EventTypeUsage, Value: llm.TokenUsage{InputTokens: 12, OutputTokens: 7}}, events[4])
+		assert.Equal(t, llm.EventTypeEnd, events[5].Type)
+	})
+
+	t.Run("reasoning can be disabled per request", func(t *testing.T) {
+		var captured chatRequest
+		server := fakeOllama(t, `{}`, []string{`{"message":{"content":"Hi"},"done":true}`}, &captured)
+		defer server.Close()
+
+		text, err := newTestOllama(server.URL, llm.BotConfig{ReasoningEnabled: true}).ChatCompletionNoStream(t.Context(), llm.CompletionRequest{Context: llm.NewContext()}, llm.WithReasoningDisabled())
+		require.NoError(t, err)
+		assert.Equal(t, "Hi", text)
+		require.NotNil(t, captured.Think)
+		assert.False(t, *captured.Think)
+		assert.EqualValues(t, DefaultContextLength, captured.Options["num_ctx"])
+	})
+
+	t.Run("errors in the stream are reported", func(t *testing.T) {
+		server := fakeOllama(t, `{}`, []string{`{"error":"out of memory"}`}, nil)
+		defer server.Close()
+
+		_, err := newTestOllama(server.URL, llm.BotConfig{}).ChatCompletionNoStream(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		assert.ErrorContains(t, err, "out of memory")
+	})
+
+	t.Run("a truncated stream is an error", func(t *testing.T) {
+		server := fakeOllama(t, `{}`, []string{`{"message":{"content":"Hi"}}`}, nil)
+		defer server.Close()
+
+		_, err := newTestOllama(server.URL, llm.BotConfig{}).ChatCompletionNoStream(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		assert.Error(t, err)
+	})
+
+	t.Run("models that are not pulled are reported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"model \"qwen3:8b\" not found, try pulling it first"}`))
+		}))
+		defer server.Close()
+
+		_, err := newTestOllama(server.URL, llm.BotConfig{}).ChatCompletion(t.Context(), llm.CompletionRequest{Context: llm.NewContext()})
+		var notPulled *ModelNotPulledError
+		require.ErrorAs(t, err, &notPulled)
+		assert.Equal(t, "qwen3:8b", notPulled.Model)
+		assert.Equal(t, llm.ErrorClassInvalidRequest, ClassifyError(err))
+	})
+}
+
+func TestContextLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		show     string
+		expected int
+		wantErr  bool
+	}{
+		{
+			name:     "trained context length",
+			show:     `{"model_info":{"general.architecture":"llama","llama.context_length":131072}}`,
+			expected: 131072,
+		},
+		{
+			name:     "num_ctx from the Modelfile wins",
+			show:     `{"parameters":"stop \"<|eot_id|>\"\nnum_ctx                        8192","model_info":{"general.architecture":"llama","llama.context_length":131072}}`,
+			expected: 8192,
+		},
+		{
+			name:    "unknown context length",
+			show:    `{"model_info":{}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeOllama(t, tt.show, nil, nil)
+			defer server.Close()
+
+			length, err := newTestOllama(server.URL, llm.BotConfig{}).ContextLength(t.Context(), "llama3.1")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, length)
+		})
+	}
+
+	t.Run("context lengths set in the Modelfile aren't capped", func(t *testing.T) {
+		server := fakeOllama(t, `{"parameters":"num_ctx 65536","model_info":{"general.architecture":"llama","llama.context_length":131072}}`, nil, nil)
+		defer server.Close()
+
+		assert.Equal(t, 65536, newTestOllama(server.URL, llm.BotConfig{}).InputTokenLimit())
+	})
+
+	t.Run("context lengths that can't be read aren't read again right away", func(t *testing.T) {
+		var shows atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			shows.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		o := newTestOllama(server.URL, llm.BotConfig{})
+		assert.Equal(t, DefaultContextLength, o.InputTokenLimit())
+		assert.Equal(t, DefaultContextLength, o.InputTokenLimit())
+		assert.Equal(t, int32(1), shows.Load())
+	})
+
+	t.Run("configured token limit takes precedence", func(t *testing.T) {
+		o := New(llm.ServiceConfig{APIURL: "http://127.0.0.1:0", InputTokenLimit: 2048}, llm.BotConfig{}, http.DefaultClient)
+		assert.Equal(t, 2048, o.InputTokenLimit())
+	})
+}
+
+func TestFetchModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama3.1:8b","details":{"parameter_size":"8.0B","quantization_level":"Q4_K_M"}},{"name":"nomic-embed-text:latest"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := FetchModels(server.URL, "secret", http.DefaultClient)
+	require.NoError(t, err)
+	assert.Equal(t, []llm.ModelInfo{
+		{ID: "llama3.1:8b", DisplayName: "llama3.1:8b (8.0B, Q4_K_M)"},
+		{ID: "nomic-embed-text:latest", DisplayName: "nomic-embed-text:latest"},
+	}, models)
+}
+
+func TestEmbeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		var req embedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+
+		embeddings := make([][]float32, len(req.Input))
+		for i := range embeddings {
+			embeddings[i] = []float32{float32(i), 1, 2}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(embedResponse{Embeddings: embeddings}))
+	}))
+	defer server.Close()
+
+	provider := NewEmbeddings(EmbeddingConfig{APIURL: server.URL, EmbeddingModel: "nomic-embed-text", EmbeddingDimensions: 3}, http.DefaultClient)
+	assert.Equal(t, 3, provider.Dimensions())
+
+	embeddings, err := provider.BatchCreateEmbeddings(t.Context(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0, 1, 2}, {1, 1, 2}}, embeddings)
+
+	embedding, err := provider.CreateEmbedding(t.Context(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0, 1, 2}, embedding)
+
+	mismatched := NewEmbeddings(EmbeddingConfig{APIURL: server.URL, EmbeddingModel: "nomic-embed-text", EmbeddingDimensions: 768}, http.DefaultClient)
+	_, err = mismatched.CreateEmbedding(t.Context(), "a")
+	assert.ErrorContains(t, err, "3 dimensions, expected 768")
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package openai
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/openai/openai-go/v2"
+)
+
+// ClassifyError categorises errors returned by the OpenAI SDK and this package.
+func ClassifyError(err error) llm.ErrorClass {
+	if errors.Is(err, ErrStreamingTimeout) {
+		return llm.ErrorClassTimeout
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return llm.ClassifyHTTPStatus(apiErr.StatusCode)
+	}
+
+	return llm.ClassifyError(err)
+}
+
+// RetryAfter returns the delay requested by the OpenAI API in a rate limit response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return llm.ParseRetryAfter(apiErr.Response.Header)
+	}
+	return 0, false
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/subtitles"
+	"github.com/mattermost/mattermost-plugin-ai/tokenizer"
 	"github.com/openai/openai-go/v2"
 	"github.com/openai/openai-go/v2/azure"
 	"github.com/openai/openai-go/v2/option"
@@ -322,18 +323,18 @@ type ToolBufferElement struct {
 	args strings.Builder
 }
 
-func (s *OpenAI) streamResultToChannels(params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig, output chan<- llm.TextStreamEvent) {
+func (s *OpenAI) streamResultToChannels(ctx context.Context, params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig, output chan<- llm.TextStreamEvent) {
 	// Route to Responses API or Completions API based on configuration
 	if s.config.UseResponsesAPI {
-		s.streamResponsesAPIToChannels(params, llmContext, cfg, output)
+		s.streamResponsesAPIToChannels(ctx, params, llmContext, cfg, output)
 	} else {
-		s.streamCompletionsAPIToChannels(params, llmContext, output)
+		s.streamCompletionsAPIToChannels(ctx, params, llmContext, output)
 	}
 }
 
 // streamCompletionsAPIToChannels uses the original Completions API for streaming
-func (s *OpenAI) streamCompletionsAPIToChannels(params openai.ChatCompletionNewParams, llmContext *llm.Context, output chan<- llm.TextStreamEvent) {
-	ctx, cancel := context.WithCancelCause(context.Background())
+func (s *OpenAI) streamCompletionsAPIToChannels(ctx context.Context, params openai.ChatCompletionNewParams, llmContext *llm.Context, output chan<- llm.TextStreamEvent) {
+	ctx, cancel := context.WithCancelCause(ctx)
 	defer cancel(nil)
 
 	// watchdog to cancel if the streaming stalls
@@ -357,7 +358,8 @@ func (s *OpenAI) streamCompletionsAPIToChannels(params openai.ChatCompletionNewP
 		}
 	}()
 
-	stream := s.client.Chat.Completions.NewStreaming(ctx, params)
+	// Retries are handled by llm.RetryWrapper so they can honour the overall streaming budget
+	stream := s.client.Chat.Completions.NewStreaming(ctx, params, option.WithMaxRetries(0))
 	defer stream.Close()
 
 	// Buffering in the case of tool use
@@ -488,8 +490,8 @@ func (s *OpenAI) streamCompletionsAPIToChannels(params openai.ChatCompletionNewP
 }
 
 // streamResponsesAPIToChannels uses the new Responses API for streaming
-func (s *OpenAI) streamResponsesAPIToChannels(params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig, output chan<- llm.TextStreamEvent) {
-	ctx, cancel := context.WithCancelCause(context.Background())
+func (s *OpenAI) streamResponsesAPIToChannels(ctx context.Context, params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig, output chan<- llm.TextStreamEvent) {
+	ctx, cancel := context.WithCancelCause(ctx)
 	defer cancel(nil)
 
 	// watchdog to cancel if the streaming stalls
@@ -517,7 +519,8 @@ func (s *OpenAI) streamResponsesAPIToChannels(params openai.ChatCompletionNewPar
 	responseParams := s.convertToResponseParams(params, llmContext, cfg)
 
 	// Create a streaming request
-	stream := s.client.Responses.NewStreaming(ctx, responseParams)
+	// Retries are handled by llm.RetryWrapper so they can honour the overall streaming budget
+	stream := s.client.Responses.NewStreaming(ctx, responseParams, option.WithMaxRetries(0))
 	defer stream.Close()
 
 	// Buffering in the case of tool use
@@ -1053,11 +1056,11 @@ func (s *OpenAI) convertToResponseParams(params openai.ChatCompletionNewParams,
 	return result
 }
 
-func (s *OpenAI) streamResult(params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig) (*llm.TextStreamResult, error) {
+func (s *OpenAI) streamResult(ctx context.Context, params openai.ChatCompletionNewParams, llmContext *llm.Context, cfg llm.LanguageModelConfig) (*llm.TextStreamResult, error) {
 	eventStream := make(chan llm.TextStreamEvent)
 	go func() {
 		defer close(eventStream)
-		s.streamResultToChannels(params, llmContext, cfg, eventStream)
+		s.streamResultToChannels(ctx, params, llmContext, cfg, eventStream)
 	}()
 
 	return &llm.TextStreamResult{Stream: eventStream}, nil
@@ -1132,7 +1135,7 @@ func getModelConstant(model string) shared.ChatModel {
 	}
 }
 
-func (s *OpenAI) ChatCompletion(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
+func (s *OpenAI) ChatCompletion(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (*llm.TextStreamResult, error) {
 	cfg := s.createConfig(opts)
 	params := s.completionRequestFromConfig(cfg)
 	params = modifyCompletionRequestWithRequest(params, request, cfg)
@@ -1147,12 +1150,12 @@ func (s *OpenAI) ChatCompletion(request llm.CompletionRequest, opts ...llm.Langu
 			params.User = openai.String(request.Context.RequestingUser.Id)
 		}
 	}
-	return s.streamResult(params, request.Context, cfg)
+	return s.streamResult(ctx, params, request.Context, cfg)
 }
 
-func (s *OpenAI) ChatCompletionNoStream(request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
+func (s *OpenAI) ChatCompletionNoStream(ctx context.Context, request llm.CompletionRequest, opts ...llm.LanguageModelOption) (string, error) {
 	// This could perform better if we didn't use the streaming API here, but the complexity is not worth it.
-	result, err := s.ChatCompletion(request, opts...)
+	result, err := s.ChatCompletion(ctx, request, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -1217,12 +1220,7 @@ func (s *OpenAI) GenerateImage(prompt string) (image.Image, error) {
 }
 
 func (s *OpenAI) CountTokens(text string) int {
-	// Counting tokens is really annoying, so we approximate for now.
-	charCount := float64(len(text)) / 4.0
-	wordCount := float64(len(strings.Fields(text))) / 0.75
-
-	// Average the two
-	return int((charCount + wordCount) / 2.0)
+	return tokenizer.ForModel(s.config.DefaultModel).CountTokens(text)
 }
 
 func (s *OpenAI) InputTokenLimit() int {
@@ -5,6 +5,8 @@ package prompts
 
 // Automatically generated convenience vars for the filenames in prompts/
 const (
+	PromptAgentRouterSystem                = "agent_router_system"
+	PromptAutoResponseClassifySystem       = "auto_response_classify_system"
 	PromptDirectMessageQuestionSystem      = "direct_message_question_system"
 	PromptEmojiSelectSystem                = "emoji_select_system"
 	PromptFindActionItemsSystem            = "find_action_items_system"
@@ -6,6 +6,8 @@
 package bridgeclient
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 )
 
@@ -47,6 +49,9 @@ type CompletionRequest struct {
 	// ChannelID is the optional Mattermost channel ID context for the request.
 	// If provided along with UserID, the bridge will check both user and channel permissions.
 	ChannelID string `json:"channel_id,omitempty"`
+	// DisableCache prevents a non-streaming request from being answered from the response cache.
+	// Set it when the response must be generated fresh, for example when sampling several answers.
+	DisableCache bool `json:"disable_cache,omitempty"`
 }
 
 // CompletionResponse represents a non-streaming completion response
@@ -54,9 +59,26 @@ type CompletionResponse struct {
 	Completion string `json:"completion"`
 }
 
+// ErrorCodeJSONValidation is the code of errors for responses that don't match the requested JSONOutputFormat
+const ErrorCodeJSONValidation = "json_validation_error"
+
+// ErrJSONValidation is wrapped by the errors of requests whose response doesn't match the
+// requested JSONOutputFormat, even after the model was asked to correct it
+var ErrJSONValidation = errors.New("response does not match the JSON output format")
+
 // ErrorResponse represents an error response from the API
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code identifies the kind of error, such as ErrorCodeJSONValidation
+	Code string `json:"code,omitempty"`
+}
+
+// err returns the error described by the response.
+func (r ErrorResponse) err() error {
+	if r.Code == ErrorCodeJSONValidation {
+		return fmt.Errorf("%w: %s", ErrJSONValidation, r.Error)
+	}
+	return errors.New(r.Error)
 }
 
 // BridgeAgentInfo represents basic agent information from the bridge API
@@ -7,6 +7,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -94,7 +95,7 @@ func (c *Client) doCompletionRequest(url string, request CompletionRequest) (str
 		if err := json.Unmarshal(respBody, &errResp); err != nil {
 			return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
 		}
-		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, errResp.Error)
+		return "", fmt.Errorf("request failed with status %d: %w", resp.StatusCode, errResp.err())
 	}
 
 	// Parse the success response
@@ -148,7 +149,7 @@ func (c *Client) doStreamingRequest(url string, request CompletionRequest) (*llm
 		if err := json.Unmarshal(respBody, &errResp); err != nil {
 			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
 		}
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, errResp.Error)
+		return nil, fmt.Errorf("request failed with status %d: %w", resp.StatusCode, errResp.err())
 	}
 
 	// Create a channel for the stream
@@ -187,6 +188,11 @@ func (c *Client) doStreamingRequest(url string, request CompletionRequest) (*llm
 				return
 			}
 
+			// Error values are sent as an ErrorResponse or a message
+			if event.Type == llm.EventTypeError {
+				event.Value = streamEventError(event.Value)
+			}
+
 			// Send the event to the channel
 			stream <- event
 
@@ -211,3 +217,17 @@ func (c *Client) doStreamingRequest(url string, request CompletionRequest) (*llm
 		Stream: stream,
 	}, nil
 }
+
+// streamEventError returns the error described by the value of a decoded error event.
+func streamEventError(value any) error {
+	switch v := value.(type) {
+	case string:
+		return errors.New(v)
+	case map[string]any:
+		message, _ := v["error"].(string)
+		code, _ := v["code"].(string)
+		return ErrorResponse{Error: message, Code: code}.err()
+	default:
+		return errors.New("unknown error")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package quota accumulates token usage and enforces the daily and monthly token limits
+// admins configure per bot, team and user.
+package quota
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+)
+
+type Scope string
+
+const (
+	ScopeBot  Scope = "bot"
+	ScopeTeam Scope = "team"
+	ScopeUser Scope = "user"
+)
+
+type Period string
+
+const (
+	PeriodDaily   Period = "daily"
+	PeriodMonthly Period = "monthly"
+)
+
+// Start returns the start of the period containing now, in UTC.
+func (p Period) Start(now time.Time) time.Time {
+	now = now.UTC()
+	if p == PeriodMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// End returns the start of the period following the one containing now, in UTC.
+func (p Period) End(now time.Time) time.Time {
+	if p == PeriodMonthly {
+		return p.Start(now).AddDate(0, 1, 0)
+	}
+	return p.Start(now).AddDate(0, 0, 1)
+}
+
+// Limit is a token quota configured by an admin.
+type Limit struct {
+	Scope Scope `json:"scope"`
+	// ID is the bot name, team ID or user ID the limit applies to.
+	// An empty ID applies the limit to each bot, team or user that doesn't have a limit of its own.
+	ID     string `json:"id"`
+	Period Period `json:"period"`
+	// InputTokens and OutputTokens are the maximum tokens per period, 0 means unlimited
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+}
+
+type Config interface {
+	GetTokenQuotas() []Limit
+}
+
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// exceeds reports whether usage has reached either of the limit's token counts.
+func (u Usage) exceeds(limit Limit) bool {
+	return (limit.InputTokens > 0 && u.InputTokens >= limit.InputTokens) ||
+		(limit.OutputTokens > 0 && u.OutputTokens >= limit.OutputTokens)
+}
+
+// ScopeUsage is the usage of a single bot, team or user.
+type ScopeUsage struct {
+	ID string `json:"id"`
+	Usage
+}
+
+// PeriodUsage is a user's usage for the current period along with their limits.
+type PeriodUsage struct {
+	Period Period `json:"period"`
+	Usage
+	InputTokenLimit  int64     `json:"input_token_limit"`
+	OutputTokenLimit int64     `json:"output_token_limit"`
+	ResetsAt         time.Time `json:"resets_at"`
+}
+
+// store persists usage aggregated per user, team, bot and day.
+type store interface {
+	addUsage(day time.Time, userID, teamID, botName string, usage Usage) error
+	getUsage(scope Scope, id string, since time.Time) (Usage, error)
+	getUsageByScope(scope Scope, since, until time.Time) ([]ScopeUsage, error)
+}
+
+// subject identifies who a request is made for in each scope.
+type subject map[Scope]string
+
+func subjectFromContext(botName string, context *llm.Context) subject {
+	s := subject{ScopeBot: botName}
+	if context == nil {
+		return s
+	}
+	if context.RequestingUser != nil {
+		s[ScopeUser] = context.RequestingUser.Id
+	}
+	if context.Team != nil {
+		s[ScopeTeam] = context.Team.Id
+	} else if context.Channel != nil {
+		s[ScopeTeam] = context.Channel.TeamId
+	}
+	return s
+}
+
+// applicableLimits returns the limits that apply to s. A limit for a specific ID replaces
+// the default limit for the same scope and period.
+func applicableLimits(limits []Limit, s subject) []Limit {
+	type key struct {
+		scope  Scope
+		period Period
+	}
+	selected := map[key]Limit{}
+	var order []key
+	for _, limit := range limits {
+		id := s[limit.Scope]
+		if id == "" || (limit.ID != "" && limit.ID != id) {
+			continue
+		}
+		k := key{limit.Scope, limit.Period}
+		existing, ok := selected[k]
+		if !ok {
+			order = append(order, k)
+		} else if existing.ID != "" && limit.ID == "" {
+			continue
+		}
+		selected[k] = limit
+	}
+
+	result := make([]Limit, 0, len(order))
+	for _, k := range order {
+		result = append(result, selected[k])
+	}
+	return result
+}
+
+// Service enforces token quotas. It implements llm.QuotaEnforcer.
+type Service struct {
+	store  store
+	config Config
+	log    llm.Logger
+	now    func() time.Time
+}
+
+func New(db *mmapi.DBClient, config Config, log llm.Logger) *Service {
+	return &Service{
+		store:  &dbStore{db: db},
+		config: config,
+		log:    log,
+		now:    time.Now,
+	}
+}
+
+// CheckQuota returns a *llm.QuotaExceededError if any quota that applies to the request has been used up.
+// Errors reading usage are logged and the request is allowed.
+func (s *Service) CheckQuota(botName string, context *llm.Context) error {
+	subj := subjectFromContext(botName, context)
+	now := s.now()
+	for _, limit := range applicableLimits(s.config.GetTokenQuotas(), subj) {
+		id := subj[limit.Scope]
+		used, err := s.store.getUsage(limit.Scope, id, limit.Period.Start(now))
+		if err != nil {
+			s.log.Warn("Failed to get token usage for quota check", "scope", string(limit.Scope), "id", id, "error", err.Error())
+			continue
+		}
+		if used.exceeds(limit) {
+			return &llm.QuotaExceededError{
+				Scope:   string(limit.Scope),
+				ScopeID: id,
+				Period:  string(limit.Period),
+				ResetAt: limit.Period.End(now),
+			}
+		}
+	}
+	return nil
+}
+
+// RecordUsage adds the tokens used by a request to today's usage.
+func (s *Service) RecordUsage(botName string, context *llm.Context, usage llm.TokenUsage) {
+	subj := subjectFromContext(botName, context)
+	if err := s.store.addUsage(s.now(), subj[ScopeUser], subj[ScopeTeam], botName, Usage{
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+	}); err != nil {
+		s.log.Warn("Failed to record token usage", "bot_name", botName, "error", err.Error())
+	}
+}
+
+// GetUserUsage returns a user's usage for the current day and month along with their personal limits.
+func (s *Service) GetUserUsage(userID string) ([]PeriodUsage, error) {
+	now := s.now()
+	limits := applicableLimits(s.config.GetTokenQuotas(), subject{ScopeUser: userID})
+
+	result := make([]PeriodUsage, 0, 2)
+	for _, period := range []Period{PeriodDaily, PeriodMonthly} {
+		used, err := s.store.getUsage(ScopeUser, userID, period.Start(now))
+		if err != nil {
+			return nil, err
+		}
+		periodUsage := PeriodUsage{
+			Period:   period,
+			Usage:    used,
+			ResetsAt: period.End(now),
+		}
+		for _, limit := range limits {
+			if limit.Period == period {
+				periodUsage.InputTokenLimit = limit.InputTokens
+				periodUsage.OutputTokenLimit = limit.OutputTokens
+			}
+		}
+		result = append(result, periodUsage)
+	}
+
+	return result, nil
+}
+
+// GetUsageReport returns the usage of every bot, team or user between since and until, largest first.
+func (s *Service) GetUsageReport(scope Scope, since, until time.Time) ([]ScopeUsage, error) {
+	return s.store.getUsageByScope(scope, since, until)
+}
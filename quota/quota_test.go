@@ -0,0 +1,156 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type usageRow struct {
+	day                     time.Time
+	userID, teamID, botName string
+	usage                   Usage
+}
+
+type memoryStore struct {
+	rows []usageRow
+}
+
+func (s *memoryStore) addUsage(day time.Time, userID, teamID, botName string, usage Usage) error {
+	s.rows = append(s.rows, usageRow{day, userID, teamID, botName, usage})
+	return nil
+}
+
+func (s *memoryStore) getUsage(scope Scope, id string, since time.Time) (Usage, error) {
+	var total Usage
+	for _, row := range s.rows {
+		rowID := map[Scope]string{ScopeBot: row.botName, ScopeTeam: row.teamID, ScopeUser: row.userID}[scope]
+		if rowID == id && !row.day.Before(since) {
+			total.InputTokens += row.usage.InputTokens
+			total.OutputTokens += row.usage.OutputTokens
+		}
+	}
+	return total, nil
+}
+
+func (s *memoryStore) getUsageByScope(Scope, time.Time, time.Time) ([]ScopeUsage, error) {
+	return nil, nil
+}
+
+type staticConfig []Limit
+
+func (c staticConfig) GetTokenQuotas() []Limit {
+	return c
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Debug(string, ...any) {}
+
+func TestPeriod(t *testing.T) {
+	now := time.Date(2024, time.February, 29, 15, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC), PeriodDaily.Start(now))
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), PeriodDaily.End(now))
+	assert.Equal(t, time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), PeriodMonthly.Start(now))
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), PeriodMonthly.End(now))
+}
+
+func TestApplicableLimits(t *testing.T) {
+	limits := []Limit{
+		{Scope: ScopeUser, Period: PeriodDaily, InputTokens: 100},
+		{Scope: ScopeUser, ID: "vip", Period: PeriodDaily, InputTokens: 1000},
+		{Scope: ScopeUser, Period: PeriodMonthly, InputTokens: 2000},
+		{Scope: ScopeTeam, ID: "other-team", Period: PeriodDaily, InputTokens: 10},
+	}
+
+	t.Run("default limits apply to everyone", func(t *testing.T) {
+		applied := applicableLimits(limits, subject{ScopeUser: "user", ScopeTeam: "team"})
+		assert.Equal(t, []Limit{limits[0], limits[2]}, applied)
+	})
+
+	t.Run("specific limits replace the default", func(t *testing.T) {
+		applied := applicableLimits(limits, subject{ScopeUser: "vip"})
+		assert.Equal(t, []Limit{limits[1], limits[2]}, applied)
+	})
+
+	t.Run("limits for unknown scopes are skipped", func(t *testing.T) {
+		applied := applicableLimits(limits, subject{ScopeBot: "bot"})
+		assert.Empty(t, applied)
+	})
+}
+
+func TestService(t *testing.T) {
+	now := time.Date(2024, time.May, 10, 12, 0, 0, 0, time.UTC)
+	newService := func(limits ...Limit) *Service {
+		return &Service{
+			store:  &memoryStore{},
+			config: staticConfig(limits),
+			log:    noopLogger{},
+			now:    func() time.Time { return now },
+		}
+	}
+	context := &llm.Context{
+		RequestingUser: &model.User{Id: "user"},
+		Channel:        &model.Channel{Id: "channel", TeamId: "team"},
+	}
+
+	t.Run("allows requests under quota", func(t *testing.T) {
+		service := newService(Limit{Scope: ScopeUser, Period: PeriodDaily, InputTokens: 100})
+		service.RecordUsage("bot", context, llm.TokenUsage{InputTokens: 99, OutputTokens: 500})
+		assert.NoError(t, service.CheckQuota("bot", context))
+	})
+
+	t.Run("rejects requests once a quota is used up", func(t *testing.T) {
+		service := newService(Limit{Scope: ScopeTeam, Period: PeriodMonthly, OutputTokens: 100})
+		service.RecordUsage("bot", context, llm.TokenUsage{InputTokens: 10, OutputTokens: 60})
+		require.NoError(t, service.CheckQuota("bot", context))
+		service.RecordUsage("bot", context, llm.TokenUsage{InputTokens: 10, OutputTokens: 60})
+
+		err := service.CheckQuota("bot", context)
+		var quotaErr *llm.QuotaExceededError
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, "team", quotaErr.Scope)
+		assert.Equal(t, "team", quotaErr.ScopeID)
+		assert.Equal(t, "monthly", quotaErr.Period)
+		assert.Equal(t, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), quotaErr.ResetAt)
+	})
+
+	t.Run("usage from previous periods does not count", func(t *testing.T) {
+		service := newService(Limit{Scope: ScopeBot, Period: PeriodDaily, InputTokens: 100})
+		service.RecordUsage("bot", context, llm.TokenUsage{InputTokens: 100})
+		now = now.AddDate(0, 0, 1)
+		defer func() { now = now.AddDate(0, 0, -1) }()
+
+		assert.NoError(t, service.CheckQuota("bot", context))
+	})
+
+	t.Run("reports usage and limits for a user", func(t *testing.T) {
+		service := newService(Limit{Scope: ScopeUser, Period: PeriodMonthly, InputTokens: 1000, OutputTokens: 500})
+		service.RecordUsage("bot", context, llm.TokenUsage{InputTokens: 10, OutputTokens: 20})
+
+		periods, err := service.GetUserUsage("user")
+		require.NoError(t, err)
+		require.Len(t, periods, 2)
+		assert.Equal(t, PeriodUsage{
+			Period:   PeriodDaily,
+			Usage:    Usage{InputTokens: 10, OutputTokens: 20},
+			ResetsAt: time.Date(2024, time.May, 11, 0, 0, 0, 0, time.UTC),
+		}, periods[0])
+		assert.Equal(t, PeriodUsage{
+			Period:           PeriodMonthly,
+			Usage:            Usage{InputTokens: 10, OutputTokens: 20},
+			InputTokenLimit:  1000,
+			OutputTokenLimit: 500,
+			ResetsAt:         time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		}, periods[1])
+	})
+}
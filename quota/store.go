@@ -0,0 +1,95 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package quota
+
+import (
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/mattermost-plugin-ai/mmapi"
+)
+
+const dayFormat = "2006-01-02"
+
+// dbStore stores usage in the LLM_TokenUsage table.
+type dbStore struct {
+	db *mmapi.DBClient
+}
+
+func scopeColumn(scope Scope) (string, error) {
+	switch scope {
+	case ScopeBot:
+		return "BotName", nil
+	case ScopeTeam:
+		return "TeamID", nil
+	case ScopeUser:
+		return "UserID", nil
+	default:
+		return "", fmt.Errorf("unknown scope %q", scope)
+	}
+}
+
+func (s *dbStore) addUsage(day time.Time, userID, teamID, botName string, usage Usage) error {
+	_, err := s.db.ExecBuilder(s.db.Builder().Insert("LLM_TokenUsage").
+		Columns("UserID", "TeamID", "BotName", "Day", "InputTokens", "OutputTokens").
+		Values(userID, teamID, botName, day.UTC().Format(dayFormat), usage.InputTokens, usage.OutputTokens).
+		Suffix("ON CONFLICT (UserID, TeamID, BotName, Day) DO UPDATE SET " +
+			"InputTokens = LLM_TokenUsage.InputTokens + EXCLUDED.InputTokens, " +
+			"OutputTokens = LLM_TokenUsage.OutputTokens + EXCLUDED.OutputTokens"))
+	if err != nil {
+		return fmt.Errorf("failed to add token usage: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStore) getUsage(scope Scope, id string, since time.Time) (Usage, error) {
+	column, err := scopeColumn(scope)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var rows []Usage
+	if err := s.db.DoQuery(&rows, s.db.Builder().
+		Select(
+			"COALESCE(SUM(InputTokens), 0) AS InputTokens",
+			"COALESCE(SUM(OutputTokens), 0) AS OutputTokens",
+		).
+		From("LLM_TokenUsage").
+		Where(sq.Eq{column: id}).
+		Where(sq.GtOrEq{"Day": since.UTC().Format(dayFormat)}),
+	); err != nil {
+		return Usage{}, fmt.Errorf("failed to get token usage: %w", err)
+	}
+	if len(rows) == 0 {
+		return Usage{}, nil
+	}
+
+	return rows[0], nil
+}
+
+func (s *dbStore) getUsageByScope(scope Scope, since, until time.Time) ([]ScopeUsage, error) {
+	column, err := scopeColumn(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ScopeUsage
+	if err := s.db.DoQuery(&rows, s.db.Builder().
+		Select(
+			column+" AS ID",
+			"SUM(InputTokens) AS InputTokens",
+			"SUM(OutputTokens) AS OutputTokens",
+		).
+		From("LLM_TokenUsage").
+		Where(sq.GtOrEq{"Day": since.UTC().Format(dayFormat)}).
+		Where(sq.Lt{"Day": until.UTC().Format(dayFormat)}).
+		GroupBy(column).
+		OrderBy("SUM(InputTokens) + SUM(OutputTokens) DESC"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to get token usage report: %w", err)
+	}
+
+	return rows, nil
+}
@@ -62,13 +62,13 @@ func TestReactResolve(t *testing.T) {
 			prompts, err := llm.NewPrompts(prompts.PromptsFolder)
 			assert.NoError(t, err)
 
-			mockLLM.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything).Return(tc.llmResponse, tc.llmError)
+			mockLLM.EXPECT().ChatCompletionNoStream(mock.Anything, mock.Anything, mock.Anything).Return(tc.llmResponse, tc.llmError)
 
 			r := react.New(mockLLM, prompts)
 			ctx := llm.NewContext()
 
 			// Execute
-			emoji, err := r.Resolve(tc.message, ctx)
+			emoji, err := r.Resolve(t.Context(), tc.message, ctx)
 
 			// Assert
 			if tc.expectedError {
@@ -112,7 +112,7 @@ func TestReactEval(t *testing.T) {
 			r := react.New(t.LLM, t.Prompts)
 			llmContext := llm.NewContext()
 
-			result, err := r.Resolve(tc.message, llmContext)
+			result, err := r.Resolve(t.Context(), tc.message, llmContext)
 
 			require.NoError(t, err)
 			assert.NotEmpty(t, result, "Expected a non-empty emoji reaction")
@@ -0,0 +1,226 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package redaction detects personal data and secrets in prompts so they can be replaced
+// with placeholders before the prompts are sent to an LLM.
+package redaction
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+// Built in detector categories
+const (
+	CategoryEmail      = "email"
+	CategoryPhone      = "phone"
+	CategoryCreditCard = "credit_card"
+	CategoryAPIKey     = "api_key"
+)
+
+// CustomPattern is a pattern defined by an admin.
+type CustomPattern struct {
+	// Name is the category reported for values matching the pattern, such as "employee_id"
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Config is the redaction configuration set by admins. Redaction is enabled per bot.
+type Config struct {
+	// Detectors are the built in detectors to use. Empty uses all of them.
+	Detectors      []string        `json:"detectors"`
+	CustomPatterns []CustomPattern `json:"customPatterns"`
+}
+
+type ConfigProvider interface {
+	GetRedactionConfig() Config
+}
+
+// detector finds one category of values. validate, if set, filters out false positives.
+type detector struct {
+	category string
+	pattern  *regexp.Regexp
+	validate func(value string) bool
+}
+
+var builtinDetectors = []detector{
+	{
+		category: CategoryEmail,
+		pattern:  regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		category: CategoryAPIKey,
+		pattern: regexp.MustCompile(strings.Join([]string{
+			`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+			`\bsk-(?:proj-|ant-[a-z0-9]+-)?[A-Za-z0-9_\-]{20,}`,
+			`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+			`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+			`\bgithub_pat_[A-Za-z0-9_]{40,}\b`,
+			`\bxox[abprs]-[A-Za-z0-9\-]{10,}`,
+			`\bAIza[0-9A-Za-z_\-]{35}`,
+			`\bglpat-[A-Za-z0-9_\-]{20,}`,
+			`\b[Bb]earer\s+[A-Za-z0-9._~+/\-]{20,}=*`,
+		}, "|")),
+	},
+	{
+		category: CategoryCreditCard,
+		pattern:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: isValidCardNumber,
+	},
+	{
+		category: CategoryPhone,
+		pattern:  regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{2,4}\)[ .\-]?|\b\d{2,4}[ .\-])\d{3,4}[ .\-]\d{3,4}\b`),
+		validate: isValidPhoneNumber,
+	},
+}
+
+// isValidCardNumber checks the length and Luhn checksum of a card number.
+func isValidCardNumber(value string) bool {
+	digits := digitsOf(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isValidPhoneNumber(value string) bool {
+	digits := digitsOf(value)
+	return len(digits) >= 7 && len(digits) <= 15
+}
+
+func digitsOf(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// Redactor finds sensitive values with the configured detectors. It implements llm.Redactor.
+type Redactor struct {
+	config ConfigProvider
+	log    llm.Logger
+
+	mu sync.Mutex
+	// compiled caches the detectors for the configuration they were built from
+	compiledFor string
+	compiled    []detector
+}
+
+func New(config ConfigProvider, log llm.Logger) *Redactor {
+	return &Redactor{
+		config: config,
+		log:    log,
+	}
+}
+
+// detectors returns the detectors for the current configuration.
+// Custom patterns that don't compile are logged and skipped.
+func (r *Redactor) detectors() []detector {
+	cfg := r.config.GetRedactionConfig()
+
+	var fingerprint strings.Builder
+	for _, name := range cfg.Detectors {
+		fingerprint.WriteString(name + "\x00")
+	}
+	fingerprint.WriteString("\x01")
+	for _, custom := range cfg.CustomPatterns {
+		fingerprint.WriteString(custom.Name + "\x00" + custom.Pattern + "\x00")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.compiled != nil && r.compiledFor == fingerprint.String() {
+		return r.compiled
+	}
+
+	compiled := make([]detector, 0, len(builtinDetectors)+len(cfg.CustomPatterns))
+	for _, d := range builtinDetectors {
+		if len(cfg.Detectors) == 0 || slices.Contains(cfg.Detectors, d.category) {
+			compiled = append(compiled, d)
+		}
+	}
+	for _, custom := range cfg.CustomPatterns {
+		if custom.Name == "" || custom.Pattern == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			r.log.Warn("Skipping invalid redaction pattern", "name", custom.Name, "error", err.Error())
+			continue
+		}
+		compiled = append(compiled, detector{
+			category: custom.Name,
+			pattern:  pattern,
+		})
+	}
+
+	r.compiledFor = fingerprint.String()
+	r.compiled = compiled
+	return compiled
+}
+
+// Find returns the sensitive values in text. When values found by different detectors overlap,
+// the one that starts first wins, and the longer one if they start at the same position.
+func (r *Redactor) Find(text string) []llm.RedactedSpan {
+	if text == "" {
+		return nil
+	}
+
+	var spans []llm.RedactedSpan
+	for _, d := range r.detectors() {
+		for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if d.validate != nil && !d.validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			spans = append(spans, llm.RedactedSpan{
+				Start:    loc[0],
+				End:      loc[1],
+				Category: d.category,
+			})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].End > spans[j].End
+	})
+	result := spans[:0]
+	end := 0
+	for _, span := range spans {
+		if span.Start < end {
+			continue
+		}
+		result = append(result, span)
+		end = span.End
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package responsecache stores LLM responses in the plugin KV store so repeated requests
+// can be answered without calling the LLM again. The KV store is shared by every server
+// in a cluster, so a response cached by one server is available to all of them.
+package responsecache
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+const (
+	keyPrefix = "response_cache_"
+
+	DefaultTTLMinutes     = 60
+	DefaultMaxEntrySizeKB = 64
+)
+
+// Config is the response cache configuration set by admins.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// TTLMinutes is how long responses are kept, DefaultTTLMinutes when 0
+	TTLMinutes int `json:"ttlMinutes"`
+	// MaxEntrySizeKB is the size of the largest response that is cached, DefaultMaxEntrySizeKB when 0
+	MaxEntrySizeKB int `json:"maxEntrySizeKB"`
+}
+
+func (c Config) ttl() time.Duration {
+	if c.TTLMinutes <= 0 {
+		return DefaultTTLMinutes * time.Minute
+	}
+	return time.Duration(c.TTLMinutes) * time.Minute
+}
+
+func (c Config) maxEntrySize() int {
+	if c.MaxEntrySizeKB <= 0 {
+		return DefaultMaxEntrySizeKB * 1024
+	}
+	return c.MaxEntrySizeKB * 1024
+}
+
+type ConfigProvider interface {
+	GetResponseCacheConfig() Config
+}
+
+// KVStore is the subset of the plugin KV store used by the cache. *pluginapi.KVService satisfies it.
+type KVStore interface {
+	Get(key string, o any) error
+	Set(key string, value any, options ...pluginapi.KVSetOption) (bool, error)
+}
+
+type entry struct {
+	Response string `json:"response"`
+}
+
+// Cache stores responses in the plugin KV store. It implements llm.ResponseCache.
+// Errors are logged and treated as cache misses so the cache never fails a request.
+type Cache struct {
+	kv     KVStore
+	config ConfigProvider
+	log    llm.Logger
+}
+
+func New(kv KVStore, config ConfigProvider, log llm.Logger) *Cache {
+	return &Cache{
+		kv:     kv,
+		config: config,
+		log:    log,
+	}
+}
+
+// Get returns the response stored for key, if the cache is enabled and the response hasn't expired.
+func (c *Cache) Get(key string) (string, bool) {
+	if !c.config.GetResponseCacheConfig().Enabled {
+		return "", false
+	}
+
+	var cached entry
+	if err := c.kv.Get(keyPrefix+key, &cached); err != nil {
+		c.log.Warn("Failed to read cached LLM response", "error", err.Error())
+		return "", false
+	}
+	if cached.Response == "" {
+		return "", false
+	}
+
+	c.log.Debug("Answered LLM request from the response cache")
+	return cached.Response, true
+}
+
+// Set stores response for key if the cache is enabled and the response isn't larger than the configured limit.
+func (c *Cache) Set(key string, response string) {
+	cfg := c.config.GetResponseCacheConfig()
+	if !cfg.Enabled || len(response) > cfg.maxEntrySize() {
+		return
+	}
+
+	if _, err := c.kv.Set(keyPrefix+key, entry{Response: response}, pluginapi.SetExpiry(cfg.ttl())); err != nil {
+		c.log.Warn("Failed to store LLM response in the response cache", "error", err.Error())
+	}
+}
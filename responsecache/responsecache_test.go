@@ -0,0 +1,120 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package responsecache
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKV struct {
+	values  map[string][]byte
+	expiry  map[string]time.Duration
+	readErr error
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{
+		values: map[string][]byte{},
+		expiry: map[string]time.Duration{},
+	}
+}
+
+func (f *fakeKV) Get(key string, o any) error {
+	if f.readErr != nil {
+		return f.readErr
+	}
+	data, ok := f.values[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(data, o)
+}
+
+func (f *fakeKV) Set(key string, value any, options ...pluginapi.KVSetOption) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	opts := pluginapi.KVSetOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	f.values[key] = data
+	f.expiry[key] = time.Duration(opts.ExpireInSeconds) * time.Second
+	return true, nil
+}
+
+type staticConfig Config
+
+func (c staticConfig) GetResponseCacheConfig() Config {
+	return Config(c)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Debug(string, ...any) {}
+
+func TestCache(t *testing.T) {
+	t.Run("stores responses with the configured expiry", func(t *testing.T) {
+		kv := newFakeKV()
+		cache := New(kv, staticConfig{Enabled: true, TTLMinutes: 5}, nopLogger{})
+
+		_, ok := cache.Get("key")
+		assert.False(t, ok)
+
+		cache.Set("key", "response")
+		response, ok := cache.Get("key")
+		require.True(t, ok)
+		assert.Equal(t, "response", response)
+		assert.Equal(t, 5*time.Minute, kv.expiry[keyPrefix+"key"])
+	})
+
+	t.Run("defaults the expiry", func(t *testing.T) {
+		kv := newFakeKV()
+		cache := New(kv, staticConfig{Enabled: true}, nopLogger{})
+
+		cache.Set("key", "response")
+		assert.Equal(t, DefaultTTLMinutes*time.Minute, kv.expiry[keyPrefix+"key"])
+	})
+
+	t.Run("does nothing when disabled", func(t *testing.T) {
+		kv := newFakeKV()
+		cache := New(kv, staticConfig{}, nopLogger{})
+
+		cache.Set("key", "response")
+		assert.Empty(t, kv.values)
+
+		kv.values[keyPrefix+"key"] = []byte(`{"response":"response"}`)
+		_, ok := cache.Get("key")
+		assert.False(t, ok)
+	})
+
+	t.Run("skips responses over the size limit", func(t *testing.T) {
+		kv := newFakeKV()
+		cache := New(kv, staticConfig{Enabled: true, MaxEntrySizeKB: 1}, nopLogger{})
+
+		cache.Set("large", strings.Repeat("a", 1025))
+		cache.Set("small", strings.Repeat("a", 1024))
+		assert.NotContains(t, kv.values, keyPrefix+"large")
+		assert.Contains(t, kv.values, keyPrefix+"small")
+	})
+
+	t.Run("read errors are cache misses", func(t *testing.T) {
+		kv := newFakeKV()
+		kv.readErr = errors.New("database unavailable")
+		cache := New(kv, staticConfig{Enabled: true}, nopLogger{})
+
+		_, ok := cache.Get("key")
+		assert.False(t, ok)
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/chunking"
 	"github.com/mattermost/mattermost-plugin-ai/embeddings"
 	"github.com/mattermost/mattermost-plugin-ai/enterprise"
+	"github.com/mattermost/mattermost-plugin-ai/ollama"
 	"github.com/mattermost/mattermost-plugin-ai/openai"
 	"github.com/mattermost/mattermost-plugin-ai/postgres"
 )
@@ -49,6 +50,13 @@ func newEmbeddingProvider(config embeddings.UpstreamConfig, dimensions int, http
 		}
 		openaiConfig.EmbeddingDimensions = dimensions
 		return openai.NewEmbeddings(openaiConfig, httpClient), nil
+	case embeddings.ProviderTypeOllama:
+		var ollamaConfig ollama.EmbeddingConfig
+		if err := json.Unmarshal(config.Parameters, &ollamaConfig); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Ollama config: %w", err)
+		}
+		ollamaConfig.EmbeddingDimensions = dimensions
+		return ollama.NewEmbeddings(ollamaConfig, httpClient), nil
 	case embeddings.ProviderTypeMock:
 		return embeddings.NewMockEmbeddingProvider(dimensions), nil
 	}
@@ -14,7 +14,9 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/llm"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -78,6 +80,21 @@ func (s *Search) Enabled() bool {
 	return s != nil && s.EmbeddingSearch != nil
 }
 
+// Search searches the embeddings for posts relevant to query.
+func (s *Search) Search(ctx context.Context, query string, opts embeddings.SearchOptions) (results []embeddings.SearchResult, err error) {
+	ctx, span := tracing.Start(ctx, "search.Search",
+		attribute.Int("search.limit", opts.Limit),
+		attribute.String("search.team_id", opts.TeamID),
+		attribute.String("search.channel_id", opts.ChannelID),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("search.results", len(results)))
+		tracing.End(span, err)
+	}()
+
+	return s.EmbeddingSearch.Search(ctx, query, opts)
+}
+
 // convertToRAGResults converts embeddings.EmbeddingSearchResult to RAGResult with enriched metadata
 func (s *Search) convertToRAGResults(searchResults []embeddings.SearchResult) []RAGResult {
 	var ragResults []RAGResult
@@ -233,7 +250,10 @@ func (s *Search) RunSearch(ctx context.Context, userID string, bot *bots.Bot, qu
 			Context: promptCtx,
 		}
 
-		resultStream, err := bot.LLM().ChatCompletion(prompt)
+		requestCtx, cancel := s.streamingService.NewRequestContext()
+		defer cancel()
+
+		resultStream, err := bot.LLM().ChatCompletion(requestCtx, prompt, llm.WithTask(llm.TaskSearch))
 		if err != nil {
 			s.mmclient.LogError("Error generating answer", "error", err)
 			processingError = err
@@ -255,7 +275,7 @@ func (s *Search) RunSearch(ctx context.Context, userID string, bot *bots.Bot, qu
 			return
 		}
 
-		streamContext, err := s.streamingService.GetStreamingContext(context.Background(), responsePost.Id)
+		streamContext, err := s.streamingService.GetStreamingContext(requestCtx, responsePost.Id)
 		if err != nil {
 			s.mmclient.LogError("Error getting post streaming context", "error", err)
 			processingError = err
@@ -325,7 +345,7 @@ func (s *Search) SearchQuery(ctx context.Context, userID string, bot *bots.Bot,
 		Context: promptCtx,
 	}
 
-	answer, err := bot.LLM().ChatCompletionNoStream(prompt)
+	answer, err := bot.LLM().ChatCompletionNoStream(ctx, prompt, llm.WithTask(llm.TaskSearch))
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to generate answer: %w", err)
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/bots"
 	"github.com/mattermost/mattermost-plugin-ai/config"
 	"github.com/mattermost/mattermost-plugin-ai/conversations"
+	"github.com/mattermost/mattermost-plugin-ai/costs"
 	"github.com/mattermost/mattermost-plugin-ai/database"
 	"github.com/mattermost/mattermost-plugin-ai/enterprise"
 	"github.com/mattermost/mattermost-plugin-ai/i18n"
@@ -23,12 +24,17 @@ import (
 	"github.com/mattermost/mattermost-plugin-ai/mcp"
 	"github.com/mattermost/mattermost-plugin-ai/mcpserver"
 	"github.com/mattermost/mattermost-plugin-ai/meetings"
+	"github.com/mattermost/mattermost-plugin-ai/memory"
 	"github.com/mattermost/mattermost-plugin-ai/metrics"
 	"github.com/mattermost/mattermost-plugin-ai/mmapi"
 	"github.com/mattermost/mattermost-plugin-ai/mmtools"
 	"github.com/mattermost/mattermost-plugin-ai/prompts"
+	"github.com/mattermost/mattermost-plugin-ai/quota"
+	"github.com/mattermost/mattermost-plugin-ai/redaction"
+	"github.com/mattermost/mattermost-plugin-ai/responsecache"
 	"github.com/mattermost/mattermost-plugin-ai/search"
 	"github.com/mattermost/mattermost-plugin-ai/streaming"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
@@ -50,6 +56,8 @@ type Plugin struct {
 	indexerService       *indexer.Indexer
 	conversationsService *conversations.Conversations
 	mcpClientManager     *mcp.ClientManager
+	streamingService     *streaming.MMPostStreamService
+	tracingService       *tracing.Service
 }
 
 func (p *Plugin) OnActivate() error {
@@ -60,8 +68,13 @@ func (p *Plugin) OnActivate() error {
 
 	i18nBundle := i18n.Init()
 
+	tracingService := tracing.New(&p.configuration, &pluginAPI.Log, manifest.Version)
+	tracingService.Configure()
+	p.configuration.RegisterUpdateListener(tracingService.Configure)
+
 	llmUpstreamHTTPClient := httpservice.MakeHTTPServicePlugin(p.API).MakeClient(true)
 	llmUpstreamHTTPClient.Timeout = time.Minute * 10 // LLM requests can be slow
+	llmUpstreamHTTPClient.Transport = tracing.NewTransport(llmUpstreamHTTPClient.Transport)
 
 	untrustedHTTPClient := httpservice.MakeHTTPServicePlugin(p.API).MakeClient(false)
 
@@ -88,6 +101,13 @@ func (p *Plugin) OnActivate() error {
 	}
 
 	bots := bots.New(p.API, pluginAPI, licenseChecker, &p.configuration, llmUpstreamHTTPClient, tokenLogger, metricsService)
+	bots.SetConversationSummaryStore(conversations.NewSummaryStore(dbClient))
+	quotaService := quota.New(dbClient, &p.configuration, &pluginAPI.Log)
+	bots.SetQuotaEnforcer(quotaService)
+	costService := costs.New(dbClient, &p.configuration, &pluginAPI.Log)
+	bots.SetCostTracker(costService)
+	bots.SetResponseCache(responsecache.New(&pluginAPI.KV, &p.configuration, &pluginAPI.Log))
+	bots.SetRedactor(redaction.New(&p.configuration, &pluginAPI.Log))
 	p.configuration.RegisterUpdateListener(func() {
 		if ensureErr := bots.EnsureBots(); ensureErr != nil {
 			pluginAPI.Log.Error("failed to ensure bots on configuration update", "error", ensureErr)
@@ -175,10 +195,13 @@ func (p *Plugin) OnActivate() error {
 		mcpClientManager.ReInit(p.configuration.MCP(), embeddedServer)
 	})
 
+	memoryService := memory.New(dbClient)
+
 	contextBuilder := llmcontext.NewLLMContextBuilder(
 		pluginAPI,
 		toolProvider,
 		mcpClientManager,
+		memoryService,
 		&p.configuration,
 	)
 
@@ -210,6 +233,9 @@ func (p *Plugin) OnActivate() error {
 	// TODO: Refactor to avoid circular dependency
 	conversationsService.SetMeetingsService(meetingsService)
 
+	// Bots ask other bots through conversations, which builds contexts with the builder
+	contextBuilder.SetDelegationProvider(conversationsService)
+
 	// Initialize embedded MCP server handlers for plugin endpoints
 	var mcpHandlers *mcpserver.PluginMCPHandlers
 	// Create logger adapter to route MCP handler logs through plugin logging
@@ -241,6 +267,9 @@ func (p *Plugin) OnActivate() error {
 		mcpClientManager,
 		mcpHandlers,
 		llmUpstreamHTTPClient,
+		quotaService,
+		costService,
+		memoryService,
 	)
 
 	// Keep only what we need
@@ -250,6 +279,8 @@ func (p *Plugin) OnActivate() error {
 	p.indexerService = indexerService
 	p.conversationsService = conversationsService
 	p.mcpClientManager = mcpClientManager
+	p.streamingService = streamingService
+	p.tracingService = tracingService
 
 	return nil
 }
@@ -258,6 +289,16 @@ func (p *Plugin) OnDeactivate() error {
 	// Clean up MCP client manager if it exists
 	p.mcpClientManager.Close()
 
+	// Abort in-flight LLM requests and stop their streams
+	if p.streamingService != nil {
+		p.streamingService.Close()
+	}
+
+	// Flush the spans of the aborted requests
+	if p.tracingService != nil {
+		p.tracingService.Shutdown()
+	}
+
 	return nil
 }
 
@@ -297,6 +338,8 @@ func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, newPost, oldPost *mode
 			}
 		}
 	}
+
+	p.conversationsService.MessageHasBeenUpdated(c, newPost, oldPost)
 }
 
 func (p *Plugin) MessageHasBeenDeleted(c *plugin.Context, post *model.Post) {
@@ -0,0 +1,144 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// AgentStepsProp holds the completed steps of a response that resolved tools, in order. The post's
+// message, reasoning and tool calls stay visible: the message continues after the text of each step,
+// and the tool calls of the steps are kept with their results.
+const AgentStepsProp = "agent_steps"
+
+// DelegatedToProp holds the IDs of the bots that answered questions the response asked them, in the
+// order they were first asked.
+const DelegatedToProp = "delegated_to"
+
+// agentStepSeparator separates the text of the steps of a response in its message
+const agentStepSeparator = "\n\n"
+
+// GetAgentSteps returns the completed steps of a response post.
+func GetAgentSteps(post *model.Post) ([]llm.AgentStep, error) {
+	stepsJSON, ok := post.GetProp(AgentStepsProp).(string)
+	if !ok || stepsJSON == "" {
+		return nil, nil
+	}
+
+	var steps []llm.AgentStep
+	if err := json.Unmarshal([]byte(stepsJSON), &steps); err != nil {
+		return nil, fmt.Errorf("post agent steps not valid JSON: %w", err)
+	}
+	return steps, nil
+}
+
+// AddAgentStep completes a step of a response post. The step is added to the post's steps and its
+// resolved tool calls replace the pending ones of the post. The message and reasoning of the post
+// are kept, the next step continues the message.
+func AddAgentStep(post *model.Post, step llm.AgentStep) error {
+	steps, err := GetAgentSteps(post)
+	if err != nil {
+		return err
+	}
+
+	// The tool calls are copied as the caller may still be using them
+	step.ToolCalls = slices.Clone(step.ToolCalls)
+	for i := range step.ToolCalls {
+		step.ToolCalls[i].SanitizeArguments()
+	}
+	if err := SetToolCalls(post, step.ToolCalls); err != nil {
+		return err
+	}
+	stepsJSON, err := json.Marshal(append(steps, step))
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent steps: %w", err)
+	}
+
+	post.AddProp(AgentStepsProp, string(stepsJSON))
+	return nil
+}
+
+// SetToolCalls stores toolCalls in the tool calls of post. Calls the post already has are replaced,
+// the others are added after them.
+func SetToolCalls(post *model.Post, toolCalls []llm.ToolCall) error {
+	var existing []llm.ToolCall
+	if existingJSON, ok := post.GetProp(ToolCallProp).(string); ok && existingJSON != "" {
+		if err := json.Unmarshal([]byte(existingJSON), &existing); err != nil {
+			return fmt.Errorf("post tool calls not valid JSON: %w", err)
+		}
+	}
+
+	for _, toolCall := range toolCalls {
+		i := slices.IndexFunc(existing, func(tc llm.ToolCall) bool {
+			return tc.ID == toolCall.ID
+		})
+		if i < 0 {
+			existing = append(existing, toolCall)
+			continue
+		}
+		existing[i] = toolCall
+	}
+
+	toolCallsJSON, err := json.Marshal(existing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool calls: %w", err)
+	}
+	post.AddProp(ToolCallProp, string(toolCallsJSON))
+	return nil
+}
+
+// TrimAgentSteps returns the part of the message of a response post written after its completed steps.
+func TrimAgentSteps(message string, steps []llm.AgentStep) string {
+	for _, step := range steps {
+		if step.Message == "" {
+			continue
+		}
+		rest, ok := strings.CutPrefix(message, step.Message)
+		if !ok {
+			return message
+		}
+		message = strings.TrimPrefix(rest, agentStepSeparator)
+	}
+	return message
+}
+
+// GetDelegatedTo returns the IDs of the bots that answered questions of a response post.
+func GetDelegatedTo(post *model.Post) []string {
+	switch botIDs := post.GetProp(DelegatedToProp).(type) {
+	case []string:
+		return botIDs
+	case []any:
+		// Props read back from the database are decoded from JSON
+		result := make([]string, 0, len(botIDs))
+		for _, botID := range botIDs {
+			if botID, ok := botID.(string); ok {
+				result = append(result, botID)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// AddDelegatedTo adds the bots of botIDs to the bots that answered questions of a response post.
+// Bots asked more than once are listed once.
+func AddDelegatedTo(post *model.Post, botIDs []string) {
+	delegatedTo := GetDelegatedTo(post)
+	added := false
+	for _, botID := range botIDs {
+		if !slices.Contains(delegatedTo, botID) {
+			delegatedTo = append(delegatedTo, botID)
+			added = true
+		}
+	}
+	if added {
+		post.AddProp(DelegatedToProp, delegatedTo)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package streaming
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddAgentStep(t *testing.T) {
+	post := &model.Post{Message: "Let me search."}
+	post.AddProp(ReasoningSummaryProp, "The user wants results")
+	post.AddProp(ToolCallProp, `[{"id":"search","name":"search"}]`)
+
+	require.NoError(t, AddAgentStep(post, llm.AgentStep{
+		Message:   post.Message,
+		Reasoning: "The user wants results",
+		ToolCalls: []llm.ToolCall{{ID: "search", Name: "search", Status: llm.ToolCallStatusSuccess, Result: "3 results"}},
+	}))
+
+	assert.Equal(t, "Let me search.", post.Message, "the message of the step stays visible")
+	assert.Equal(t, "The user wants results", post.GetProp(ReasoningSummaryProp))
+	var toolCalls []llm.ToolCall
+	require.NoError(t, json.Unmarshal([]byte(post.GetProp(ToolCallProp).(string)), &toolCalls))
+	require.Len(t, toolCalls, 1, "resolved tool calls replace the pending ones")
+	assert.Equal(t, llm.ToolCallStatusSuccess, toolCalls[0].Status)
+	assert.Equal(t, "3 results", toolCalls[0].Result)
+
+	steps, err := GetAgentSteps(post)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	assert.Equal(t, "Let me search.", steps[0].Message)
+
+	assert.Equal(t, "Found them.", TrimAgentSteps("Let me search."+agentStepSeparator+"Found them.", steps))
+}
+
+func TestAddDelegatedTo(t *testing.T) {
+	post := &model.Post{}
+	AddDelegatedTo(post, nil)
+	assert.Nil(t, post.GetProp(DelegatedToProp), "responses that didn't ask other bots have no delegations")
+
+	AddDelegatedTo(post, []string{"hrbot"})
+	AddDelegatedTo(post, []string{"codebot", "hrbot"})
+	assert.Equal(t, []string{"hrbot", "codebot"}, GetDelegatedTo(post))
+
+	// Props saved in the database are read back as JSON
+	post.AddProp(DelegatedToProp, []any{"hrbot", "codebot"})
+	assert.Equal(t, []string{"hrbot", "codebot"}, GetDelegatedTo(post))
+}
@@ -6,13 +6,16 @@ package streaming
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/mattermost/mattermost-plugin-ai/i18n"
 	"github.com/mattermost/mattermost-plugin-ai/llm"
+	"github.com/mattermost/mattermost-plugin-ai/tracing"
 	"github.com/mattermost/mattermost/server/public/model"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Client defines the minimal client interface needed for streaming operations.
@@ -36,8 +39,13 @@ const ToolCallProp = "pending_tool_call"
 const ReasoningSummaryProp = "reasoning_summary"
 const ReasoningSignatureProp = "reasoning_signature"
 const AnnotationsProp = "annotations"
+const ServiceProp = "llm_service"
 
 type Service interface {
+	// NewRequestContext returns a context for an LLM request whose result will be streamed with StreamToNewPost
+	// or StreamToNewDM. Stopping the stream or closing the service cancels it, aborting the upstream request.
+	// cancel must be called if the result is never streamed.
+	NewRequestContext() (ctx context.Context, cancel context.CancelFunc)
 	StreamToNewPost(ctx context.Context, botID string, requesterUserID string, stream *llm.TextStreamResult, post *model.Post, respondingToPostID string) error
 	StreamToNewDM(ctx context.Context, botID string, stream *llm.TextStreamResult, userID string, post *model.Post, respondingToPostID string) error
 	StreamToPost(ctx context.Context, stream *llm.TextStreamResult, post *model.Post, userLocale string)
@@ -50,23 +58,48 @@ type postStreamContext struct {
 	cancel context.CancelFunc
 }
 
+// requestCancelKey is the context key of the function that cancels a context created by NewRequestContext.
+type requestCancelKey struct{}
+
 var ErrAlreadyStreamingToPost = fmt.Errorf("already streaming to post")
 
 type MMPostStreamService struct {
 	contexts      map[string]postStreamContext
 	contextsMutex sync.Mutex
+	baseCtx       context.Context
+	cancelAll     context.CancelFunc
 	mmClient      Client
 	i18n          *i18n.Bundle
 }
 
 func NewMMPostStreamService(mmClient Client, i18n *i18n.Bundle) *MMPostStreamService {
+	baseCtx, cancelAll := context.WithCancel(context.Background())
 	return &MMPostStreamService{
-		contexts: make(map[string]postStreamContext),
-		mmClient: mmClient,
-		i18n:     i18n,
+		contexts:  make(map[string]postStreamContext),
+		mmClient:  mmClient,
+		i18n:      i18n,
+		baseCtx:   baseCtx,
+		cancelAll: cancelAll,
 	}
 }
 
+func (p *MMPostStreamService) NewRequestContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(p.baseCtx)
+	return context.WithValue(ctx, requestCancelKey{}, cancel), cancel
+}
+
+// Close stops every stream and cancels every request created with NewRequestContext.
+// It is called when the plugin is deactivated.
+func (p *MMPostStreamService) Close() {
+	p.contextsMutex.Lock()
+	defer p.contextsMutex.Unlock()
+	for postID, streamContext := range p.contexts {
+		streamContext.cancel()
+		delete(p.contexts, postID)
+	}
+	p.cancelAll()
+}
+
 func (p *MMPostStreamService) StreamToNewPost(ctx context.Context, botID string, requesterUserID string, stream *llm.TextStreamResult, post *model.Post, respondingToPostID string) error {
 	// We use ModifyPostForBot directly here to add the responding to post ID
 	ModifyPostForBot(botID, requesterUserID, post, respondingToPostID)
@@ -75,9 +108,7 @@ func (p *MMPostStreamService) StreamToNewPost(ctx context.Context, botID string,
 		return fmt.Errorf("unable to create post: %w", err)
 	}
 
-	// The callback is already set when creating the context
-
-	ctx, err := p.GetStreamingContext(context.Background(), post.Id)
+	ctx, err := p.GetStreamingContext(ctx, post.Id)
 	if err != nil {
 		return err
 	}
@@ -117,9 +148,7 @@ func (p *MMPostStreamService) StreamToNewDM(ctx context.Context, botID string, s
 		return fmt.Errorf("failed to post DM: %w", err)
 	}
 
-	// The callback is already set when creating the context
-
-	ctx, err := p.GetStreamingContext(context.Background(), post.Id)
+	ctx, err := p.GetStreamingContext(ctx, post.Id)
 	if err != nil {
 		return err
 	}
@@ -200,6 +229,15 @@ func (p *MMPostStreamService) GetStreamingContext(inCtx context.Context, postID
 
 	ctx, cancel := context.WithCancel(inCtx)
 
+	// Stopping a stream started with NewRequestContext also aborts the upstream request
+	if requestCancel, ok := inCtx.Value(requestCancelKey{}).(context.CancelFunc); ok {
+		streamCancel := cancel
+		cancel = func() {
+			streamCancel()
+			requestCancel()
+		}
+	}
+
 	streamingContext := postStreamContext{
 		cancel: cancel,
 	}
@@ -214,12 +252,19 @@ func (p *MMPostStreamService) GetStreamingContext(inCtx context.Context, postID
 func (p *MMPostStreamService) FinishStreaming(postID string) {
 	p.contextsMutex.Lock()
 	defer p.contextsMutex.Unlock()
+	if streamContext, ok := p.contexts[postID]; ok {
+		// The stream is done, cancelling releases the resources of its contexts
+		streamContext.cancel()
+	}
 	delete(p.contexts, postID)
 }
 
 // StreamToPost streams the result of a TextStreamResult to a post.
 // it will internally handle logging needs and updating the post.
 func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.TextStreamResult, post *model.Post, userLocale string) {
+	_, span := tracing.Start(ctx, "streaming.StreamToPost", attribute.String("post_id", post.Id))
+	defer span.End()
+
 	broadcast := &model.WebsocketBroadcast{ChannelId: post.ChannelId}
 	p.sendPostStreamingControlEventWithBroadcast(post, PostStreamingControlStart, broadcast)
 	defer func() {
@@ -230,6 +275,12 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 	messageBuilder.Grow(4096) // Pre-allocate for typical response size
 	var reasoningBuffer strings.Builder
 
+	// A response continuing after resolved tools keeps the text of its completed steps
+	if post.GetProp(AgentStepsProp) != nil {
+		messageBuilder.WriteString(post.Message)
+	}
+	stepStarted := messageBuilder.Len() > 0
+
 	for {
 		select {
 		case event := <-stream.Stream:
@@ -237,6 +288,12 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 			case llm.EventTypeText:
 				// Handle text event
 				if textChunk, ok := event.Value.(string); ok {
+					if stepStarted && textChunk != "" {
+						stepStarted = false
+						if messageBuilder.Len() > 0 {
+							messageBuilder.WriteString(agentStepSeparator)
+						}
+					}
 					messageBuilder.WriteString(textChunk)
 					post.Message = messageBuilder.String()
 					p.sendPostStreamingUpdateEventWithBroadcast(post, post.Message, broadcast)
@@ -279,8 +336,13 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 					post.Message += "\n\n"
 				}
 				p.mmClient.LogError("Streaming result to post failed partway", "error", err)
+				span.RecordError(err)
 				T := i18n.LocalizerFunc(p.i18n, userLocale)
 				post.Message = T("agents.stream_to_post_access_llm_error", "Sorry! An error occurred while accessing the LLM. See server logs for details.")
+				var quotaErr *llm.QuotaExceededError
+				if errors.As(err, &quotaErr) {
+					post.Message = QuotaExceededMessage(T, quotaErr)
+				}
 
 				// Persist any accumulated reasoning before erroring out
 				if reasoningBuffer.Len() > 0 {
@@ -322,18 +384,17 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 			case llm.EventTypeToolCalls:
 				// Handle tool call event
 				if toolCalls, ok := event.Value.([]llm.ToolCall); ok {
-					// Ensure all tool calls have Pending status and sanitize arguments
+					// Ensure all tool calls not already rejected by a tool policy have Pending status and sanitize arguments
 					for i := range toolCalls {
-						toolCalls[i].Status = llm.ToolCallStatusPending
+						if toolCalls[i].Status != llm.ToolCallStatusRejected {
+							toolCalls[i].Status = llm.ToolCallStatusPending
+						}
 						toolCalls[i].SanitizeArguments()
 					}
 
-					// Add the tool call as a prop to the post
-					toolCallJSON, err := json.Marshal(toolCalls)
-					if err != nil {
-						p.mmClient.LogError("Failed to marshal tool call", "error", err)
-					} else {
-						post.AddProp(ToolCallProp, string(toolCallJSON))
+					// Add the tool call as a prop to the post, after those resolved by earlier steps
+					if err := SetToolCalls(post, toolCalls); err != nil {
+						p.mmClient.LogError("Failed to add tool call", "error", err)
 					}
 
 					// Update the post with the tool call and any reasoning that was previously added
@@ -345,10 +406,35 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 					p.mmClient.PublishWebSocketEvent("postupdate", map[string]interface{}{
 						"post_id":   post.Id,
 						"control":   "tool_call",
-						"tool_call": string(toolCallJSON),
+						"tool_call": post.GetProp(ToolCallProp),
 					}, broadcast)
 				}
 				return
+			case llm.EventTypeAgentStep:
+				// The text so far belongs to the completed step, the response continues after it
+				if step, ok := event.Value.(llm.AgentStep); ok {
+					if err := AddAgentStep(post, step); err != nil {
+						p.mmClient.LogError("Failed to add agent step", "error", err)
+						continue
+					}
+					stepStarted = true
+					reasoningBuffer.Reset()
+
+					if err := p.mmClient.UpdatePost(post); err != nil {
+						p.mmClient.LogError("Failed to update post with agent step", "error", err)
+					}
+
+					p.mmClient.PublishWebSocketEvent("postupdate", map[string]interface{}{
+						"post_id":     post.Id,
+						"control":     "agent_step",
+						"agent_steps": post.GetProp(AgentStepsProp),
+					}, broadcast)
+				}
+			case llm.EventTypeDelegation:
+				// Saved with the next update of the post
+				if botIDs, ok := event.Value.([]string); ok {
+					AddDelegatedTo(post, botIDs)
+				}
 			case llm.EventTypeAnnotations:
 				if annotations, ok := event.Value.([]llm.Annotation); ok {
 					annotationsJSON, err := json.Marshal(annotations)
@@ -360,8 +446,25 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 						p.sendPostStreamingAnnotationsEventWithBroadcast(post, string(annotationsJSON), broadcast)
 					}
 				}
+			case llm.EventTypeServiceInfo:
+				if serviceInfo, ok := event.Value.(llm.ServiceInfo); ok {
+					serviceJSON, err := json.Marshal(serviceInfo)
+					if err != nil {
+						p.mmClient.LogError("Failed to marshal service info", "error", err)
+					} else {
+						post.AddProp(ServiceProp, string(serviceJSON))
+					}
+				}
 			}
 		case <-ctx.Done():
+			span.AddEvent("canceled")
+
+			// Keep reading so the goroutines producing the stream can exit once the request is aborted
+			go func() {
+				for range stream.Stream {
+				}
+			}()
+
 			// Persist any accumulated reasoning before canceling
 			if reasoningBuffer.Len() > 0 {
 				post.AddProp(ReasoningSummaryProp, reasoningBuffer.String())
@@ -377,3 +480,16 @@ func (p *MMPostStreamService) StreamToPost(ctx context.Context, stream *llm.Text
 		}
 	}
 }
+
+// QuotaExceededMessage explains to the user which token quota stopped their request and when it resets.
+func QuotaExceededMessage(T i18n.TranslationFunc, err *llm.QuotaExceededError) string {
+	resetAt := err.ResetAt.UTC().Format("2006-01-02 15:04 MST")
+	switch err.Scope {
+	case "bot":
+		return T("agents.quota_exceeded_bot", "Sorry! This agent has used up its token quota. It resets at %s.", resetAt)
+	case "team":
+		return T("agents.quota_exceeded_team", "Sorry! Your team has used up its token quota. It resets at %s.", resetAt)
+	default:
+		return T("agents.quota_exceeded_user", "Sorry! You have used up your token quota. It resets at %s.", resetAt)
+	}
+}
@@ -102,13 +102,13 @@ func TestThreadsAnalyze(t *testing.T) {
 			}
 
 			if tc.expectedLLMCalls > 0 {
-				mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything).Return(&llm.TextStreamResult{}, tc.llmError)
+				mockLLM.EXPECT().ChatCompletion(mock.Anything, mock.Anything, mock.Anything).Return(&llm.TextStreamResult{}, tc.llmError)
 			}
 
 			threadService := threads.New(mockLLM, prompts, mockClient)
 
 			// Execute
-			result, err := threadService.Analyze(tc.postID, ctx, tc.promptName)
+			result, err := threadService.Analyze(t.Context(), tc.postID, ctx, tc.promptName)
 
 			// Assert
 			if tc.expectedError {
@@ -142,7 +142,7 @@ func runThreadAnalysisEval(t *evals.EvalT, threadData *evals.ThreadExport, promp
 
 	// Do the thread analysis
 	threadService := threads.New(t.LLM, t.Prompts, mockClient)
-	result, err := threadService.Analyze(threadData.RootPost.Id, llmContext, promptName)
+	result, err := threadService.Analyze(t.Context(), threadData.RootPost.Id, llmContext, promptName)
 	require.NoError(t, err)
 	require.NotNil(t, result)
 	output, err := result.ReadAll()
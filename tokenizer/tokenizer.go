@@ -0,0 +1,286 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package tokenizer provides byte pair encoding (BPE) tokenizers compatible with
+// OpenAI's cl100k_base and o200k_base encodings.
+//
+// The vocabularies are embedded in the plugin so token counts are available without
+// network access. Models from providers that do not publish their tokenizer are counted
+// with cl100k_base, which is a much closer estimate than character based heuristics.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+
+	"github.com/mattermost/mattermost-plugin-ai/llm"
+)
+
+//go:embed vocab/*.tiktoken.gz
+var vocabFS embed.FS
+
+type Encoding string
+
+const (
+	EncodingCL100kBase Encoding = "cl100k_base"
+	EncodingO200kBase  Encoding = "o200k_base"
+)
+
+// maxPieceLength bounds the size of a pre-tokenized piece passed to the quadratic
+// merge step. Longer pieces (e.g. long runs of punctuation) are merged in slices.
+const maxPieceLength = 4096
+
+var patterns = map[Encoding]string{
+	EncodingCL100kBase: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	EncodingO200kBase: strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^\s\p{L}\p{N}]+[\r\n/]*`,
+		`\s*[\r\n]+`,
+		`\s+(?!\S)`,
+		`\s+`,
+	}, "|"),
+}
+
+// BPE is a byte pair encoding tokenizer. The vocabulary is loaded lazily on first use.
+type BPE struct {
+	encoding Encoding
+
+	once    sync.Once
+	loadErr error
+	ranks   map[string]int
+	decoder map[int]string
+	pattern *regexp2.Regexp
+}
+
+var (
+	encodersMutex sync.Mutex
+	encoders      = map[Encoding]*BPE{}
+)
+
+// Get returns the shared tokenizer for an encoding.
+func Get(encoding Encoding) *BPE {
+	encodersMutex.Lock()
+	defer encodersMutex.Unlock()
+
+	if bpe, ok := encoders[encoding]; ok {
+		return bpe
+	}
+	bpe := &BPE{encoding: encoding}
+	encoders[encoding] = bpe
+	return bpe
+}
+
+// EncodingForModel returns the encoding used by a model.
+func EncodingForModel(model string) Encoding {
+	model = strings.ToLower(model)
+	for _, prefix := range []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return EncodingO200kBase
+		}
+	}
+	return EncodingCL100kBase
+}
+
+// ForModel returns the tokenizer to use for a model.
+func ForModel(model string) llm.Tokenizer {
+	return Get(EncodingForModel(model))
+}
+
+func (b *BPE) load() error {
+	b.once.Do(func() {
+		pattern, ok := patterns[b.encoding]
+		if !ok {
+			b.loadErr = fmt.Errorf("unknown encoding %q", b.encoding)
+			return
+		}
+		b.pattern, b.loadErr = regexp2.Compile(pattern, regexp2.None)
+		if b.loadErr != nil {
+			return
+		}
+		b.ranks, b.decoder, b.loadErr = loadVocab(b.encoding)
+	})
+	return b.loadErr
+}
+
+func loadVocab(encoding Encoding) (map[string]int, map[int]string, error) {
+	data, err := vocabFS.ReadFile("vocab/" + string(encoding) + ".tiktoken.gz")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress vocabulary: %w", err)
+	}
+	defer reader.Close()
+
+	ranks := make(map[string]int, 200000)
+	decoder := make(map[int]string, 200000)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		token, rankStr, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid vocabulary token %q: %w", token, err)
+		}
+		rank, err := strconv.Atoi(rankStr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid vocabulary rank %q: %w", rankStr, err)
+		}
+		ranks[string(decoded)] = rank
+		decoder[rank] = string(decoded)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	return ranks, decoder, nil
+}
+
+// pieces splits text into the chunks that BPE merging is applied to.
+func (b *BPE) pieces(text string, yield func(piece string)) {
+	match, err := b.pattern.FindStringMatch(text)
+	for err == nil && match != nil {
+		yield(match.String())
+		match, err = b.pattern.FindNextMatch(match)
+	}
+}
+
+// Encode converts text into token ranks.
+func (b *BPE) Encode(text string) ([]int, error) {
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+
+	var tokens []int
+	b.pieces(text, func(piece string) {
+		tokens = b.encodePiece(piece, tokens)
+	})
+	return tokens, nil
+}
+
+// Decode converts token ranks back into text.
+func (b *BPE) Decode(tokens []int) (string, error) {
+	if err := b.load(); err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	for _, token := range tokens {
+		value, ok := b.decoder[token]
+		if !ok {
+			return "", fmt.Errorf("unknown token %d", token)
+		}
+		result.WriteString(value)
+	}
+	return result.String(), nil
+}
+
+// CountTokens returns the number of tokens in text. If the vocabulary cannot be
+// loaded the count falls back to an estimate.
+func (b *BPE) CountTokens(text string) int {
+	if err := b.load(); err != nil {
+		return llm.ApproximateTokenizer{}.CountTokens(text)
+	}
+
+	count := 0
+	b.pieces(text, func(piece string) {
+		if _, ok := b.ranks[piece]; ok {
+			count++
+			return
+		}
+		count += len(b.encodePiece(piece, nil))
+	})
+	return count
+}
+
+func (b *BPE) encodePiece(piece string, tokens []int) []int {
+	if rank, ok := b.ranks[piece]; ok {
+		return append(tokens, rank)
+	}
+
+	for len(piece) > maxPieceLength {
+		cut := maxPieceLength
+		for cut > 0 && !utf8.RuneStart(piece[cut]) {
+			cut--
+		}
+		tokens = b.mergePiece(piece[:cut], tokens)
+		piece = piece[cut:]
+	}
+	return b.mergePiece(piece, tokens)
+}
+
+// mergePiece applies byte pair merges to piece, always merging the lowest ranked pair first.
+func (b *BPE) mergePiece(piece string, tokens []int) []int {
+	if len(piece) == 0 {
+		return tokens
+	}
+	if len(piece) == 1 {
+		return append(tokens, b.ranks[piece])
+	}
+
+	type part struct {
+		start int
+		rank  int
+	}
+
+	rankOf := func(s string) int {
+		if rank, ok := b.ranks[s]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+
+	parts := make([]part, 0, len(piece)+1)
+	for i := 0; i < len(piece)-1; i++ {
+		parts = append(parts, part{start: i, rank: rankOf(piece[i : i+2])})
+	}
+	parts = append(parts, part{start: len(piece) - 1, rank: math.MaxInt}, part{start: len(piece), rank: math.MaxInt})
+
+	// rankAfterMerge returns the rank of the pair starting at i once parts[i+1] has been removed
+	rankAfterMerge := func(i int) int {
+		if i+3 < len(parts) {
+			return rankOf(piece[parts[i].start:parts[i+3].start])
+		}
+		return math.MaxInt
+	}
+
+	for {
+		minIdx, minRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < minRank {
+				minIdx, minRank = i, parts[i].rank
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+
+		if minIdx > 0 {
+			parts[minIdx-1].rank = rankAfterMerge(minIdx - 1)
+		}
+		parts[minIdx].rank = rankAfterMerge(minIdx)
+		parts = append(parts[:minIdx+1], parts[minIdx+2:]...)
+	}
+
+	for i := 0; i < len(parts)-1; i++ {
+		tokens = append(tokens, b.ranks[piece[parts[i].start:parts[i+1].start]])
+	}
+	return tokens
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	for _, test := range []struct {
+		encoding Encoding
+		text     string
+		tokens   []int
+	}{
+		{EncodingCL100kBase, "hello world", []int{15339, 1917}},
+		{EncodingCL100kBase, "", nil},
+		{EncodingO200kBase, "hello world", []int{24912, 2375}},
+	} {
+		t.Run(string(test.encoding)+"/"+test.text, func(t *testing.T) {
+			tokens, err := Get(test.encoding).Encode(test.text)
+			require.NoError(t, err)
+			assert.Equal(t, test.tokens, tokens)
+			assert.Equal(t, len(test.tokens), Get(test.encoding).CountTokens(test.text))
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"The quick brown fox jumps over the lazy dog.",
+		"こんにちは世界、これはテストです。",
+		"Emoji 🎉🚀 and accents: café, naïve, Straße",
+		"func main() {\n\tfmt.Println(\"hi\")\n}\n",
+		strings.Repeat("=", 10000),
+	}
+
+	for _, encoding := range []Encoding{EncodingCL100kBase, EncodingO200kBase} {
+		bpe := Get(encoding)
+		for _, input := range inputs {
+			tokens, err := bpe.Encode(input)
+			require.NoError(t, err)
+			decoded, err := bpe.Decode(tokens)
+			require.NoError(t, err)
+			assert.Equal(t, input, decoded)
+			assert.Equal(t, len(tokens), bpe.CountTokens(input))
+		}
+	}
+}
+
+func TestEncodingForModel(t *testing.T) {
+	assert.Equal(t, EncodingO200kBase, EncodingForModel("gpt-4o-mini"))
+	assert.Equal(t, EncodingO200kBase, EncodingForModel("o3-mini"))
+	assert.Equal(t, EncodingO200kBase, EncodingForModel("GPT-5"))
+	assert.Equal(t, EncodingCL100kBase, EncodingForModel("gpt-4-turbo"))
+	assert.Equal(t, EncodingCL100kBase, EncodingForModel("claude-sonnet-4"))
+	assert.Equal(t, EncodingCL100kBase, EncodingForModel(""))
+}
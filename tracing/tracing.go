@@ -0,0 +1,210 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package tracing exports OpenTelemetry traces of LLM requests to an OTLP collector.
+// Tracing is off by default, in which case spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	instrumentationName = "github.com/mattermost/mattermost-plugin-ai"
+	serviceName         = "mattermost-plugin-agents"
+
+	// defaultURLPath is the OTLP/HTTP traces path, used when the endpoint doesn't have one
+	defaultURLPath  = "/v1/traces"
+	shutdownTimeout = 5 * time.Second
+)
+
+// Config is the tracing configuration set by admins.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Endpoint is the OTLP/HTTP collector URL, such as "http://localhost:4318"
+	Endpoint string `json:"endpoint"`
+	// Headers are sent with every export, for example to authenticate with the collector
+	Headers map[string]string `json:"headers"`
+	// SampleRatio is the fraction of traces that are exported, all of them when 0
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+type ConfigProvider interface {
+	GetTracingConfig() Config
+}
+
+type Logger interface {
+	Info(message string, keyValuePairs ...any)
+	Error(message string, keyValuePairs ...any)
+}
+
+func init() {
+	// W3C trace context lets LLM and MCP servers that export their own traces join ours
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Service sets the global OpenTelemetry tracer provider from the plugin configuration.
+type Service struct {
+	config  ConfigProvider
+	log     Logger
+	version string
+
+	mu         sync.Mutex
+	configured *Config
+	provider   *sdktrace.TracerProvider
+}
+
+// New creates a Service. version is reported as the service version of exported spans.
+func New(config ConfigProvider, log Logger, version string) *Service {
+	return &Service{
+		config:  config,
+		log:     log,
+		version: version,
+	}
+}
+
+// Configure applies the current configuration, replacing the exporter if the configuration changed.
+func (s *Service) Configure() {
+	cfg := s.config.GetTracingConfig()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configured != nil && reflect.DeepEqual(*s.configured, cfg) {
+		return
+	}
+	s.configured = &cfg
+
+	s.shutdown()
+	if !cfg.Enabled {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return
+	}
+
+	provider, err := s.newProvider(cfg)
+	if err != nil {
+		s.log.Error("Failed to configure tracing", "error", err.Error())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return
+	}
+	s.provider = provider
+	otel.SetTracerProvider(provider)
+	s.log.Info("Exporting traces", "endpoint", cfg.Endpoint)
+}
+
+func (s *Service) newProvider(cfg Config) (*sdktrace.TracerProvider, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("no endpoint configured")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", cfg.Endpoint)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = defaultURLPath
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint.String())}
+	if len(cfg.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", s.version),
+		)),
+	), nil
+}
+
+// shutdown flushes and stops the current exporter. Callers must hold mu.
+func (s *Service) shutdown() {
+	if s.provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.provider.Shutdown(ctx); err != nil {
+		s.log.Error("Failed to flush traces", "error", err.Error())
+	}
+	s.provider = nil
+}
+
+// Shutdown flushes pending spans and stops exporting.
+func (s *Service) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown()
+	s.configured = nil
+	otel.SetTracerProvider(noop.NewTracerProvider())
+}
+
+// Start starts a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	// The tracer is looked up for every span so configuration changes apply immediately
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// transport adds the trace context of each request to its headers.
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport returns a RoundTripper that propagates the trace context of requests to the server
+// before sending them with base, or http.DefaultTransport if base is nil.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	carrier := propagation.HeaderCarrier{}
+	otel.GetTextMapPropagator().Inject(req.Context(), carrier)
+	if len(carrier) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	for key, values := range carrier {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type fakeConfig struct {
+	cfg Config
+}
+
+func (f *fakeConfig) GetTracingConfig() Config {
+	return f.cfg
+}
+
+type fakeLogger struct {
+	errors []string
+}
+
+func (f *fakeLogger) Info(string, ...any) {}
+
+func (f *fakeLogger) Error(message string, _ ...any) {
+	f.errors = append(f.errors, message)
+}
+
+func TestTransport(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	t.Run("propagates the trace of the request", func(t *testing.T) {
+		ctx, span := Start(t.Context(), "test")
+		defer span.End()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+		assert.Empty(t, req.Header.Get("traceparent"), "the caller's request is not modified")
+	})
+
+	t.Run("requests without a trace are sent unchanged", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Empty(t, traceparent)
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	config := &fakeConfig{cfg: Config{Enabled: true, Endpoint: "http://localhost:4318"}}
+	log := &fakeLogger{}
+	service := New(config, log, "1.0.0")
+
+	service.Configure()
+	require.NotNil(t, service.provider)
+	assert.Same(t, service.provider, otel.GetTracerProvider())
+
+	// Unchanged configuration keeps the exporter
+	provider := service.provider
+	service.Configure()
+	assert.Same(t, provider, service.provider)
+
+	config.cfg = Config{Enabled: true, Endpoint: "not a url"}
+	service.Configure()
+	assert.Nil(t, service.provider)
+	assert.Equal(t, []string{"Failed to configure tracing"}, log.errors)
+	_, span := Start(t.Context(), "span")
+	assert.False(t, span.IsRecording())
+
+	config.cfg = Config{}
+	service.Configure()
+	assert.Nil(t, service.provider)
+	service.Shutdown()
+}
@@ -9,9 +9,11 @@ import styled from 'styled-components';
 import {WebSocketMessage} from '@mattermost/client';
 import {GlobalState} from '@mattermost/types/store';
 
+import {LLMBot} from '@/bots';
 import {doPostbackSummary, doRegenerate, doStopGenerating} from '@/client';
 import {useSelectNotAIPost} from '@/hooks';
 import {PostMessagePreview} from '@/mm_webapp';
+import manifest from '@/manifest';
 
 import {SearchSources} from '../search_sources';
 import PostText from '../post_text';
@@ -56,6 +58,7 @@ interface LLMBotPostProps {
 }
 
 const SearchResultsPropKey = 'search_results';
+const DelegatedToPropKey = 'delegated_to';
 
 export const LLMBotPost = (props: LLMBotPostProps) => {
     const selectPost = useSelectNotAIPost();
@@ -117,6 +120,7 @@ export const LLMBotPost = (props: LLMBotPostProps) => {
 
     const currentUserId = useSelector<GlobalState, string>((state) => state.entities.users.currentUserId);
     const rootPost = useSelector<GlobalState, any>((state) => state.entities.posts.posts[props.post.root_id]);
+    const bots = useSelector<GlobalState, LLMBot[] | null>((state: any) => state['plugins-' + manifest.id].bots);
 
     // Initialize reasoning from persisted data when navigating to different posts
     const previousPostIdRef = useRef(props.post.id);
@@ -341,6 +345,10 @@ export const LLMBotPost = (props: LLMBotPostProps) => {
     const isNoShowRegen = (props.post.props?.no_regen && props.post.props?.no_regen !== '');
     const isTranscriptionResult = rootPost?.props?.referenced_transcript_post_id && rootPost?.props?.referenced_transcript_post_id !== '';
 
+    // The agents the bot asked while answering, by username
+    const delegatedTo: string[] = props.post.props?.[DelegatedToPropKey] || [];
+    const delegatedUsernames = delegatedTo.map((id) => bots?.find((bot) => bot.id === id)?.username).filter(Boolean).map((username) => '@' + username);
+
     let permalinkView = null;
     if (PostMessagePreview) { // Ignore permalink if version does not export PostMessagePreview
         const permalinkData = extractPermalinkData(props.post);
@@ -401,6 +409,14 @@ export const LLMBotPost = (props: LLMBotPostProps) => {
                     sources={JSON.parse(props.post.props[SearchResultsPropKey])}
                 />
             )}
+            {delegatedUsernames.length > 0 && (
+                <DelegatedToMessage data-testid='llm-bot-delegated-to'>
+                    <FormattedMessage
+                        defaultMessage='Answered with help from {agents}'
+                        values={{agents: delegatedUsernames.join(', ')}}
+                    />
+                </DelegatedToMessage>
+            )}
             {toolCalls && toolCalls.length > 0 && (
                 <ToolApprovalSet
                     postID={props.post.id}
@@ -430,6 +446,13 @@ export const LLMBotPost = (props: LLMBotPostProps) => {
 const PostBody = styled.div`
 `;
 
+const DelegatedToMessage = styled.div`
+	font-size: 12px;
+	line-height: 16px;
+	color: rgba(var(--center-channel-color-rgb), 0.64);
+	margin-top: 8px;
+`;
+
 const PostSummaryHelpMessage = styled.div`
 	font-size: 14px;
 	font-style: italic;